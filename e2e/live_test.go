@@ -23,7 +23,6 @@ import (
 	"testing"
 
 	livetest "github.com/GoogleContainerTools/kpt/pkg/test/live"
-	"github.com/stretchr/testify/require"
 )
 
 func TestLiveApply(t *testing.T) {
@@ -40,38 +39,19 @@ func runTests(t *testing.T, path string) {
 	livetest.RemoveKindCluster(t)
 	livetest.CreateKindCluster(t)
 
-	for p := range testCases {
-		p := p
-		c := testCases[p]
-
+	var runners []*livetest.Runner
+	for p, c := range testCases {
 		if !c.Parallel {
 			continue
 		}
-
-		t.Run(p, func(t *testing.T) {
-			if c.Parallel {
-				t.Parallel()
-			}
-
-			if c.NoResourceGroup {
-				require.False(t, c.Parallel, "Parallel tests can not modify the RG CRD")
-				if livetest.CheckIfResourceGroupInstalled(t) {
-					livetest.RemoveResourceGroup(t)
-				}
-			} else {
-				livetest.InstallResourceGroup(t)
-			}
-
-			ns := filepath.Base(p)
-			livetest.CreateNamespace(t, ns)
-			defer livetest.RemoveNamespace(t, ns)
-
-			(&livetest.Runner{
-				Config: c,
-				Path:   p,
-			}).Run(t)
+		runners = append(runners, &livetest.Runner{
+			Config: c,
+			Path:   p,
 		})
 	}
+	(&livetest.SuiteRunner{
+		Runners: runners,
+	}).Run(t)
 }
 
 func scanTestCases(t *testing.T, path string) map[string]livetest.TestCaseConfig {
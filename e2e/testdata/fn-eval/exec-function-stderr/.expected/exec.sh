@@ -15,4 +15,4 @@
 
 set -eo pipefail
 
-kpt fn eval --exec ./function.sh
+kpt fn eval --allow-exec --exec ./function.sh --verbose
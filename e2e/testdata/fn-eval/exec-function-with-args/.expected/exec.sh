@@ -15,4 +15,4 @@
 
 set -eo pipefail
 
-kpt fn eval --exec "sed -e 's/foo/bar/'"
+kpt fn eval --allow-exec --exec "sed -e 's/foo/bar/'"
@@ -15,6 +15,6 @@
 
 set -eo pipefail
 
-rm -rf out; mkdir out
+rm -rf out; mkdir out; touch out/resources.yaml
 
 kpt fn eval --image gcr.io/kpt-fn/set-namespace:v0.1.3 -o out -- namespace=staging
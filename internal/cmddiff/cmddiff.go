@@ -17,7 +17,10 @@ package cmddiff
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
 	"github.com/GoogleContainerTools/kpt/internal/pkg"
@@ -27,6 +30,7 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/util/diff"
 	"github.com/GoogleContainerTools/kpt/internal/util/pathutil"
 	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/errors"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
@@ -55,6 +59,52 @@ func NewRunner(ctx context.Context, parent string) *Runner {
 		"diff tool to use to show the changes")
 	c.Flags().StringVar(&r.DiffToolOpts, "diff-tool-opts", diffToolOpts,
 		"diff tool commandline options to use to show the changes")
+	c.Flags().BoolVar(&r.SkipToolCheck, "skip-tool-check", false,
+		"don't verify that --diff-tool can compare 3 packages for the 3way diff type")
+	c.Flags().BoolVar(&r.listTools, "list-tools", false,
+		"list the known diff tools found in the PATH and the diff types they support, without comparing packages")
+	c.Flags().StringVar(&r.color, "color", diff.ColorAuto.String(),
+		"when to color the built-in 3way diff output e.g. "+diff.SupportedColorModesLabel())
+	c.Flags().StringVar(&r.format, "output", diff.FormatText.String(),
+		"output format of the changes e.g. "+diff.SupportedFormatsLabel())
+	c.Flags().StringVar(&r.OutputFile, "output-file", "",
+		"path of a file to write the changes to instead of stdout")
+	c.Flags().StringVar(&r.FromRef, "from", "",
+		"upstream ref to compare against --to, instead of the local package")
+	c.Flags().StringVar(&r.ToRef, "to", "",
+		"upstream ref to compare against, same as specifying PKG_PATH@VERSION")
+	c.Flags().StringVar(&r.ref, "ref", "",
+		"upstream ref to compare against, same as --to")
+	c.Flags().StringVar(&r.Repo, "repo", "",
+		"git repository to fetch the upstream package from, overriding the upstream in the Kptfile")
+	c.Flags().StringArrayVar(&r.ExcludePatterns, "diff-exclude", []string{},
+		"gitignore style pattern of files to exclude from the comparison")
+	c.Flags().StringVar(&r.Subpath, "path-filter", "",
+		"path of a file or directory in the package to restrict the comparison to")
+	c.Flags().StringArrayVar(&r.IgnoreFields, "ignore-field", []string{},
+		"dotted path of a resource field to ignore when comparing, e.g. metadata.creationTimestamp")
+	c.Flags().BoolVar(&r.IncludeKptfile, "include-kptfile", false,
+		"compare the Kptfile of the packages, which is excluded by default")
+	c.Flags().BoolVar(&r.includeSubpackages, "include-subpackages", true,
+		"compare the subpackages of the packages, set to false to compare only the root package")
+	c.Flags().BoolVar(&r.Stat, "stat", false,
+		"print the number of changed, added and removed files after the changes")
+	c.Flags().StringVar(&r.StageDir, "stage-dir", "",
+		"directory to keep the fetched upstream packages in and reuse them from on later runs")
+	c.Flags().BoolVar(&r.CleanStageDir, "clean-stage-dir", false,
+		"remove the upstream packages used by the diff from --stage-dir afterwards")
+	c.Flags().BoolVar(&r.NoCache, "no-cache", false,
+		"fetch the upstream packages instead of reusing previously fetched packages")
+	c.Flags().BoolVar(&r.NameOnly, "name-only", false,
+		"print the paths of the added, removed and modified files instead of the changes")
+	c.Flags().BoolVar(&r.NameStatus, "name-status", false,
+		"print the paths of the changed files prefixed with A, D or M for added, removed and modified files")
+	c.Flags().BoolVar(&r.Quick, "quick", false,
+		"compare content hashes of the packages first and skip the diff tool if they match")
+	c.Flags().BoolVar(&r.Quiet, "quiet", false,
+		"don't print a message when the diff tool finds no differences")
+	c.Flags().BoolVar(&r.ExitCode, "exit-code", false,
+		"exit with code 1 if there are differences and with code 2 if the diff tool fails")
 	c.Flags().BoolVar(&r.Debug, "debug", false,
 		"when true, prints additional debug information and do not delete staged pkg dirs")
 	r.C = c
@@ -74,9 +124,21 @@ type Runner struct {
 	diff.Command
 	C        *cobra.Command
 	diffType string
+	ref      string
+	format   string
+	color    string
+
+	includeSubpackages bool
+	listTools          bool
 }
 
 func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
+	if r.listTools {
+		if len(args) > 0 {
+			return errors.Errorf("--list-tools doesn't compare packages, it can't be used with a package")
+		}
+		return nil
+	}
 	if len(args) == 0 {
 		args = append(args, pkg.CurDir)
 	}
@@ -85,6 +147,18 @@ func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if r.ref != "" {
+		if r.ToRef != "" || r.FromRef != "" {
+			return errors.Errorf("--ref can't be combined with --from or --to, use --to instead")
+		}
+		r.ToRef = r.ref
+	}
+	if version != "" && r.ToRef != "" {
+		return errors.Errorf("target version can be specified either using PKG_PATH@VERSION or --to, not both")
+	}
+	if version == "" && r.FromRef == "" {
+		version = r.ToRef
+	}
 	if r.diffType == "" {
 		// pick sensible defaults for diff-type
 		r.DiffType = diff.TypeLocal
@@ -97,6 +171,22 @@ func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
 		r.DiffType = diff.Type(r.diffType)
 	}
 
+	r.Format = diff.Format(r.format)
+	r.ExcludeSubpackages = !r.includeSubpackages
+	r.Color = diff.ColorMode(r.color)
+	if r.C.Flags().Changed("color") {
+		// the local flag shadows the global --color flag, make it apply
+		// to the error messages as well.
+		cmdutil.Color = r.color
+	}
+
+	if r.DiffType == diff.Type3Way && !r.C.Flags().Changed("diff-tool") &&
+		os.Getenv("KPT_EXTERNAL_DIFF") == "" {
+		// the diff command can't compare 3 packages, use the built-in
+		// renderer unless a diff tool has been chosen explicitly.
+		r.DiffTool = ""
+	}
+
 	resolvedPath, err := argutil.ResolveSymlink(r.ctx, dir)
 	if err != nil {
 		return err
@@ -114,10 +204,35 @@ func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
 	r.Path = string(p.UniquePath)
 	r.Ref = version
 	r.Output = printer.FromContextOrDie(r.ctx).OutStream()
+	r.Progress = printer.FromContextOrDie(r.ctx).ErrStream()
 
 	return r.Validate()
 }
 
 func (r *Runner) runE(c *cobra.Command, args []string) error {
+	if r.listTools {
+		return r.printTools()
+	}
 	return r.Run(r.ctx)
 }
+
+// printTools prints a table of the known diff tools found in the PATH and the
+// diff types they support.
+func (r *Runner) printTools() error {
+	out := printer.FromContextOrDie(r.ctx).OutStream()
+	tools := diff.ListTools()
+	if len(tools) == 0 {
+		_, err := fmt.Fprintln(out, "No known diff tools found in the PATH.")
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TOOL\tDIFF TYPES\tPATH")
+	for _, tool := range tools {
+		var types []string
+		for _, dt := range tool.DiffTypes {
+			types = append(types, dt.String())
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", tool.Name, strings.Join(types, ","), tool.Path)
+	}
+	return w.Flush()
+}
@@ -15,20 +15,34 @@
 package cmddiff_test
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/GoogleContainerTools/kpt/internal/cmddiff"
 	"github.com/GoogleContainerTools/kpt/internal/cmdget"
 	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
 	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/diff"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestMain(m *testing.M) {
-	os.Exit(testutil.ConfigureTestKptCache(m))
+	cacheDir, err := ioutil.TempDir("", "kpt-test-cache-diff-")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Setenv(diff.PkgCacheDirEnv, cacheDir); err != nil {
+		panic(err)
+	}
+	code := testutil.ConfigureTestKptCache(m)
+	_ = os.RemoveAll(cacheDir)
+	os.Exit(code)
 }
 
 func TestCmdInvalidDiffType(t *testing.T) {
@@ -37,7 +51,7 @@ func TestCmdInvalidDiffType(t *testing.T) {
 	err := runner.C.Execute()
 	assert.EqualError(t,
 		err,
-		"invalid diff-type 'invalid': supported diff-types are: local, remote, combined, 3way")
+		"invalid diff-type 'invalid': supported diff-types are: local, remote, combined, 3way, merged")
 }
 
 func TestCmdInvalidDiffTool(t *testing.T) {
@@ -49,6 +63,74 @@ func TestCmdInvalidDiffTool(t *testing.T) {
 		"diff-tool 'nodiff' not found in the PATH")
 }
 
+func TestCmdListTools(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	for name, script := range map[string]string{
+		"diff": `[ "$#" -eq 2 ] || exit 2`,
+		"meld": "exit 2",
+	} {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0700)) {
+			t.FailNow()
+		}
+	}
+	t.Setenv("PATH", dir)
+
+	out := &bytes.Buffer{}
+	runner := cmddiff.NewRunner(fake.CtxWithPrinter(out, &bytes.Buffer{}), "")
+	runner.C.SetArgs([]string{"--list-tools"})
+	if !assert.NoError(t, runner.C.Execute()) {
+		t.FailNow()
+	}
+	assert.Equal(t, fmt.Sprintf(`TOOL  DIFF TYPES                         PATH
+diff  local,remote,combined,merged       %s
+meld  local,remote,combined,3way,merged  %s
+`, filepath.Join(dir, "diff"), filepath.Join(dir, "meld")), out.String())
+
+	t.Setenv("PATH", t.TempDir())
+	out.Reset()
+	runner = cmddiff.NewRunner(fake.CtxWithPrinter(out, &bytes.Buffer{}), "")
+	runner.C.SetArgs([]string{"--list-tools"})
+	if !assert.NoError(t, runner.C.Execute()) {
+		t.FailNow()
+	}
+	assert.Equal(t, "No known diff tools found in the PATH.\n", out.String())
+
+	runner = cmddiff.NewRunner(fake.CtxWithDefaultPrinter(), "")
+	runner.C.SetArgs([]string{"--list-tools", "pkg"})
+	assert.EqualError(t, runner.C.Execute(),
+		"--list-tools doesn't compare packages, it can't be used with a package")
+}
+
+func TestCmdInvalidOutputFormat(t *testing.T) {
+	runner := cmddiff.NewRunner(fake.CtxWithDefaultPrinter(), "")
+	runner.C.SetArgs([]string{"--output", "yaml"})
+	err := runner.C.Execute()
+	assert.EqualError(t,
+		err,
+		"invalid output format 'yaml': supported formats are: text, json")
+}
+
+func TestCmdInvalidColor(t *testing.T) {
+	runner := cmddiff.NewRunner(fake.CtxWithDefaultPrinter(), "")
+	runner.C.SetArgs([]string{"--color", "sometimes"})
+	err := runner.C.Execute()
+	assert.EqualError(t,
+		err,
+		"invalid color 'sometimes': supported values are: auto, always, never")
+}
+
+func TestCmdInvalidPathFilter(t *testing.T) {
+	runner := cmddiff.NewRunner(fake.CtxWithDefaultPrinter(), "")
+	runner.C.SetArgs([]string{"--path-filter", "../other/deployment.yaml"})
+	err := runner.C.Execute()
+	assert.EqualError(t,
+		err,
+		"path-filter '../other/deployment.yaml' must be a relative path within the package")
+}
+
 func TestCmdExecute(t *testing.T) {
 	g, w, clean := testutil.SetupRepoAndWorkspace(t, testutil.Content{
 		Data:   testutil.Dataset1,
@@ -0,0 +1,169 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdfnplan contains the plan command
+package cmdfnplan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/util/argutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/pathutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/render"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// Formats the plan can be printed in.
+const (
+	tableOutput = "table"
+	jsonOutput  = "json"
+)
+
+// NewRunner returns a command runner
+func NewRunner(ctx context.Context, parent string) *Runner {
+	r := &Runner{ctx: ctx}
+	c := &cobra.Command{
+		Use:     "plan [PKG_PATH] [flags]",
+		Args:    cobra.MaximumNArgs(1),
+		Short:   docs.PlanShort,
+		Long:    docs.PlanShort + "\n" + docs.PlanLong,
+		Example: docs.PlanExamples,
+		RunE:    r.runE,
+		PreRunE: r.preRunE,
+	}
+	c.Flags().StringVarP(&r.output, "output", "o", tableOutput,
+		fmt.Sprintf("format the functions are printed in. It must be one of %s and %s.", tableOutput, jsonOutput))
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return NewRunner(ctx, parent).Command
+}
+
+// Runner contains the run function of the plan command
+type Runner struct {
+	pkgPath string
+	output  string
+	Command *cobra.Command
+	ctx     context.Context
+}
+
+func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
+	if r.output != tableOutput && r.output != jsonOutput {
+		return fmt.Errorf("--output must be one of %s and %s", tableOutput, jsonOutput)
+	}
+	if len(args) == 0 {
+		// no pkg path specified, default to current working dir
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		r.pkgPath = wd
+	} else {
+		r.pkgPath = args[0]
+	}
+	var err error
+	r.pkgPath, err = argutil.ResolveSymlink(r.ctx, r.pkgPath)
+	return err
+}
+
+func (r *Runner) runE(_ *cobra.Command, _ []string) error {
+	absPkgPath, _, err := pathutil.ResolveAbsAndRelPaths(r.pkgPath)
+	if err != nil {
+		return err
+	}
+	plan, err := render.Plan(r.ctx, filesys.FileSystemOrOnDisk{}, absPkgPath)
+	if err != nil {
+		return err
+	}
+	out := printer.FromContextOrDie(r.ctx).OutStream()
+	if r.output == jsonOutput {
+		e := json.NewEncoder(out)
+		e.SetIndent("", "  ")
+		return e.Encode(plan)
+	}
+	if len(plan) == 0 {
+		_, err := fmt.Fprintf(out, "No functions in the pipelines of package %q.\n", r.pkgPath)
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tTYPE\tNAME\tFUNCTION\tCONFIG\tSELECTORS")
+	for _, f := range plan {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", f.Package, f.Type, orNone(f.Name),
+			functionSummary(f.Function), configSummary(f.Function), selectorSummary(f.Selectors, f.Exclusions))
+	}
+	return w.Flush()
+}
+
+// orNone returns s, or - if it's empty.
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// functionSummary returns the image of the function, or its exec command.
+func functionSummary(f kptfilev1.Function) string {
+	if f.Exec != "" {
+		return "exec: " + f.Exec
+	}
+	return f.Image
+}
+
+// configSummary returns where the function config of the function comes from.
+func configSummary(f kptfilev1.Function) string {
+	switch {
+	case f.ConfigPath != "":
+		return f.ConfigPath
+	case len(f.ConfigMap) > 0:
+		return fmt.Sprintf("configMap (%d keys)", len(f.ConfigMap))
+	default:
+		return "-"
+	}
+}
+
+// selectorSummary returns the resources selected by the selectors without
+// those selected by the exclusions. Resources are selected if they match any
+// of the selectors, which are separated by |.
+func selectorSummary(selectors, exclusions []kptfilev1.Selector) string {
+	summary := "all"
+	if len(selectors) > 0 {
+		summary = selectorsString(selectors)
+	}
+	if len(exclusions) > 0 {
+		summary += " except " + selectorsString(exclusions)
+	}
+	return summary
+}
+
+func selectorsString(selectors []kptfilev1.Selector) string {
+	var ss []string
+	for _, s := range selectors {
+		ss = append(ss, s.String())
+	}
+	return strings.Join(ss, " | ")
+}
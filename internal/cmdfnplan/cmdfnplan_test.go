@@ -0,0 +1,190 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdfnplan
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+const rootKptfile = `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: root
+pipeline:
+  mutators:
+  - image: set-labels:v0.1
+    name: labels
+    configMap:
+      app: foo
+      tier: backend
+    selectors:
+    - kind: Deployment
+      labels:
+        app: foo
+    - kind: Service
+    exclude:
+    - name: skipped
+  validators:
+  - exec: ./validate.sh
+    configPath: config.yaml
+`
+
+const subKptfile = `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: sub
+pipeline:
+  mutators:
+  - image: gcr.io/example.com/my-fn:v1
+`
+
+const config = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+
+// setupPackage creates the root package with a subpackage in a temporary
+// directory and returns the directory.
+func setupPackage(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"Kptfile":         rootKptfile,
+		"config.yaml":     config,
+		"sub/Kptfile":     subKptfile,
+		"empty/Kptfile":   "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: empty\n",
+		"sub/deploy.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: foo\n",
+	}
+	for p, content := range files {
+		p = filepath.Join(dir, p)
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, os.WriteFile(p, []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+	return dir
+}
+
+func TestCmd_table(t *testing.T) {
+	dir := setupPackage(t)
+	out := &bytes.Buffer{}
+	r := NewRunner(fake.CtxWithPrinter(out, &bytes.Buffer{}), "kpt")
+	r.Command.SetArgs([]string{dir})
+	if !assert.NoError(t, r.Command.Execute()) {
+		t.FailNow()
+	}
+	assert.Equal(t, `PACKAGE  TYPE       NAME    FUNCTION                       CONFIG              SELECTORS
+sub      mutator    -       gcr.io/example.com/my-fn:v1    -                   all
+.        mutator    labels  gcr.io/kpt-fn/set-labels:v0.1  configMap (2 keys)  kind=Deployment,labels.app=foo | kind=Service except name=skipped
+.        validator  -       exec: ./validate.sh            config.yaml         all
+`, out.String())
+}
+
+func TestCmd_json(t *testing.T) {
+	dir := setupPackage(t)
+	out := &bytes.Buffer{}
+	r := NewRunner(fake.CtxWithPrinter(out, &bytes.Buffer{}), "kpt")
+	r.Command.SetArgs([]string{dir, "-o", "json"})
+	if !assert.NoError(t, r.Command.Execute()) {
+		t.FailNow()
+	}
+	assert.JSONEq(t, `[
+  {"package": "sub", "type": "mutator", "image": "gcr.io/example.com/my-fn:v1"},
+  {
+    "package": ".",
+    "type": "mutator",
+    "image": "gcr.io/kpt-fn/set-labels:v0.1",
+    "name": "labels",
+    "configMap": {"app": "foo", "tier": "backend"},
+    "selectors": [{"kind": "Deployment", "labels": {"app": "foo"}}, {"kind": "Service"}],
+    "exclude": [{"name": "skipped"}]
+  },
+  {"package": ".", "type": "validator", "exec": "./validate.sh", "configPath": "config.yaml"}
+]`, out.String())
+}
+
+func TestCmd_emptyPipeline(t *testing.T) {
+	dir := filepath.Join(setupPackage(t), "empty")
+	for format, expected := range map[string]string{
+		"table": "No functions in the pipelines of package \"" + dir + "\".\n",
+		"json":  "[]\n",
+	} {
+		t.Run(format, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			r := NewRunner(fake.CtxWithPrinter(out, &bytes.Buffer{}), "kpt")
+			r.Command.SetArgs([]string{dir, "-o", format})
+			if assert.NoError(t, r.Command.Execute()) {
+				assert.Equal(t, expected, out.String())
+			}
+		})
+	}
+}
+
+func TestCmd_errors(t *testing.T) {
+	dir := setupPackage(t)
+	invalid := filepath.Join(dir, "invalid")
+	if !assert.NoError(t, os.MkdirAll(invalid, 0700)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, os.WriteFile(filepath.Join(invalid, "Kptfile"), []byte(`apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: invalid
+pipeline:
+  mutators:
+  - configPath: missing.yaml
+`), 0600)) {
+		t.FailNow()
+	}
+
+	testCases := map[string]struct {
+		args []string
+		err  string
+	}{
+		"invalid output": {
+			args: []string{dir, "-o", "yaml"},
+			err:  "--output must be one of table and json",
+		},
+		"invalid pipeline": {
+			args: []string{invalid},
+			err:  "must specify a functon (`image` or `exec`) to execute",
+		},
+		"invalid subpackage pipeline": {
+			args: []string{dir},
+			err:  "must specify a functon (`image` or `exec`) to execute",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			r := NewRunner(fake.CtxWithPrinter(&bytes.Buffer{}, &bytes.Buffer{}), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			r.Command.SetArgs(tc.args)
+			err := r.Command.Execute()
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
@@ -52,6 +52,8 @@ func NewRunner(ctx context.Context, parent string) *Runner {
 		fmt.Sprintf("pull image before running the container. It must be one of %s, %s and %s.", fnruntime.AlwaysPull, fnruntime.IfNotPresentPull, fnruntime.NeverPull))
 	c.Flags().BoolVar(&r.allowExec, "allow-exec", false,
 		"allow binary executable to be run during pipeline execution.")
+	c.Flags().BoolVar(&r.noDocker, "no-docker", false,
+		"fail if the pipeline contains container functions instead of running them.")
 	cmdutil.FixDocs("kpt", parent, c)
 	r.Command = c
 	return r
@@ -67,6 +69,7 @@ type Runner struct {
 	resultsDirPath  string
 	imagePullPolicy string
 	allowExec       bool
+	noDocker        bool
 	dest            string
 	Command         *cobra.Command
 	ctx             context.Context
@@ -121,11 +124,12 @@ func (r *Runner) runE(c *cobra.Command, _ []string) error {
 		Output:          output,
 		ImagePullPolicy: cmdutil.StringToImagePullPolicy(r.imagePullPolicy),
 		AllowExec:       r.allowExec,
+		NoDocker:        r.noDocker,
 		FileSystem:      filesys.FileSystemOrOnDisk{},
 	}
 	if err := executor.Execute(r.ctx); err != nil {
 		return err
 	}
 
-	return cmdutil.WriteFnOutput(r.dest, outContent.String(), false, printer.FromContextOrDie(r.ctx).OutStream())
+	return cmdutil.WriteFnOutput(r.dest, outContent.String(), false, nil, printer.FromContextOrDie(r.ctx).OutStream())
 }
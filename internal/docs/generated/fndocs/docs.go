@@ -29,13 +29,14 @@ var DocExamples = `
 
 var EvalShort = `Execute function on resources`
 var EvalLong = `
-  kpt fn eval [DIR|-] [flags] [-- fn-args]
+  kpt fn eval [DIR... | -] [flags] [-- fn-args]
 
 Args:
 
   DIR|-:
     Path to the local directory containing resources. Defaults to the current
-    working directory. Using '-' as the directory path will cause ` + "`" + `eval` + "`" + ` to
+    working directory. Multiple directories can be specified, in which case the
+    function is executed on each of them separately. Using '-' as the directory path will cause ` + "`" + `eval` + "`" + ` to
     read resources from ` + "`" + `stdin` + "`" + ` and write the output to ` + "`" + `stdout` + "`" + `. When resources are
     read from ` + "`" + `stdin` + "`" + `, they must be in one of the following input formats:
   
@@ -46,6 +47,13 @@ Args:
   
     If the output is written to ` + "`" + `stdout` + "`" + `, resources are written in multi object YAML
     format where resources are separated by ` + "`" + `---` + "`" + `.
+  
+    A path ending with ` + "`" + `.tar.gz` + "`" + ` or ` + "`" + `.tgz` + "`" + ` is a gzipped tarball of the package.
+    It is extracted to a temporary directory, which may contain the package
+    directory or the package content, and the package is written back to the
+    archive after the function was executed successfully. The archive is not
+    modified if the function fails. Archives can't be combined with other
+    directories or with ` + "`" + `--save` + "`" + `.
 
   fn-args:
     function arguments to be provided as input to the function. These must be
@@ -53,6 +61,14 @@ Args:
 
 Flags:
 
+  --allow-exec:
+    Allow exec functions, i.e. ` + "`" + `--exec` + "`" + ` and the ` + "`" + `exec` + "`" + ` functions of ` + "`" + `--pipeline` + "`" + `,
+    to run. Exec functions run local executables which can perform privileged
+    operations on your system, so they are disabled by default and ` + "`" + `eval` + "`" + ` fails
+    until they are deliberately enabled. They are also allowed if the
+    ` + "`" + `KPT_FN_ALLOW_EXEC` + "`" + ` environment variable is ` + "`" + `true` + "`" + `, which lets platform teams
+    control them by policy. Container functions are not affected.
+  
   --as-current-user:
     Use the ` + "`" + `uid` + "`" + ` and ` + "`" + `gid` + "`" + ` of the kpt process for container function execution.
     By default, container function is executed as ` + "`" + `nobody` + "`" + ` user. You may want to use
@@ -70,27 +86,142 @@ Flags:
   --match-namespace:
     Select resources matching the given namespace.
   
+  --match-file:
+    Select resources in files matching the given glob pattern. The pattern is
+    matched against the file path of the resources relative to the package root,
+    e.g. ` + "`" + `deployments/*.yaml` + "`" + `, so resources in subpackages are matched by patterns
+    including the subpackage directory. This flag can't be used with ` + "`" + `--save` + "`" + `.
+  
+  --changed-since:
+    Select resources in files added or modified since the given git ref, e.g. a
+    branch, tag, commit or ` + "`" + `HEAD~1` + "`" + `, of the repository containing the package.
+    Uncommitted changes are included, while changes to the Kptfile aren't. If no
+    files changed, the function isn't executed. This flag can't be used with
+    ` + "`" + `--save` + "`" + `, ` + "`" + `--pipeline` + "`" + `, archives or when reading resources from ` + "`" + `stdin` + "`" + `.
+  
+  --exclude-api-version:
+    Exclude resources matching the given apiVersion.
+  
+  --exclude-kind:
+    Exclude resources matching the given kind.
+  
+  --exclude-name:
+    Exclude resources matching the given name.
+  
+  --exclude-namespace:
+    Exclude resources matching the given namespace.
+  
+    Exclusions are applied after the ` + "`" + `--match-*` + "`" + ` selectors, so a resource that
+    matches both is excluded. The exclusions are saved in the ` + "`" + `exclude` + "`" + ` field of
+    the function in the Kptfile when used with ` + "`" + `--save` + "`" + `.
+  
   --env, e:
     List of local environment variables to be exported to the container function.
     By default, none of local environment variables are made available to the
     container running the function. The value can be in ` + "`" + `key=value` + "`" + ` format or only
-    the key of an already exported environment variable.
+    the key of an already exported environment variable. A key can be a glob
+    pattern, e.g. ` + "`" + `MYFN_*` + "`" + `, to export all the local environment variables
+    matching it. Patterns aren't expanded in the ` + "`" + `key=value` + "`" + ` format, and they
+    aren't supported by ` + "`" + `--exec-env` + "`" + `.
+  
+  --exec-env:
+    List of environment variables to be set for the exec function, in addition to
+    the environment kpt is running with. The value can be in ` + "`" + `key=value` + "`" + ` format or
+    only the key of an already exported environment variable. This flag only
+    applies to exec functions and is ignored for image functions, use ` + "`" + `--env` + "`" + ` for
+    those instead.
+  
+  --print-env:
+    If enabled, the environment variables and the mounts the container functions
+    are executed with are printed to stderr before executing them, to debug the
+    environment of the container. The values of the variables given with only
+    their key, or with a glob pattern, are looked up in the local environment.
+    The values of the variables whose keys contain ` + "`" + `TOKEN` + "`" + `, ` + "`" + `SECRET` + "`" + ` or
+    ` + "`" + `PASSWORD` + "`" + `, in any case, are masked. It can only be used with container
+    functions.
+  
+  --inject-package-path:
+    If enabled, the absolute path of the package is exported to the functions in
+    the ` + "`" + `KPT_PACKAGE_PATH` + "`" + ` environment variable. Container functions get it like
+    a variable given with ` + "`" + `--env` + "`" + `, exec functions get it in the process
+    environment they are executed with, like a variable given with ` + "`" + `--exec-env` + "`" + `.
+    Variables with the same name given by ` + "`" + `--env` + "`" + `, ` + "`" + `--exec-env` + "`" + ` or the function
+    config take precedence. When the resources are read from stdin, the variable
+    isn't set.
+  
+  --check-idempotent:
+    If enabled, the function is executed again on its own output and ` + "`" + `eval` + "`" + ` fails
+    if the second execution changes the resources. The differences between the
+    outputs of the two executions are printed. Neither the package nor the
+    results are written, so this flag can't be used with ` + "`" + `--save` + "`" + `, ` + "`" + `--output` + "`" + ` or
+    ` + "`" + `--results-dir` + "`" + `.
+  
+  --strict:
+    If enabled, ` + "`" + `eval` + "`" + ` fails if the function returns results with the ` + "`" + `warning` + "`" + `
+    severity, so they can be used to gate changes e.g. in CI. By default, only
+    results with the ` + "`" + `error` + "`" + ` severity fail. Results with the ` + "`" + `info` + "`" + ` severity
+    never fail. The resources are still written when warnings fail ` + "`" + `eval` + "`" + `. This
+    flag can't be used with ` + "`" + `--check-idempotent` + "`" + `.
+  
+  --fail-fast:
+    If enabled, execution stops at the first package for which the function fails
+    when multiple directories are specified. By default, the function is executed
+    on the remaining packages and the failed packages are reported at the end.
+  
+  --fail-on-empty:
+    If enabled, the execution fails if the function selects no resources, e.g.
+    when the ` + "`" + `--match-*` + "`" + ` flags don't match any resource, or if the output of the
+    function doesn't contain any resources. With ` + "`" + `--pipeline` + "`" + `, it applies to each
+    of the functions. By default, the function is executed on an empty list of
+    resources and an empty output removes all the resources of the package.
   
   --exec:
     Path to the local executable binary to execute as a function. Quotes are needed
     if the executable requires arguments. ` + "`" + `eval` + "`" + ` executes only one function, so do
     not use ` + "`" + `--image` + "`" + ` flag with this flag. This is useful for testing function locally
     during development. It enables faster dev iterations by avoiding the function to
-    be published as container image.
+    be published as container image. Relative paths are resolved against the current
+    directory and executables without a path are looked up in ` + "`" + `PATH` + "`" + `. It requires
+    ` + "`" + `--allow-exec` + "`" + `.
   
   --fn-config:
-    Path to the file containing ` + "`" + `functionConfig` + "`" + ` for the function.
+    Path to the file containing ` + "`" + `functionConfig` + "`" + ` for the function. A relative
+    path is relative to the current working directory unless
+    ` + "`" + `--fn-config-relative` + "`" + ` is set.
+  
+  --fn-config-relative:
+    Resolve a relative ` + "`" + `--fn-config` + "`" + ` path against the directory of the package
+    instead of the current working directory, e.g. to use the function config
+    in each of multiple packages. Absolute paths are used as is. This can't be
+    used when reading resources from ` + "`" + `stdin` + "`" + `.
+  
+  --fn-config-kind:
+    Kind of the ` + "`" + `functionConfig` + "`" + ` created from the function arguments. Defaults to
+    ` + "`" + `ConfigMap` + "`" + `. This can't be combined with specifying the kind as the first
+    function argument, or with ` + "`" + `--fn-config` + "`" + `.
+  
+  --fn-config-api-version:
+    apiVersion of the ` + "`" + `functionConfig` + "`" + ` created from the function arguments.
+    Defaults to ` + "`" + `v1` + "`" + `. This can't be combined with ` + "`" + `--fn-config` + "`" + `.
   
   --image, i:
     Container image of the function to execute e.g. ` + "`" + `gcr.io/kpt-fn/set-namespace:v0.1` + "`" + `.
     For convenience, if full image path is not specified, ` + "`" + `gcr.io/kpt-fn/` + "`" + ` is added as default prefix.
     e.g. instead of passing ` + "`" + `gcr.io/kpt-fn/set-namespace:v0.1` + "`" + ` you can pass ` + "`" + `set-namespace:v0.1` + "`" + `.
     ` + "`" + `eval` + "`" + ` executes only one function, so do not use ` + "`" + `--exec` + "`" + ` flag with this flag.
+    Before an image is pulled, its media type is checked and ` + "`" + `eval` + "`" + ` fails if it
+    refers to an OCI artifact which isn't a container image, e.g. a wasm module.
+    Wasm functions are not supported yet.
+  
+  --image-prefix:
+    Registry path the short image names of ` + "`" + `--image` + "`" + ` and of the functions of
+    ` + "`" + `--pipeline` + "`" + ` are resolved against instead of ` + "`" + `gcr.io/kpt-fn/` + "`" + `, e.g. to use a
+    mirror of the function catalog in a private registry. With
+    ` + "`" + `--image-prefix registry.example.com/kpt-fn` + "`" + `, ` + "`" + `set-namespace:v0.4` + "`" + ` resolves
+    to ` + "`" + `registry.example.com/kpt-fn/set-namespace:v0.4` + "`" + `. Images whose path
+    contains a ` + "`" + `/` + "`" + `, e.g. because they contain a registry host, aren't changed.
+    Defaults to the value of the ` + "`" + `KPT_FN_IMAGE_PREFIX` + "`" + ` environment variable, or
+    ` + "`" + `gcr.io/kpt-fn/` + "`" + ` if it isn't set. ` + "`" + `render` + "`" + ` always uses ` + "`" + `gcr.io/kpt-fn/` + "`" + `.
   
   --image-pull-policy:
     If the image should be pulled before rendering the package(s). It can be set
@@ -102,34 +233,215 @@ Flags:
     the local cache.
     If using never, kpt will only use images from the local cache.
   
+  --pull-retries:
+    The number of times pulling the function image is retried after transient
+    failures, such as network errors, registry server errors and rate limits.
+    The delay between retries starts at 1 second and doubles every retry, up to
+    30 seconds. Other failures, e.g. when the image doesn't exist or access is
+    denied, fail immediately. Retries are printed with ` + "`" + `--verbose` + "`" + `. It has no
+    effect with the ` + "`" + `never` + "`" + ` image pull policy. Defaults to 0, which leaves
+    pulling the image to docker while running the function.
+  
+  --registry-auth:
+    Path to a docker config file in JSON format, e.g. a copy of
+    ` + "`" + `~/.docker/config.json` + "`" + `, containing the credentials the images of container
+    functions are pulled from private registries with, instead of the credentials
+    of the local docker config. This allows pulling images in CI environments
+    without a docker config. The file must contain ` + "`" + `auths` + "`" + `, ` + "`" + `credHelpers` + "`" + ` or
+    ` + "`" + `credsStore` + "`" + `, and the credentials are never printed. It can only be used
+    with container functions.
+  
+  --cache-dir:
+    Directory to cache the outputs of container functions in. A function isn't
+    executed again if the directory contains its output for the same input, and
+    the cached output and stderr are used instead. The output is cached by the
+    digest of the local function image, the input resources, the
+    ` + "`" + `functionConfig` + "`" + `, the env variables and the user, so changing any of them,
+    or pulling a new image for the same tag, executes the function again.
+    Functions with ` + "`" + `--network` + "`" + ` or ` + "`" + `--mount` + "`" + ` aren't cached, as their output may
+    depend on more than their input, and neither are functions executed with
+    ` + "`" + `--exec` + "`" + `. Failed executions aren't cached. The cache is never cleaned up,
+    remove the directory to clear it. Nothing is cached by default.
+  
+  --no-cache:
+    Execute the function even if its output is cached in ` + "`" + `--cache-dir` + "`" + `, and
+    replace the cached output. It can only be used with ` + "`" + `--cache-dir` + "`" + `.
+  
   --include-meta-resources, m:
     If enabled, meta resources (i.e. ` + "`" + `Kptfile` + "`" + ` and ` + "`" + `functionConfig` + "`" + `) are included
     in the input to the function. By default it is disabled.
   
+  --max-results:
+    Maximum number of results of the function which are printed and written to
+    ` + "`" + `--results-dir` + "`" + `. If the function returns more results, the remaining ones are
+    dropped and a result with the number of truncated results is added instead.
+    Error results are retained over other results. Defaults to 0, which means
+    all results are retained.
+  
   --mount:
     List of storage options to enable reading from the local filesytem. By default,
-    container functions can not access the local filesystem. It accepts the same options
-    as specified on the [Docker Volumes] for ` + "`" + `docker run` + "`" + `. All volumes are mounted
-    readonly by default. Specify ` + "`" + `rw=true` + "`" + ` to mount volumes in read-write mode.
+    container functions can not access the local filesystem. Each mount must be
+    in one of the following formats, which correspond to the options specified on
+    the [Docker Volumes] for ` + "`" + `docker run` + "`" + `:
+  
+    - a bind mount, ` + "`" + `type=bind,src=<path>,dst=<path>[,rw=true]` + "`" + `. The ` + "`" + `src` + "`" + ` path
+      must exist and is resolved to an absolute path without symlinks before it
+      is mounted. Mounts without a ` + "`" + `type` + "`" + ` are bind mounts.
+    - a named volume, ` + "`" + `type=volume,src=<name>,dst=<path>[,rw=true]` + "`" + `.
+    - a tmpfs mount, ` + "`" + `type=tmpfs,dst=<path>[,size=<size>]` + "`" + `, for an ephemeral
+      scratch space. The size is in bytes, or with a ` + "`" + `k` + "`" + `, ` + "`" + `m` + "`" + ` or ` + "`" + `g` + "`" + ` unit, e.g.
+      ` + "`" + `size=64m` + "`" + `. The size isn't limited by default. tmpfs mounts are always
+      writable and have no ` + "`" + `src` + "`" + `.
+  
+    Bind mounts and volumes are mounted readonly by default. Specify ` + "`" + `rw=true` + "`" + ` to
+    mount them in read-write mode.
+  
+  --context-dir:
+    Path to a directory which is mounted readonly at ` + "`" + `/kpt-context` + "`" + ` into the
+    container functions and is their working directory, so they can read files
+    from outside of the package, e.g. shared templates in a sibling directory,
+    by relative paths. The ` + "`" + `KPT_CONTEXT_DIR` + "`" + ` environment variable is set to
+    ` + "`" + `/kpt-context` + "`" + `. The functions still mutate the resources of the package. The
+    directory must exist, and a warning is printed if it contains or is
+    contained in the package. It can only be used with container functions.
+  
+  --ignore-mount-errors:
+    If enabled, mounts whose ` + "`" + `src` + "`" + ` path doesn't exist are skipped with a warning
+    instead of failing ` + "`" + `eval` + "`" + `. Other invalid mounts still fail.
   
   --network:
     If enabled, container functions are allowed to access network.
     By default it is disabled.
   
+  --no-docker:
+    If enabled, ` + "`" + `eval` + "`" + ` fails with an error instead of running a container
+    function specified using ` + "`" + `--image` + "`" + `. Exec functions are run normally.
+  
   --output, o:
     If specified, the output resources are written to provided location,
     if not specified, resources are modified in-place.
-    Allowed values: stdout|unwrap|<OUT_DIR_PATH>
+    Allowed values: stdout|unwrap|resourcelist|<OUT_DIR_PATH>
     1. stdout: output resources are wrapped in ResourceList and written to stdout.
     2. unwrap: output resources are written to stdout, in multi-object yaml format.
-    3. OUT_DIR_PATH: output resources are written to provided directory.
-       The provided directory must not already exist.
+    3. resourcelist: output resources are wrapped in ResourceList together with
+       the ` + "`" + `functionConfig` + "`" + ` and written to stdout, in the KRM function wire format.
+    4. OUT_DIR_PATH: output resources are written to provided directory.
+       The provided directory must either not exist yet or be empty.
+    This flag can't be used with multiple directories. If the package is an
+    archive, the path must be an archive ending with ` + "`" + `.tar.gz` + "`" + ` or ` + "`" + `.tgz` + "`" + `
+    instead of a directory, which the package is written to instead of the
+    original archive.
+  
+  --sort-output:
+    If enabled, the output resources are ordered by ` + "`" + `apiVersion` + "`" + `, ` + "`" + `kind` + "`" + `,
+    ` + "`" + `namespace` + "`" + ` and ` + "`" + `name` + "`" + ` instead of the order the functions output them in,
+    so the output is the same in every run and diffs well in version control.
+    Resources with the same ` + "`" + `apiVersion` + "`" + `, ` + "`" + `kind` + "`" + `, ` + "`" + `namespace` + "`" + ` and ` + "`" + `name` + "`" + ` keep
+    their order. With an ` + "`" + `OUT_DIR_PATH` + "`" + `, every resource is written to its own
+    file named ` + "`" + `<namespace>/<kind>_<name>.yaml` + "`" + ` in the directory of its original
+    file, e.g. ` + "`" + `staging/deployment_nginx.yaml` + "`" + `. Kptfiles keep their path. It can
+    only be used with ` + "`" + `--output` + "`" + ` or when reading resources from stdin.
+  
+  --pipeline:
+    Path to a file containing a list of functions to execute in order instead of
+    a single function. The functions have the same format as the ` + "`" + `mutators` + "`" + ` of the
+    Kptfile pipeline, including ` + "`" + `configPath` + "`" + `, ` + "`" + `configMap` + "`" + `, ` + "`" + `selectors` + "`" + ` and
+    ` + "`" + `exclude` + "`" + `, and each function is executed on the output of the previous one.
+    ` + "`" + `configPath` + "`" + ` is relative to the directory of the file. The Kptfile is not
+    modified, which allows testing a pipeline before adding it to the Kptfile.
+    This can't be combined with ` + "`" + `--image` + "`" + `, ` + "`" + `--exec` + "`" + `, ` + "`" + `--save` + "`" + `, the function config
+    flags, function arguments or the selector and exclusion flags. Pipelines
+    with ` + "`" + `exec` + "`" + ` functions require ` + "`" + `--allow-exec` + "`" + `.
+  
+  --preserve-unchanged:
+    If enabled, files whose content isn't changed by the function are not written
+    when resources are modified in-place, so their modification time is preserved.
+    Files containing only deleted resources are still removed. Enabled by default.
+  
+  --quiet:
+    If enabled, informational messages such as the progress of the functions and
+    the confirmation of saving the function to the Kptfile are not printed.
+    Errors, warnings and the results of the functions are still printed, and the
+    output of ` + "`" + `eval` + "`" + ` and the files in ` + "`" + `--results-dir` + "`" + ` are not affected.
   
   --results-dir:
     Path to a directory to write structured results. Directory will be created if
     it doesn't exist. Structured results emitted by the functions are aggregated and saved
     to ` + "`" + `results.yaml` + "`" + ` file in the specified directory.
     If not specified, no result files are written to the local filesystem.
+    When multiple directories are specified, the results of each package are
+    written to a separate subdirectory.
+  
+  --results-ignore:
+    Path to a file listing the signatures of known results, e.g. acknowledged
+    validation warnings. Each signature can have a ` + "`" + `function` + "`" + `, a glob pattern
+    matching the image or exec of the function, a ` + "`" + `resource` + "`" + ` with the
+    ` + "`" + `apiVersion` + "`" + `, ` + "`" + `kind` + "`" + `, ` + "`" + `namespace` + "`" + ` and ` + "`" + `name` + "`" + ` of the resource the result
+    refers to, and a ` + "`" + `message` + "`" + `, a regular expression matching the message of the
+    result. Fields which aren't set match any result:
+  
+        - function: gcr.io/kpt-fn/kubeval:*
+          resource:
+            kind: Deployment
+            name: nginx
+        - message: ^deprecated apiVersion
+  
+    Results matching any signature are not printed, written to ` + "`" + `--results-dir` + "`" + `
+    or ` + "`" + `--junit-report` + "`" + `, or counted by ` + "`" + `--strict` + "`" + `, and a function which fails
+    only because of ignored error results passes. The number of ignored results
+    is printed after the function is executed.
+  
+  --junit-report:
+    Path to a file to write the function results to as a JUnit XML report, which
+    can be consumed by CI systems. Every function is a test suite and every
+    result is a test case, named after the resource and the field it refers to.
+    Results with the ` + "`" + `error` + "`" + ` severity are failures, as are results with the
+    ` + "`" + `warning` + "`" + ` severity with ` + "`" + `--strict` + "`" + `. A function without results is a single
+    test case. When multiple directories are specified, the report contains the
+    results of all the packages. It can be used with ` + "`" + `--results-dir` + "`" + `, and can't
+    be used with ` + "`" + `--check-idempotent` + "`" + `.
+  
+  --results-format:
+    Format to print the function results in, either ` + "`" + `text` + "`" + ` (default) or ` + "`" + `json` + "`" + `.
+    With ` + "`" + `text` + "`" + `, the status and the results of each function are printed to
+    stderr as it completes, and if stderr is a terminal, a
+    ` + "`" + `[PROGRESS] <n>/<total> functions completed` + "`" + ` line is printed after each
+    function of a ` + "`" + `--pipeline` + "`" + `. With ` + "`" + `json` + "`" + `, neither the status nor the progress
+    of the functions are printed, and the results are printed to stderr as a
+    ` + "`" + `FunctionResultList` + "`" + ` in JSON on a single line once the functions completed.
+    It can't be used with ` + "`" + `--check-idempotent` + "`" + `.
+  
+  --save, s:
+    If enabled, the function and its arguments are saved to the Kptfile of the
+    package after it was executed successfully. The function is added to
+    ` + "`" + `pipeline.validators` + "`" + ` if its image has the label
+    ` + "`" + `org.kpt.function.type=validator` + "`" + `, and to ` + "`" + `pipeline.mutators` + "`" + ` otherwise.
+    Exec functions are always saved as mutators unless ` + "`" + `--type` + "`" + ` or ` + "`" + `--save-as` + "`" + `
+    is specified.
+    If the Kptfile already contains the function, the entry is replaced unless
+    ` + "`" + `--update` + "`" + ` is specified.
+  
+  --save-as:
+    The type the function is saved to the Kptfile as with ` + "`" + `--save` + "`" + `, either
+    ` + "`" + `mutator` + "`" + ` or ` + "`" + `validator` + "`" + `. It overrides the type detected from the labels of
+    the image.
+  
+  --update:
+    With ` + "`" + `--save` + "`" + `, update the function already in the Kptfile instead of
+    replacing it. Its other fields, such as its name, are kept. The arguments
+    are merged into its ` + "`" + `configMap` + "`" + `, while ` + "`" + `--fn-config` + "`" + ` replaces it with a
+    ` + "`" + `configPath` + "`" + `. The selectors and exclusions are only replaced if ` + "`" + `--match-*` + "`" + `
+    or ` + "`" + `--exclude-*` + "`" + ` flags are specified.
+  
+  --verbose:
+    If enabled, what successful functions write to stderr is printed after their
+    status, between ` + "`" + `---- stderr from <function> ----` + "`" + ` and
+    ` + "`" + `---- end of stderr ----` + "`" + ` lines. By default it is not printed. The stderr of a
+    failed function is always included in the error in the same format.
+    If the function is only applied to some of the resources, a line is also
+    printed for every resource telling if it was selected and by which
+    criteria, and if the function modified, deleted or left it unchanged, e.g.
+    ` + "`" + `  Deployment default/nginx (deploy.yaml): selected by kind=Deployment, modified` + "`" + `.
 `
 var EvalExamples = `
   # execute container my-fn on the resources in DIR directory and
@@ -143,13 +455,30 @@ var EvalExamples = `
   # execute container my-fn with an input ConfigMap containing ` + "`" + `data: {foo: bar}` + "`" + `
   $ kpt fn eval DIR -i gcr.io/example.com/my-fn:v1.0.0 -- foo=bar
 
+  # execute container my-fn with an input SetNamespace function config
+  # containing ` + "`" + `data: {namespace: staging}` + "`" + `
+  $ kpt fn eval DIR -i gcr.io/example.com/my-fn:v1.0.0 --fn-config-kind SetNamespace \
+    --fn-config-api-version fn.kpt.dev/v1alpha1 -- namespace=staging
+
   # execute executable my-fn on the resources in DIR directory and
   # write output back to DIR
-  $ kpt fn eval DIR --exec ./my-fn
+  $ kpt fn eval DIR --allow-exec --exec ./my-fn
+
+  # execute container my-fn on the package in the pkg.tar.gz archive and
+  # write the package to the out.tar.gz archive
+  $ kpt fn eval pkg.tar.gz -i gcr.io/example.com/my-fn -o out.tar.gz
+
+  # execute container my-fn only on the resources in the files of DIR directory
+  # added or modified since the main branch
+  $ kpt fn eval DIR -i gcr.io/example.com/my-fn --changed-since main
+
+  # execute container my-fn on the resources in DIR directory, reusing its
+  # output cached in ~/.kpt/fn-cache if the resources didn't change
+  $ kpt fn eval DIR -i gcr.io/example.com/my-fn --cache-dir ~/.kpt/fn-cache
 
   # execute executable my-fn with arguments on the resources in DIR directory and
   # write output back to DIR
-  $ kpt fn eval DIR --exec "./my-fn arg1 arg2"
+  $ kpt fn eval DIR --allow-exec --exec "./my-fn arg1 arg2"
 
   # execute container my-fn on the resources in DIR directory,
   # save structured results in /tmp/my-results dir and write output back to DIR
@@ -163,11 +492,42 @@ var EvalExamples = `
   # and foo environment variable
   $ kpt fn eval DIR -i gcr.io/example.com/my-fn --env KUBECONFIG -e foo=bar
 
+  # execute the functions listed in pipeline.yaml on the resources in DIR
+  # directory and write output back to DIR
+  $ kpt fn eval DIR --pipeline pipeline.yaml
+
+  # execute container 'set-namespace' on the resources in both the staging and
+  # prod packages, stopping at the first package the function fails on
+  $ kpt fn eval staging prod -i set-namespace:v0.1 --fail-fast -- namespace=default
+
+  # execute executable my-fn on the resources in DIR and set the
+  # CREDENTIALS environment variable for it
+  $ kpt fn eval DIR --allow-exec --exec ./my-fn --exec-env CREDENTIALS=/path/to/credentials
+
+  # execute container my-fn with all the local environment variables starting
+  # with MYFN_ exported to it
+  $ kpt fn eval DIR -i gcr.io/example.com/my-fn -e 'MYFN_*'
+
   # execute kubeval function by mounting schema from a local directory on wordpress package
   $ kpt fn eval -i gcr.io/kpt-fn/kubeval:v0.1 \
     --mount type=bind,src="/path/to/schema-dir",dst=/schema-dir \
     --as-current-user wordpress -- additional_schema_locations=/schema-dir
 
+  # execute container my-fn from a private registry on the resources in DIR,
+  # pulling its image with the credentials in auth.json
+  $ kpt fn eval DIR -i us-docker.pkg.dev/my-project/fns/my-fn:v1 --registry-auth auth.json
+
+  # execute the kubeval function on the resources in DIR, ignoring the known
+  # results listed in known-results.yaml
+  $ kpt fn eval DIR -i kubeval:v0.3 --results-ignore known-results.yaml
+
+  # execute container my-fn on the resources in DIR, reading the templates it
+  # refers to by relative paths from the sibling templates directory
+  $ kpt fn eval DIR -i gcr.io/example.com/my-fn --context-dir templates
+
+  # execute container my-fn with a 64MB scratch space mounted at /scratch
+  $ kpt fn eval DIR -i gcr.io/example.com/my-fn --mount type=tmpfs,dst=/scratch,size=64m
+
   # chaining functions using the unix pipe to set namespace and set labels on
   # wordpress package
   $ kpt fn source wordpress \
@@ -184,6 +544,10 @@ var EvalExamples = `
   $ kpt fn eval -i gcr.io/kpt-fn/set-namespace:v0.1 -o unwrap -- namespace=mywordpress \
   | kubectl apply -f -
 
+  # execute container 'set-namespace' on the resources in current directory and write
+  # the output resources together with the functionConfig to stdout as a ResourceList
+  $ kpt fn eval -i gcr.io/kpt-fn/set-namespace:v0.1 -o resourcelist -- namespace=staging
+
   # execute container 'set-namespace' on the resources in current directory and write
   # the wrapped output resources to stdout which are passed to 'set-annotations' function
   # and the output resources after setting namespace and annotation is written to another directory
@@ -193,6 +557,11 @@ var EvalExamples = `
   # execute container 'set-namespace' on the resources with 'name' foo and 'kind' Deployment
   # in current directory
   kpt fn eval -i set-namespace:v0.1 --by-kind Deployment --by-name foo -- namespace=staging
+
+  # execute container 'set-namespace' on all resources in current directory except
+  # for the Secret 'generated-secret', and save the function to the Kptfile
+  $ kpt fn eval -i set-namespace:v0.1 --exclude-kind Secret --exclude-name generated-secret \
+    -s -t mutator -- namespace=staging
 `
 
 var ExportShort = `Auto-generating function pipelines for different workflow orchestrators`
@@ -227,6 +596,37 @@ var ExportExamples = `
   kpt fn export DIR/ --fn-path FUNCTIONS_DIR/ --workflow cloud-build
 `
 
+var PlanShort = `List the functions render would execute on a package.`
+var PlanLong = `
+  kpt fn plan [PKG_PATH] [flags]
+
+Args:
+
+  PKG_PATH:
+    Local package path to list the functions of. Directory must exist and
+    contain a Kptfile. Defaults to the current working directory.
+
+Flags:
+
+  --output, o:
+    Format the functions are printed in. It must be one of ` + "`" + `table` + "`" + ` and ` + "`" + `json` + "`" + `.
+    The ` + "`" + `table` + "`" + ` format prints a line for every function. The config is the
+    ` + "`" + `configPath` + "`" + ` of the function, relative to its package, or the number of keys
+    of its ` + "`" + `configMap` + "`" + `. The selectors are ` + "`" + `all` + "`" + ` if the function is executed on
+    all the resources, otherwise the criteria of its selectors separated by ` + "`" + `|` + "`" + `,
+    followed by its exclusions. The ` + "`" + `json` + "`" + ` format prints an array of the
+    functions with the fields of the Kptfile function and the ` + "`" + `package` + "`" + ` and
+    ` + "`" + `type` + "`" + ` fields. Defaults to ` + "`" + `table` + "`" + `.
+`
+var PlanExamples = `
+  # list the functions render would execute on the package in the current directory
+  $ kpt fn plan
+
+  # list the functions render would execute on the package in the DIR directory
+  # as JSON
+  $ kpt fn plan DIR -o json
+`
+
 var RenderShort = `Render a package.`
 var RenderLong = `
   kpt fn render [PKG_PATH] [flags]
@@ -244,6 +644,12 @@ Flags:
     can perform privileged operations on your system, so ensure that binaries
     referred in the pipeline are trusted and safe to execute.
   
+  --no-docker:
+    If enabled, rendering fails when a container function is declared in the
+    pipeline of any of the packages, instead of pulling and running its image.
+    Exec functions are run normally. This is useful in environments where Docker
+    isn't available.
+  
   --image-pull-policy:
     If the image should be pulled before rendering the package(s). It can be set
     to one of always, ifNotPresent, never. If unspecified, always will be the
@@ -53,7 +53,17 @@ Flags:
     combined: Shows changes in local package relative to upstream source
               package at target version.
     3way: Shows changes in local package and source package at target version
-          relative to original version side by side.
+          relative to original version side by side. Unless a diff tool is
+          specified, the changed fields of every file are shown in local,
+          remote and target columns by kpt itself. Fields which were changed
+          differently in the local and the target package are conflicts and
+          are marked with '!'.
+    merged: Shows the changes kpt pkg update would make to the local package:
+            the changes in upstream source package between original and
+            target version are merged into the local package, and the result
+            is compared with the local package. Files with conflicting
+            changes contain the local and the target version between
+            conflict markers.
   
   --diff-tool:
     Command line diffing tool ('diff' by default) for showing the changes.
@@ -68,6 +78,208 @@ Flags:
   
     # Show changes using the diff command with recursive options.
     kpt pkg diff @master --diff-tool meld --diff-tool-opts "-r"
+  
+  --skip-tool-check:
+    Don't verify that the diff tool can compare the 3 packages of the 3way diff
+    type. Unless the tool is known to support it, e.g. meld or kdiff3, it is
+    run with 3 empty directories before fetching the packages and has to exit
+    with code 0 or 1.
+  
+  --list-tools:
+    List the known diff tools found in the PATH, e.g. diff, meld or vimdiff,
+    together with the diff types each of them supports, instead of comparing
+    packages. The tools which aren't known to compare 3 packages are checked
+    like for ` + "`" + `--skip-tool-check` + "`" + `.
+  
+  --color:
+    When to color the side by side output of the 3way diff type ('auto' by
+    default). Following values are supported:
+  
+    auto: Colors the output only if it is written to a terminal.
+    always: Always colors the output.
+    never: Never colors the output.
+  
+    # Show 3way changes without colors.
+    kpt pkg diff @master --diff-type 3way --color never
+  
+  --from:
+    An upstream git tag, branch, or commit to compare against the ref given by
+    --to. When both --from and --to are specified, the local package is not
+    compared and --diff-type is ignored. The repository is read from the
+    Kptfile upstream section unless --repo is specified.
+  
+    # Show changes in the upstream package between v1 and v2.
+    kpt pkg diff --from v1 --to v2
+  
+  --to:
+    The target upstream git tag, branch, or commit. Specifying --to without
+    --from is the same as PKG_PATH@VERSION.
+  
+  --ref:
+    Same as --to.
+  
+  --repo:
+    The git repository to fetch the upstream package from, instead of the
+    upstream recorded in the Kptfile. The package directory within the
+    repository can be provided after the .git suffix, it defaults to the
+    directory recorded in the Kptfile. Required for --from and --to when the
+    package doesn't have a Kptfile. The repository must be a git URL or a local
+    directory.
+  
+    # Compare two versions of a package without a local copy.
+    kpt pkg diff --repo https://github.com/GoogleContainerTools/kpt.git/package-examples/wordpress \
+      --from v0.7 --to v0.8
+  
+    # Compare the local package with v2 of a fork of its upstream.
+    kpt pkg diff --repo https://github.com/org/fork.git --ref v2 .
+  
+  --diff-exclude:
+    A pattern of files and directories to exclude from the comparison. The
+    patterns use the same syntax as .gitignore and .krmignore files and are
+    applied to every compared package. This flag can be repeated.
+  
+    # Exclude generated files and vendored packages.
+    kpt pkg diff --diff-exclude '*.generated.yaml' --diff-exclude vendor/
+  
+  --path-filter:
+    Path of a file or directory relative to the package root. Only this file
+    or directory is compared. If it only exists in one of the compared
+    packages, it is shown as added or removed.
+  
+    # Show the upstream changes to deployment.yaml between two versions.
+    kpt pkg diff @v0.8 --diff-type remote --path-filter deployment.yaml
+  
+  --ignore-field:
+    Dotted path of a resource field that should be ignored when comparing the
+    packages. The field is removed from every resource in all compared
+    packages. Elements of a list can be addressed with '[*]' for all elements
+    or '[N]' for the element at index N. Files which are not YAML are not
+    modified. This flag can be repeated.
+  
+    # Ignore server populated fields and container images.
+    kpt pkg diff --ignore-field metadata.creationTimestamp --ignore-field status \
+      --ignore-field spec.template.spec.containers[*].image
+  
+  --include-kptfile:
+    Compare the Kptfile of the packages, e.g. to show how the pipeline of the
+    upstream package changed between versions. The Kptfile is excluded from the
+    comparison by default, as are the Kptfiles of subpackages. Combine it with
+    --ignore-field to leave out the upstream and upstreamLock sections, which
+    always differ between versions. Ignored fields are removed from the Kptfile
+    as well.
+  
+    # Show the changes to the upstream Kptfile without the upstream sections.
+    kpt pkg diff @v0.8 --diff-type remote --include-kptfile \
+      --ignore-field upstream --ignore-field upstreamLock
+  
+  --include-subpackages:
+    Compare the subpackages of the packages, i.e. the directories with their
+    own Kptfile. Defaults to true. The Kptfiles of the subpackages are excluded
+    like the Kptfile of the package unless --include-kptfile is set. Set it to
+    false to compare only the content of the root package. The subpackages are
+    removed before the ignored fields, so --ignore-field only applies to the
+    resources of the root package then.
+  
+    # Show the upstream changes to the package without its subpackages.
+    kpt pkg diff @v0.8 --diff-type remote --include-subpackages=false
+  
+  --name-only:
+    Print the paths of the files which were added, removed or modified relative
+    to the package root, one per line, instead of running the diff tool. Like
+    the diff tool, the first package is compared with the second, e.g. the local
+    package with the source package for the local diff type, so a file added to
+    the local package is listed as removed from the source package. For the 3way
+    diff type, the local package is compared with the source package at target
+    version. Not supported with the json output format.
+  
+  --name-status:
+    Same as --name-only, but every path is prefixed with ` + "`" + `A` + "`" + `, ` + "`" + `D` + "`" + ` or ` + "`" + `M` + "`" + ` and a
+    tab for added, removed and modified files. It can't be combined with
+    --name-only.
+  
+  --quick:
+    Compare content hashes of the packages before running the diff tool. If the
+    packages are identical after removing the excluded files and the ignored
+    fields, the diff tool isn't run, which is much faster for large packages,
+    and the message described for --quiet is printed. Otherwise the diff tool
+    compares the packages as usual. It only applies when a diff tool compares the packages,
+    not to ` + "`" + `--output json` + "`" + ` or the built-in 3way renderer.
+  
+  --quiet:
+    Don't print a message when the diff tool finds no differences between the
+    packages. By default, a message like
+    ` + "`" + `No differences between local-v1 and remote-v1.` + "`" + ` is printed, naming the
+    compared packages after their source (local, remote or target) and version,
+    so scripts can tell that the comparison succeeded without changes. The
+    message isn't printed for the json output format, the built-in 3way renderer
+    and --name-only. Use --exit-code to detect differences by the exit code.
+  
+  --exit-code:
+    Exit with a code describing the result of the comparison, similar to
+    git diff --exit-code. Differences are only detected if the diff tool
+    follows the convention of the diff command and exits with code 1 when
+    the packages differ.
+  
+    0: There are no differences between the packages.
+    1: There are differences between the packages.
+    2: The diff tool failed. This exit code is used even if --exit-code
+       is not specified.
+  
+    # Fail a script if the local package has been modified.
+    kpt pkg diff --exit-code
+  
+  --stat:
+    Print a summary with the number of changed, added and removed files after
+    the changes, for example '3 files changed, 1 added, 2 removed'. For the
+    3way diff type, the summary compares the local package with the source
+    package at target version. Not supported with the json output format.
+  
+  --no-cache:
+    Fetch the upstream packages from the repository instead of reusing the
+    packages cached by previous invocations. Fetched packages are cached by
+    repository, directory and commit, so a branch which has moved is always
+    fetched again. Packages which haven't been used for 7 days are removed
+    from the cache.
+  
+  --stage-dir:
+    Directory to keep the fetched upstream packages in after the diff. The
+    directory is created if it doesn't exist. Like the cache, the packages are
+    kept by repository, directory and commit, so a package is only reused if
+    its ref still points to the same commit.
+  
+    # Reuse the upstream packages on later runs.
+    kpt pkg diff --stage-dir .kpt-stage
+  
+  --clean-stage-dir:
+    Remove the upstream packages used by the diff from the directory given by
+    --stage-dir afterwards. Other content of the directory is left untouched.
+  
+  --output:
+    The output format of the changes ('text' by default). Following formats
+    are supported:
+  
+    text: Shows the changes using the command line diffing tool.
+    json: Writes the changed, added and removed files together with the
+          changed lines of each file as JSON. The diffing tool is not used.
+          Not supported for the 3way diff type.
+  
+    # Show changes in the local package as JSON.
+    kpt pkg diff --output json
+  
+  --output-file:
+    Path of a file to write the changes to instead of stdout, including the
+    output of the diff tool. Parent directories are created if they don't
+    exist.
+  
+    # Write the changes in the local package as JSON to a file.
+    kpt pkg diff --output json --output-file out/diff.json
+  
+  --debug:
+    Print additional debug information on stderr, e.g. the staging directories,
+    the invocation of the diff tool and how long they took, and keep the staged
+    packages. The output of the diff isn't affected. The fetches of the
+    upstream packages are reported on stderr together with the fetched commits. Without --debug, they are only reported if stderr is a
+    terminal.
 
 Environment Variables:
 
@@ -88,6 +300,21 @@ Environment Variables:
     Defaults to <HOME>/.kpt/repos/
     On macOS and Linux <HOME> is determined by the $HOME env variable, while on
     Windows it is given by the %USERPROFILE% env variable.
+  
+  KPT_DIFF_CACHE_DIR:
+    Directory where fetched upstream packages are cached between invocations.
+    Defaults to the kpt/diff directory in the user cache directory.
+  
+  KPT_DIFF_GIT_USERNAME, KPT_DIFF_GIT_TOKEN:
+    Username and access token used to authenticate with upstream repositories
+    accessed over HTTPS. The username defaults to "git" if only a token is set.
+  
+    # Compare the package with a private upstream repository
+    KPT_DIFF_GIT_TOKEN=<token> kpt pkg diff
+  
+  KPT_DIFF_GIT_SSH_KEY:
+    Path to the private SSH key used to authenticate with upstream repositories
+    accessed over SSH.
 `
 var DiffExamples = `
 
@@ -0,0 +1,73 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"io"
+	"os"
+	"regexp"
+
+	"golang.org/x/term"
+)
+
+const (
+	// ColorAuto colors error messages only if they are written to a terminal.
+	ColorAuto = "auto"
+	// ColorAlways always colors error messages.
+	ColorAlways = "always"
+	// ColorNever never colors error messages.
+	ColorNever = "never"
+
+	// NoColorEnv is the name of the environment variable which disables
+	// colors in auto mode when set, following https://no-color.org.
+	NoColorEnv = "NO_COLOR"
+
+	ansiReset = "\x1b[0m"
+	ansiRed   = "\x1b[1;31m"
+	ansiCyan  = "\x1b[36m"
+)
+
+// SupportedColorModes are the values accepted by the --color flag.
+var SupportedColorModes = []string{ColorAuto, ColorAlways, ColorNever}
+
+var (
+	errorKeywordPattern = regexp.MustCompile(`(?m)^(\[[A-Z0-9-]+\] )?Error:`)
+	quotedPattern       = regexp.MustCompile(`"[^"\n]*"`)
+)
+
+// ColorEnabled returns true if error messages written to w should be colored
+// for the given color mode. In auto mode, messages are only colored if w is
+// a terminal and the NO_COLOR environment variable isn't set, so output that
+// is piped to other tools stays plain text.
+func ColorEnabled(mode string, w io.Writer) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if _, found := os.LookupEnv(NoColorEnv); found {
+		return false
+	}
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// Colorize highlights the "Error:" keyword and the quoted values, such as
+// paths, in a resolved error message.
+func Colorize(msg string) string {
+	msg = quotedPattern.ReplaceAllString(msg, ansiCyan+"$0"+ansiReset)
+	return errorKeywordPattern.ReplaceAllString(msg, "${1}"+ansiRed+"Error:"+ansiReset)
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColorEnabled(t *testing.T) {
+	testCases := map[string]struct {
+		mode     string
+		noColor  bool
+		expected bool
+	}{
+		"always": {
+			mode:     ColorAlways,
+			expected: true,
+		},
+		"never": {
+			mode:     ColorNever,
+			expected: false,
+		},
+		"auto doesn't color output that isn't a terminal": {
+			mode:     ColorAuto,
+			expected: false,
+		},
+		"always ignores NO_COLOR": {
+			mode:     ColorAlways,
+			noColor:  true,
+			expected: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			if tc.noColor {
+				t.Setenv(NoColorEnv, "")
+			}
+			assert.Equal(t, tc.expected, ColorEnabled(tc.mode, &bytes.Buffer{}))
+		})
+	}
+}
+
+func TestColorize(t *testing.T) {
+	testCases := map[string]struct {
+		msg      string
+		expected string
+	}{
+		"error keyword and path": {
+			msg:      `Error: No Kptfile found at "/foo/bar".`,
+			expected: "\x1b[1;31mError:\x1b[0m No Kptfile found at \x1b[36m\"/foo/bar\"\x1b[0m.",
+		},
+		"error code prefix": {
+			msg:      `[KPT-PKG-001] Error: No Kptfile found.`,
+			expected: "[KPT-PKG-001] \x1b[1;31mError:\x1b[0m No Kptfile found.",
+		},
+		"keyword in details isn't colored": {
+			msg:      "Details:\nfailed with Error: foo",
+			expected: "Details:\nfailed with Error: foo",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Colorize(tc.msg))
+		})
+	}
+}
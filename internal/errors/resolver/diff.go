@@ -0,0 +1,49 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	goerrors "errors"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/diff"
+)
+
+//nolint:gochecknoinits
+func init() {
+	AddErrorResolver(&diffErrorResolver{})
+}
+
+// diffErrorResolver maps the errors from kpt pkg diff to the documented
+// exit codes.
+type diffErrorResolver struct{}
+
+func (*diffErrorResolver) Resolve(err error) (ResolvedResult, bool) {
+	var differencesFoundError *diff.DifferencesFoundError
+	if goerrors.As(err, &differencesFoundError) {
+		// The differences have already been written to the output.
+		return ResolvedResult{
+			ExitCode: diff.ExitCodeDifferences,
+		}, true
+	}
+
+	var toolError *diff.ToolError
+	if goerrors.As(err, &toolError) {
+		return ResolvedResult{
+			Message:  "Error: " + toolError.Error(),
+			ExitCode: diff.ExitCodeToolFailure,
+		}, true
+	}
+	return ResolvedResult{}, false
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/diff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffErrorResolver(t *testing.T) {
+	testCases := map[string]struct {
+		err      error
+		resolved bool
+		expected ResolvedResult
+	}{
+		"differences found": {
+			err:      &diff.DifferencesFoundError{},
+			resolved: true,
+			expected: ResolvedResult{ExitCode: 1},
+		},
+		"wrapped tool error": {
+			err:      fmt.Errorf("wrapped: %w", &diff.ToolError{Tool: "diff", Err: fmt.Errorf("exit status 2")}),
+			resolved: true,
+			expected: ResolvedResult{
+				Message:  `Error: diff tool "diff" failed: exit status 2`,
+				ExitCode: 2,
+			},
+		},
+		"other error": {
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			res, ok := (&diffErrorResolver{}).Resolve(tc.err)
+			assert.Equal(t, tc.resolved, ok)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
@@ -25,8 +25,27 @@ import (
 //nolint:gochecknoinits
 func init() {
 	AddErrorResolver(&gitExecErrorResolver{})
+	registerTemplates(map[string]string{
+		GenericGitExecErrorTemplate:    genericGitExecError,
+		UnknownRefGitExecErrorTemplate: unknownRefGitExecError,
+		NoGitBinaryErrorTemplate:       noGitBinaryError,
+		HTTPSAuthRequiredTemplate:      httpsAuthRequired,
+		RepositoryUnavailableTemplate:  repositoryUnavailable,
+		RepositoryNotFoundTemplate:     repositoryNotFound,
+	})
 }
 
+// Keys of the templates of the messages of the gitExecErrorResolver, which can
+// be overridden with OverrideTemplate.
+const (
+	GenericGitExecErrorTemplate    = "generic-git-exec-error"
+	UnknownRefGitExecErrorTemplate = "unknown-ref-git-exec-error"
+	NoGitBinaryErrorTemplate       = "no-git-binary-error"
+	HTTPSAuthRequiredTemplate      = "https-auth-required"
+	RepositoryUnavailableTemplate  = "repository-unavailable"
+	RepositoryNotFoundTemplate     = "repository-not-found"
+)
+
 const (
 	genericGitExecError = `
 Error: Failed to execute git command {{ printf "%q " .gitcmd }}
@@ -92,17 +111,17 @@ func (*gitExecErrorResolver) Resolve(err error) (ResolvedResult, bool) {
 	var msg string
 	switch gitExecErr.Type {
 	case gitutil.UnknownReference:
-		msg = ExecuteTemplate(unknownRefGitExecError, tmplArgs)
+		msg = ExecuteTemplate(UnknownRefGitExecErrorTemplate, tmplArgs)
 	case gitutil.GitExecutableNotFound:
-		msg = ExecuteTemplate(noGitBinaryError, tmplArgs)
+		msg = ExecuteTemplate(NoGitBinaryErrorTemplate, tmplArgs)
 	case gitutil.HTTPSAuthRequired:
-		msg = ExecuteTemplate(httpsAuthRequired, tmplArgs)
+		msg = ExecuteTemplate(HTTPSAuthRequiredTemplate, tmplArgs)
 	case gitutil.RepositoryUnavailable:
-		msg = ExecuteTemplate(repositoryUnavailable, tmplArgs)
+		msg = ExecuteTemplate(RepositoryUnavailableTemplate, tmplArgs)
 	case gitutil.RepositoryNotFound:
-		msg = ExecuteTemplate(repositoryNotFound, tmplArgs)
+		msg = ExecuteTemplate(RepositoryNotFoundTemplate, tmplArgs)
 	default:
-		msg = ExecuteTemplate(genericGitExecError, tmplArgs)
+		msg = ExecuteTemplate(GenericGitExecErrorTemplate, tmplArgs)
 	}
 	return ResolvedResult{
 		Message: msg,
@@ -27,8 +27,35 @@ import (
 //nolint:gochecknoinits
 func init() {
 	AddErrorResolver(&liveErrorResolver{})
+	registerTemplates(map[string]string{
+		NoInventoryObjErrorTemplate:          noInventoryObjErrorMsg,
+		MultipleInventoryObjErrorTemplate:    multipleInventoryObjErrorMsg,
+		ResourceGroupCRDInstallErrorTemplate: resourceGroupCRDInstallErrorMsg,
+		NoResourceGroupCRDTemplate:           noResourceGroupCRDMsg,
+		InvInfoAlreadyExistsTemplate:         invInfoAlreadyExistsMsg,
+		InvInfoInRGAlreadyExistsTemplate:     invInfoInRGAlreadyExistsMsg,
+		InvInfoInKfAlreadyExistsTemplate:     invInfoInKfAlreadyExistsMsg,
+		MultipleInvInfoTemplate:              multipleInvInfoMsg,
+		InventoryInfoValidationTemplate:      inventoryInfoValidationMsg,
+		UnknownTypesTemplate:                 unknownTypesMsg,
+	})
 }
 
+// Keys of the templates of the messages of the liveErrorResolver, which can
+// be overridden with OverrideTemplate.
+const (
+	NoInventoryObjErrorTemplate          = "no-inventory-obj-error"
+	MultipleInventoryObjErrorTemplate    = "multiple-inventory-obj-error"
+	ResourceGroupCRDInstallErrorTemplate = "resource-group-crd-install-error"
+	NoResourceGroupCRDTemplate           = "no-resource-group-crd"
+	InvInfoAlreadyExistsTemplate         = "inv-info-already-exists"
+	InvInfoInRGAlreadyExistsTemplate     = "inv-info-in-rg-already-exists"
+	InvInfoInKfAlreadyExistsTemplate     = "inv-info-in-kf-already-exists"
+	MultipleInvInfoTemplate              = "multiple-inv-info"
+	InventoryInfoValidationTemplate      = "inventory-info-validation"
+	UnknownTypesTemplate                 = "unknown-types"
+)
+
 const (
 	noInventoryObjErrorMsg = `
 Error: Package uninitialized. Please run "kpt live init" command.
@@ -104,7 +131,7 @@ func (*liveErrorResolver) Resolve(err error) (ResolvedResult, bool) {
 	var noInventoryObjError *inventory.NoInventoryObjError
 	if errors.As(err, &noInventoryObjError) {
 		return ResolvedResult{
-			Message: ExecuteTemplate(noInventoryObjErrorMsg, map[string]interface{}{
+			Message: ExecuteTemplate(NoInventoryObjErrorTemplate, map[string]interface{}{
 				"err": *noInventoryObjError,
 			}),
 		}, true
@@ -113,7 +140,7 @@ func (*liveErrorResolver) Resolve(err error) (ResolvedResult, bool) {
 	var multipleInventoryObjError *inventory.MultipleInventoryObjError
 	if errors.As(err, &multipleInventoryObjError) {
 		return ResolvedResult{
-			Message: ExecuteTemplate(multipleInventoryObjErrorMsg, map[string]interface{}{
+			Message: ExecuteTemplate(MultipleInventoryObjErrorTemplate, map[string]interface{}{
 				"err": *multipleInventoryObjError,
 			}),
 		}, true
@@ -122,7 +149,7 @@ func (*liveErrorResolver) Resolve(err error) (ResolvedResult, bool) {
 	var resourceGroupCRDInstallError *cmdutil.ResourceGroupCRDInstallError
 	if errors.As(err, &resourceGroupCRDInstallError) {
 		return ResolvedResult{
-			Message: ExecuteTemplate(resourceGroupCRDInstallErrorMsg, map[string]interface{}{
+			Message: ExecuteTemplate(ResourceGroupCRDInstallErrorTemplate, map[string]interface{}{
 				"cause": resourceGroupCRDInstallError.Err.Error(),
 			}),
 		}, true
@@ -131,7 +158,7 @@ func (*liveErrorResolver) Resolve(err error) (ResolvedResult, bool) {
 	var noResourceGroupCRDError *cmdutil.NoResourceGroupCRDError
 	if errors.As(err, &noResourceGroupCRDError) {
 		return ResolvedResult{
-			Message: ExecuteTemplate(noResourceGroupCRDMsg, map[string]interface{}{
+			Message: ExecuteTemplate(NoResourceGroupCRDTemplate, map[string]interface{}{
 				"err": *noResourceGroupCRDError,
 			}),
 		}, true
@@ -140,7 +167,7 @@ func (*liveErrorResolver) Resolve(err error) (ResolvedResult, bool) {
 	var invExistsError *cmdliveinit.InvExistsError
 	if errors.As(err, &invExistsError) {
 		return ResolvedResult{
-			Message: ExecuteTemplate(invInfoAlreadyExistsMsg, map[string]interface{}{
+			Message: ExecuteTemplate(InvInfoAlreadyExistsTemplate, map[string]interface{}{
 				"err": *invExistsError,
 			}),
 		}, true
@@ -149,7 +176,7 @@ func (*liveErrorResolver) Resolve(err error) (ResolvedResult, bool) {
 	var invInfoInRGAlreadyExistsError *cmdliveinit.InvInRGExistsError
 	if errors.As(err, &invInfoInRGAlreadyExistsError) {
 		return ResolvedResult{
-			Message: ExecuteTemplate(invInfoInRGAlreadyExistsMsg, map[string]interface{}{
+			Message: ExecuteTemplate(InvInfoInRGAlreadyExistsTemplate, map[string]interface{}{
 				"err": *invInfoInRGAlreadyExistsError,
 			}),
 		}, true
@@ -158,7 +185,7 @@ func (*liveErrorResolver) Resolve(err error) (ResolvedResult, bool) {
 	var invInKfExistsError *cmdliveinit.InvInKfExistsError
 	if errors.As(err, &invInKfExistsError) {
 		return ResolvedResult{
-			Message: ExecuteTemplate(invInfoInKfAlreadyExistsMsg, map[string]interface{}{
+			Message: ExecuteTemplate(InvInfoInKfAlreadyExistsTemplate, map[string]interface{}{
 				"err": *invInKfExistsError,
 			}),
 		}, true
@@ -167,7 +194,7 @@ func (*liveErrorResolver) Resolve(err error) (ResolvedResult, bool) {
 	var multipleInvInfoError *live.MultipleInventoryInfoError
 	if errors.As(err, &multipleInvInfoError) {
 		return ResolvedResult{
-			Message: ExecuteTemplate(multipleInvInfoMsg, map[string]interface{}{
+			Message: ExecuteTemplate(MultipleInvInfoTemplate, map[string]interface{}{
 				"err": *multipleInvInfoError,
 			}),
 		}, true
@@ -176,7 +203,7 @@ func (*liveErrorResolver) Resolve(err error) (ResolvedResult, bool) {
 	var inventoryInfoValidationError *live.InventoryInfoValidationError
 	if errors.As(err, &inventoryInfoValidationError) {
 		return ResolvedResult{
-			Message: ExecuteTemplate(inventoryInfoValidationMsg, map[string]interface{}{
+			Message: ExecuteTemplate(InventoryInfoValidationTemplate, map[string]interface{}{
 				"err": *inventoryInfoValidationError,
 			}),
 		}, true
@@ -185,7 +212,7 @@ func (*liveErrorResolver) Resolve(err error) (ResolvedResult, bool) {
 	var unknownTypesError *manifestreader.UnknownTypesError
 	if errors.As(err, &unknownTypesError) {
 		return ResolvedResult{
-			Message: ExecuteTemplate(unknownTypesMsg, map[string]interface{}{
+			Message: ExecuteTemplate(UnknownTypesTemplate, map[string]interface{}{
 				"err": *unknownTypesError,
 			}),
 		}, true
@@ -0,0 +1,78 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"os"
+	"strings"
+)
+
+// localeEnvs are the environment variables the locale of the error messages
+// is read from, in order of precedence.
+var localeEnvs = []string{"LC_ALL", "LC_MESSAGES", "LANG"}
+
+// localizedTemplates are the translations of the default templates of the
+// error messages by locale, e.g. "de", and key. Messages without a
+// translation in the locale fall back to the English default template.
+var localizedTemplates = map[string]map[string]string{}
+
+// registerLocalizedTemplates registers the translations of the templates with
+// the given keys to the language or language_territory locale. They are
+// rendered with the same data as the default templates.
+func registerLocalizedTemplates(locale string, templates map[string]string) {
+	if localizedTemplates[locale] == nil {
+		localizedTemplates[locale] = map[string]string{}
+	}
+	for key, text := range templates {
+		localizedTemplates[locale][key] = text
+	}
+}
+
+// messageLocales returns the locales to look up the translations of the
+// templates in, from the most to the least specific one. For example, the
+// locale de_DE.UTF-8 is looked up as de_DE and then de. The first set
+// variable of localeEnvs is used, and the C and POSIX locales aren't
+// translated.
+func messageLocales() []string {
+	var locale string
+	for _, env := range localeEnvs {
+		if locale = os.Getenv(env); locale != "" {
+			break
+		}
+	}
+	// drop the codeset and modifier, e.g. .UTF-8 and @euro
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return nil
+	}
+	locales := []string{locale}
+	if i := strings.Index(locale, "_"); i > 0 {
+		locales = append(locales, locale[:i])
+	}
+	return locales
+}
+
+// localizedTemplate returns the translation of the template with the given
+// key to the locale of the error messages, if there is one.
+func localizedTemplate(key string) (string, bool) {
+	for _, locale := range messageLocales() {
+		if text, found := localizedTemplates[locale][key]; found {
+			return text, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,168 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestMessageLocales(t *testing.T) {
+	testCases := map[string]struct {
+		lcAll      string
+		lcMessages string
+		lang       string
+		expected   []string
+	}{
+		"no locale": {},
+		"language": {
+			lang:     "de",
+			expected: []string{"de"},
+		},
+		"language and territory": {
+			lang:     "de_DE.UTF-8",
+			expected: []string{"de_DE", "de"},
+		},
+		"modifier": {
+			lang:     "de_AT@euro",
+			expected: []string{"de_AT", "de"},
+		},
+		"C locale": {
+			lang: "C.UTF-8",
+		},
+		"POSIX locale": {
+			lang: "POSIX",
+		},
+		"LC_MESSAGES overrides LANG": {
+			lcMessages: "de_CH.UTF-8",
+			lang:       "en_US.UTF-8",
+			expected:   []string{"de_CH", "de"},
+		},
+		"LC_ALL overrides LC_MESSAGES": {
+			lcAll:      "C",
+			lcMessages: "de_DE.UTF-8",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			t.Setenv("LC_ALL", tc.lcAll)
+			t.Setenv("LC_MESSAGES", tc.lcMessages)
+			t.Setenv("LANG", tc.lang)
+			assert.Equal(t, tc.expected, messageLocales())
+		})
+	}
+}
+
+func TestLocalizedTemplates(t *testing.T) {
+	for locale, templates := range localizedTemplates {
+		for key := range templates {
+			_, found := defaultTemplates[key]
+			assert.True(t, found, "translation of unknown template %q to %q", key, locale)
+		}
+	}
+}
+
+func TestPkgErrorResolver_localized(t *testing.T) {
+	testCases := map[string]struct {
+		lang     string
+		err      error
+		expected string
+	}{
+		"no Kptfile": {
+			lang: "de_DE.UTF-8",
+			err: &pkg.KptfileError{
+				Path: "/foo/bar",
+				Err:  os.ErrNotExist,
+			},
+			expected: `Error: Kein Kptfile unter "/foo/bar" gefunden.
+
+Führen Sie "kpt pkg init /foo/bar" aus, um ein Kptfile für das Paket zu erstellen.`,
+		},
+		"deprecated Kptfile": {
+			lang: "de",
+			err: &pkg.KptfileError{
+				Path: "/foo/bar",
+				Err:  &pkg.DeprecatedKptfileError{Version: "v1alpha2"},
+			},
+			expected: `Error: Das Kptfile unter "/foo/bar" verwendet eine alte Version ("v1alpha2") des Kptfile-Schemas.
+Bitte führen Sie "kpt fn eval <PKG_PATH> -i gcr.io/kpt-fn/fix:v0.2" aus, um das Paket zu aktualisieren, und versuchen Sie es erneut.
+
+Führen Sie aus: kpt fn eval /foo/bar -i gcr.io/kpt-fn/fix:v0.2
+um das Kptfile von "v1alpha2" auf "kpt.dev/v1" zu aktualisieren, oder führen Sie
+"kpt pkg update /foo/bar" aus, falls das Upstream-Paket bereits aktualisiert wurde.`,
+		},
+		"unknown Kptfile resource": {
+			lang: "de_AT.UTF-8",
+			err: &pkg.KptfileError{
+				Path: "/foo/bar",
+				Err: &pkg.UnknownKptfileResourceError{
+					GVK:      schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Foo"},
+					Document: 1,
+					Line:     6,
+					Column:   1,
+				},
+			},
+			expected: `Error: Das Kptfile unter "/foo/bar" enthält einen unbekannten Ressourcentyp ("example.com/v1, Kind=Foo").
+Die Ressource ist YAML-Dokument 2 des Kptfiles, in Zeile 6, Spalte 1.`,
+		},
+		"Kptfile read error": {
+			lang: "de_DE.UTF-8",
+			err: &pkg.KptfileError{
+				Path: "/foo/bar",
+				Err:  fmt.Errorf("this is a test"),
+			},
+			expected: `Error: Das Kptfile unter "/foo/bar" kann nicht gelesen werden.
+
+Details:
+this is a test`,
+		},
+		"untranslated message falls back to English": {
+			lang: "de_DE.UTF-8",
+			err:  &pkg.MissingUpstreamError{Path: "/foo/bar", Field: "upstream"},
+			expected: `Error: Package at "/foo/bar" isn't linked to an upstream package, the Kptfile has no "upstream".
+Commands that compare or merge a package with its upstream, like "kpt pkg diff" and "kpt pkg update", require it.
+
+Run "kpt pkg get <REPO_URI>[.git]/<PKG_PATH>[@VERSION] <LOCAL_DEST_DIRECTORY>" to fetch a package
+that is linked to its upstream, or add the "upstream" and "upstreamLock" to the Kptfile at "/foo/bar".`,
+		},
+		"unknown locale falls back to English": {
+			lang: "fr_FR.UTF-8",
+			err: &pkg.KptfileError{
+				Path: "/foo/bar",
+				Err:  os.ErrNotExist,
+			},
+			expected: `Error: No Kptfile found at "/foo/bar".
+
+Run "kpt pkg init /foo/bar" to create a Kptfile for the package.`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			t.Setenv("LC_ALL", "")
+			t.Setenv("LC_MESSAGES", "")
+			t.Setenv("LANG", tc.lang)
+			rr, ok := (&pkgErrorResolver{}).Resolve(tc.err)
+			assert.True(t, ok)
+			assert.Equal(t, tc.expected, rr.Message)
+		})
+	}
+}
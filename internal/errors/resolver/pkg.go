@@ -25,26 +25,114 @@ import (
 //nolint:gochecknoinits
 func init() {
 	AddErrorResolver(&pkgErrorResolver{})
+	registerTemplates(map[string]string{
+		NoKptfileTemplate:                 noKptfileMsg,
+		DeprecatedV1Alpha1KptfileTemplate: deprecatedv1Alpha1KptfileMsg,
+		DeprecatedV1Alpha2KptfileTemplate: deprecatedv1Alpha2KptfileMsg,
+		UnknownKptfileResourceTemplate:    unknownKptfileResourceMsg,
+		WrongKptfileAPIVersionTemplate:    wrongKptfileAPIVersionMsg,
+		RemoteKptfileErrTemplate:          remoteKptfileErrMsg,
+		MissingUpstreamTemplate:           missingUpstreamMsg,
+		KptfileReadErrTemplate:            kptfileReadErrMsg,
+	})
 }
 
+// Keys of the templates of the messages of the pkgErrorResolver, which can
+// be overridden with OverrideTemplate.
+const (
+	NoKptfileTemplate                 = "no-kptfile"
+	DeprecatedV1Alpha1KptfileTemplate = "deprecated-v1alpha1-kptfile"
+	DeprecatedV1Alpha2KptfileTemplate = "deprecated-v1alpha2-kptfile"
+	UnknownKptfileResourceTemplate    = "unknown-kptfile-resource"
+	WrongKptfileAPIVersionTemplate    = "wrong-kptfile-api-version"
+	RemoteKptfileErrTemplate          = "remote-kptfile-err"
+	MissingUpstreamTemplate           = "missing-upstream"
+	KptfileReadErrTemplate            = "kptfile-read-err"
+)
+
+// Error codes for the errors resolved by the pkgErrorResolver. These are
+// part of the output of kpt, so existing codes must not be changed.
+const (
+	CodeNoKptfile              = "KPT-PKG-001"
+	CodeDeprecatedKptfile      = "KPT-PKG-002"
+	CodeUnknownKptfileResource = "KPT-PKG-003"
+	CodeKptfileReadErr         = "KPT-PKG-004"
+	CodeKptfileValidateErr     = "KPT-PKG-005"
+	CodeRemoteKptfileErr       = "KPT-PKG-006"
+	CodeMissingUpstream        = "KPT-PKG-007"
+	CodeWrongKptfileAPIVersion = "KPT-PKG-008"
+)
+
 const (
 	noKptfileMsg = `
 Error: No Kptfile found at {{ printf "%q" .path }}.
+{{- if hints }}
+
+Run "kpt pkg init {{ .path }}" to create a Kptfile for the package.
+{{- end }}
 `
 
 	//nolint:lll
 	deprecatedv1Alpha1KptfileMsg = `
 Error: Kptfile at {{ printf "%q" .path }} has an old version ({{ printf "%q" .version }}) of the Kptfile schema.
 Please update the package to the latest format by following https://kpt.dev/installation/migration.
+{{- if hints }}
+
+Run: kpt fn eval {{ .path }} -i gcr.io/kpt-fn/fix:v0.2
+to upgrade the Kptfile from {{ printf "%q" .version }} to {{ printf "%q" .latest }}, then review the
+remaining manual steps in the migration guide.
+{{- end }}
 `
 
 	deprecatedv1Alpha2KptfileMsg = `
 Error: Kptfile at {{ printf "%q" .path }} has an old version ({{ printf "%q" .version }}) of the Kptfile schema.
-Please run "kpt fn eval <PKG_PATH> -i gcr.io/kpt-fn/fix:v0.2 --include-meta-resources" to upgrade the package and retry.
+Please run "kpt fn eval <PKG_PATH> -i gcr.io/kpt-fn/fix:v0.2" to upgrade the package and retry.
+{{- if hints }}
+
+Run: kpt fn eval {{ .path }} -i gcr.io/kpt-fn/fix:v0.2
+to upgrade the Kptfile from {{ printf "%q" .version }} to {{ printf "%q" .latest }}, or run
+"kpt pkg update {{ .path }}" if the upstream package has already been upgraded.
+{{- end }}
 `
 
 	unknownKptfileResourceMsg = `
 Error: Kptfile at {{ printf "%q" .path }} has an unknown resource type ({{ printf "%q" .gvk.String }}).
+{{- if .line }}
+The resource is YAML document {{ .document }} of the Kptfile, at line {{ .line }}, column {{ .column }}.
+{{- end }}
+`
+
+	//nolint:lll
+	wrongKptfileAPIVersionMsg = `
+Error: Kptfile at {{ printf "%q" .path }} has the apiVersion {{ printf "%q" .version }}, but the Kptfile apiVersion is {{ printf "%q" .latest }}.
+{{- if .line }}
+The Kptfile is YAML document {{ .document }} of the file, at line {{ .line }}, column {{ .column }}.
+{{- end }}
+Please update the package to the latest format by following https://kpt.dev/installation/migration.
+{{- if hints }}
+
+If the Kptfile already follows the {{ printf "%q" .latest }} schema, change its apiVersion to {{ printf "%q" .latest }}.
+{{- end }}
+`
+
+	remoteKptfileErrMsg = `
+Error: Kptfile from repo {{ printf "%q" .repo }} can't be read.
+{{- if .notFound }}
+No Kptfile was found in the remote package. Check that the repo, directory and ref are correct.
+{{- end }}
+
+{{- template "NestedErrDetails" . }}
+`
+
+	//nolint:lll
+	missingUpstreamMsg = `
+Error: Package at {{ printf "%q" .path }} isn't linked to an upstream package, the Kptfile has no {{ printf "%q" .field }}.
+Commands that compare or merge a package with its upstream, like "kpt pkg diff" and "kpt pkg update", require it.
+{{- if hints }}
+
+Run "kpt pkg get <REPO_URI>[.git]/<PKG_PATH>[@VERSION] <LOCAL_DEST_DIRECTORY>" to fetch a package
+that is linked to its upstream, or add the "upstream" and "upstreamLock" to the Kptfile at {{ printf "%q" .path }}.
+{{- end }}
 `
 
 	kptfileReadErrMsg = `
@@ -72,18 +160,39 @@ func (*pkgErrorResolver) Resolve(err error) (ResolvedResult, bool) {
 
 	var remoteKptfileError *pkg.RemoteKptfileError
 	if errors.As(err, &remoteKptfileError) {
-		path := remoteKptfileError.RepoSpec.RepoRef()
+		notFound := errors.Is(remoteKptfileError, os.ErrNotExist)
 		tmplArgs := map[string]interface{}{
-			"path": path,
-			"err":  kptfileError,
+			"repo":     remoteKptfileError.RepoSpec.RepoRef(),
+			"err":      remoteKptfileError,
+			"notFound": notFound,
 		}
-		return resolveNestedErr(remoteKptfileError, tmplArgs)
+		code := CodeRemoteKptfileErr
+		if notFound {
+			code = CodeNoKptfile
+		}
+		return ResolvedResult{
+			Message: ExecuteTemplate(RemoteKptfileErrTemplate, tmplArgs),
+			Code:    code,
+		}, true
+	}
+
+	var missingUpstreamError *pkg.MissingUpstreamError
+	if errors.As(err, &missingUpstreamError) {
+		tmplArgs := map[string]interface{}{
+			"path":  missingUpstreamError.Path,
+			"field": missingUpstreamError.Field,
+		}
+		return ResolvedResult{
+			Message: ExecuteTemplate(MissingUpstreamTemplate, tmplArgs),
+			Code:    CodeMissingUpstream,
+		}, true
 	}
 
 	var validateError *kptfile.ValidateError
 	if errors.As(err, &validateError) {
 		return ResolvedResult{
 			Message: validateError.Error(),
+			Code:    CodeKptfileValidateErr,
 		}, true
 	}
 
@@ -93,7 +202,8 @@ func (*pkgErrorResolver) Resolve(err error) (ResolvedResult, bool) {
 func resolveNestedErr(err error, tmplArgs map[string]interface{}) (ResolvedResult, bool) {
 	if errors.Is(err, os.ErrNotExist) {
 		return ResolvedResult{
-			Message: ExecuteTemplate(noKptfileMsg, tmplArgs),
+			Message: ExecuteTemplate(NoKptfileTemplate, tmplArgs),
+			Code:    CodeNoKptfile,
 		}, true
 	}
 
@@ -101,31 +211,52 @@ func resolveNestedErr(err error, tmplArgs map[string]interface{}) (ResolvedResul
 	if errors.As(err, &deprecatedv1alpha1KptfileError) &&
 		deprecatedv1alpha1KptfileError.Version == pkg.DeprecatedKptfileVersions[0] {
 		tmplArgs["version"] = deprecatedv1alpha1KptfileError.Version
-		errMsg := deprecatedv1Alpha1KptfileMsg
+		tmplArgs["latest"] = kptfile.KptFileAPIVersion
+		errMsg := DeprecatedV1Alpha1KptfileTemplate
 		return ResolvedResult{
 			Message: ExecuteTemplate(errMsg, tmplArgs),
+			Code:    CodeDeprecatedKptfile,
 		}, true
 	}
 
 	var deprecatedv1alpha2KptfileError *pkg.DeprecatedKptfileError
 	if errors.As(err, &deprecatedv1alpha2KptfileError) &&
-		deprecatedv1alpha1KptfileError.Version == pkg.DeprecatedKptfileVersions[1] {
+		deprecatedv1alpha2KptfileError.Version == pkg.DeprecatedKptfileVersions[1] {
 		tmplArgs["version"] = deprecatedv1alpha2KptfileError.Version
-		errMsg := deprecatedv1Alpha2KptfileMsg
+		tmplArgs["latest"] = kptfile.KptFileAPIVersion
+		errMsg := DeprecatedV1Alpha2KptfileTemplate
 		return ResolvedResult{
 			Message: ExecuteTemplate(errMsg, tmplArgs),
+			Code:    CodeDeprecatedKptfile,
 		}, true
 	}
 
 	var unknownKptfileResourceError *pkg.UnknownKptfileResourceError
 	if errors.As(err, &unknownKptfileResourceError) {
-		tmplArgs["gvk"] = unknownKptfileResourceError.GVK
+		gvk := unknownKptfileResourceError.GVK
+		tmplArgs["gvk"] = gvk
+		// documents are numbered from 1 for the users
+		tmplArgs["document"] = unknownKptfileResourceError.Document + 1
+		tmplArgs["line"] = unknownKptfileResourceError.Line
+		tmplArgs["column"] = unknownKptfileResourceError.Column
+		// The right kind with an unknown group or version is most likely
+		// an outdated or mistyped apiVersion rather than another resource.
+		if gvk.Kind == kptfile.KptFileKind {
+			tmplArgs["version"] = gvk.GroupVersion().String()
+			tmplArgs["latest"] = kptfile.KptFileAPIVersion
+			return ResolvedResult{
+				Message: ExecuteTemplate(WrongKptfileAPIVersionTemplate, tmplArgs),
+				Code:    CodeWrongKptfileAPIVersion,
+			}, true
+		}
 		return ResolvedResult{
-			Message: ExecuteTemplate(unknownKptfileResourceMsg, tmplArgs),
+			Message: ExecuteTemplate(UnknownKptfileResourceTemplate, tmplArgs),
+			Code:    CodeUnknownKptfileResource,
 		}, true
 	}
 
 	return ResolvedResult{
-		Message: ExecuteTemplate(kptfileReadErrMsg, tmplArgs),
+		Message: ExecuteTemplate(KptfileReadErrTemplate, tmplArgs),
+		Code:    CodeKptfileReadErr,
 	}, true
 }
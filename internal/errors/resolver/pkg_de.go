@@ -0,0 +1,90 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+//nolint:gochecknoinits
+func init() {
+	registerLocalizedTemplates("de", map[string]string{
+		NoKptfileTemplate:                 noKptfileMsgDE,
+		DeprecatedV1Alpha1KptfileTemplate: deprecatedv1Alpha1KptfileMsgDE,
+		DeprecatedV1Alpha2KptfileTemplate: deprecatedv1Alpha2KptfileMsgDE,
+		UnknownKptfileResourceTemplate:    unknownKptfileResourceMsgDE,
+		WrongKptfileAPIVersionTemplate:    wrongKptfileAPIVersionMsgDE,
+		KptfileReadErrTemplate:            kptfileReadErrMsgDE,
+	})
+}
+
+// German translations of the templates of the pkgErrorResolver. The "Error:"
+// keyword isn't translated, so the messages are colored and can be searched
+// in every locale.
+const (
+	noKptfileMsgDE = `
+Error: Kein Kptfile unter {{ printf "%q" .path }} gefunden.
+{{- if hints }}
+
+Führen Sie "kpt pkg init {{ .path }}" aus, um ein Kptfile für das Paket zu erstellen.
+{{- end }}
+`
+
+	//nolint:lll
+	deprecatedv1Alpha1KptfileMsgDE = `
+Error: Das Kptfile unter {{ printf "%q" .path }} verwendet eine alte Version ({{ printf "%q" .version }}) des Kptfile-Schemas.
+Bitte aktualisieren Sie das Paket anhand von https://kpt.dev/installation/migration auf das neueste Format.
+{{- if hints }}
+
+Führen Sie aus: kpt fn eval {{ .path }} -i gcr.io/kpt-fn/fix:v0.2
+um das Kptfile von {{ printf "%q" .version }} auf {{ printf "%q" .latest }} zu aktualisieren, und prüfen Sie
+anschließend die verbleibenden manuellen Schritte in der Migrationsanleitung.
+{{- end }}
+`
+
+	//nolint:lll
+	deprecatedv1Alpha2KptfileMsgDE = `
+Error: Das Kptfile unter {{ printf "%q" .path }} verwendet eine alte Version ({{ printf "%q" .version }}) des Kptfile-Schemas.
+Bitte führen Sie "kpt fn eval <PKG_PATH> -i gcr.io/kpt-fn/fix:v0.2" aus, um das Paket zu aktualisieren, und versuchen Sie es erneut.
+{{- if hints }}
+
+Führen Sie aus: kpt fn eval {{ .path }} -i gcr.io/kpt-fn/fix:v0.2
+um das Kptfile von {{ printf "%q" .version }} auf {{ printf "%q" .latest }} zu aktualisieren, oder führen Sie
+"kpt pkg update {{ .path }}" aus, falls das Upstream-Paket bereits aktualisiert wurde.
+{{- end }}
+`
+
+	unknownKptfileResourceMsgDE = `
+Error: Das Kptfile unter {{ printf "%q" .path }} enthält einen unbekannten Ressourcentyp ({{ printf "%q" .gvk.String }}).
+{{- if .line }}
+Die Ressource ist YAML-Dokument {{ .document }} des Kptfiles, in Zeile {{ .line }}, Spalte {{ .column }}.
+{{- end }}
+`
+
+	//nolint:lll
+	wrongKptfileAPIVersionMsgDE = `
+Error: Das Kptfile unter {{ printf "%q" .path }} hat die apiVersion {{ printf "%q" .version }}, die apiVersion des Kptfiles ist jedoch {{ printf "%q" .latest }}.
+{{- if .line }}
+Das Kptfile ist YAML-Dokument {{ .document }} der Datei, in Zeile {{ .line }}, Spalte {{ .column }}.
+{{- end }}
+Bitte aktualisieren Sie das Paket anhand von https://kpt.dev/installation/migration auf das neueste Format.
+{{- if hints }}
+
+Falls das Kptfile bereits dem Schema {{ printf "%q" .latest }} entspricht, ändern Sie seine apiVersion auf {{ printf "%q" .latest }}.
+{{- end }}
+`
+
+	kptfileReadErrMsgDE = `
+Error: Das Kptfile unter {{ printf "%q" .path }} kann nicht gelesen werden.
+
+{{- template "NestedErrDetails" . }}
+`
+)
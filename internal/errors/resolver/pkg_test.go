@@ -20,21 +20,126 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/GoogleContainerTools/kpt/internal/errors"
 	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/git"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func TestPkgErrorResolver(t *testing.T) {
 	testCases := map[string]struct {
 		err      error
+		noHints  bool
 		expected string
+		code     string
 	}{
 		"kptfileError has nested ErrNotExist": {
 			err: &pkg.KptfileError{
 				Path: "/foo/bar",
 				Err:  os.ErrNotExist,
 			},
+			expected: `
+Error: No Kptfile found at "/foo/bar".
+
+Run "kpt pkg init /foo/bar" to create a Kptfile for the package.
+`,
+			code: CodeNoKptfile,
+		},
+		"kptfileError has nested ErrNotExist without hints": {
+			err: &pkg.KptfileError{
+				Path: "/foo/bar",
+				Err:  os.ErrNotExist,
+			},
+			noHints:  true,
 			expected: "Error: No Kptfile found at \"/foo/bar\".",
+			code:     CodeNoKptfile,
+		},
+		"kptfileError has nested v1alpha2 DeprecatedKptfileError": {
+			err: &pkg.KptfileError{
+				Path: "/foo/bar",
+				Err:  &pkg.DeprecatedKptfileError{Version: "v1alpha2"},
+			},
+			expected: `
+Error: Kptfile at "/foo/bar" has an old version ("v1alpha2") of the Kptfile schema.
+Please run "kpt fn eval <PKG_PATH> -i gcr.io/kpt-fn/fix:v0.2" to upgrade the package and retry.
+
+Run: kpt fn eval /foo/bar -i gcr.io/kpt-fn/fix:v0.2
+to upgrade the Kptfile from "v1alpha2" to "kpt.dev/v1", or run
+"kpt pkg update /foo/bar" if the upstream package has already been upgraded.
+`,
+			code: CodeDeprecatedKptfile,
+		},
+		"kptfileError has nested v1alpha1 DeprecatedKptfileError without hints": {
+			err: &pkg.KptfileError{
+				Path: "/foo/bar",
+				Err:  &pkg.DeprecatedKptfileError{Version: "v1alpha1"},
+			},
+			noHints: true,
+			expected: `
+Error: Kptfile at "/foo/bar" has an old version ("v1alpha1") of the Kptfile schema.
+Please update the package to the latest format by following https://kpt.dev/installation/migration.
+`,
+			code: CodeDeprecatedKptfile,
+		},
+		"kptfileError has nested UnknownKptfileResourceError": {
+			err: &pkg.KptfileError{
+				Path: "/foo/bar",
+				Err: &pkg.UnknownKptfileResourceError{
+					GVK:      schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Foo"},
+					Document: 1,
+					Line:     6,
+					Column:   1,
+				},
+			},
+			expected: `
+Error: Kptfile at "/foo/bar" has an unknown resource type ("example.com/v1, Kind=Foo").
+The resource is YAML document 2 of the Kptfile, at line 6, column 1.
+`,
+			code: CodeUnknownKptfileResource,
+		},
+		"kptfileError has nested UnknownKptfileResourceError without position": {
+			err: &pkg.KptfileError{
+				Path: "/foo/bar",
+				Err: &pkg.UnknownKptfileResourceError{
+					GVK: schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Foo"},
+				},
+			},
+			expected: `
+Error: Kptfile at "/foo/bar" has an unknown resource type ("example.com/v1, Kind=Foo").
+`,
+			code: CodeUnknownKptfileResource,
+		},
+		"kptfileError has nested UnknownKptfileResourceError with the Kptfile kind": {
+			err: &pkg.KptfileError{
+				Path: "/foo/bar",
+				Err: &pkg.UnknownKptfileResourceError{
+					GVK:    schema.GroupVersionKind{Group: "kpt.dev", Version: "v1alpha3", Kind: "Kptfile"},
+					Line:   1,
+					Column: 1,
+				},
+			},
+			expected: `
+Error: Kptfile at "/foo/bar" has the apiVersion "kpt.dev/v1alpha3", but the Kptfile apiVersion is "kpt.dev/v1".
+The Kptfile is YAML document 1 of the file, at line 1, column 1.
+Please update the package to the latest format by following https://kpt.dev/installation/migration.
+
+If the Kptfile already follows the "kpt.dev/v1" schema, change its apiVersion to "kpt.dev/v1".
+`,
+			code: CodeWrongKptfileAPIVersion,
+		},
+		"kptfileError has nested UnknownKptfileResourceError with the Kptfile kind without hints": {
+			err: &pkg.KptfileError{
+				Path: "/foo/bar",
+				Err: &pkg.UnknownKptfileResourceError{
+					GVK: schema.GroupVersionKind{Group: "kpt.io", Version: "v1", Kind: "Kptfile"},
+				},
+			},
+			noHints: true,
+			expected: `Error: Kptfile at "/foo/bar" has the apiVersion "kpt.io/v1", but the Kptfile apiVersion is "kpt.dev/v1".
+Please update the package to the latest format by following https://kpt.dev/installation/migration.`,
+			code: CodeWrongKptfileAPIVersion,
 		},
 		"kptfileError doesn't have a known nested error": {
 			err: &pkg.KptfileError{
@@ -47,22 +152,82 @@ Error: Kptfile at "/some/path" can't be read.
 Details:
 this is a test
 `,
+			code: CodeKptfileReadErr,
+		},
+		"remoteKptfileError has nested ErrNotExist": {
+			err: &pkg.RemoteKptfileError{
+				RepoSpec: &git.RepoSpec{
+					OrgRepo: "github.com/GoogleContainerTools/kpt",
+					Path:    "package-examples/wordpress",
+					Ref:     "v1.0",
+				},
+				Err: fmt.Errorf("fetching package: %w", os.ErrNotExist),
+			},
+			expected: `
+Error: Kptfile from repo "github.com/GoogleContainerTools/kpt/package-examples/wordpress@v1.0" can't be read.
+No Kptfile was found in the remote package. Check that the repo, directory and ref are correct.
+
+Details:
+fetching package: file does not exist
+`,
+			code: CodeNoKptfile,
+		},
+		"remoteKptfileError doesn't have a known nested error": {
+			err: &pkg.RemoteKptfileError{
+				RepoSpec: &git.RepoSpec{
+					OrgRepo: "github.com/GoogleContainerTools/kpt",
+				},
+				Err: fmt.Errorf("authentication required"),
+			},
+			expected: `
+Error: Kptfile from repo "github.com/GoogleContainerTools/kpt" can't be read.
+
+Details:
+authentication required
+`,
+			code: CodeRemoteKptfileErr,
 		},
 		"kptfileError without nested error": {
 			err: &pkg.KptfileError{
 				Path: "/some/path",
 			},
 			expected: "Error: Kptfile at \"/some/path\" can't be read.",
+			code:     CodeKptfileReadErr,
+		},
+		"missingUpstreamError wrapped in a kpt error": {
+			err: errors.E(errors.Op("update.Run"), types.UniquePath("/foo/bar"),
+				&pkg.MissingUpstreamError{Path: "/foo/bar", Field: "upstream.git"}),
+			expected: `
+Error: Package at "/foo/bar" isn't linked to an upstream package, the Kptfile has no "upstream.git".
+Commands that compare or merge a package with its upstream, like "kpt pkg diff" and "kpt pkg update", require it.
+
+Run "kpt pkg get <REPO_URI>[.git]/<PKG_PATH>[@VERSION] <LOCAL_DEST_DIRECTORY>" to fetch a package
+that is linked to its upstream, or add the "upstream" and "upstreamLock" to the Kptfile at "/foo/bar".
+`,
+			code: CodeMissingUpstream,
+		},
+		"missingUpstreamError without hints": {
+			err:     &pkg.MissingUpstreamError{Path: "/foo/bar", Field: "upstream"},
+			noHints: true,
+			expected: `
+Error: Package at "/foo/bar" isn't linked to an upstream package, the Kptfile has no "upstream".
+Commands that compare or merge a package with its upstream, like "kpt pkg diff" and "kpt pkg update", require it.
+`,
+			code: CodeMissingUpstream,
 		},
 	}
 
 	for tn, tc := range testCases {
 		t.Run(tn, func(t *testing.T) {
+			if tc.noHints {
+				t.Setenv(NoHintsEnv, "true")
+			}
 			res, ok := (&pkgErrorResolver{}).Resolve(tc.err)
 			if !ok {
 				t.Error("expected error to be resolved, but it wasn't")
 			}
 			assert.Equal(t, strings.TrimSpace(tc.expected), strings.TrimSpace(res.Message))
+			assert.Equal(t, tc.code, res.Code)
 		})
 	}
 }
@@ -14,6 +14,17 @@
 
 package resolver
 
+import (
+	"fmt"
+	"os"
+)
+
+// ErrorCodesEnv is the name of the environment variable that enables
+// prefixing resolved error messages with their error code, e.g.
+// "[KPT-PKG-001] Error: No Kptfile found at ...". This makes it easy to
+// search logs for specific failures.
+const ErrorCodesEnv = "KPT_ERROR_CODES"
+
 // errorResolvers is the list of known resolvers for kpt errors.
 var errorResolvers []ErrorResolver
 
@@ -35,15 +46,29 @@ func ResolveError(err error) (ResolvedResult, bool) {
 			rr.ExitCode = 1
 		}
 		if found {
+			if rr.Code != "" && rr.Message != "" && errorCodesEnabled() {
+				rr.Message = fmt.Sprintf("[%s] %s", rr.Code, rr.Message)
+			}
 			return rr, true
 		}
 	}
 	return ResolvedResult{}, false
 }
 
+// errorCodesEnabled returns true if resolved error messages should be
+// prefixed with their error code.
+func errorCodesEnabled() bool {
+	e := os.Getenv(ErrorCodesEnv)
+	return e == "true" || e == "1"
+}
+
 type ResolvedResult struct {
 	Message  string
 	ExitCode int
+	// Code is a stable identifier for the kind of error, which can be used
+	// by tools to categorize failures without parsing the message. It is
+	// empty for errors that haven't been assigned a code.
+	Code string
 }
 
 // ErrorResolver is an interface that allows kpt to resolve an error into
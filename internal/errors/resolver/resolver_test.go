@@ -15,9 +15,11 @@
 package resolver
 
 import (
+	"os"
 	"testing"
 
 	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -33,6 +35,24 @@ func TestResolveError_DefaultExitCode(t *testing.T) {
 	assert.Equal(t, 1, rr.ExitCode)
 }
 
+func TestResolveError_ErrorCodes(t *testing.T) {
+	err := &pkg.KptfileError{
+		Path: "/foo/bar",
+		Err:  os.ErrNotExist,
+	}
+	t.Setenv(NoHintsEnv, "true")
+
+	rr, ok := ResolveError(err)
+	assert.True(t, ok)
+	assert.Equal(t, CodeNoKptfile, rr.Code)
+	assert.Equal(t, "Error: No Kptfile found at \"/foo/bar\".", rr.Message)
+
+	t.Setenv(ErrorCodesEnv, "true")
+	rr, ok = ResolveError(err)
+	assert.True(t, ok)
+	assert.Equal(t, "[KPT-PKG-001] Error: No Kptfile found at \"/foo/bar\".", rr.Message)
+}
+
 type TestErrorResolver struct{}
 
 func (t *TestErrorResolver) Resolve(err error) (ResolvedResult, bool) {
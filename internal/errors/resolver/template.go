@@ -17,12 +17,29 @@ package resolver
 import (
 	"bytes"
 	"fmt"
+	"os"
+	"sort"
 	"strings"
+	"sync"
 	"text/template"
 )
 
+// NoHintsEnv is the name of the environment variable that suppresses the
+// suggested fixes included in error messages. This is useful for tools
+// that consume the output of kpt.
+const NoHintsEnv = "KPT_NO_ERROR_HINTS"
+
+// hintsEnabled returns true unless suggested fixes have been suppressed
+// through the NoHintsEnv environment variable.
+func hintsEnabled() bool {
+	e := os.Getenv(NoHintsEnv)
+	return e != "true" && e != "1"
+}
+
 var baseTemplate = func() *template.Template {
-	tmpl := template.New("base")
+	tmpl := template.New("base").Funcs(template.FuncMap{
+		"hints": hintsEnabled,
+	})
 	tmpl = template.Must(tmpl.Parse(detailsHelperTemplate))
 	tmpl = template.Must(tmpl.Parse(nestedErrTemplate))
 	return tmpl
@@ -64,11 +81,88 @@ var (
 `
 )
 
-// ExecuteTemplate takes the provided template string and data, and renders
-// the template. If something goes wrong, it panics.
-func ExecuteTemplate(text string, data interface{}) string {
+var (
+	// defaultTemplates are the templates of the error messages by key,
+	// registered by the resolvers.
+	defaultTemplates = map[string]string{}
+
+	templatesMu sync.RWMutex
+	// overriddenTemplates are the templates which replace the default ones,
+	// set with OverrideTemplate.
+	overriddenTemplates = map[string]string{}
+)
+
+// registerTemplates registers the default templates of the error messages by
+// key. It panics if a key is already registered.
+func registerTemplates(templates map[string]string) {
+	for key, text := range templates {
+		if _, found := defaultTemplates[key]; found {
+			panic(fmt.Errorf("template %q is already registered", key))
+		}
+		defaultTemplates[key] = text
+	}
+}
+
+// TemplateKeys returns the sorted keys of the templates of the error messages
+// which can be overridden with OverrideTemplate.
+func TemplateKeys() []string {
+	var keys []string
+	for key := range defaultTemplates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// OverrideTemplate replaces the template of the error messages with the given
+// key, so tools embedding kpt can change their wording or link their own
+// documentation. The template is rendered with the same data as the default
+// one and can use the same functions and subtemplates. An empty text restores
+// the default template. An error is returned if the key is unknown or the
+// template can't be parsed.
+func OverrideTemplate(key, text string) error {
+	if _, found := defaultTemplates[key]; !found {
+		return fmt.Errorf("unknown error message template %q", key)
+	}
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	if text == "" {
+		delete(overriddenTemplates, key)
+		return nil
+	}
+	if _, err := template.Must(baseTemplate.Clone()).Parse(text); err != nil {
+		return fmt.Errorf("invalid error message template %q: %w", key, err)
+	}
+	overriddenTemplates[key] = text
+	return nil
+}
+
+// lookupTemplate returns the template with the given key, which is the
+// overridden template if there is one, or else its translation to the locale
+// of the error messages. It panics if the key is unknown.
+func lookupTemplate(key string) string {
+	templatesMu.RLock()
+	text, found := overriddenTemplates[key]
+	templatesMu.RUnlock()
+	if found {
+		return text
+	}
+	if text, found = localizedTemplate(key); found {
+		return text
+	}
+	text, found = defaultTemplates[key]
+	if !found {
+		panic(fmt.Errorf("unknown error message template %q", key))
+	}
+	return text
+}
+
+// ExecuteTemplate looks up the template with the provided key, which may have
+// been overridden with OverrideTemplate, and renders it with data. If
+// something goes wrong, it panics.
+func ExecuteTemplate(key string, data interface{}) string {
 	tmpl := template.Must(baseTemplate.Clone())
-	template.Must(tmpl.Parse(text))
+	template.Must(tmpl.Parse(lookupTemplate(key)))
 
 	var b bytes.Buffer
 	execErr := tmpl.Execute(&b, data)
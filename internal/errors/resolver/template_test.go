@@ -0,0 +1,65 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"os"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverrideTemplate(t *testing.T) {
+	defer func() {
+		assert.NoError(t, OverrideTemplate(NoKptfileTemplate, ""))
+	}()
+	kfErr := &pkg.KptfileError{
+		Path: "/foo/bar",
+		Err:  os.ErrNotExist,
+	}
+	t.Setenv(NoHintsEnv, "true")
+
+	assert.Contains(t, TemplateKeys(), NoKptfileTemplate)
+
+	assert.NoError(t, OverrideTemplate(NoKptfileTemplate, `
+Error: {{ printf "%q" .path }} isn't a package.
+{{- if hints }}
+See https://example.com/packages.
+{{- end }}
+`))
+	rr, ok := ResolveError(kfErr)
+	assert.True(t, ok)
+	assert.Equal(t, CodeNoKptfile, rr.Code)
+	assert.Equal(t, `Error: "/foo/bar" isn't a package.`, rr.Message)
+
+	// an empty template restores the default one
+	assert.NoError(t, OverrideTemplate(NoKptfileTemplate, ""))
+	rr, ok = ResolveError(kfErr)
+	assert.True(t, ok)
+	assert.Equal(t, "Error: No Kptfile found at \"/foo/bar\".", rr.Message)
+
+	err := OverrideTemplate("unknown", "Error: unknown")
+	if assert.Error(t, err) {
+		assert.Equal(t, `unknown error message template "unknown"`, err.Error())
+	}
+	err = OverrideTemplate(NoKptfileTemplate, "{{ .path")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid error message template "no-kptfile"`)
+	}
+	rr, ok = ResolveError(kfErr)
+	assert.True(t, ok)
+	assert.Equal(t, "Error: No Kptfile found at \"/foo/bar\".", rr.Message)
+}
@@ -22,8 +22,19 @@ import (
 //nolint:gochecknoinits
 func init() {
 	AddErrorResolver(&updateErrorResolver{})
+	registerTemplates(map[string]string{
+		PkgNotGitRepoTemplate: pkgNotGitRepo,
+		PkgRepoDirtyTemplate:  pkgRepoDirty,
+	})
 }
 
+// Keys of the templates of the messages of the updateErrorResolver, which can
+// be overridden with OverrideTemplate.
+const (
+	PkgNotGitRepoTemplate = "pkg-not-git-repo"
+	PkgRepoDirtyTemplate  = "pkg-repo-dirty"
+)
+
 var (
 	//nolint:lll
 	pkgNotGitRepo = `
@@ -44,14 +55,14 @@ func (*updateErrorResolver) Resolve(err error) (ResolvedResult, bool) {
 
 	var pkgNotGitRepoError *update.PkgNotGitRepoError
 	if errors.As(err, &pkgNotGitRepoError) {
-		msg = ExecuteTemplate(pkgNotGitRepo, map[string]interface{}{
+		msg = ExecuteTemplate(PkgNotGitRepoTemplate, map[string]interface{}{
 			"repo": pkgNotGitRepoError.Path,
 		})
 	}
 
 	var pkgRepoDirtyError *update.PkgRepoDirtyError
 	if errors.As(err, &pkgRepoDirtyError) {
-		msg = ExecuteTemplate(pkgRepoDirty, map[string]interface{}{
+		msg = ExecuteTemplate(PkgRepoDirtyTemplate, map[string]interface{}{
 			"repo": pkgRepoDirtyError.Path,
 		})
 	}
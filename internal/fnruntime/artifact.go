@@ -0,0 +1,136 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Media types of the OCI artifacts function references may resolve to.
+const (
+	dockerManifestMediaType     = "application/vnd.docker.distribution.manifest.v2+json"
+	dockerManifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+	dockerConfigMediaType       = "application/vnd.docker.container.image.v1+json"
+	ociManifestMediaType        = "application/vnd.oci.image.manifest.v1+json"
+	ociIndexMediaType           = "application/vnd.oci.image.index.v1+json"
+	ociConfigMediaType          = "application/vnd.oci.image.config.v1+json"
+
+	// wasmMediaTypePrefix is the prefix of the media types of wasm modules
+	// stored as OCI artifacts, e.g. application/vnd.wasm.config.v1+json.
+	wasmMediaTypePrefix = "application/vnd.wasm."
+)
+
+// UnsupportedArtifactError is returned when the image of a function refers
+// to an OCI artifact which isn't a runnable container image.
+type UnsupportedArtifactError struct {
+	Image     string
+	MediaType string
+}
+
+func (e *UnsupportedArtifactError) Error() string {
+	if e.IsWasm() {
+		return fmt.Sprintf("function %q is a wasm module (media type %q), wasm functions are not supported yet",
+			e.Image, e.MediaType)
+	}
+	return fmt.Sprintf("function %q has an unsupported media type %q, it must be a container image",
+		e.Image, e.MediaType)
+}
+
+// IsWasm returns true if the artifact is a wasm module.
+func (e *UnsupportedArtifactError) IsWasm() bool {
+	return strings.HasPrefix(e.MediaType, wasmMediaTypePrefix)
+}
+
+// ociManifest contains the fields of an image manifest or index which tell
+// what kind of artifact it describes.
+type ociManifest struct {
+	MediaType    string `json:"mediaType"`
+	ArtifactType string `json:"artifactType"`
+	Config       struct {
+		MediaType string `json:"mediaType"`
+	} `json:"config"`
+}
+
+// artifactMediaType returns the media type of the artifact described by the
+// manifest, or an empty string if it is a runnable container image.
+func artifactMediaType(manifest []byte) (string, error) {
+	var m ociManifest
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return "", err
+	}
+	if m.ArtifactType != "" {
+		return m.ArtifactType, nil
+	}
+	switch m.MediaType {
+	case dockerManifestListMediaType, ociIndexMediaType:
+		// multi-platform images
+		return "", nil
+	case dockerManifestMediaType, ociManifestMediaType, "":
+		// OCI manifests don't need to specify their media type
+	default:
+		return m.MediaType, nil
+	}
+	switch m.Config.MediaType {
+	case dockerConfigMediaType, ociConfigMediaType:
+		return "", nil
+	case "":
+		return m.MediaType, nil
+	default:
+		return m.Config.MediaType, nil
+	}
+}
+
+// checkedArtifacts caches the results of checkArtifact by image.
+var checkedArtifacts sync.Map
+
+// checkArtifact returns an UnsupportedArtifactError if the image of the
+// function has to be pulled and refers to an OCI artifact which isn't a
+// container image. Local images are container images. Failures to inspect
+// the remote manifest are ignored, so pulling the image reports them.
+func (f *ContainerFn) checkArtifact(ctx context.Context) error {
+	if f.ImagePullPolicy == NeverPull {
+		return nil
+	}
+	if err, found := checkedArtifacts.Load(f.Image); found {
+		if err == nil {
+			return nil
+		}
+		return err.(error)
+	}
+	if f.ImagePullPolicy != AlwaysPull && imageExists(ctx, f.Image) {
+		checkedArtifacts.Store(f.Image, nil)
+		return nil
+	}
+	out, err := exec.CommandContext(ctx, dockerBin, f.dockerArgs("manifest", "inspect", f.Image)...).Output()
+	if err != nil {
+		return nil
+	}
+	mediaType, err := artifactMediaType(out)
+	if err != nil {
+		return nil
+	}
+	if mediaType == "" {
+		checkedArtifacts.Store(f.Image, nil)
+		return nil
+	}
+	err = &UnsupportedArtifactError{Image: f.Image, MediaType: mediaType}
+	checkedArtifacts.Store(f.Image, err)
+	return err
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArtifactMediaType(t *testing.T) {
+	testCases := map[string]struct {
+		manifest  string
+		mediaType string
+	}{
+		"docker image": {
+			manifest: `{"mediaType":"application/vnd.docker.distribution.manifest.v2+json",
+"config":{"mediaType":"application/vnd.docker.container.image.v1+json"}}`,
+		},
+		"oci image without manifest media type": {
+			manifest: `{"config":{"mediaType":"application/vnd.oci.image.config.v1+json"}}`,
+		},
+		"multi-platform image": {
+			manifest: `{"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`,
+		},
+		"wasm module": {
+			manifest: `{"mediaType":"application/vnd.oci.image.manifest.v1+json",
+"config":{"mediaType":"application/vnd.wasm.config.v1+json"}}`,
+			mediaType: "application/vnd.wasm.config.v1+json",
+		},
+		"artifact type": {
+			manifest: `{"mediaType":"application/vnd.oci.image.manifest.v1+json",
+"artifactType":"application/vnd.example.fn.v1",
+"config":{"mediaType":"application/vnd.oci.empty.v1+json"}}`,
+			mediaType: "application/vnd.example.fn.v1",
+		},
+		"unknown manifest": {
+			manifest:  `{"mediaType":"application/vnd.example.manifest.v1+json"}`,
+			mediaType: "application/vnd.example.manifest.v1+json",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			mediaType, err := artifactMediaType([]byte(tc.manifest))
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.mediaType, mediaType)
+			}
+		})
+	}
+}
+
+func TestUnsupportedArtifactError(t *testing.T) {
+	err := &UnsupportedArtifactError{Image: "example.com/fn:v1", MediaType: "application/vnd.wasm.config.v1+json"}
+	assert.True(t, err.IsWasm())
+	assert.Equal(t, `function "example.com/fn:v1" is a wasm module (media type "application/vnd.wasm.config.v1+json"), `+
+		`wasm functions are not supported yet`, err.Error())
+
+	err = &UnsupportedArtifactError{Image: "example.com/fn:v1", MediaType: "application/vnd.example.fn.v1"}
+	assert.False(t, err.IsWasm())
+	assert.Equal(t, `function "example.com/fn:v1" has an unsupported media type "application/vnd.example.fn.v1", `+
+		`it must be a container image`, err.Error())
+}
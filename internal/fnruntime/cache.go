@@ -0,0 +1,175 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cacheable returns true if the output of the function is cached in
+// CacheDir. Functions with network access or mounts aren't cached, as their
+// output may depend on more than the image and the input.
+func (f *ContainerFn) cacheable() bool {
+	return f.CacheDir != "" && !f.Perm.AllowNetwork && len(f.StorageMounts) == 0
+}
+
+// runCached runs the function like run, unless CacheDir contains the output
+// of the function for the same image digest and input, in which case the
+// cached output is written instead. The output of successful runs is cached.
+// The function is run without the cache if its image can't be inspected.
+func (f *ContainerFn) runCached(reader io.Reader, writer io.Writer) error {
+	digest, err := f.localImageDigest(contextOrBackground(f.Ctx))
+	if err != nil {
+		return err
+	}
+	if digest == "" {
+		return f.run(reader, writer)
+	}
+	input, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	key := f.cacheKey(digest, input)
+	if !f.RefreshCache {
+		if output, stderr, found := readCacheEntry(f.CacheDir, key); found {
+			if len(stderr) > 0 {
+				f.FnResult.Stderr = string(stderr)
+			}
+			_, err := writer.Write(output)
+			return err
+		}
+	}
+	// the image is present locally now, so it isn't pulled again
+	fn := *f
+	fn.ImagePullPolicy = NeverPull
+	fn.PullRetries = 0
+	var output bytes.Buffer
+	if err := fn.run(bytes.NewReader(input), io.MultiWriter(writer, &output)); err != nil {
+		return err
+	}
+	if err := writeCacheEntry(f.CacheDir, key, output.Bytes(), []byte(f.FnResult.Stderr)); err != nil {
+		return fmt.Errorf("cannot cache the output of function %q: %w", f.Image, err)
+	}
+	return nil
+}
+
+// localImageDigest returns the digest of the image of the function, pulling
+// the image first as required by ImagePullPolicy. The digest is the ID of the
+// local image, so a tag pointing to another image doesn't reuse its outputs.
+// An empty digest is returned if the image isn't present and can't be pulled.
+func (f *ContainerFn) localImageDigest(ctx context.Context) (string, error) {
+	if err := f.checkArtifact(ctx); err != nil {
+		return "", err
+	}
+	if f.ImagePullPolicy != NeverPull && (f.ImagePullPolicy == AlwaysPull || !imageExists(ctx, f.Image)) {
+		if err := f.pullImage(ctx); err != nil {
+			return "", err
+		}
+	}
+	out, err := exec.CommandContext(ctx, dockerBin, "image", "inspect", "--format", "{{.Id}}", f.Image).Output()
+	if err != nil {
+		return "", nil
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// cacheKey returns the key of the output of the function for input, which is
+// the ResourceList containing the resources and the functionConfig. It also
+// covers the settings of the container which may change the output.
+func (f *ContainerFn) cacheKey(digest string, input []byte) string {
+	env := append([]string{}, f.Env...)
+	sort.Strings(env)
+	h := sha256.New()
+	for _, s := range append([]string{digest, f.UIDGID}, env...) {
+		// the length prefix keeps the fields apart
+		fmt.Fprintf(h, "%d:%s\n", len(s), s)
+	}
+	h.Write(input)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cacheEntryPaths returns the paths of the files containing the output and
+// the stderr of the cache entry with key.
+func cacheEntryPaths(dir, key string) (string, string) {
+	p := filepath.Join(dir, key[:2], key)
+	return p + ".yaml", p + ".stderr"
+}
+
+// readCacheEntry returns the output and the stderr of the cache entry with
+// key, or false if there is no such entry.
+func readCacheEntry(dir, key string) ([]byte, []byte, bool) {
+	outputPath, stderrPath := cacheEntryPaths(dir, key)
+	output, err := ioutil.ReadFile(outputPath)
+	if err != nil {
+		return nil, nil, false
+	}
+	stderr, err := ioutil.ReadFile(stderrPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, false
+	}
+	return output, stderr, true
+}
+
+// writeCacheEntry stores the output and the stderr of the function run with
+// the key. The stderr is written first, and the files are renamed into place,
+// so a concurrent readCacheEntry never reads a partial entry.
+func writeCacheEntry(dir, key string, output, stderr []byte) error {
+	outputPath, stderrPath := cacheEntryPaths(dir, key)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return err
+	}
+	if len(stderr) == 0 {
+		if err := os.Remove(stderrPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if err := writeFileAtomically(stderrPath, stderr); err != nil {
+		return err
+	}
+	return writeFileAtomically(outputPath, output)
+}
+
+// writeFileAtomically writes data to a temporary file next to path and renames
+// it to path.
+func writeFileAtomically(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -18,11 +18,15 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	goerrors "errors"
 	"fmt"
 	"io"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/GoogleContainerTools/kpt/internal/printer"
@@ -46,6 +50,14 @@ const (
 	NeverPull        ImagePullPolicy = "Never"
 )
 
+// FunctionTypeLabel is the label of a function image which tells whether the
+// function is a `mutator` or a `validator`.
+const FunctionTypeLabel = "org.kpt.function.type"
+
+// removeContainerTimeout is the time given to docker to remove the container
+// of a stopped function.
+const removeContainerTimeout = 30 * time.Second
+
 type ImagePullPolicy string
 
 // ContainerFnPermission contains the permission of container
@@ -74,26 +86,80 @@ type ContainerFn struct {
 	UIDGID string
 	// StorageMounts are the storage or directories to mount
 	// into the container
-	StorageMounts []runtimeutil.StorageMount
+	StorageMounts []StorageMount
 	// Env is a slice of env string that will be exposed to container
 	Env []string
+	// WorkDir is the working directory of the function in the container. If
+	// it's empty, the working directory of the image is used.
+	WorkDir string
 	// FnResult is used to store the information about the result from
 	// the function.
 	FnResult *fnresult.Result
+	// PullRetries is the number of times pulling the image is retried after
+	// transient failures. If it is 0, docker pulls the image when running
+	// the container.
+	PullRetries int
+	// LogPullRetries prints every retry of pulling the image.
+	LogPullRetries bool
+	// RegistryConfigDir is the docker config directory containing the
+	// registry credentials the image is pulled with. If it's empty, the
+	// default docker config is used.
+	RegistryConfigDir string
+	// CacheDir is the directory the outputs of the function are cached in,
+	// keyed on the digest of the image and the input. The cached output is
+	// used instead of running the function again. Functions with network
+	// access or mounts aren't cached. If it's empty, nothing is cached.
+	CacheDir string
+	// RefreshCache runs the function even if CacheDir contains its output,
+	// and replaces the cached output.
+	RefreshCache bool
 }
 
 // Run runs the container function using docker runtime.
 // It reads the input from the given reader and writes the output
 // to the provided writer.
 func (f *ContainerFn) Run(reader io.Reader, writer io.Writer) error {
+	if f.cacheable() {
+		return f.runCached(reader, writer)
+	}
+	return f.run(reader, writer)
+}
+
+// run runs the container function without the cache.
+func (f *ContainerFn) run(reader io.Reader, writer io.Writer) error {
 	errSink := bytes.Buffer{}
-	cmd, cancel := f.getDockerCmd()
+	// setup container run timeout
+	timeout := defaultLongTimeout
+	if f.Timeout != 0 {
+		timeout = f.Timeout
+	}
+	ctx, cancel := context.WithTimeout(contextOrBackground(f.Ctx), timeout)
 	defer cancel()
+	if err := f.checkArtifact(ctx); err != nil {
+		return err
+	}
+	pullPolicy := f.ImagePullPolicy
+	if f.PullRetries > 0 && pullPolicy != NeverPull {
+		if pullPolicy == AlwaysPull || !imageExists(ctx, f.Image) {
+			if err := f.pullImage(ctx); err != nil {
+				return err
+			}
+		}
+		// the image is present locally
+		pullPolicy = NeverPull
+	}
+	name := newContainerName()
+	cmd := f.getDockerCmd(name, pullPolicy)
 	cmd.Stdin = reader
 	cmd.Stdout = writer
 	cmd.Stderr = &errSink
 
-	if err := cmd.Run(); err != nil {
+	if err := runCmd(ctx, cmd); err != nil {
+		if ctx.Err() != nil {
+			// killing the docker CLI doesn't stop the container
+			removeContainer(name)
+			return fmt.Errorf("function %q was stopped: %w", f.Image, ctx.Err())
+		}
 		var exitErr *exec.ExitError
 		if goerrors.As(err, &exitErr) {
 			return &ExecError{
@@ -112,7 +178,7 @@ func (f *ContainerFn) Run(reader io.Reader, writer io.Writer) error {
 	return nil
 }
 
-func (f *ContainerFn) getDockerCmd() (*exec.Cmd, context.CancelFunc) {
+func (f *ContainerFn) getDockerCmd(name string, pullPolicy ImagePullPolicy) *exec.Cmd {
 	network := networkNameNone
 	if f.Perm.AllowNetwork {
 		network = networkNameHost
@@ -123,14 +189,14 @@ func (f *ContainerFn) getDockerCmd() (*exec.Cmd, context.CancelFunc) {
 	}
 
 	args := []string{
-		"run", "--rm", "-i",
+		"run", "--rm", "-i", "--name", name,
 		"-a", "STDIN", "-a", "STDOUT", "-a", "STDERR",
 		"--network", string(network),
 		"--user", uidgid,
 		"--security-opt=no-new-privileges",
 	}
 
-	switch f.ImagePullPolicy {
+	switch pullPolicy {
 	case NeverPull:
 		args = append(args, "--pull", "never")
 	case AlwaysPull:
@@ -145,14 +211,52 @@ func (f *ContainerFn) getDockerCmd() (*exec.Cmd, context.CancelFunc) {
 	}
 	args = append(args,
 		NewContainerEnvFromStringSlice(f.Env).GetDockerFlags()...)
+	if f.WorkDir != "" {
+		args = append(args, "--workdir", f.WorkDir)
+	}
 	args = append(args, f.Image)
-	// setup container run timeout
-	timeout := defaultLongTimeout
-	if f.Timeout != 0 {
-		timeout = f.Timeout
+	return exec.Command(dockerBin, f.dockerArgs(args...)...)
+}
+
+// newContainerName returns a unique name for the container of a function.
+func newContainerName() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "kpt-fn-" + hex.EncodeToString(b)
+}
+
+// removeContainer force removes the container with the given name.
+func removeContainer(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), removeContainerTimeout)
+	defer cancel()
+	_ = exec.CommandContext(ctx, dockerBin, "rm", "--force", name).Run()
+}
+
+// imageFunctionTypes caches the function types looked up by
+// ImageFunctionType by image.
+var imageFunctionTypes sync.Map
+
+// ImageFunctionType returns the function type declared by the
+// FunctionTypeLabel in the config of the local image. It returns an empty
+// string if the image doesn't have the label. The lookup is cached, so
+// inspecting the image of a function again, e.g. for every package, doesn't
+// run docker.
+func ImageFunctionType(ctx context.Context, image string) (string, error) {
+	if fnType, ok := imageFunctionTypes.Load(image); ok {
+		return fnType.(string), nil
+	}
+	out, err := exec.CommandContext(contextOrBackground(ctx), dockerBin, "image", "inspect",
+		"--format", "{{json .Config.Labels}}", image).Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot inspect image %q: %w", image, err)
+	}
+	var labels map[string]string
+	if err := json.Unmarshal(out, &labels); err != nil {
+		return "", fmt.Errorf("cannot read the labels of image %q: %w", image, err)
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	return exec.CommandContext(ctx, dockerBin, args...), cancel
+	fnType := labels[FunctionTypeLabel]
+	imageFunctionTypes.Store(image, fnType)
+	return fnType, nil
 }
 
 // NewContainerEnvFromStringSlice returns a new ContainerEnv pointer with parsing
@@ -175,11 +279,23 @@ func NewContainerEnvFromStringSlice(envStr []string) *runtimeutil.ContainerEnv {
 	return ce
 }
 
+// DefaultImagePathPrefix is the registry path of the function catalog short
+// image names are resolved against.
+const DefaultImagePathPrefix = "gcr.io/kpt-fn/"
+
 // AddDefaultImagePathPrefix converts the function short path to the full image url.
 // If the function is Catalog function, it adds "gcr.io/kpt-fn/".e.g. set-namespace:v0.1 --> gcr.io/kpt-fn/set-namespace:v0.1
 // If the function is porch function, it queries porch to get the function image by name and namespace.
 // e.g. default:set-namespace:v0.1 --> us-west1-docker.pkg.dev/cpa-kit-dev/packages/set-namespace:v0.1
 func AddDefaultImagePathPrefix(ctx context.Context, image string) string {
+	return AddImagePathPrefix(ctx, image, "")
+}
+
+// AddImagePathPrefix converts the function short path to the full image url
+// like AddDefaultImagePathPrefix, using prefix instead of the default prefix
+// if it isn't empty. Images whose path contains a / already, e.g. because
+// they contain a registry host, aren't changed.
+func AddImagePathPrefix(ctx context.Context, image, prefix string) string {
 	segments := strings.Split(image, ":")
 	if len(segments) == 4 {
 		// Porch function
@@ -191,7 +307,10 @@ func AddDefaultImagePathPrefix(ctx context.Context, image string) string {
 		return function.Spec.Image
 	}
 	if !strings.Contains(image, "/") {
-		return fmt.Sprintf("gcr.io/kpt-fn/%s", image)
+		if prefix == "" {
+			prefix = DefaultImagePathPrefix
+		}
+		return strings.TrimSuffix(prefix, "/") + "/" + image
 	}
 	return image
 }
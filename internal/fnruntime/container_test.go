@@ -74,3 +74,59 @@ func TestContainerFn(t *testing.T) {
 		})
 	}
 }
+
+func TestAddImagePathPrefix(t *testing.T) {
+	testCases := map[string]struct {
+		image    string
+		prefix   string
+		env      string
+		expected string
+	}{
+		"short name": {
+			image:    "set-namespace:v0.4",
+			expected: "gcr.io/kpt-fn/set-namespace:v0.4",
+		},
+		"short name with custom prefix": {
+			image:    "set-namespace:v0.4",
+			prefix:   "registry.example.com/kpt-fn",
+			expected: "registry.example.com/kpt-fn/set-namespace:v0.4",
+		},
+		"custom prefix with trailing slash": {
+			image:    "set-namespace:v0.4",
+			prefix:   "registry.example.com/kpt-fn/",
+			expected: "registry.example.com/kpt-fn/set-namespace:v0.4",
+		},
+		"prefix env is ignored": {
+			image:    "set-namespace:v0.4",
+			env:      "mirror.example.com/fns",
+			expected: "gcr.io/kpt-fn/set-namespace:v0.4",
+		},
+		"qualified name with custom prefix": {
+			image:    "gcr.io/kpt-fn/set-namespace:v0.4",
+			prefix:   "registry.example.com/kpt-fn",
+			expected: "gcr.io/kpt-fn/set-namespace:v0.4",
+		},
+		"registry with port with custom prefix": {
+			image:    "localhost:5000/set-namespace:v0.4",
+			prefix:   "registry.example.com/kpt-fn",
+			expected: "localhost:5000/set-namespace:v0.4",
+		},
+		"digest pinned short name with custom prefix": {
+			image:    "set-namespace@sha256:c347e28606fa1a608e8e02e03541a5a46e4a0152005df4a11e44f6c4ab1edd9a",
+			prefix:   "registry.example.com/kpt-fn",
+			expected: "registry.example.com/kpt-fn/set-namespace@sha256:c347e28606fa1a608e8e02e03541a5a46e4a0152005df4a11e44f6c4ab1edd9a",
+		},
+		"digest pinned qualified name with custom prefix": {
+			image:    "gcr.io/kpt-fn/set-namespace@sha256:c347e28606fa1a608e8e02e03541a5a46e4a0152005df4a11e44f6c4ab1edd9a",
+			prefix:   "registry.example.com/kpt-fn",
+			expected: "gcr.io/kpt-fn/set-namespace@sha256:c347e28606fa1a608e8e02e03541a5a46e4a0152005df4a11e44f6c4ab1edd9a",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			t.Setenv("KPT_FN_IMAGE_PREFIX", tc.env)
+			assert.Equal(t, tc.expected, fnruntime.AddImagePathPrefix(context.Background(), tc.image, tc.prefix))
+		})
+	}
+}
@@ -0,0 +1,409 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+)
+
+func TestImageFunctionType(t *testing.T) {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	// the fake docker logs its calls and prints the labels of the images
+	docker := `#!/bin/sh
+echo "$@" >> ` + calls + `
+case "$5" in
+  validator:v1) echo '{"org.kpt.function.type":"validator"}' ;;
+  mutator:v1) echo '{"org.kpt.function.type":"mutator","other":"label"}' ;;
+  unlabeled:v1) echo 'null' ;;
+  *) echo "Error: No such image: $5" >&2; exit 1 ;;
+esac
+`
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "docker"), []byte(docker), 0700)) {
+		t.FailNow()
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	testCases := map[string]struct {
+		image    string
+		expected string
+		err      string
+	}{
+		"validator": {
+			image:    "validator:v1",
+			expected: "validator",
+		},
+		"mutator": {
+			image:    "mutator:v1",
+			expected: "mutator",
+		},
+		"unlabeled": {
+			image: "unlabeled:v1",
+		},
+		"missing image": {
+			image: "missing:v1",
+			err:   `cannot inspect image "missing:v1": exit status 1`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				fnType, err := ImageFunctionType(context.Background(), tc.image)
+				if tc.err != "" {
+					if assert.Error(t, err) {
+						assert.Equal(t, tc.err, err.Error())
+					}
+					continue
+				}
+				if assert.NoError(t, err) {
+					assert.Equal(t, tc.expected, fnType)
+				}
+			}
+		})
+	}
+
+	b, err := ioutil.ReadFile(calls)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// the labels of each image are only inspected once, failures are retried
+	assert.Equal(t, []string{
+		"image inspect --format {{json .Config.Labels}} missing:v1",
+		"image inspect --format {{json .Config.Labels}} missing:v1",
+		"image inspect --format {{json .Config.Labels}} mutator:v1",
+		"image inspect --format {{json .Config.Labels}} unlabeled:v1",
+		"image inspect --format {{json .Config.Labels}} validator:v1",
+	}, sortedLines(string(b)))
+}
+
+func TestContainerFn_checkArtifact(t *testing.T) {
+	dir := t.TempDir()
+	// the fake docker has the local:v1 image, the other images are remote
+	docker := `#!/bin/sh
+case "$1 $2 $3" in
+  "image inspect local:v1") exit 0 ;;
+  "image inspect "*) exit 1 ;;
+  "manifest inspect image:v1") echo '{"config":{"mediaType":"application/vnd.oci.image.config.v1+json"}}' ;;
+  "manifest inspect wasm:v1") echo '{"config":{"mediaType":"application/vnd.wasm.config.v1+json"}}' ;;
+  "manifest inspect local:v1") echo '{"config":{"mediaType":"application/vnd.wasm.config.v1+json"}}' ;;
+  *) exit 1 ;;
+esac
+`
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "docker"), []byte(docker), 0700)) {
+		t.FailNow()
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	testCases := map[string]struct {
+		image  string
+		policy ImagePullPolicy
+		err    string
+	}{
+		"local image": {
+			image: "local:v1",
+		},
+		"remote image": {
+			image: "image:v1",
+		},
+		"remote wasm module": {
+			image: "wasm:v1",
+			err:   `function "wasm:v1" is a wasm module (media type "application/vnd.wasm.config.v1+json"), wasm functions are not supported yet`,
+		},
+		"never pulled": {
+			image:  "wasm:v2",
+			policy: NeverPull,
+		},
+		"manifest inspect fails": {
+			image: "missing:v1",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			err := (&ContainerFn{Image: tc.image, ImagePullPolicy: tc.policy}).checkArtifact(context.Background())
+			if tc.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Equal(t, tc.err, err.Error())
+			}
+		})
+	}
+}
+
+// sortedLines returns the sorted lines of s without the trailing newline.
+func sortedLines(s string) []string {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	sort.Strings(lines)
+	return lines
+}
+
+func TestContainerFn_pullImage(t *testing.T) {
+	dir := t.TempDir()
+	attempts := filepath.Join(dir, "attempts")
+	// the fake docker fails to pull the images with the errors in their
+	// names until the third attempt
+	docker := `#!/bin/sh
+echo "$@" >> ` + attempts + `
+n=$(grep -c "$2" ` + attempts + `)
+case "$2" in
+  timeout:*) [ "$n" -ge 3 ] && exit 0; echo "Error response from daemon: i/o timeout" >&2; exit 1 ;;
+  missing:*) echo "Error response from daemon: manifest for $2 not found: manifest unknown" >&2; exit 1 ;;
+  unauthorized:*) echo "Error response from daemon: unauthorized: authentication required" >&2; exit 1 ;;
+esac
+exit 0
+`
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "docker"), []byte(docker), 0700)) {
+		t.FailNow()
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	defer func(d time.Duration) { pullRetryBaseDelay = d }(pullRetryBaseDelay)
+	pullRetryBaseDelay = time.Millisecond
+
+	testCases := map[string]struct {
+		image    string
+		retries  int
+		attempts int
+		err      string
+		log      string
+	}{
+		"pulled": {
+			image:    "image:v1",
+			retries:  3,
+			attempts: 1,
+		},
+		"transient failures retried": {
+			image:    "timeout:v1",
+			retries:  3,
+			attempts: 3,
+			log: "Retrying pull of image \"timeout:v1\" in 1ms (retry 1 of 3): Error response from daemon: i/o timeout\n" +
+				"Retrying pull of image \"timeout:v1\" in 2ms (retry 2 of 3): Error response from daemon: i/o timeout\n",
+		},
+		"retries exhausted": {
+			image:    "timeout:v2",
+			retries:  1,
+			attempts: 2,
+			err:      `cannot pull image "timeout:v2" after 2 attempt(s): Error response from daemon: i/o timeout`,
+		},
+		"missing image not retried": {
+			image:    "missing:v1",
+			retries:  3,
+			attempts: 1,
+			err:      `doesn't exist remotely`,
+		},
+		"unauthorized not retried": {
+			image:    "unauthorized:v1",
+			retries:  3,
+			attempts: 1,
+			err:      `cannot pull image "unauthorized:v1": Error response from daemon: unauthorized: authentication required`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			var errOut bytes.Buffer
+			f := &ContainerFn{
+				Ctx:            fake.CtxWithPrinter(&bytes.Buffer{}, &errOut),
+				Image:          tc.image,
+				PullRetries:    tc.retries,
+				LogPullRetries: true,
+			}
+			err := f.pullImage(context.Background())
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+			if tc.log != "" {
+				assert.Equal(t, tc.log, errOut.String())
+			}
+
+			b, err := ioutil.ReadFile(attempts)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.attempts, strings.Count(string(b), "pull "+tc.image+"\n"))
+		})
+	}
+}
+
+func TestContainerFn_registryConfigDir(t *testing.T) {
+	defer func(d time.Duration) { pullRetryBaseDelay = d }(pullRetryBaseDelay)
+	pullRetryBaseDelay = time.Millisecond
+
+	dir := t.TempDir()
+	commands := filepath.Join(dir, "commands")
+	configs := filepath.Join(dir, "configs")
+	pulled := filepath.Join(dir, "pulled")
+	// the fake docker records its arguments and the docker config it is run
+	// with, fails to inspect the manifest, which is ignored, and fails the
+	// first pull with a transient error
+	docker := `#!/bin/sh
+echo "$@" >> ` + commands + `
+cat "$2/config.json" >> ` + configs + `
+[ "$3" = "manifest" ] && exit 1
+if [ "$3" = "pull" ] && [ ! -f ` + pulled + ` ]; then
+  touch ` + pulled + `
+  echo "toomanyrequests: rate limit exceeded" >&2
+  exit 1
+fi
+exit 0
+`
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "docker"), []byte(docker), 0700)) {
+		t.FailNow()
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	configDir := filepath.Join(dir, "config")
+	if !assert.NoError(t, os.Mkdir(configDir, 0700)) {
+		t.FailNow()
+	}
+	config := `{"auths": {"example.com": {"auth": "dXNlcjpzM2NyM3Q="}}}`
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(configDir, "config.json"), []byte(config), 0600)) {
+		t.FailNow()
+	}
+
+	out := &bytes.Buffer{}
+	f := &ContainerFn{
+		Ctx:             fake.CtxWithPrinter(out, out),
+		Image:           "example.com/private/fn:v1",
+		ImagePullPolicy: AlwaysPull,
+		PullRetries:     1,
+		// the retries are logged with --verbose
+		LogPullRetries:    true,
+		RegistryConfigDir: configDir,
+	}
+	if !assert.NoError(t, f.checkArtifact(context.Background())) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, f.pullImage(context.Background())) {
+		t.FailNow()
+	}
+	cmd := f.getDockerCmd("fn", AlwaysPull)
+
+	b, err := ioutil.ReadFile(commands)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "--config "+configDir+" manifest inspect example.com/private/fn:v1\n"+
+		"--config "+configDir+" pull example.com/private/fn:v1\n"+
+		"--config "+configDir+" pull example.com/private/fn:v1\n", string(b))
+	assert.Equal(t, []string{"--config", configDir, "run"}, cmd.Args[1:4])
+
+	// every docker command saw the credentials
+	b, err = ioutil.ReadFile(configs)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, strings.Repeat(config, 3), string(b))
+
+	// the credentials aren't printed
+	assert.Contains(t, out.String(), "Retrying pull of image")
+	assert.NotContains(t, out.String(), "dXNlcjpzM2NyM3Q=")
+}
+
+func TestContainerFn_runCached(t *testing.T) {
+	dir := t.TempDir()
+	runs := filepath.Join(dir, "runs")
+	digest := filepath.Join(dir, "digest")
+	// the fake docker has the image with the ID in the digest file, and its
+	// function copies the input to the output
+	docker := `#!/bin/sh
+case "$1 $2 $3" in
+  "image inspect --format") cat ` + digest + ` ;;
+  "image inspect "*) exit 0 ;;
+  "run "*) echo "$@" >> ` + runs + `; cat; echo "function stderr" >&2 ;;
+  *) exit 1 ;;
+esac
+`
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "docker"), []byte(docker), 0700)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, ioutil.WriteFile(digest, []byte("sha256:aaa\n"), 0600)) {
+		t.FailNow()
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	cacheDir := filepath.Join(dir, "cache")
+
+	run := func(f ContainerFn, input string) {
+		f.Image = "image:v1"
+		f.CacheDir = cacheDir
+		f.FnResult = &fnresult.Result{}
+		var out bytes.Buffer
+		if !assert.NoError(t, f.Run(strings.NewReader(input), &out)) {
+			t.FailNow()
+		}
+		assert.Equal(t, input, out.String())
+		assert.Equal(t, "function stderr", f.FnResult.Stderr)
+	}
+	assertRuns := func(expected int) {
+		b, err := ioutil.ReadFile(runs)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, expected, strings.Count(string(b), "\n"))
+	}
+
+	run(ContainerFn{}, "input: a\n")
+	assertRuns(1)
+	// the same input is read from the cache
+	run(ContainerFn{}, "input: a\n")
+	assertRuns(1)
+	run(ContainerFn{}, "input: b\n")
+	assertRuns(2)
+	run(ContainerFn{Env: []string{"FOO=bar"}}, "input: a\n")
+	assertRuns(3)
+	run(ContainerFn{RefreshCache: true}, "input: a\n")
+	assertRuns(4)
+	run(ContainerFn{}, "input: a\n")
+	assertRuns(4)
+
+	// a new image for the same tag doesn't reuse the cached output
+	if !assert.NoError(t, ioutil.WriteFile(digest, []byte("sha256:bbb\n"), 0600)) {
+		t.FailNow()
+	}
+	run(ContainerFn{}, "input: a\n")
+	assertRuns(5)
+	run(ContainerFn{}, "input: a\n")
+	assertRuns(5)
+
+	// functions with network access or mounts aren't cached
+	network := ContainerFn{Perm: ContainerFnPermission{AllowNetwork: true}}
+	run(network, "input: a\n")
+	run(network, "input: a\n")
+	assertRuns(7)
+	mounts := ContainerFn{StorageMounts: []StorageMount{{StorageMount: runtimeutil.StorageMount{MountType: "bind", Src: dir, DstPath: "/data"}}}}
+	run(mounts, "input: a\n")
+	run(mounts, "input: a\n")
+	assertRuns(9)
+}
@@ -20,7 +20,9 @@ import (
 	goerrors "errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
+	"sort"
 	"time"
 
 	"github.com/GoogleContainerTools/kpt/internal/printer"
@@ -28,11 +30,17 @@ import (
 )
 
 type ExecFn struct {
+	// Ctx stops the executable and the processes it spawned when it is done.
+	Ctx context.Context
 	// Path is the os specific path to the executable
 	// file. It can be relative or absolute.
 	Path string
 	// Args are the arguments to the executable
 	Args []string
+	// Env is a list of environment variables in KEY=VALUE format which are
+	// set in addition to the environment kpt is running with. Variables
+	// specified only as KEY are already inherited from the environment.
+	Env []string
 	// Container function will be killed after this timeour.
 	// The default value is 5 minutes.
 	Timeout time.Duration
@@ -41,6 +49,21 @@ type ExecFn struct {
 	FnResult *fnresult.Result
 }
 
+// environ returns the environment of kpt with the variables in Env added.
+func (f *ExecFn) environ() []string {
+	env := os.Environ()
+	ce := NewContainerEnvFromStringSlice(f.Env)
+	var keys []string
+	for key := range ce.EnvVars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		env = append(env, key+"="+ce.EnvVars[key])
+	}
+	return env
+}
+
 // Run runs the executable file which reads the input from r and
 // writes the output to w.
 func (f *ExecFn) Run(r io.Reader, w io.Writer) error {
@@ -49,17 +72,23 @@ func (f *ExecFn) Run(r io.Reader, w io.Writer) error {
 	if f.Timeout != 0 {
 		timeout = f.Timeout
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(contextOrBackground(f.Ctx), timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, f.Path, f.Args...)
+	cmd := exec.Command(f.Path, f.Args...)
+	if len(f.Env) > 0 {
+		cmd.Env = f.environ()
+	}
 
 	errSink := bytes.Buffer{}
 	cmd.Stdin = r
 	cmd.Stdout = w
 	cmd.Stderr = &errSink
 
-	if err := cmd.Run(); err != nil {
+	if err := runCmd(ctx, cmd); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("function %q was stopped: %w", f.Path, ctx.Err())
+		}
 		var exitErr *exec.ExitError
 		if goerrors.As(err, &exitErr) {
 			return &ExecError{
@@ -78,3 +107,30 @@ func (f *ExecFn) Run(r io.Reader, w io.Writer) error {
 
 	return nil
 }
+
+// runCmd runs cmd and kills it together with the processes it spawned when
+// ctx is done.
+func runCmd(ctx context.Context, cmd *exec.Cmd) error {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessTree(cmd)
+		case <-done:
+		}
+	}()
+	return cmd.Wait()
+}
+
+// contextOrBackground returns ctx, or the background context if ctx is nil.
+func contextOrBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
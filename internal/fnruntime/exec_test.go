@@ -0,0 +1,65 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecFn_Env(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Setenv("KPT_TEST_HOST", "host")
+
+	testCases := map[string]struct {
+		env      []string
+		expected string
+	}{
+		"inherits the environment": {
+			expected: "host,",
+		},
+		"sets variables": {
+			env:      []string{"KPT_TEST_FOO=foo=bar"},
+			expected: "host,foo=bar",
+		},
+		"overrides inherited variables": {
+			env:      []string{"KPT_TEST_HOST=override", "KPT_TEST_FOO"},
+			expected: "override,",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			f := &ExecFn{
+				Path:     "sh",
+				Args:     []string{"-c", `echo "$KPT_TEST_HOST,$KPT_TEST_FOO"`},
+				Env:      tc.env,
+				FnResult: &fnresult.Result{},
+			}
+			out := &bytes.Buffer{}
+			if !assert.NoError(t, f.Run(&bytes.Buffer{}, out)) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expected, strings.TrimSpace(out.String()))
+		})
+	}
+}
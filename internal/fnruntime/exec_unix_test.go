@@ -0,0 +1,84 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecFn_Canceled(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the function spawns a process and waits for it
+	f := &ExecFn{
+		Ctx:      ctx,
+		Path:     "sh",
+		Args:     []string{"-c", "sleep 60 & echo $! > " + pidFile + "; wait"},
+		FnResult: &fnresult.Result{},
+	}
+	go func() {
+		// cancel once the process has been spawned
+		for ctx.Err() == nil {
+			if b, err := ioutil.ReadFile(pidFile); err == nil && strings.HasSuffix(string(b), "\n") {
+				cancel()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	start := time.Now()
+	err := f.Run(&bytes.Buffer{}, &bytes.Buffer{})
+	assert.True(t, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+	assert.Less(t, time.Since(start), 30*time.Second)
+	b, err := ioutil.ReadFile(pidFile)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// the spawned process is killed as well
+	assert.Eventually(t, func() bool { return !isRunning(pid) }, 5*time.Second, 10*time.Millisecond)
+}
+
+// isRunning returns true if the process with the pid is running. Processes
+// which have exited but haven't been reaped yet aren't running.
+func isRunning(pid int) bool {
+	if b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid)); err == nil {
+		fields := strings.Fields(string(b[bytes.LastIndexByte(b, ')')+1:]))
+		return len(fields) > 0 && fields[0] != "Z"
+	}
+	return syscall.Kill(pid, 0) == nil
+}
@@ -59,3 +59,34 @@ func (fe *ExecError) String() string {
 func (fe *ExecError) Error() string {
 	return fe.String()
 }
+
+// StderrError is the error returned for a failed function when its stderr is
+// reported separately from the status of the function.
+type StderrError struct {
+	// Name is the image or the exec path of the function.
+	Name string
+
+	// Stderr is the content written to function stderr
+	Stderr string
+
+	// ExitCode is the exit code returned from function
+	ExitCode int
+}
+
+func (e *StderrError) Error() string {
+	msg := fmt.Sprintf("function %q failed with exit code %d", e.Name, e.ExitCode)
+	if e.Stderr == "" {
+		return msg
+	}
+	return msg + "\n" + stderrBlock(e.Name, e.Stderr)
+}
+
+// stderrBlock returns stderr of the function name between delimiter lines,
+// so it can be told apart from the messages of kpt.
+func stderrBlock(name, stderr string) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("---- stderr from %s ----\n", name))
+	b.WriteString(strings.TrimSuffix(stderr, "\n"))
+	b.WriteString("\n---- end of stderr ----")
+	return b.String()
+}
@@ -211,3 +211,40 @@ lastline`,
 		})
 	}
 }
+
+func TestStderrErrorString(t *testing.T) {
+	testcases := []struct {
+		name     string
+		err      StderrError
+		expected string
+	}{
+		{
+			name: "without stderr",
+			err: StderrError{
+				Name:     "gcr.io/kpt-fn/set-labels:v0.1",
+				ExitCode: 1,
+			},
+			expected: `function "gcr.io/kpt-fn/set-labels:v0.1" failed with exit code 1`,
+		},
+		{
+			name: "with stderr",
+			err: StderrError{
+				Name:     "gcr.io/kpt-fn/set-labels:v0.1",
+				Stderr:   "error message1\nerror message2\n",
+				ExitCode: 1,
+			},
+			expected: `function "gcr.io/kpt-fn/set-labels:v0.1" failed with exit code 1
+---- stderr from gcr.io/kpt-fn/set-labels:v0.1 ----
+error message1
+error message2
+---- end of stderr ----`,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.err.Error())
+		})
+	}
+}
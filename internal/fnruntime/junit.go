@@ -0,0 +1,154 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+)
+
+// JUnitReport is a JUnit XML report of function results. Every function is
+// a test suite and every result of the function is a test case.
+type JUnitReport struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+}
+
+// JUnitTestSuite contains the test cases of a function.
+type JUnitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []JUnitTestCase `xml:"testcase"`
+}
+
+// JUnitTestCase is a result of a function. ClassName is the package the
+// function is executed on.
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *JUnitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// JUnitFailure is the failure of a test case.
+type JUnitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// NewJUnitReport returns an empty JUnitReport.
+func NewJUnitReport() *JUnitReport {
+	return &JUnitReport{Name: "kpt"}
+}
+
+// Add adds the results of the functions executed on pkg to the report.
+// Results with error severity are failures, as are results with warning
+// severity if failOnWarning is set. A function without results is a single
+// test case, which fails if the function exited with a non-zero exit code.
+func (r *JUnitReport) Add(pkg string, results *fnresult.ResultList, failOnWarning bool) {
+	if results == nil {
+		return
+	}
+	for _, item := range results.Items {
+		name := item.Image
+		if name == "" {
+			name = item.ExecPath
+		}
+		suite := JUnitTestSuite{Name: name}
+		for _, result := range item.Results {
+			if result == nil {
+				continue
+			}
+			tc := JUnitTestCase{Name: junitTestCaseName(result), ClassName: pkg}
+			if result.Severity == framework.Error || (failOnWarning && result.Severity == framework.Warning) {
+				tc.Failure = &JUnitFailure{
+					Message: result.Message,
+					Type:    string(result.Severity),
+					Body:    result.String(),
+				}
+			} else {
+				tc.SystemOut = result.String()
+			}
+			suite.add(tc)
+		}
+		if suite.Tests == 0 {
+			tc := JUnitTestCase{Name: name, ClassName: pkg}
+			if item.ExitCode != 0 {
+				tc.Failure = &JUnitFailure{
+					Message: fmt.Sprintf("function failed with exit code %d", item.ExitCode),
+					Type:    string(framework.Error),
+					Body:    item.Stderr,
+				}
+			}
+			suite.add(tc)
+		}
+		r.Suites = append(r.Suites, suite)
+		r.Tests += suite.Tests
+		r.Failures += suite.Failures
+	}
+}
+
+func (s *JUnitTestSuite) add(tc JUnitTestCase) {
+	s.TestCases = append(s.TestCases, tc)
+	s.Tests++
+	if tc.Failure != nil {
+		s.Failures++
+	}
+}
+
+// Write writes the report as XML to w.
+func (r *JUnitReport) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(r); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// junitTestCaseName returns the name of the test case of result, made of the
+// resource and the field the result refers to. The message is used as the
+// check if the result doesn't refer to a field.
+func junitTestCaseName(result *framework.Result) string {
+	check := result.Message
+	if result.Field != nil && result.Field.Path != "" {
+		check = result.Field.Path
+	}
+	ref := result.ResourceRef
+	if ref == nil {
+		return check
+	}
+	var id []string
+	for _, s := range []string{ref.APIVersion, ref.Kind, ref.Namespace, ref.Name} {
+		if s != "" {
+			id = append(id, s)
+		}
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(id, "/"), check)
+}
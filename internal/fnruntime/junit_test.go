@@ -0,0 +1,78 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"testing"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestJUnitReport(t *testing.T) {
+	results := fnresult.NewResultList()
+	results.Items = append(results.Items,
+		fnresult.Result{
+			Image: "gcr.io/kpt-fn/kubeval:v0.1",
+			Results: framework.Results{
+				{
+					Message:  `field "replicas" <must> be set`,
+					Severity: framework.Error,
+					ResourceRef: &yaml.ResourceIdentifier{
+						TypeMeta: yaml.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+						NameMeta: yaml.NameMeta{Name: "nginx", Namespace: "default"},
+					},
+					Field: &framework.Field{Path: "spec.replicas"},
+				},
+				{
+					Message:  "deprecated",
+					Severity: framework.Warning,
+				},
+			},
+		},
+		fnresult.Result{
+			ExecPath: "./validate.sh",
+			ExitCode: 1,
+			Stderr:   "validation failed",
+		},
+	)
+	report := NewJUnitReport()
+	report.Add("pkg", results, false)
+
+	var out bytes.Buffer
+	if !assert.NoError(t, report.Write(&out)) {
+		t.FailNow()
+	}
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="kpt" tests="3" failures="2">
+  <testsuite name="gcr.io/kpt-fn/kubeval:v0.1" tests="2" failures="1">
+    <testcase name="apps/v1/Deployment/default/nginx: spec.replicas" classname="pkg">
+      <failure message="field &#34;replicas&#34; &lt;must&gt; be set" type="error">[error] apps/v1/Deployment/default/nginx spec.replicas: field &#34;replicas&#34; &lt;must&gt; be set</failure>
+    </testcase>
+    <testcase name="deprecated" classname="pkg">
+      <system-out>[warning]: deprecated</system-out>
+    </testcase>
+  </testsuite>
+  <testsuite name="./validate.sh" tests="1" failures="1">
+    <testcase name="./validate.sh" classname="pkg">
+      <failure message="function failed with exit code 1" type="error">validation failed</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, out.String())
+}
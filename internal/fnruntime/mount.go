@@ -0,0 +1,42 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+)
+
+// StorageMount is a mount of a container function. It adds the size of
+// tmpfs mounts to the bind mounts and volumes of runtimeutil.StorageMount.
+type StorageMount struct {
+	runtimeutil.StorageMount
+	// TmpfsSize is the size of a tmpfs mount in bytes. The size of the
+	// mount isn't limited if it's 0.
+	TmpfsSize int64
+}
+
+// String returns the mount in the format of the docker --mount flag. tmpfs
+// mounts don't have a source and are always writable.
+func (s StorageMount) String() string {
+	if s.MountType != "tmpfs" {
+		return s.StorageMount.String()
+	}
+	if s.TmpfsSize > 0 {
+		return fmt.Sprintf("type=tmpfs,target=%s,tmpfs-size=%d", s.DstPath, s.TmpfsSize)
+	}
+	return "type=tmpfs,target=" + s.DstPath
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+)
+
+func TestStorageMount_String(t *testing.T) {
+	testCases := map[string]struct {
+		mount    StorageMount
+		expected string
+	}{
+		"bind mount": {
+			mount:    StorageMount{StorageMount: runtimeutil.StorageMount{MountType: "bind", Src: "/src", DstPath: "/dst"}},
+			expected: "type=bind,source=/src,target=/dst,readonly",
+		},
+		"writable volume": {
+			mount:    StorageMount{StorageMount: runtimeutil.StorageMount{MountType: "volume", Src: "myvol", DstPath: "/data", ReadWriteMode: true}},
+			expected: "type=volume,source=myvol,target=/data",
+		},
+		"tmpfs": {
+			mount:    StorageMount{StorageMount: runtimeutil.StorageMount{MountType: "tmpfs", DstPath: "/scratch"}},
+			expected: "type=tmpfs,target=/scratch",
+		},
+		"tmpfs with size": {
+			mount:    StorageMount{StorageMount: runtimeutil.StorageMount{MountType: "tmpfs", DstPath: "/scratch"}, TmpfsSize: 64 << 20},
+			expected: "type=tmpfs,target=/scratch,tmpfs-size=67108864",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.mount.String())
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+)
+
+// pullRetryBaseDelay is the delay before the first retry of a pull. It is
+// doubled for every further retry, up to maxPullRetryDelay. This is a
+// variable so it can be shortened in tests.
+var pullRetryBaseDelay = time.Second
+
+const maxPullRetryDelay = 30 * time.Second
+
+// permanentPullErrors are the parts of the docker pull errors which can't be
+// fixed by retrying. They take precedence over transientPullErrors, e.g. a
+// missing image must not be retried because of a timeout in its message.
+var permanentPullErrors = []string{
+	"unauthorized",
+	"authentication required",
+	"denied",
+	"forbidden",
+	"not found",
+	"manifest unknown",
+	"name unknown",
+	"invalid reference format",
+}
+
+// transientPullErrors are the parts of the docker pull errors caused by
+// network failures, registry server errors and rate limits.
+var transientPullErrors = []string{
+	"toomanyrequests",
+	"too many requests",
+	"rate limit",
+	"internal server error",
+	"bad gateway",
+	"service unavailable",
+	"gateway timeout",
+	"timeout",
+	"timed out",
+	"connection reset",
+	"connection refused",
+	"network is unreachable",
+	"temporary failure",
+	"unexpected eof",
+	"tls handshake",
+}
+
+// serverErrorStatus matches the 5xx HTTP statuses in docker pull errors.
+var serverErrorStatus = regexp.MustCompile(`(?i)status(?: code)?:? 5\d\d\b`)
+
+// isNotFoundPullError returns true if the docker pull output tells that the
+// image doesn't exist.
+func isNotFoundPullError(out string) bool {
+	out = strings.ToLower(out)
+	return strings.Contains(out, "not found") || strings.Contains(out, "manifest unknown")
+}
+
+// isTransientPullError returns true if the docker pull output tells that
+// the pull can succeed if it's retried. Unknown errors aren't transient.
+func isTransientPullError(out string) bool {
+	lower := strings.ToLower(out)
+	for _, s := range permanentPullErrors {
+		if strings.Contains(lower, s) {
+			return false
+		}
+	}
+	for _, s := range transientPullErrors {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return serverErrorStatus.MatchString(out)
+}
+
+// pullRetryDelay returns the delay before the retry after the given failed
+// attempt, starting at 0.
+func pullRetryDelay(attempt int) time.Duration {
+	d := pullRetryBaseDelay
+	for i := 0; i < attempt && d < maxPullRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxPullRetryDelay {
+		return maxPullRetryDelay
+	}
+	return d
+}
+
+// imageExists returns true if the image is present locally.
+func imageExists(ctx context.Context, image string) bool {
+	return exec.CommandContext(ctx, dockerBin, "image", "inspect", image).Run() == nil
+}
+
+// dockerArgs returns the arguments of a docker command accessing the
+// registry of the image, which use the credentials in RegistryConfigDir if it
+// is set.
+func (f *ContainerFn) dockerArgs(args ...string) []string {
+	if f.RegistryConfigDir == "" {
+		return args
+	}
+	return append([]string{"--config", f.RegistryConfigDir}, args...)
+}
+
+// pullImage pulls the image of the function, retrying transient failures up
+// to PullRetries times with exponential backoff. Other failures are returned
+// immediately.
+func (f *ContainerFn) pullImage(ctx context.Context) error {
+	for attempt := 0; ; attempt++ {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, dockerBin, f.dockerArgs("pull", f.Image)...)
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("pulling image %q was stopped: %w", f.Image, ctx.Err())
+		}
+		out := strings.TrimSpace(stderr.String())
+		if out == "" {
+			out = err.Error()
+		}
+		if isNotFoundPullError(out) {
+			return &ContainerImageError{Image: f.Image, Output: out}
+		}
+		if !isTransientPullError(out) {
+			return fmt.Errorf("cannot pull image %q: %s", f.Image, out)
+		}
+		if attempt >= f.PullRetries {
+			return fmt.Errorf("cannot pull image %q after %d attempt(s): %s", f.Image, attempt+1, out)
+		}
+		delay := pullRetryDelay(attempt)
+		if f.LogPullRetries {
+			printer.FromContextOrDie(f.Ctx).Printf("Retrying pull of image %q in %v (retry %d of %d): %s\n",
+				f.Image, delay, attempt+1, f.PullRetries, out)
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("pulling image %q was stopped: %w", f.Image, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
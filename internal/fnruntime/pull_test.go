@@ -0,0 +1,84 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTransientPullError(t *testing.T) {
+	testCases := map[string]struct {
+		out       string
+		transient bool
+	}{
+		"network timeout": {
+			out:       `Error response from daemon: Get "https://gcr.io/v2/": net/http: request canceled while waiting for connection (Client.Timeout exceeded while awaiting headers)`,
+			transient: true,
+		},
+		"connection reset": {
+			out:       `Error response from daemon: Get "https://gcr.io/v2/": read tcp 10.0.0.1:52000->142.250.0.1:443: read: connection reset by peer`,
+			transient: true,
+		},
+		"server error": {
+			out:       `Error response from daemon: received unexpected HTTP status: 503 Service Unavailable`,
+			transient: true,
+		},
+		"server error status code": {
+			out:       `Error response from daemon: unknown: status code: 500`,
+			transient: true,
+		},
+		"rate limit": {
+			out:       `Error response from daemon: toomanyrequests: You have reached your pull rate limit.`,
+			transient: true,
+		},
+		"not found": {
+			out: `Error response from daemon: manifest for gcr.io/kpt-fn/foo:v1 not found: manifest unknown: manifest unknown`,
+		},
+		"unauthorized": {
+			out: `Error response from daemon: unauthorized: authentication required`,
+		},
+		"access denied": {
+			out: `Error response from daemon: pull access denied for foo, repository does not exist or may require 'docker login'`,
+		},
+		"unknown error": {
+			out: `Error response from daemon: something unexpected`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tc.transient, isTransientPullError(tc.out))
+		})
+	}
+}
+
+func TestPullRetryDelay(t *testing.T) {
+	var delays []time.Duration
+	for attempt := 0; attempt < 7; attempt++ {
+		delays = append(delays, pullRetryDelay(attempt))
+	}
+	assert.Equal(t, []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		16 * time.Second,
+		30 * time.Second,
+		30 * time.Second,
+	}, delays)
+}
@@ -0,0 +1,160 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ResultSignature identifies known results of functions. A result matches the
+// signature if it matches all of the fields which are set.
+type ResultSignature struct {
+	// Function is a glob pattern matching the image or the exec of the
+	// function, e.g. gcr.io/kpt-fn/kubeval:*.
+	Function string `yaml:"function,omitempty"`
+
+	// Resource identifies the resource the result refers to. Its empty fields
+	// match any value.
+	Resource *yaml.ResourceIdentifier `yaml:"resource,omitempty"`
+
+	// Message is a regular expression matching the message of the result.
+	Message string `yaml:"message,omitempty"`
+
+	message *regexp.Regexp
+}
+
+// ResultsFilter drops the function results matching any of its signatures
+// and counts them. A nil ResultsFilter doesn't drop any results.
+type ResultsFilter struct {
+	signatures []ResultSignature
+	ignored    int
+}
+
+// ReadResultsFilter reads a ResultsFilter from the file at path, which
+// contains a list of ResultSignatures.
+func ReadResultsFilter(path string) (*ResultsFilter, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results ignore file %q: %w", path, err)
+	}
+	var signatures []ResultSignature
+	d := yaml.NewDecoder(bytes.NewReader(b))
+	d.KnownFields(true)
+	if err := d.Decode(&signatures); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid results ignore file %q: %w", path, err)
+	}
+	f, err := NewResultsFilter(signatures)
+	if err != nil {
+		return nil, fmt.Errorf("invalid results ignore file %q: %w", path, err)
+	}
+	return f, nil
+}
+
+// NewResultsFilter returns a ResultsFilter dropping the results matching any
+// of signatures.
+func NewResultsFilter(signatures []ResultSignature) (*ResultsFilter, error) {
+	f := &ResultsFilter{}
+	for i, s := range signatures {
+		if s.Function == "" && s.Resource == nil && s.Message == "" {
+			return nil, fmt.Errorf("signature %d must have a function, a resource or a message", i)
+		}
+		if _, err := path.Match(s.Function, ""); err != nil {
+			return nil, fmt.Errorf("function pattern %q of signature %d must be valid: %w", s.Function, i, err)
+		}
+		if s.Message != "" {
+			re, err := regexp.Compile(s.Message)
+			if err != nil {
+				return nil, fmt.Errorf("message pattern %q of signature %d must be valid: %w", s.Message, i, err)
+			}
+			s.message = re
+		}
+		f.signatures = append(f.signatures, s)
+	}
+	return f, nil
+}
+
+// Ignored returns the number of results dropped so far.
+func (f *ResultsFilter) Ignored() int {
+	if f == nil {
+		return 0
+	}
+	return f.ignored
+}
+
+// Filter drops the results of fnResult matching any of the signatures and
+// returns them.
+func (f *ResultsFilter) Filter(fnResult *fnresult.Result) framework.Results {
+	if f == nil || len(fnResult.Results) == 0 {
+		return nil
+	}
+	function := fnResult.Image
+	if function == "" {
+		function = fnResult.ExecPath
+	}
+	var kept, ignored framework.Results
+	for _, r := range fnResult.Results {
+		if r != nil && f.matches(function, r) {
+			ignored = append(ignored, r)
+		} else {
+			kept = append(kept, r)
+		}
+	}
+	fnResult.Results = kept
+	f.ignored += len(ignored)
+	return ignored
+}
+
+func (f *ResultsFilter) matches(function string, result *framework.Result) bool {
+	for _, s := range f.signatures {
+		if s.matches(function, result) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s ResultSignature) matches(function string, result *framework.Result) bool {
+	if s.Function != "" {
+		if ok, _ := path.Match(s.Function, function); !ok {
+			return false
+		}
+	}
+	if s.Resource != nil {
+		ref := result.ResourceRef
+		if ref == nil {
+			return false
+		}
+		for _, f := range [][2]string{
+			{s.Resource.APIVersion, ref.APIVersion},
+			{s.Resource.Kind, ref.Kind},
+			{s.Resource.Namespace, ref.Namespace},
+			{s.Resource.Name, ref.Name},
+		} {
+			if f[0] != "" && f[0] != f[1] {
+				return false
+			}
+		}
+	}
+	return s.message == nil || s.message.MatchString(result.Message)
+}
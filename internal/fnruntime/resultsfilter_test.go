@@ -0,0 +1,132 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestReadResultsFilter(t *testing.T) {
+	testCases := map[string]struct {
+		content string
+		err     string
+	}{
+		"signatures": {
+			content: `
+- function: gcr.io/kpt-fn/kubeval:*
+  resource:
+    kind: Deployment
+    name: nginx
+- message: ^missing
+`,
+		},
+		"empty": {},
+		"unknown field": {
+			content: "- image: gcr.io/kpt-fn/kubeval:v0.3\n",
+			err:     "field image not found",
+		},
+		"empty signature": {
+			content: "- message: a\n- {}\n",
+			err:     "signature 1 must have a function, a resource or a message",
+		},
+		"invalid function pattern": {
+			content: "- function: '[a'\n",
+			err:     `function pattern "[a" of signature 0 must be valid`,
+		},
+		"invalid message pattern": {
+			content: "- message: '(a'\n",
+			err:     `message pattern "(a" of signature 0 must be valid`,
+		},
+	}
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ignore.yaml")
+			if !assert.NoError(t, ioutil.WriteFile(path, []byte(tc.content), 0600)) {
+				t.FailNow()
+			}
+			_, err := ReadResultsFilter(path)
+			if tc.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestResultsFilter_Filter(t *testing.T) {
+	f, err := NewResultsFilter([]ResultSignature{
+		{
+			Function: "gcr.io/kpt-fn/kubeval:*",
+			Resource: &yaml.ResourceIdentifier{
+				TypeMeta: yaml.TypeMeta{Kind: "Deployment"},
+				NameMeta: yaml.NameMeta{Name: "nginx"},
+			},
+		},
+		{Message: "^deprecated"},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	deployment := func(name string) *yaml.ResourceIdentifier {
+		return &yaml.ResourceIdentifier{
+			TypeMeta: yaml.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+			NameMeta: yaml.NameMeta{Name: name, Namespace: "default"},
+		}
+	}
+	fnResult := &fnresult.Result{
+		Image: "gcr.io/kpt-fn/kubeval:v0.3",
+		Results: framework.Results{
+			{Message: "invalid replicas", Severity: framework.Error, ResourceRef: deployment("nginx")},
+			{Message: "invalid replicas", Severity: framework.Error, ResourceRef: deployment("redis")},
+			{Message: "missing resource", Severity: framework.Error},
+			{Message: "deprecated apiVersion", Severity: framework.Warning},
+		},
+	}
+
+	ignored := f.Filter(fnResult)
+	assert.Equal(t, framework.Results{
+		{Message: "invalid replicas", Severity: framework.Error, ResourceRef: deployment("nginx")},
+		{Message: "deprecated apiVersion", Severity: framework.Warning},
+	}, ignored)
+	assert.Equal(t, framework.Results{
+		{Message: "invalid replicas", Severity: framework.Error, ResourceRef: deployment("redis")},
+		{Message: "missing resource", Severity: framework.Error},
+	}, fnResult.Results)
+
+	// the resource signature doesn't match the results of other functions
+	fnResult = &fnresult.Result{
+		ExecPath: "./kubeval",
+		Results: framework.Results{
+			{Message: "invalid replicas", Severity: framework.Error, ResourceRef: deployment("nginx")},
+		},
+	}
+	assert.Empty(t, f.Filter(fnResult))
+	assert.Len(t, fnResult.Results, 1)
+	assert.Equal(t, 2, f.Ignored())
+
+	var nilFilter *ResultsFilter
+	assert.Empty(t, nilFilter.Filter(fnResult))
+	assert.Equal(t, 0, nilFilter.Ignored())
+}
@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -57,7 +58,7 @@ func NewRunner(
 	setPkgPathAnnotation, displayResourceCount bool,
 	runtime fn.FunctionRuntime,
 ) (kio.Filter, error) {
-	config, err := newFnConfig(fsys, f, pkgPath)
+	config, err := NewFnConfig(fsys, f, pkgPath)
 	if err != nil {
 		return nil, err
 	}
@@ -112,6 +113,7 @@ func NewRunner(
 					execArgs = s[1:]
 				}
 				eFn := &ExecFn{
+					Ctx:      ctx,
 					Path:     execPath,
 					Args:     execArgs,
 					FnResult: fnResult,
@@ -122,18 +124,60 @@ func NewRunner(
 			}
 		}
 	}
-	return NewFunctionRunner(ctx, fltr, pkgPath, fnResult, fnResults, setPkgPathAnnotation, displayResourceCount)
+	return NewFunctionRunner(ctx, fltr, pkgPath, fnResult, fnResults, setPkgPathAnnotation, displayResourceCount, 0, InlineStderr, nil, nil)
 }
 
+// StderrMode controls how FunctionRunner reports what functions write to
+// stderr.
+type StderrMode int
+
+const (
+	// InlineStderr prints the stderr of functions together with their status.
+	InlineStderr StderrMode = iota
+	// SeparateStderr returns the stderr of failed functions in a StderrError
+	// and drops the stderr of successful functions.
+	SeparateStderr
+	// VerboseStderr is SeparateStderr, but the stderr of successful functions
+	// is printed after their status.
+	VerboseStderr
+)
+
+// ProgressEvent describes a function which completed.
+type ProgressEvent struct {
+	// Name is the image or the exec path of the function.
+	Name string
+	// Duration is the time it took to run the function.
+	Duration time.Duration
+	// Result is the result of the function.
+	Result *fnresult.Result
+	// Err is the error the function failed with, nil if it succeeded.
+	Err error
+	// Index is the 1-based position of the function among the Total
+	// functions run on the resources. They are 0 if the position is unknown.
+	Index int
+	Total int
+}
+
+// ProgressFunc is called by FunctionRunner when a function completes, after
+// its status is printed.
+type ProgressFunc func(ProgressEvent)
+
 // NewFunctionRunner returns a kio.Filter given a specification of a function
-// and it's config.
+// and it's config. If maxResults is greater than 0, at most maxResults of the
+// results returned by the function are retained. The results matching
+// resultsFilter are dropped before. progress is called when the function
+// completes if it isn't nil.
 func NewFunctionRunner(ctx context.Context,
 	fltr *runtimeutil.FunctionFilter,
 	pkgPath types.UniquePath,
 	fnResult *fnresult.Result,
 	fnResults *fnresult.ResultList,
 	setPkgPathAnnotation bool,
-	displayResourceCount bool) (kio.Filter, error) {
+	displayResourceCount bool,
+	maxResults int,
+	stderrMode StderrMode,
+	resultsFilter *ResultsFilter,
+	progress ProgressFunc) (kio.Filter, error) {
 	name := fnResult.Image
 	if name == "" {
 		name = fnResult.ExecPath
@@ -147,6 +191,10 @@ func NewFunctionRunner(ctx context.Context,
 		fnResults:            fnResults,
 		setPkgPathAnnotation: setPkgPathAnnotation,
 		displayResourceCount: displayResourceCount,
+		maxResults:           maxResults,
+		stderrMode:           stderrMode,
+		resultsFilter:        resultsFilter,
+		progress:             progress,
 	}, nil
 }
 
@@ -164,34 +212,56 @@ type FunctionRunner struct {
 	// functions do not have this annotation set.
 	setPkgPathAnnotation bool
 	displayResourceCount bool
+	// maxResults is the number of function results which are retained,
+	// all results are retained if it is 0.
+	maxResults int
+	// stderrMode controls how the stderr of the function is reported.
+	stderrMode StderrMode
+	// resultsFilter drops the known results of the function.
+	resultsFilter *ResultsFilter
+	// progress is called when the function completes.
+	progress ProgressFunc
 }
 
 func (fr *FunctionRunner) Filter(input []*yaml.RNode) (output []*yaml.RNode, err error) {
 	pr := printer.FromContextOrDie(fr.ctx)
 	if !fr.disableCLIOutput {
-		pr.Printf("[RUNNING] %q", fr.name)
 		if fr.displayResourceCount {
-			pr.Printf(" on %d resource(s)", len(input))
+			pr.OptPrintf(printer.NewOpt().Informational(), "[RUNNING] %q on %d resource(s)\n", fr.name, len(input))
+		} else {
+			pr.OptPrintf(printer.NewOpt().Informational(), "[RUNNING] %q\n", fr.name)
 		}
-		pr.Printf("\n")
 	}
 	t0 := time.Now()
 	output, err = fr.do(input)
+	if fr.progress != nil {
+		// the progress is reported after the status of the function
+		event := ProgressEvent{Name: fr.name, Duration: time.Since(t0), Result: fr.fnResult, Err: err}
+		defer fr.progress(event)
+	}
 	if err != nil {
 		printOpt := printer.NewOpt()
 		pr.OptPrintf(printOpt, "[FAIL] %q in %v\n", fr.name, time.Since(t0).Truncate(time.Millisecond*100))
 		printFnResult(fr.ctx, fr.fnResult, printOpt)
 		var fnErr *ExecError
 		if goerrors.As(err, &fnErr) {
+			if fr.stderrMode != InlineStderr {
+				return nil, &StderrError{Name: fr.name, Stderr: fnErr.Stderr, ExitCode: fnErr.ExitCode}
+			}
 			printFnExecErr(fr.ctx, fnErr)
 			return nil, errors.ErrAlreadyHandled
 		}
 		return nil, err
 	}
 	if !fr.disableCLIOutput {
-		pr.Printf("[PASS] %q in %v\n", fr.name, time.Since(t0).Truncate(time.Millisecond*100))
+		pr.OptPrintf(printer.NewOpt().Informational(), "[PASS] %q in %v\n", fr.name, time.Since(t0).Truncate(time.Millisecond*100))
 		printFnResult(fr.ctx, fr.fnResult, printer.NewOpt())
-		printFnStderr(fr.ctx, fr.fnResult.Stderr)
+		switch {
+		case fr.stderrMode == InlineStderr:
+			printFnStderr(fr.ctx, fr.fnResult.Stderr)
+		case fr.stderrMode == VerboseStderr && fr.fnResult.Stderr != "":
+			pr.Printf("%s\n", stderrBlock(fr.name, fr.fnResult.Stderr))
+		}
 	}
 	return output, err
 }
@@ -225,6 +295,11 @@ func (fr *FunctionRunner) do(input []*yaml.RNode) (output []*yaml.RNode, err err
 		// function exec error. Revisit this if this turns out to be true.
 		return output, resultErr
 	}
+	ignored := fr.resultsFilter.Filter(fnResult)
+	truncateResults(fnResult, fr.maxResults)
+	if err != nil && onlyFailedOnIgnoredResults(err, ignored, fnResult) {
+		err = nil
+	}
 	if err != nil {
 		var execErr *ExecError
 		if goerrors.As(err, &execErr) {
@@ -241,6 +316,59 @@ func (fr *FunctionRunner) do(input []*yaml.RNode) (output []*yaml.RNode, err err
 	return output, nil
 }
 
+// onlyFailedOnIgnoredResults returns true if the function failed because it
+// returned error results, all of which are ignored.
+func onlyFailedOnIgnoredResults(err error, ignored framework.Results, fnResult *fnresult.Result) bool {
+	var execErr *ExecError
+	if !goerrors.As(err, &execErr) {
+		return false
+	}
+	return hasErrorResult(ignored) && !hasErrorResult(fnResult.Results)
+}
+
+func hasErrorResult(results framework.Results) bool {
+	for _, r := range results {
+		if r != nil && r.Severity == framework.Error {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateResults drops the results of fnResult exceeding max and appends a
+// result with the number of dropped results. Error results are retained over
+// other results, so the results still show why a function failed.
+func truncateResults(fnResult *fnresult.Result, max int) {
+	if max <= 0 || len(fnResult.Results) <= max {
+		return
+	}
+	results := fnResult.Results
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Severity == framework.Error && results[j].Severity != framework.Error
+	})
+	fnResult.Results = append(results[:max:max], &framework.Result{
+		Message:  fmt.Sprintf("... (%d more results truncated)", len(results)-max),
+		Severity: framework.Info,
+	})
+}
+
+// CountResults returns the number of results of the functions in results
+// with the given severity.
+func CountResults(results *fnresult.ResultList, severity framework.Severity) int {
+	if results == nil {
+		return 0
+	}
+	var n int
+	for _, item := range results.Items {
+		for _, r := range item.Results {
+			if r.Severity == severity {
+				n++
+			}
+		}
+	}
+	return n
+}
+
 func setPkgPathAnnotationIfNotExist(resources []*yaml.RNode, pkgPath types.UniquePath) error {
 	for _, r := range resources {
 		currPkgPath, err := pkg.GetPkgPathAnnotation(r)
@@ -483,7 +611,9 @@ func (ri *multiLineFormatter) String() string {
 	return b.String()
 }
 
-func newFnConfig(fsys filesys.FileSystem, f *kptfilev1.Function, pkgPath types.UniquePath) (*yaml.RNode, error) {
+// NewFnConfig returns the functionConfig of f, either read from its configPath
+// relative to pkgPath or created as ConfigMap from its configMap.
+func NewFnConfig(fsys filesys.FileSystem, f *kptfilev1.Function, pkgPath types.UniquePath) (*yaml.RNode, error) {
 	const op errors.Op = "fn.readConfig"
 	fn := errors.Fn(f.Image)
 
@@ -19,6 +19,7 @@ package fnruntime
 import (
 	"bytes"
 	"context"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
@@ -27,10 +28,12 @@ import (
 
 	"github.com/GoogleContainerTools/kpt/internal/printer"
 	"github.com/GoogleContainerTools/kpt/internal/types"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/stretchr/testify/assert"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
@@ -91,7 +94,7 @@ data: {foo: bar}
 				c.fn.ConfigPath = path.Base(tmp.Name())
 			}
 			fsys := filesys.MakeFsOnDisk()
-			cn, err := newFnConfig(fsys, &c.fn, types.UniquePath(os.TempDir()))
+			cn, err := NewFnConfig(fsys, &c.fn, types.UniquePath(os.TempDir()))
 			assert.NoError(t, err, "unexpected error")
 			actual, err := cn.String()
 			assert.NoError(t, err, "unexpected error")
@@ -647,3 +650,245 @@ func TestPrintFnStderr(t *testing.T) {
 		})
 	}
 }
+
+// TestFunctionRunner_StreamsStatus verifies that the status of a function is
+// printed as soon as it is known instead of after the pipeline completes.
+func TestFunctionRunner_StreamsStatus(t *testing.T) {
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	ctx := printer.WithContext(context.Background(), printer.New(out, errOut))
+
+	var statusWhileRunning string
+	fltr := &runtimeutil.FunctionFilter{
+		Run: func(r io.Reader, w io.Writer) error {
+			statusWhileRunning = errOut.String()
+			_, err := io.Copy(w, r)
+			return err
+		},
+	}
+	fnResult := &fnresult.Result{Image: "gcr.io/kpt-fn/example:v0.1"}
+	fr, err := NewFunctionRunner(ctx, fltr, "", fnResult, fnresult.NewResultList(), false, false, 0, InlineStderr, nil, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	_, err = fr.Filter([]*yaml.RNode{yaml.MustParse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`)})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "[RUNNING] \"gcr.io/kpt-fn/example:v0.1\"\n", statusWhileRunning)
+	assert.Contains(t, errOut.String(), "[PASS] \"gcr.io/kpt-fn/example:v0.1\" in ")
+	assert.Equal(t, "", out.String())
+}
+
+func TestFunctionRunner_Progress(t *testing.T) {
+	testCases := map[string]struct {
+		runErr error
+	}{
+		"success": {},
+		"failure": {runErr: &ExecError{ExitCode: 1}},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			errOut := &bytes.Buffer{}
+			ctx := printer.WithContext(context.Background(), printer.New(&bytes.Buffer{}, errOut))
+			fltr := &runtimeutil.FunctionFilter{
+				Run: func(r io.Reader, w io.Writer) error {
+					if tc.runErr != nil {
+						return tc.runErr
+					}
+					_, err := io.Copy(w, r)
+					return err
+				},
+			}
+			fnResult := &fnresult.Result{ExecPath: "my-fn"}
+			var events []ProgressEvent
+			var statusBeforeProgress string
+			progress := func(e ProgressEvent) {
+				statusBeforeProgress = errOut.String()
+				events = append(events, e)
+			}
+			fr, err := NewFunctionRunner(ctx, fltr, "", fnResult, fnresult.NewResultList(), false, false, 0, SeparateStderr, nil, progress)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			_, err = fr.Filter([]*yaml.RNode{yaml.MustParse("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n")})
+			if tc.runErr != nil {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			if assert.Len(t, events, 1) {
+				assert.Equal(t, "my-fn", events[0].Name)
+				assert.Same(t, fnResult, events[0].Result)
+				assert.Equal(t, tc.runErr != nil, events[0].Err != nil)
+			}
+			// the progress is reported after the status
+			assert.Regexp(t, `\[(PASS|FAIL)\] "my-fn"`, statusBeforeProgress)
+		})
+	}
+}
+
+func TestFunctionRunner_MaxResults(t *testing.T) {
+	out := &bytes.Buffer{}
+	ctx := printer.WithContext(context.Background(), printer.New(out, out))
+
+	fltr := &runtimeutil.FunctionFilter{
+		Run: func(r io.Reader, w io.Writer) error {
+			_, err := io.WriteString(w, `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cm
+results:
+- message: first warning
+  severity: warning
+- message: second warning
+  severity: warning
+- message: invalid config
+  severity: error
+- message: some info
+  severity: info
+`)
+			if err != nil {
+				return err
+			}
+			return &ExecError{OriginalErr: io.EOF, ExitCode: 1}
+		},
+	}
+	fnResult := &fnresult.Result{Image: "gcr.io/kpt-fn/example:v0.1"}
+	fnResults := fnresult.NewResultList()
+	fr, err := NewFunctionRunner(ctx, fltr, "", fnResult, fnResults, false, false, 2, InlineStderr, nil, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	_, err = fr.Filter([]*yaml.RNode{yaml.MustParse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`)})
+	assert.Error(t, err)
+	assert.Equal(t, 1, fnResults.ExitCode)
+	if !assert.Len(t, fnResults.Items, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, framework.Results{
+		{Message: "invalid config", Severity: framework.Error},
+		{Message: "first warning", Severity: framework.Warning},
+		{Message: "... (2 more results truncated)", Severity: framework.Info},
+	}, fnResults.Items[0].Results)
+	assert.Contains(t, out.String(), "[info]: ... (2 more results truncated)")
+	assert.NotContains(t, out.String(), "second warning")
+}
+
+func TestFunctionRunner_ResultsFilter(t *testing.T) {
+	fltr := func() *runtimeutil.FunctionFilter {
+		return &runtimeutil.FunctionFilter{
+			Run: func(r io.Reader, w io.Writer) error {
+				_, err := io.WriteString(w, `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cm
+results:
+- message: known error
+  severity: error
+- message: known warning
+  severity: warning
+- message: some info
+  severity: info
+`)
+				if err != nil {
+					return err
+				}
+				return &ExecError{OriginalErr: io.EOF, ExitCode: 1}
+			},
+		}
+	}
+	input := []*yaml.RNode{yaml.MustParse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`)}
+
+	testCases := map[string]struct {
+		signatures []ResultSignature
+		results    framework.Results
+		err        bool
+	}{
+		"all errors ignored": {
+			signatures: []ResultSignature{{Message: "^known"}},
+			results: framework.Results{
+				{Message: "some info", Severity: framework.Info},
+			},
+		},
+		"no errors ignored": {
+			signatures: []ResultSignature{{Message: "warning"}},
+			results: framework.Results{
+				{Message: "known error", Severity: framework.Error},
+				{Message: "some info", Severity: framework.Info},
+			},
+			err: true,
+		},
+	}
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			ctx := printer.WithContext(context.Background(), printer.New(out, out))
+			rf, err := NewResultsFilter(tc.signatures)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			fnResult := &fnresult.Result{Image: "gcr.io/kpt-fn/example:v0.1"}
+			fnResults := fnresult.NewResultList()
+			fr, err := NewFunctionRunner(ctx, fltr(), "", fnResult, fnResults, false, false, 0, InlineStderr, rf, nil)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			output, err := fr.Filter(input)
+			if tc.err {
+				assert.Error(t, err)
+				assert.Equal(t, 1, fnResults.ExitCode)
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, output, 1)
+				assert.Equal(t, 0, fnResults.ExitCode)
+			}
+			if assert.Len(t, fnResults.Items, 1) {
+				assert.Equal(t, tc.results, fnResults.Items[0].Results)
+			}
+			assert.NotContains(t, out.String(), "known warning")
+		})
+	}
+}
+
+func TestCountResults(t *testing.T) {
+	results := &fnresult.ResultList{
+		Items: []fnresult.Result{
+			{Results: framework.Results{
+				{Message: "a", Severity: framework.Warning},
+				{Message: "b", Severity: framework.Info},
+			}},
+			{Results: framework.Results{
+				{Message: "c", Severity: framework.Warning},
+				{Message: "d", Severity: framework.Error},
+			}},
+		},
+	}
+	assert.Equal(t, 2, CountResults(results, framework.Warning))
+	assert.Equal(t, 1, CountResults(results, framework.Error))
+	assert.Equal(t, 1, CountResults(results, framework.Info))
+	assert.Equal(t, 0, CountResults(nil, framework.Warning))
+}
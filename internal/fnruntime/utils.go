@@ -17,12 +17,15 @@ package fnruntime
 import (
 	"bytes"
 	"fmt"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/GoogleContainerTools/kpt/internal/types"
 	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
@@ -137,20 +140,83 @@ func DeleteResourceIds(input []*yaml.RNode) error {
 
 type SelectionContext struct {
 	RootPackagePath types.UniquePath
+	// Decisions records why the resources are selected or not if it isn't
+	// nil.
+	Decisions *SelectionDecisions
 }
 
-// SelectInput returns the selected resources based on criteria in selectors
-func SelectInput(input []*yaml.RNode, selectors, exclusions []kptfilev1.Selector, _ *SelectionContext) ([]*yaml.RNode, error) {
+// SelectionDecision tells whether a resource is selected and which criteria
+// decided it, e.g. "selected by kind=Deployment".
+type SelectionDecision struct {
+	Node     *yaml.RNode
+	Selected bool
+	Reason   string
+}
+
+// SelectionDecisions are the selection decisions of resources, in the order
+// the resources are first recorded. Recording a resource again replaces its
+// decision, so a later selection step overrides an earlier one. A nil
+// SelectionDecisions doesn't record anything.
+type SelectionDecisions struct {
+	decisions []SelectionDecision
+	index     map[*yaml.RNode]int
+}
+
+// NewSelectionDecisions returns an empty SelectionDecisions.
+func NewSelectionDecisions() *SelectionDecisions {
+	return &SelectionDecisions{index: map[*yaml.RNode]int{}}
+}
+
+// Record records the decision for node.
+func (d *SelectionDecisions) Record(node *yaml.RNode, selected bool, reason string) {
+	if d == nil {
+		return
+	}
+	decision := SelectionDecision{Node: node, Selected: selected, Reason: reason}
+	if i, found := d.index[node]; found {
+		d.decisions[i] = decision
+		return
+	}
+	d.index[node] = len(d.decisions)
+	d.decisions = append(d.decisions, decision)
+}
+
+// List returns the recorded decisions.
+func (d *SelectionDecisions) List() []SelectionDecision {
+	if d == nil {
+		return nil
+	}
+	return d.decisions
+}
+
+// SelectInput returns the selected resources based on criteria in selectors.
+// The decisions are recorded in the Decisions of sc.
+func SelectInput(input []*yaml.RNode, selectors, exclusions []kptfilev1.Selector, sc *SelectionContext) ([]*yaml.RNode, error) {
+	var decisions *SelectionDecisions
+	if sc != nil {
+		decisions = sc.Decisions
+	}
 	var selectedInput []*yaml.RNode
 	if len(selectors) == 0 {
 		selectedInput = input
+		for _, node := range input {
+			decisions.Record(node, true, "selected")
+		}
 	} else {
 		for _, node := range input {
+			matched := false
 			for _, selector := range selectors {
 				if isMatch(node, selector) {
 					selectedInput = append(selectedInput, node)
+					if !matched {
+						decisions.Record(node, true, "selected by "+selector.String())
+					}
+					matched = true
 				}
 			}
+			if !matched {
+				decisions.Record(node, false, "not selected, no selector matches")
+			}
 		}
 	}
 	if len(exclusions) == 0 {
@@ -162,6 +228,7 @@ func SelectInput(input []*yaml.RNode, selectors, exclusions []kptfilev1.Selector
 		for _, exclusion := range exclusions {
 			if !exclusion.IsEmpty() && isMatch(node, exclusion) {
 				matchesExclusion = true
+				decisions.Record(node, false, "not selected, excluded by "+exclusion.String())
 				break
 			}
 		}
@@ -172,6 +239,49 @@ func SelectInput(input []*yaml.RNode, selectors, exclusions []kptfilev1.Selector
 	return filteredInput, nil
 }
 
+// SelectByFilePath returns the resources in input whose path annotation
+// matches the slash separated glob pattern. The paths are relative to the
+// root package, so resources in a subpackage are selected with patterns
+// like "subpkg/*.yaml".
+func SelectByFilePath(input []*yaml.RNode, pattern string) ([]*yaml.RNode, error) {
+	pattern = path.Clean(strings.TrimPrefix(filepath.ToSlash(pattern), "./"))
+	var selectedInput []*yaml.RNode
+	for _, node := range input {
+		p, _, err := kioutil.GetFileAnnotations(node)
+		if err != nil {
+			return nil, err
+		}
+		matched, err := path.Match(pattern, path.Clean(filepath.ToSlash(p)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid file path pattern %q: %w", pattern, err)
+		}
+		if matched {
+			selectedInput = append(selectedInput, node)
+		}
+	}
+	return selectedInput, nil
+}
+
+// SelectByFilePaths returns the resources in input whose path annotation is
+// one of the slash separated paths, relative to the root package.
+func SelectByFilePaths(input []*yaml.RNode, paths []string) ([]*yaml.RNode, error) {
+	selected := map[string]bool{}
+	for _, p := range paths {
+		selected[path.Clean(strings.TrimPrefix(filepath.ToSlash(p), "./"))] = true
+	}
+	var selectedInput []*yaml.RNode
+	for _, node := range input {
+		p, _, err := kioutil.GetFileAnnotations(node)
+		if err != nil {
+			return nil, err
+		}
+		if selected[path.Clean(filepath.ToSlash(p))] {
+			selectedInput = append(selectedInput, node)
+		}
+	}
+	return selectedInput, nil
+}
+
 // isMatch returns true if the resource matches input selection criteria
 func isMatch(node *yaml.RNode, selector kptfilev1.Selector) bool {
 	// keep expanding with new selectors
@@ -15,6 +15,7 @@
 package fnruntime
 
 import (
+	"strings"
 	"testing"
 
 	kptfile "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
@@ -140,3 +141,218 @@ spec:
 		})
 	}
 }
+
+func TestSelectByFilePath(t *testing.T) {
+	input := []*yaml.RNode{
+		yaml.MustParse(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: root
+  annotations:
+    config.kubernetes.io/path: deployments/root.yaml
+`),
+		yaml.MustParse(`apiVersion: v1
+kind: Service
+metadata:
+  name: root
+  annotations:
+    config.kubernetes.io/path: service.yaml
+`),
+		yaml.MustParse(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: subpkg
+  annotations:
+    config.kubernetes.io/path: subpkg/deployments/subpkg.yaml
+`),
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		expected []string
+		err      string
+	}{
+		{
+			name:     "directory glob",
+			pattern:  "deployments/*.yaml",
+			expected: []string{"deployments/root.yaml"},
+		},
+		{
+			name:     "leading ./ is ignored",
+			pattern:  "./service.yaml",
+			expected: []string{"service.yaml"},
+		},
+		{
+			name:     "subpackage",
+			pattern:  "subpkg/*/*.yaml",
+			expected: []string{"subpkg/deployments/subpkg.yaml"},
+		},
+		{
+			name:    "no match",
+			pattern: "*.yml",
+		},
+		{
+			name:    "invalid pattern",
+			pattern: "[",
+			err:     `invalid file path pattern "["`,
+		},
+	}
+
+	for i := range tests {
+		tc := tests[i]
+		t.Run(tc.name, func(t *testing.T) {
+			selected, err := SelectByFilePath(input, tc.pattern)
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			var paths []string
+			for _, node := range selected {
+				paths = append(paths, node.GetAnnotations()["config.kubernetes.io/path"])
+			}
+			assert.Equal(t, tc.expected, paths)
+		})
+	}
+}
+
+func TestSelectByFilePaths(t *testing.T) {
+	input := []*yaml.RNode{
+		yaml.MustParse(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: root
+  annotations:
+    config.kubernetes.io/path: deployments/root.yaml
+`),
+		yaml.MustParse(`apiVersion: v1
+kind: Service
+metadata:
+  name: root
+  annotations:
+    config.kubernetes.io/path: service.yaml
+`),
+		yaml.MustParse(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: subpkg
+  annotations:
+    config.kubernetes.io/path: subpkg/deployments/subpkg.yaml
+`),
+	}
+
+	selected, err := SelectByFilePaths(input, []string{"./service.yaml", "subpkg/deployments/subpkg.yaml", "missing.yaml"})
+	assert.NoError(t, err)
+	var paths []string
+	for _, node := range selected {
+		paths = append(paths, node.GetAnnotations()["config.kubernetes.io/path"])
+	}
+	assert.Equal(t, []string{"service.yaml", "subpkg/deployments/subpkg.yaml"}, paths)
+
+	selected, err = SelectByFilePaths(input, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, selected)
+}
+
+func TestSelectInput(t *testing.T) {
+	input := []*yaml.RNode{
+		yaml.MustParse(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+`),
+		yaml.MustParse(`apiVersion: v1
+kind: Secret
+metadata:
+  name: generated-secret
+`),
+		yaml.MustParse(`apiVersion: v1
+kind: Secret
+metadata:
+  name: secret
+`),
+	}
+
+	tests := []struct {
+		name       string
+		selectors  []kptfile.Selector
+		exclusions []kptfile.Selector
+		expected   []string
+		decisions  []string
+	}{
+		{
+			name:     "no selectors",
+			expected: []string{"nginx-deployment", "generated-secret", "secret"},
+			decisions: []string{
+				"nginx-deployment: selected",
+				"generated-secret: selected",
+				"secret: selected",
+			},
+		},
+		{
+			name:       "exclusion only",
+			exclusions: []kptfile.Selector{{Kind: "Secret", Name: "generated-secret"}},
+			expected:   []string{"nginx-deployment", "secret"},
+			decisions: []string{
+				"nginx-deployment: selected",
+				"generated-secret: not selected, excluded by kind=Secret,name=generated-secret",
+				"secret: selected",
+			},
+		},
+		{
+			name:       "exclusion wins over selector",
+			selectors:  []kptfile.Selector{{Kind: "Secret"}},
+			exclusions: []kptfile.Selector{{Name: "generated-secret"}},
+			expected:   []string{"secret"},
+			decisions: []string{
+				"nginx-deployment: not selected, no selector matches",
+				"generated-secret: not selected, excluded by name=generated-secret",
+				"secret: selected by kind=Secret",
+			},
+		},
+		{
+			name:       "empty exclusion is ignored",
+			selectors:  []kptfile.Selector{{Kind: "Deployment"}},
+			exclusions: []kptfile.Selector{{}},
+			expected:   []string{"nginx-deployment"},
+			decisions: []string{
+				"nginx-deployment: selected by kind=Deployment",
+				"generated-secret: not selected, no selector matches",
+				"secret: not selected, no selector matches",
+			},
+		},
+		{
+			name:      "first matching selector is recorded",
+			selectors: []kptfile.Selector{{Name: "secret"}, {Kind: "Secret"}},
+			expected:  []string{"generated-secret", "secret", "secret"},
+			decisions: []string{
+				"nginx-deployment: not selected, no selector matches",
+				"generated-secret: selected by kind=Secret",
+				"secret: selected by name=secret",
+			},
+		},
+	}
+
+	for i := range tests {
+		tc := tests[i]
+		t.Run(tc.name, func(t *testing.T) {
+			decisions := NewSelectionDecisions()
+			selected, err := SelectInput(input, tc.selectors, tc.exclusions, &SelectionContext{Decisions: decisions})
+			assert.NoError(t, err)
+			var names []string
+			for _, node := range selected {
+				names = append(names, node.GetName())
+			}
+			assert.Equal(t, tc.expected, names)
+			var recorded []string
+			for _, d := range decisions.List() {
+				assert.Equal(t, strings.HasPrefix(d.Reason, "selected"), d.Selected)
+				recorded = append(recorded, d.Node.GetName()+": "+d.Reason)
+			}
+			assert.Equal(t, tc.decisions, recorded)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// defaultTokenUsername is the username used for token authentication if no
+// username is provided. Most git servers ignore the username for tokens.
+const defaultTokenUsername = "git"
+
+// Credentials are used by git to authenticate with remote repositories.
+// They are passed to git using environment variables, so they never show up
+// in the arguments of the git commands.
+type Credentials struct {
+	// Username is the username for basic authentication over HTTPS.
+	Username string
+
+	// Token is the password or access token for basic authentication
+	// over HTTPS.
+	Token string
+
+	// SSHKeyPath is the path to the private key used for repositories
+	// accessed over SSH.
+	SSHKeyPath string
+}
+
+// IsEmpty returns true if no credentials are provided.
+func (c Credentials) IsEmpty() bool {
+	return c.Token == "" && c.SSHKeyPath == ""
+}
+
+// env returns the environment variables passing the credentials to git.
+func (c Credentials) env() []string {
+	var env []string
+	if c.Token != "" {
+		username := c.Username
+		if username == "" {
+			username = defaultTokenUsername
+		}
+		auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + c.Token))
+		env = append(env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+auth)
+	}
+	if c.SSHKeyPath != "" {
+		env = append(env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -i '%s' -o IdentitiesOnly=yes",
+			strings.ReplaceAll(c.SSHKeyPath, "'", `'\''`)))
+	}
+	return env
+}
+
+type credentialsKey struct{}
+
+// WithCredentials returns a context which makes all git commands run with it
+// authenticate using creds.
+func WithCredentials(ctx context.Context, creds Credentials) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, creds)
+}
+
+// credentialsFromContext returns the credentials in ctx, if any.
+func credentialsFromContext(ctx context.Context) Credentials {
+	creds, _ := ctx.Value(credentialsKey{}).(Credentials)
+	return creds
+}
@@ -27,6 +27,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/GoogleContainerTools/kpt/internal/errors"
@@ -101,6 +102,9 @@ func (g *GitLocalRunner) run(ctx context.Context, verbose bool, command string,
 	// Disable git prompting the user for credentials.
 	cmd.Env = append(os.Environ(),
 		"GIT_TERMINAL_PROMPT=0")
+	// Credentials are passed in the environment, so they aren't printed
+	// together with the arguments when debugging.
+	cmd.Env = append(cmd.Env, credentialsFromContext(ctx).env()...)
 	pr := printer.FromContextOrDie(ctx)
 	cmdStdout := &bytes.Buffer{}
 	cmdStderr := &bytes.Buffer{}
@@ -305,6 +309,20 @@ func (gur *GitUpstreamRepo) getRepoDir(uri string) string {
 	return strings.ToLower(base32.StdEncoding.EncodeToString(md5.New().Sum([]byte(uri))))
 }
 
+// repoCacheLocks holds a *sync.Mutex for every repo uri that is in use.
+var repoCacheLocks sync.Map
+
+// LockRepoCache locks the cache of the repo with the given uri within this
+// process and returns the function that releases the lock. The cached
+// repo and its worktree are shared by all fetches of the repo, so the lock
+// must be held while fetching packages from the same repo concurrently.
+func LockRepoCache(uri string) func() {
+	l, _ := repoCacheLocks.LoadOrStore(uri, &sync.Mutex{})
+	mu := l.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
 // getRepoCacheDir
 func (gur *GitUpstreamRepo) getRepoCacheDir() (string, error) {
 	const op errors.Op = "gitutil.getRepoCacheDir"
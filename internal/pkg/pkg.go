@@ -86,6 +86,34 @@ func (e *RemoteKptfileError) Unwrap() error {
 	return e.Err
 }
 
+// MissingUpstreamError is returned when a command that needs the upstream
+// of a package, e.g. diff or update, is run on a package whose Kptfile
+// doesn't (fully) specify it.
+type MissingUpstreamError struct {
+	Path types.UniquePath
+	// Field is the missing Kptfile field, e.g. `upstream.git`.
+	Field string
+}
+
+func (e *MissingUpstreamError) Error() string {
+	return fmt.Sprintf("package at %q must have an upstream reference: Kptfile has no `%s`",
+		e.Path.String(), e.Field)
+}
+
+// CheckUpstream returns a MissingUpstreamError if the Kptfile of the package
+// at path doesn't specify the git repo and ref of its upstream.
+func CheckUpstream(kf *kptfilev1.KptFile, path types.UniquePath) error {
+	switch {
+	case kf.Upstream == nil:
+		return &MissingUpstreamError{Path: path, Field: "upstream"}
+	case kf.Upstream.Git == nil:
+		return &MissingUpstreamError{Path: path, Field: "upstream.git"}
+	case kf.Upstream.Git.Repo == "":
+		return &MissingUpstreamError{Path: path, Field: "upstream.git.repo"}
+	}
+	return nil
+}
+
 // DeprecatedKptfileError is an implementation of the error interface that is
 // returned whenever kpt encounters a Kptfile using the legacy format.
 type DeprecatedKptfileError struct {
@@ -98,10 +126,21 @@ func (e *DeprecatedKptfileError) Error() string {
 
 type UnknownKptfileResourceError struct {
 	GVK schema.GroupVersionKind
+	// Document is the index of the YAML document of the resource in the
+	// Kptfile, starting at 0.
+	Document int
+	// Line and Column are the position of the resource in the Kptfile. They
+	// are 0 if the position is unknown.
+	Line   int
+	Column int
 }
 
 func (e *UnknownKptfileResourceError) Error() string {
-	return fmt.Sprintf("unknown resource type %q found in Kptfile", e.GVK.String())
+	if e.Line == 0 {
+		return fmt.Sprintf("unknown resource type %q found in Kptfile", e.GVK.String())
+	}
+	return fmt.Sprintf("unknown resource type %q found in Kptfile at line %d, column %d",
+		e.GVK.String(), e.Line, e.Column)
 }
 
 // RGError is an implementation of the error interface that is returned whenever
@@ -222,16 +261,31 @@ func DecodeKptfile(in io.Reader) (*kptfilev1.KptFile, error) {
 	return kf, nil
 }
 
-// CheckKptfileVersion verifies the apiVersion and kind of the resource
+// CheckKptfileVersion verifies the apiVersion and kind of the resources
 // within the Kptfile. If the legacy version is found, the DeprecatedKptfileError
 // is returned. If the currently supported apiVersion and kind is found, no
 // error is returned.
 func CheckKptfileVersion(content []byte) error {
-	r, err := yaml.Parse(string(content))
-	if err != nil {
-		return err
+	d := yaml.NewDecoder(bytes.NewBuffer(content))
+	for i := 0; ; i++ {
+		n := &yaml.Node{}
+		if err := d.Decode(n); err != nil {
+			// An empty Kptfile is an error, the end of the following
+			// documents is not.
+			if i > 0 && err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := checkResourceVersion(yaml.NewRNode(n), i); err != nil {
+			return err
+		}
 	}
+}
 
+// checkResourceVersion verifies the apiVersion and kind of the resource r,
+// which is the document at index of the Kptfile.
+func checkResourceVersion(r *yaml.RNode, index int) error {
 	m, err := r.GetMeta()
 	if err != nil {
 		return err
@@ -260,8 +314,17 @@ func CheckKptfileVersion(content []byte) error {
 	// If the combination of group, version and kind are unknown to us, return
 	// UnknownKptfileResourceError.
 	default:
+		// Point at the apiVersion of the resource, or at the start of the
+		// document if it doesn't have one.
+		pos := r.YNode()
+		if f := r.Field(yaml.APIVersionField); f != nil {
+			pos = f.Key.YNode()
+		}
 		return &UnknownKptfileResourceError{
-			GVK: gv.WithKind(kind),
+			GVK:      gv.WithKind(kind),
+			Document: index,
+			Line:     pos.Line,
+			Column:   pos.Column,
 		}
 	}
 }
@@ -26,6 +26,7 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/util/pathutil"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
@@ -535,3 +536,111 @@ func Chdir(t *testing.T, path string) func() {
 	}
 	return revertFunc
 }
+
+func TestCheckUpstream(t *testing.T) {
+	testCases := map[string]struct {
+		upstream *kptfilev1.Upstream
+		field    string
+	}{
+		"no upstream": {
+			field: "upstream",
+		},
+		"no git upstream": {
+			upstream: &kptfilev1.Upstream{Type: kptfilev1.GitOrigin},
+			field:    "upstream.git",
+		},
+		"no git repo": {
+			upstream: &kptfilev1.Upstream{
+				Type: kptfilev1.GitOrigin,
+				Git:  &kptfilev1.Git{Directory: "/", Ref: "main"},
+			},
+			field: "upstream.git.repo",
+		},
+		"git upstream": {
+			upstream: &kptfilev1.Upstream{
+				Type: kptfilev1.GitOrigin,
+				Git:  &kptfilev1.Git{Repo: "https://github.com/foo/bar", Directory: "/", Ref: "main"},
+			},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			err := CheckUpstream(&kptfilev1.KptFile{Upstream: tc.upstream}, "/foo")
+			if tc.field == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, &MissingUpstreamError{Path: "/foo", Field: tc.field}, err)
+		})
+	}
+}
+
+func TestCheckKptfileVersion(t *testing.T) {
+	testCases := map[string]struct {
+		content  string
+		expected error
+	}{
+		"supported version": {
+			content: `
+apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: foo
+`,
+		},
+		"deprecated version": {
+			content: `
+apiVersion: kpt.dev/v1alpha2
+kind: Kptfile
+metadata:
+  name: foo
+`,
+			expected: &DeprecatedKptfileError{Version: "v1alpha2"},
+		},
+		"unknown resource": {
+			content: `
+# comment
+kind: Foo
+apiVersion: example.com/v1
+metadata:
+  name: foo
+`,
+			expected: &UnknownKptfileResourceError{
+				GVK:    schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Foo"},
+				Line:   4,
+				Column: 1,
+			},
+		},
+		"unknown resource in second document": {
+			content: `
+apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: foo
+---
+apiVersion: example.com/v1
+kind: Foo
+metadata:
+  name: foo
+`,
+			expected: &UnknownKptfileResourceError{
+				GVK:      schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Foo"},
+				Document: 1,
+				Line:     7,
+				Column:   1,
+			},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			err := CheckKptfileVersion([]byte(tc.content))
+			if tc.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tc.expected, err)
+		})
+	}
+}
@@ -45,6 +45,9 @@ type Options struct {
 	PkgPath types.UniquePath
 	// PkgDisplayPath is the display path for the package
 	PkgDisplayPath types.DisplayPath
+	// Info marks the message as informational, informational messages
+	// are not displayed by a printer with QuietVerbosity
+	Info bool
 }
 
 // NewOpt returns a pointer to new options
@@ -64,8 +67,31 @@ func (opt *Options) PkgDisplay(p types.DisplayPath) *Options {
 	return opt
 }
 
+// Informational marks the message as informational in options
+func (opt *Options) Informational() *Options {
+	opt.Info = true
+	return opt
+}
+
+// Verbosity defines which messages are displayed by the printer.
+type Verbosity int
+
+const (
+	// NormalVerbosity displays all messages.
+	NormalVerbosity Verbosity = iota
+	// QuietVerbosity doesn't display informational messages, errors and
+	// warnings are still displayed.
+	QuietVerbosity
+)
+
 // New returns an instance of Printer.
 func New(outStream, errStream io.Writer) Printer {
+	return NewWithVerbosity(outStream, errStream, NormalVerbosity)
+}
+
+// NewWithVerbosity returns an instance of Printer which displays the
+// messages according to the verbosity v.
+func NewWithVerbosity(outStream, errStream io.Writer, v Verbosity) Printer {
 	if outStream == nil {
 		outStream = os.Stdout
 	}
@@ -75,6 +101,7 @@ func New(outStream, errStream io.Writer) Printer {
 	return &printer{
 		outStream: outStream,
 		errStream: errStream,
+		verbosity: v,
 	}
 }
 
@@ -82,6 +109,7 @@ func New(outStream, errStream io.Writer) Printer {
 type printer struct {
 	outStream io.Writer
 	errStream io.Writer
+	verbosity Verbosity
 }
 
 // The key type is unexported to prevent collisions with context keys defined in
@@ -127,6 +155,9 @@ func (pr *printer) OptPrintf(opt *Options, format string, args ...interface{}) {
 		fmt.Fprintf(pr.errStream, format, args...)
 		return
 	}
+	if opt.Info && pr.verbosity == QuietVerbosity {
+		return
+	}
 	o := pr.errStream
 	if !opt.PkgDisplayPath.Empty() {
 		format = fmt.Sprintf("Package %q: ", string(opt.PkgDisplayPath)) + format
@@ -18,22 +18,32 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
 	"github.com/GoogleContainerTools/kpt/internal/util/function"
 	"github.com/GoogleContainerTools/kpt/internal/util/httputil"
 	"github.com/GoogleContainerTools/kpt/internal/util/porch"
+	kptfile "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
 	"github.com/spf13/cobra"
 	"golang.org/x/mod/semver"
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/sets"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 const (
@@ -41,6 +51,7 @@ const (
 	trueString                              = "true"
 	Stdout                                  = "stdout"
 	Unwrap                                  = "unwrap"
+	ResourceList                            = "resourcelist"
 	dockerVersionTimeout      time.Duration = 5 * time.Second
 	minSupportedDockerVersion string        = "v20.10.0"
 	FunctionsCatalogURL                     = "https://catalog.kpt.dev/catalog-v2.json"
@@ -65,6 +76,10 @@ func PrintErrorStacktrace() bool {
 // StackOnError if true, will print a stack trace on failure.
 var StackOnError bool
 
+// Color controls whether error messages are colored. One of auto, always
+// or never.
+var Color string
+
 // DockerCmdAvailable runs `docker version` to check that the docker command is
 // available and is a supported version. Returns an error with installation
 // instructions if it is not
@@ -123,7 +138,7 @@ func StringToImagePullPolicy(v string) fnruntime.ImagePullPolicy {
 }
 
 // WriteFnOutput writes the output resources of function commands to provided destination
-func WriteFnOutput(dest, content string, fromStdin bool, w io.Writer) error {
+func WriteFnOutput(dest, content string, fromStdin bool, fnConfig *yaml.RNode, w io.Writer) error {
 	r := strings.NewReader(content)
 	switch dest {
 	case Stdout:
@@ -133,6 +148,10 @@ func WriteFnOutput(dest, content string, fromStdin bool, w io.Writer) error {
 	case Unwrap:
 		// if user specified dest is "unwrap", write the unwrapped content to the provided writer
 		return WriteToOutput(r, w, "")
+	case ResourceList:
+		// if user specified dest is "resourcelist", write the content wrapped
+		// in a ResourceList including the functionConfig to the provided writer
+		return writeResourceList(r, fnConfig, w)
 	case "":
 		if fromStdin {
 			// if user didn't specify dest, and if input is from STDIN, write the wrapped content provided writer
@@ -147,6 +166,100 @@ func WriteFnOutput(dest, content string, fromStdin bool, w io.Writer) error {
 	return nil
 }
 
+// writeResourceList reads the input from r and writes it to w as a single
+// ResourceList in the KRM function wire format, with fnConfig as its
+// functionConfig.
+func writeResourceList(r io.Reader, fnConfig *yaml.RNode, w io.Writer) error {
+	return kio.Pipeline{
+		Inputs: []kio.Reader{&kio.ByteReader{
+			Reader:                r,
+			OmitReaderAnnotations: true,
+			WrapBareSeqNode:       true,
+		}},
+		Outputs: []kio.Writer{kio.ByteWriter{
+			Writer:                w,
+			KeepReaderAnnotations: true,
+			FunctionConfig:        fnConfig,
+			WrappingKind:          kio.ResourceListKind,
+			WrappingAPIVersion:    kio.ResourceListAPIVersion,
+		}},
+	}.Execute()
+}
+
+// SortFnOutput returns the resources of the function output content, a
+// ResourceList, ordered by apiVersion, kind, namespace and name. Resources
+// with the same key keep their order. If stableFileNames is set, every
+// resource is moved to its own file named <kind>_<name>.yaml, in a directory
+// named after its namespace under the directory of its current file, so the
+// files don't depend on where the functions put the resources. Kptfiles keep
+// their path.
+func SortFnOutput(content string, stableFileNames bool) (string, error) {
+	nodes, err := (&kio.ByteReader{
+		Reader:                strings.NewReader(content),
+		OmitReaderAnnotations: true,
+		WrapBareSeqNode:       true,
+	}).Read()
+	if err != nil {
+		return "", err
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return sortKey(nodes[i]) < sortKey(nodes[j])
+	})
+	if stableFileNames {
+		if err := setStableFileNames(nodes); err != nil {
+			return "", err
+		}
+	}
+	var out bytes.Buffer
+	err = kio.ByteWriter{
+		Writer:                &out,
+		KeepReaderAnnotations: true,
+		WrappingKind:          kio.ResourceListKind,
+		WrappingAPIVersion:    kio.ResourceListAPIVersion,
+	}.Write(nodes)
+	return out.String(), err
+}
+
+// sortKey returns the apiVersion, kind, namespace and name of the resource,
+// separated by a character which sorts before the characters they may contain.
+func sortKey(node *yaml.RNode) string {
+	return strings.Join([]string{node.GetApiVersion(), node.GetKind(), node.GetNamespace(), node.GetName()}, "\x00")
+}
+
+// setStableFileNames sets the path annotations of the resources as described
+// in SortFnOutput. Resources ending up in the same file, e.g. with the same
+// kind and name but a different apiVersion, are indexed in their order.
+func setStableFileNames(nodes []*yaml.RNode) error {
+	indexes := map[string]int{}
+	for _, node := range nodes {
+		p, _, err := kioutil.GetFileAnnotations(node)
+		if err != nil {
+			return err
+		}
+		p = filepath.ToSlash(p)
+		if path.Base(p) != kptfile.KptFileName {
+			meta, err := node.GetMeta()
+			if err != nil {
+				return err
+			}
+			p = kioutil.CreatePathAnnotationValue(path.Dir(p), meta)
+		}
+		index := strconv.Itoa(indexes[p])
+		indexes[p]++
+		for _, a := range []struct{ key, value string }{
+			{kioutil.PathAnnotation, p},
+			{kioutil.LegacyPathAnnotation, p}, // nolint:staticcheck
+			{kioutil.IndexAnnotation, index},
+			{kioutil.LegacyIndexAnnotation, index}, // nolint:staticcheck
+		} {
+			if err := node.PipeE(yaml.SetAnnotation(a.key, a.value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // WriteToOutput reads the input from r and writes the output to either w or outDir
 func WriteToOutput(r io.Reader, w io.Writer, outDir string) error {
 	var outputs []kio.Writer
@@ -181,6 +294,72 @@ func CheckDirectoryNotPresent(outDir string) error {
 	return nil
 }
 
+// CheckOutputDirectory returns an error explaining why the resources can't be
+// written to the --output directory outDir, which must either not exist yet or
+// be empty. If it doesn't exist, its nearest existing parent must be a
+// writable directory.
+func CheckOutputDirectory(outDir string) error {
+	info, err := os.Stat(outDir)
+	switch {
+	case err == nil && !info.IsDir():
+		return fmt.Errorf("--output %q is a file, it must be a new or empty directory, "+
+			"or use --output %s to write the resources to stdout", outDir, Stdout)
+	case err == nil:
+		empty, err := isEmptyDir(outDir)
+		if err != nil {
+			return fmt.Errorf("cannot read --output directory %q: %w", outDir, err)
+		}
+		if !empty {
+			return fmt.Errorf("--output directory %q already exists and isn't empty, it must be a new or empty "+
+				"directory, or use --output %s to write the resources to stdout", outDir, Stdout)
+		}
+		return nil
+	case !isMissing(err):
+		return fmt.Errorf("cannot check --output directory %q: %w", outDir, err)
+	}
+
+	// the missing parents are created together with the directory
+	parent := filepath.Dir(filepath.Clean(outDir))
+	for {
+		info, err = os.Stat(parent)
+		if err == nil || !isMissing(err) || filepath.Dir(parent) == parent {
+			break
+		}
+		parent = filepath.Dir(parent)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot create --output directory %q: %w", outDir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("cannot create --output directory %q, as %q is a file", outDir, parent)
+	}
+	// creating a directory is the only portable way to tell if parent is writable
+	probe, err := ioutil.TempDir(parent, ".kpt-output-")
+	if err != nil {
+		return fmt.Errorf("cannot create --output directory %q, as %q is not writable", outDir, parent)
+	}
+	return os.Remove(probe)
+}
+
+// isMissing returns true if err tells that a path doesn't exist, including
+// because one of its parents is a file.
+func isMissing(err error) bool {
+	return os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR)
+}
+
+// isEmptyDir returns true if the directory at path doesn't contain any files.
+func isEmptyDir(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if _, err = f.Readdirnames(1); err == io.EOF {
+		return true, nil
+	}
+	return false, err
+}
+
 func GetKeywordsFromFlag(cmd *cobra.Command) []string {
 	flagVal := cmd.Flag("keywords").Value.String()
 	flagVal = strings.TrimPrefix(flagVal, "[")
@@ -216,6 +395,63 @@ func SuggestKeywords(cmd *cobra.Command) []string {
 	return porch.UnifyKeywords(matched)
 }
 
+// SuggestKinds returns the distinct kinds of the resources in the package
+// directory given as the first argument, or the current directory if no
+// argument is given.
+func SuggestKinds(args []string) []string {
+	return suggestResourceValues(args, func(node *yaml.RNode) string {
+		return node.GetKind()
+	})
+}
+
+// SuggestNames returns the distinct names of the resources in the package
+// directory given as the first argument, or the current directory if no
+// argument is given. Only resources of the given kind are considered if kind
+// isn't empty.
+func SuggestNames(args []string, kind string) []string {
+	return suggestResourceValues(args, func(node *yaml.RNode) string {
+		if kind != "" && node.GetKind() != kind {
+			return ""
+		}
+		return node.GetName()
+	})
+}
+
+// suggestResourceValues returns the sorted distinct non-empty values returned
+// by value for the resources in the package. Nothing is returned if the
+// package can't be read, so that shell completion doesn't show errors.
+func suggestResourceValues(args []string, value func(node *yaml.RNode) string) []string {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if path == "-" {
+		return nil
+	}
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return nil
+	}
+	nodes, err := (&kio.LocalPackageReader{
+		PackagePath:           path,
+		PackageFileName:       kptfile.KptFileName,
+		IncludeSubpackages:    true,
+		MatchFilesGlob:        append([]string{kptfile.KptFileName}, kio.MatchAll...),
+		OmitReaderAnnotations: true,
+	}).Read()
+	if err != nil {
+		return nil
+	}
+	values := sets.String{}
+	for _, node := range nodes {
+		if v := value(node); v != "" {
+			values.Insert(v)
+		}
+	}
+	list := values.List()
+	sort.Strings(list)
+	return list
+}
+
 func DiscoverFunctions(cmd *cobra.Command) []v1alpha1.Function {
 	porchFns := porch.FunctionListGetter{}.Get(cmd.Context())
 	catalogV2Fns := fetchCatalogFunctions()
@@ -16,7 +16,10 @@ package cmdutil
 
 import (
 	"bytes"
+	"fmt"
+	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"testing"
 
@@ -24,6 +27,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 func TestWriteFnOutput(t *testing.T) {
@@ -273,7 +278,7 @@ metadata:
 			}
 
 			// this method should create a directory and write the output if the dest is a directory path
-			err := WriteFnOutput(test.dest, test.content, test.fromStdin, &test.writer)
+			err := WriteFnOutput(test.dest, test.content, test.fromStdin, nil, &test.writer)
 			if !assert.NoError(t, err) {
 				t.FailNow()
 			}
@@ -308,6 +313,153 @@ metadata:
 	}
 }
 
+func TestWriteFnOutput_ResourceList(t *testing.T) {
+	content := `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: nginx-deployment
+    annotations:
+      config.kubernetes.io/index: '0'
+      config.kubernetes.io/path: 'deployment.yaml'
+      internal.config.kubernetes.io/index: '0'
+      internal.config.kubernetes.io/path: 'deployment.yaml'
+  spec:
+    replicas: 3
+`
+	fnConfig, err := yaml.Parse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: function-input
+data:
+  namespace: staging
+`)
+	require.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, WriteFnOutput(ResourceList, content, false, fnConfig, out))
+	assert.Equal(t, `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: nginx-deployment
+    annotations:
+      config.kubernetes.io/index: '0'
+      config.kubernetes.io/path: 'deployment.yaml'
+      internal.config.kubernetes.io/index: '0'
+      internal.config.kubernetes.io/path: 'deployment.yaml'
+  spec:
+    replicas: 3
+functionConfig:
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: function-input
+  data:
+    namespace: staging
+`, out.String())
+
+	// the output can be read back as a ResourceList
+	rw := &kio.ByteReader{Reader: out, OmitReaderAnnotations: true}
+	nodes, err := rw.Read()
+	require.NoError(t, err)
+	if assert.Len(t, nodes, 1) {
+		assert.Equal(t, "nginx-deployment", nodes[0].GetName())
+	}
+	assert.Equal(t, fnConfig.MustString(), rw.FunctionConfig.MustString())
+}
+
+func TestSortFnOutput(t *testing.T) {
+	content := `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: nginx-svc
+    annotations:
+      internal.config.kubernetes.io/index: '1'
+      internal.config.kubernetes.io/path: 'all.yaml'
+- apiVersion: kpt.dev/v1
+  kind: Kptfile
+  metadata:
+    name: pkg
+    annotations:
+      internal.config.kubernetes.io/index: '0'
+      internal.config.kubernetes.io/path: 'Kptfile'
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cm
+    namespace: staging
+    annotations:
+      internal.config.kubernetes.io/index: '0'
+      internal.config.kubernetes.io/path: 'sub/cm.yaml'
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: nginx
+    namespace: staging
+    annotations:
+      internal.config.kubernetes.io/index: '0'
+      internal.config.kubernetes.io/path: 'all.yaml'
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: nginx
+    annotations:
+      internal.config.kubernetes.io/index: '2'
+      internal.config.kubernetes.io/path: 'all.yaml'
+`
+	tests := map[string]struct {
+		stableFileNames bool
+		expected        []string
+	}{
+		"sorted": {
+			expected: []string{
+				"Deployment nginx all.yaml 2",
+				"Deployment staging/nginx all.yaml 0",
+				"Kptfile pkg Kptfile 0",
+				"ConfigMap staging/cm sub/cm.yaml 0",
+				"Service nginx-svc all.yaml 1",
+			},
+		},
+		"stable file names": {
+			stableFileNames: true,
+			expected: []string{
+				"Deployment nginx deployment_nginx.yaml 0",
+				"Deployment staging/nginx staging/deployment_nginx.yaml 0",
+				"Kptfile pkg Kptfile 0",
+				"ConfigMap staging/cm sub/staging/configmap_cm.yaml 0",
+				"Service nginx-svc service_nginx-svc.yaml 0",
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			out, err := SortFnOutput(content, tc.stableFileNames)
+			require.NoError(t, err)
+			nodes, err := (&kio.ByteReader{Reader: bytes.NewBufferString(out), OmitReaderAnnotations: true}).Read()
+			require.NoError(t, err)
+			var resources []string
+			for _, node := range nodes {
+				id := node.GetName()
+				if ns := node.GetNamespace(); ns != "" {
+					id = ns + "/" + id
+				}
+				p, i, err := kioutil.GetFileAnnotations(node)
+				require.NoError(t, err)
+				resources = append(resources, fmt.Sprintf("%s %s %s %s", node.GetKind(), id, p, i))
+			}
+			assert.Equal(t, tc.expected, resources)
+		})
+	}
+}
+
 func TestListImages(t *testing.T) {
 	functions := parseFunctions(`{
   "apply-setters": {
@@ -389,3 +541,111 @@ func TestIsSupportedDockerVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestSuggestResources(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: app
+`,
+		"resources.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: nginx-svc
+`,
+		"sub/cm.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`,
+		"README.md": "not a resource",
+	}
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+
+	assert.Equal(t, []string{"ConfigMap", "Deployment", "Kptfile", "Service"}, SuggestKinds([]string{dir}))
+	assert.Equal(t, []string{"app", "config", "nginx", "nginx-svc"}, SuggestNames([]string{dir}, ""))
+	assert.Equal(t, []string{"nginx-svc"}, SuggestNames([]string{dir}, "Service"))
+	assert.Empty(t, SuggestKinds([]string{filepath.Join(dir, "missing")}))
+	assert.Empty(t, SuggestKinds([]string{filepath.Join(dir, "README.md")}))
+	assert.Empty(t, SuggestNames([]string{"-"}, ""))
+}
+
+func TestCheckOutputDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "empty"), 0700))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "populated"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "populated", "resources.yaml"), nil, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), nil, 0600))
+
+	testCases := map[string]struct {
+		outDir      string
+		expectedErr string
+	}{
+		"new directory": {
+			outDir: filepath.Join(dir, "out"),
+		},
+		"new nested directory": {
+			outDir: filepath.Join(dir, "a", "b", "out"),
+		},
+		"empty directory": {
+			outDir: filepath.Join(dir, "empty"),
+		},
+		"populated directory": {
+			outDir: filepath.Join(dir, "populated"),
+			expectedErr: fmt.Sprintf("--output directory %q already exists and isn't empty, it must be a new or empty "+
+				"directory, or use --output stdout to write the resources to stdout", filepath.Join(dir, "populated")),
+		},
+		"file": {
+			outDir: filepath.Join(dir, "file"),
+			expectedErr: fmt.Sprintf("--output %q is a file, it must be a new or empty directory, "+
+				"or use --output stdout to write the resources to stdout", filepath.Join(dir, "file")),
+		},
+		"parent is a file": {
+			outDir: filepath.Join(dir, "file", "out"),
+			expectedErr: fmt.Sprintf("cannot create --output directory %q, as %q is a file",
+				filepath.Join(dir, "file", "out"), filepath.Join(dir, "file")),
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			err := CheckOutputDirectory(tc.outDir)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+	// checking the directory doesn't create it
+	assert.NoDirExists(t, filepath.Join(dir, "out"))
+	assert.NoDirExists(t, filepath.Join(dir, "a"))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+func TestCheckOutputDirectory_parentNotWritable(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("directory permissions aren't enforced")
+	}
+	dir := filepath.Join(t.TempDir(), "read-only")
+	require.NoError(t, os.Mkdir(dir, 0500))
+	defer func() {
+		_ = os.Chmod(dir, 0700)
+	}()
+	outDir := filepath.Join(dir, "out")
+	assert.EqualError(t, CheckOutputDirectory(outDir),
+		fmt.Sprintf("cannot create --output directory %q, as %q is not writable", outDir, dir))
+}
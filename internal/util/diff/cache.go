@@ -0,0 +1,199 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// PkgCacheDirEnv is the name of the environment variable that controls the
+// directory where fetched upstream packages are cached between diff
+// invocations. Defaults to <UserCacheDir>/kpt/diff if unspecified.
+const PkgCacheDirEnv = "KPT_DIFF_CACHE_DIR"
+
+// DefaultPkgCacheMaxAge is the duration after which a cached package that
+// hasn't been used is evicted from the cache.
+const DefaultPkgCacheMaxAge = 7 * 24 * time.Hour
+
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// cachingPkgGetter wraps a PkgGetter and caches the fetched packages keyed
+// by repo, directory and the commit the ref resolves to. The ref is resolved
+// against the remote on every call, so a branch which has advanced since the
+// package was cached is fetched again.
+type cachingPkgGetter struct {
+	// PkgGetter fetches the packages which aren't in the cache.
+	PkgGetter PkgGetter
+
+	// Dir is the directory where the packages are cached.
+	Dir string
+
+	// MaxAge is the duration after which unused entries are evicted.
+	MaxAge time.Duration
+
+	// resolveCommit resolves a ref to a commit SHA. Defaults to looking
+	// up the ref in the remote repository.
+	resolveCommit func(ctx context.Context, repo, ref string) (string, bool, error)
+}
+
+// newCachingPkgGetter returns a cachingPkgGetter storing packages in the
+// default cache location, or nil if the location can't be determined.
+func newCachingPkgGetter(pg PkgGetter) *cachingPkgGetter {
+	dir, err := getPkgCacheDir()
+	if err != nil {
+		return nil
+	}
+	return &cachingPkgGetter{
+		PkgGetter: pg,
+		Dir:       dir,
+		MaxAge:    DefaultPkgCacheMaxAge,
+	}
+}
+
+// getPkgCacheDir returns the directory where fetched packages are cached.
+func getPkgCacheDir() (string, error) {
+	if dir := os.Getenv(PkgCacheDirEnv); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "kpt", "diff"), nil
+}
+
+// GetPkg returns the package from the cache if the ref still resolves to the
+// cached commit, otherwise it fetches the package and adds it to the cache.
+// Failures to read from or write to the cache fall back to fetching the package.
+func (pg *cachingPkgGetter) GetPkg(ctx context.Context, stagingDir, targetDir, repo, path, ref string) (string, error) {
+	resolve := pg.resolveCommit
+	if resolve == nil {
+		resolve = resolveRemoteCommit
+	}
+	commit, found, err := resolve(ctx, repo, ref)
+	if err != nil || !found {
+		return pg.PkgGetter.GetPkg(ctx, stagingDir, targetDir, repo, path, ref)
+	}
+
+	entry := filepath.Join(pg.Dir, cacheKey(repo, path, commit))
+	if _, err := os.Stat(entry); err == nil {
+		dir, err := stageDirectory(stagingDir, targetDir)
+		if err != nil {
+			return dir, err
+		}
+		if err := copyutil.CopyDir(entry, dir); err == nil {
+			now := time.Now()
+			_ = os.Chtimes(entry, now, now)
+			return dir, nil
+		}
+		// the cache entry is unusable, fetch the package again
+		if err := os.RemoveAll(dir); err != nil {
+			return dir, err
+		}
+	}
+
+	dir, err := pg.PkgGetter.GetPkg(ctx, stagingDir, targetDir, repo, path, ref)
+	if err != nil {
+		return dir, err
+	}
+	pg.store(dir, repo, path, commit)
+	pg.evict()
+	return dir, nil
+}
+
+// store adds the package in dir to the cache. The package is only stored if
+// the commit it was fetched at matches the commit the ref resolved to, as the
+// ref may have moved in between resolving and fetching it.
+func (pg *cachingPkgGetter) store(dir, repo, path, commit string) {
+	kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, dir)
+	if err != nil || kf.UpstreamLock == nil || kf.UpstreamLock.Git == nil ||
+		kf.UpstreamLock.Git.Commit != commit {
+		return
+	}
+	if err := os.MkdirAll(pg.Dir, os.ModePerm); err != nil {
+		return
+	}
+	tmp, err := ioutil.TempDir(pg.Dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	if err := copyutil.CopyDir(dir, tmp); err != nil {
+		_ = os.RemoveAll(tmp)
+		return
+	}
+	if err := os.Rename(tmp, filepath.Join(pg.Dir, cacheKey(repo, path, commit))); err != nil {
+		// most likely another invocation cached the same package concurrently
+		_ = os.RemoveAll(tmp)
+	}
+}
+
+// evict removes all cache entries which haven't been used within MaxAge.
+func (pg *cachingPkgGetter) evict() {
+	if pg.MaxAge <= 0 {
+		return
+	}
+	entries, err := ioutil.ReadDir(pg.Dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if time.Since(e.ModTime()) > pg.MaxAge {
+			_ = os.RemoveAll(filepath.Join(pg.Dir, e.Name()))
+		}
+	}
+}
+
+// cacheKey returns the name of the cache entry for the package at path in
+// repo at the given commit.
+func cacheKey(repo, path, commit string) string {
+	h := sha256.New()
+	for _, s := range []string{repo, path, commit} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// resolveRemoteCommit resolves ref to a commit using the branches and tags
+// of the remote repo. A full commit SHA resolves to itself.
+func resolveRemoteCommit(ctx context.Context, repo, ref string) (string, bool, error) {
+	if commitPattern.MatchString(ref) {
+		return ref, true, nil
+	}
+	unlock := gitutil.LockRepoCache(repo)
+	defer unlock()
+	gur, err := gitutil.NewGitUpstreamRepo(ctx, repo)
+	if err != nil {
+		return "", false, err
+	}
+	commit, found := gur.ResolveRef(ref)
+	return commit, found, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePkgGetter writes a package containing the commit it was fetched at.
+type fakePkgGetter struct {
+	commit  string
+	fetches int
+}
+
+func (pg *fakePkgGetter) GetPkg(_ context.Context, stagingDir, targetDir, repo, path, ref string) (string, error) {
+	pg.fetches++
+	dir, err := stageDirectory(stagingDir, targetDir)
+	if err != nil {
+		return dir, err
+	}
+	kf := kptfileutil.DefaultKptfile(targetDir)
+	kf.UpstreamLock = &kptfilev1.UpstreamLock{
+		Type: kptfilev1.GitOrigin,
+		Git: &kptfilev1.GitLock{
+			Repo:      repo,
+			Directory: path,
+			Ref:       ref,
+			Commit:    pg.commit,
+		},
+	}
+	if err := kptfileutil.WriteFile(dir, kf); err != nil {
+		return dir, err
+	}
+	return dir, ioutil.WriteFile(filepath.Join(dir, "commit.txt"), []byte(pg.commit), 0600)
+}
+
+func TestCachingPkgGetter(t *testing.T) {
+	fake := &fakePkgGetter{commit: "a"}
+	remoteCommit := "a"
+	pg := &cachingPkgGetter{
+		PkgGetter: fake,
+		Dir:       t.TempDir(),
+		MaxAge:    time.Hour,
+		resolveCommit: func(context.Context, string, string) (string, bool, error) {
+			return remoteCommit, true, nil
+		},
+	}
+
+	getPkg := func(target string) string {
+		dir, err := pg.GetPkg(context.Background(), t.TempDir(), target, "https://repo", "/pkg", "main")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, "commit.txt"))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return string(b)
+	}
+
+	// the first fetch populates the cache, the second one reuses it
+	assert.Equal(t, "a", getPkg("first"))
+	assert.Equal(t, "a", getPkg("second"))
+	assert.Equal(t, 1, fake.fetches)
+
+	// the branch moved, so the package must be fetched again
+	remoteCommit, fake.commit = "b", "b"
+	assert.Equal(t, "b", getPkg("third"))
+	assert.Equal(t, 2, fake.fetches)
+
+	// the entry for the old commit expired and is evicted by the next fetch
+	old := time.Now().Add(-2 * time.Hour)
+	oldEntry := filepath.Join(pg.Dir, cacheKey("https://repo", "/pkg", "a"))
+	assert.NoError(t, os.Chtimes(oldEntry, old, old))
+	remoteCommit, fake.commit = "c", "c"
+	assert.Equal(t, "c", getPkg("fourth"))
+	assert.Equal(t, 3, fake.fetches)
+	assert.NoDirExists(t, oldEntry)
+	assert.DirExists(t, filepath.Join(pg.Dir, cacheKey("https://repo", "/pkg", "b")))
+}
+
+func TestCachingPkgGetter_CommitMismatch(t *testing.T) {
+	// the ref moved between resolving and fetching it, so the fetched
+	// package must not be cached under the resolved commit
+	fake := &fakePkgGetter{commit: "b"}
+	pg := &cachingPkgGetter{
+		PkgGetter: fake,
+		Dir:       t.TempDir(),
+		resolveCommit: func(context.Context, string, string) (string, bool, error) {
+			return "a", true, nil
+		},
+	}
+	for _, target := range []string{"first", "second"} {
+		_, err := pg.GetPkg(context.Background(), t.TempDir(), target, "https://repo", "/pkg", "main")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, fake.fetches)
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/util/pkgutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// ChangedFiles returns the slash separated paths, relative to the package at
+// path, of the files which were added or modified in the package since ref of
+// the git repository containing it. ref is any revision git can resolve to a
+// commit, e.g. a branch, tag, commit or HEAD~1. Removed
+// files and changes to Kptfiles aren't included. The working tree of the
+// package is compared, so uncommitted changes are included as well.
+func ChangedFiles(ctx context.Context, path, ref string) ([]string, error) {
+	repo, dir, err := gitRepoOf(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := resolveCommit(ctx, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	stagingDirectory, err := ioutil.TempDir("", "kpt-")
+	if err != nil {
+		return nil, errors.Errorf("failed to create stage dir: %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(stagingDirectory)
+	}()
+
+	currPkg, err := stageDirectory(stagingDirectory, NameStagingDirectory(LocalPackageSource, ""))
+	if err != nil {
+		return nil, errors.Errorf("failed to create stage dir for current package: %v", err)
+	}
+	if err := pkgutil.CopyPackage(path, currPkg, true, pkg.Local); err != nil {
+		return nil, errors.Errorf("failed to stage current package: %v", err)
+	}
+	refPkg, err := defaultPkgGetter{}.GetPkg(ctx, stagingDirectory,
+		NameStagingDirectory(TargetRemotePackageSource, commit), repo, dir, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	d := defaultPkgDiffer{DiffType: TypeCombined}
+	result, err := d.result(currPkg, refPkg)
+	if err != nil {
+		return nil, err
+	}
+	// the files of the local package which aren't in the package at ref
+	// count as removed from the local package
+	changed := append(result.Changed, result.Removed...)
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// gitRepoOf returns the root of the git repository containing the directory
+// at path, and the slash separated path of the directory within it.
+func gitRepoOf(ctx context.Context, path string) (string, string, error) {
+	gitRunner, err := gitutil.NewLocalGitRunner(path)
+	if err != nil {
+		return "", "", err
+	}
+	rr, err := gitRunner.Run(ctx, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", "", errors.Errorf("package at '%s' is not in a git repository: %v", path, err)
+	}
+	repo := strings.TrimSpace(rr.Stdout)
+	// git resolves symlinks in the root, so they are resolved in path too
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+	if absPath, err = filepath.EvalSymlinks(absPath); err != nil {
+		return "", "", err
+	}
+	if resolvedRepo, err := filepath.EvalSymlinks(repo); err == nil {
+		repo = resolvedRepo
+	}
+	dir, err := filepath.Rel(repo, absPath)
+	if err != nil {
+		return "", "", err
+	}
+	if dir == "." {
+		return repo, "/", nil
+	}
+	return repo, "/" + filepath.ToSlash(dir), nil
+}
+
+// resolveCommit returns the commit ref of the git repository at repo refers
+// to. Fetching a package only resolves branches, tags and commits.
+func resolveCommit(ctx context.Context, repo, ref string) (string, error) {
+	gitRunner, err := gitutil.NewLocalGitRunner(repo)
+	if err != nil {
+		return "", err
+	}
+	rr, err := gitRunner.Run(ctx, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", errors.Errorf("'%s' is not a commit of the repository at '%s'", ref, repo)
+	}
+	return strings.TrimSpace(rr.Stdout), nil
+}
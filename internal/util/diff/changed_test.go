@@ -0,0 +1,80 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangedFiles(t *testing.T) {
+	repo := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=kpt", "-c", "user.email=kpt@example.com"}, args...)...)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		if !assert.NoError(t, err, string(out)) {
+			t.FailNow()
+		}
+	}
+	write := func(path, content string) {
+		path = filepath.Join(repo, path)
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+	write("pkg/Kptfile", "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: pkg\n")
+	write("pkg/a.yaml", "a: 1\n")
+	write("pkg/b.yaml", "b: 1\n")
+	write("pkg/sub/c.yaml", "c: 1\n")
+	write("other.yaml", "other: 1\n")
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+
+	ctx := fake.CtxWithDefaultPrinter()
+	pkgDir := filepath.Join(repo, "pkg")
+	changed, err := ChangedFiles(ctx, pkgDir, "HEAD")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Empty(t, changed)
+
+	write("pkg/Kptfile", "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: renamed\n")
+	write("pkg/b.yaml", "b: 2\n")
+	write("pkg/sub/c.yaml", "c: 2\n")
+	write("pkg/d.yaml", "d: 1\n")
+	write("other.yaml", "other: 2\n")
+	if !assert.NoError(t, os.Remove(filepath.Join(pkgDir, "a.yaml"))) {
+		t.FailNow()
+	}
+	changed, err = ChangedFiles(ctx, pkgDir, "HEAD")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{"b.yaml", "d.yaml", "sub/c.yaml"}, changed)
+
+	_, err = ChangedFiles(ctx, t.TempDir(), "HEAD")
+	assert.Error(t, err)
+}
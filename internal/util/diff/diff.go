@@ -20,18 +20,27 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/GoogleContainerTools/kpt/internal/gitutil"
 	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/types"
 	"github.com/GoogleContainerTools/kpt/internal/util/addmergecomment"
 	"github.com/GoogleContainerTools/kpt/internal/util/fetch"
+	"github.com/GoogleContainerTools/kpt/internal/util/parse"
 	"github.com/GoogleContainerTools/kpt/internal/util/pkgutil"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/go-logr/logr"
+	"golang.org/x/sync/errgroup"
 	"sigs.k8s.io/kustomize/kyaml/errors"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
@@ -48,8 +57,64 @@ const (
 	TypeCombined Type = "combined"
 	// 3way shows changes in local and remote changes side-by-side
 	Type3Way Type = "3way"
+	// TypeMerged shows changes in local pkg after merging the changes in the upstream
+	// source pkg between original and target version, like pkg update does
+	TypeMerged Type = "merged"
 )
 
+// Format represents the output format of the diff command.
+type Format string
+
+const (
+	// FormatText delegates rendering the differences to the diff tool.
+	FormatText Format = "text"
+	// FormatJSON writes the changed, added and removed files together with
+	// their hunks as a JSON document.
+	FormatJSON Format = "json"
+)
+
+// String implements Stringer.
+func (f Format) String() string {
+	return string(f)
+}
+
+var SupportedFormats = []Format{FormatText, FormatJSON}
+
+func SupportedFormatsLabel() string {
+	var labels []string
+	for _, f := range SupportedFormats {
+		labels = append(labels, f.String())
+	}
+	return strings.Join(labels, ", ")
+}
+
+// ColorMode controls whether the built-in 3way renderer colors its output.
+type ColorMode string
+
+const (
+	// ColorAuto colors the output only if it is written to a terminal.
+	ColorAuto ColorMode = "auto"
+	// ColorAlways always colors the output.
+	ColorAlways ColorMode = "always"
+	// ColorNever never colors the output.
+	ColorNever ColorMode = "never"
+)
+
+// String implements Stringer.
+func (m ColorMode) String() string {
+	return string(m)
+}
+
+var SupportedColorModes = []ColorMode{ColorAuto, ColorAlways, ColorNever}
+
+func SupportedColorModesLabel() string {
+	var labels []string
+	for _, m := range SupportedColorModes {
+		labels = append(labels, m.String())
+	}
+	return strings.Join(labels, ", ")
+}
+
 // A collection of user-readable "source" definitions for diffed packages.
 const (
 	// localPackageSource represents the local package
@@ -58,6 +123,9 @@ const (
 	RemotePackageSource string = "remote"
 	// targetRemotePackageSource represents the targeted remote version of a package
 	TargetRemotePackageSource string = "target"
+	// mergedPackageSource represents the local package merged with the targeted
+	// remote version of the package
+	MergedPackageSource string = "merged"
 )
 
 const (
@@ -68,12 +136,51 @@ const (
 		"--help.\n"
 )
 
+// Names of the environment variables providing the credentials for the
+// upstream repositories if Command.Credentials isn't set.
+const (
+	GitUsernameEnv = "KPT_DIFF_GIT_USERNAME"
+	GitTokenEnv    = "KPT_DIFF_GIT_TOKEN"
+	GitSSHKeyEnv   = "KPT_DIFF_GIT_SSH_KEY"
+)
+
+const (
+	// ExitCodeDifferences is the exit code of kpt when ExitCode is set and
+	// the compared packages differ.
+	ExitCodeDifferences = 1
+	// ExitCodeToolFailure is the exit code of kpt when the diff tool fails.
+	ExitCodeToolFailure = 2
+)
+
+// DifferencesFoundError is returned when ExitCode is set and the compared
+// packages differ.
+type DifferencesFoundError struct{}
+
+func (e *DifferencesFoundError) Error() string {
+	return "differences found"
+}
+
+// ToolError is returned when the diff tool fails for a reason other than
+// finding differences between the packages.
+type ToolError struct {
+	Tool string
+	Err  error
+}
+
+func (e *ToolError) Error() string {
+	return fmt.Sprintf("diff tool %q failed: %v", e.Tool, e.Err)
+}
+
+func (e *ToolError) Unwrap() error {
+	return e.Err
+}
+
 // String implements Stringer.
 func (dt Type) String() string {
 	return string(dt)
 }
 
-var SupportedDiffTypes = []Type{TypeLocal, TypeRemote, TypeCombined, Type3Way}
+var SupportedDiffTypes = []Type{TypeLocal, TypeRemote, TypeCombined, Type3Way, TypeMerged}
 
 func SupportedDiffTypesLabel() string {
 	var labels []string
@@ -85,6 +192,10 @@ func SupportedDiffTypesLabel() string {
 
 // Command shows changes in local package relative to upstream source pkg, changes in
 // upstream source package between original and target version etc.
+//
+// Run sets the defaults and the resolved target version on the Command, so a
+// Command must not be run concurrently. Separate Commands can be run
+// concurrently, also against the same package, repository or StageDir.
 type Command struct {
 	// Path to the local package directory
 	Path string
@@ -92,23 +203,141 @@ type Command struct {
 	// Ref is the target Ref in the upstream source package to compare against
 	Ref string
 
+	// FromRef and ToRef are refs of the upstream repository to compare
+	// against each other. When both are set, the local package is not staged
+	// and the upstream package at FromRef is compared with the upstream
+	// package at ToRef. When only ToRef is set, it is used as Ref.
+	FromRef string
+	ToRef   string
+
+	// Repo is the git repository to fetch FromRef and ToRef from. It may
+	// include the package directory after a .git suffix, for example
+	// https://github.com/org/repo.git/path/to/pkg. Defaults to the upstream
+	// repository recorded in the Kptfile.
+	Repo string
+
 	// DiffType specifies the type of changes to show
 	DiffType Type
 
 	// Difftool refers to diffing commandline tool for showing changes.
+	// If DiffType is 3way and DiffTool is empty, the changes are shown side
+	// by side by a built-in renderer.
 	DiffTool string
 
 	// DiffToolOpts refers to the commandline options to for the diffing tool.
 	DiffToolOpts string
 
+	// Color controls if the built-in 3way renderer colors the changes.
+	// Defaults to ColorAuto.
+	Color ColorMode
+
+	// Format specifies the output format. The text format (default) shows
+	// the changes using DiffTool, the json format bypasses DiffTool.
+	Format Format
+
+	// IgnoreFields is a list of dotted field paths, e.g.
+	// `metadata.creationTimestamp` or `spec.containers[*].image`, which are
+	// removed from all resources before comparing the packages.
+	IgnoreFields []string
+
+	// ExcludePatterns is a list of gitignore style patterns. Matching files
+	// and directories are removed from all staged packages before comparing.
+	ExcludePatterns []string
+
+	// IncludeKptfile compares the Kptfile of the packages, which is removed
+	// from the staged packages by default. The upstream and upstreamLock
+	// fields can be ignored with IgnoreFields. It applies to the Kptfiles of
+	// the subpackages as well.
+	IncludeKptfile bool
+
+	// ExcludeSubpackages removes the subpackages, i.e. the directories with
+	// their own Kptfile, from the staged packages, so only the content of
+	// the root package is compared.
+	ExcludeSubpackages bool
+
+	// Subpath restricts the comparison to the file or directory at this
+	// path relative to the package root. If it only exists in one of the
+	// packages, it is shown as added or removed.
+	Subpath string
+
+	// Credentials are used to authenticate with the upstream repositories,
+	// e.g. private repositories accessed over HTTPS. Defaults to the
+	// credentials in the GitUsernameEnv, GitTokenEnv and GitSSHKeyEnv
+	// environment variables.
+	Credentials gitutil.Credentials
+
+	// StageDir is a directory where the fetched upstream packages are kept
+	// after the diff. Packages which are already in StageDir at the commit
+	// their ref resolves to are reused instead of being fetched again.
+	// StageDir is created if it doesn't exist.
+	StageDir string
+
+	// CleanStageDir removes the packages used by the diff from StageDir
+	// after the diff.
+	CleanStageDir bool
+
+	// NoCache disables the cache of fetched upstream packages, so every
+	// package is fetched from the upstream repository.
+	NoCache bool
+
+	// SkipToolCheck skips verifying that DiffTool can compare the 3
+	// packages of the 3way diff type.
+	SkipToolCheck bool
+
+	// ExitCode makes the command return a DifferencesFoundError if the
+	// packages differ, so kpt exits with ExitCodeDifferences.
+	ExitCode bool
+
+	// Stat appends a summary with the number of changed, added and removed
+	// files to the output. For the 3way diff type, the summary compares the
+	// local package with the target package.
+	Stat bool
+
+	// NameOnly prints the slash separated paths of the files which were
+	// added, removed or modified instead of running DiffTool, one per line.
+	// For the 3way diff type, the local package is compared with the target
+	// package.
+	NameOnly bool
+
+	// NameStatus is NameOnly, but the paths are prefixed with A, D or M for
+	// added, removed and modified files.
+	NameStatus bool
+
+	// Quick compares content hashes of the staged packages first and
+	// reports that there are no differences like DiffTool, without running
+	// it, if they match. DiffTool is only run if the hashes differ.
+	Quick bool
+
+	// Quiet suppresses the message written to Output when DiffTool finds no
+	// differences between 2 packages, e.g. `No differences between
+	// local-v1 and remote-v1.`, which names the compared packages after
+	// LocalPackageSource, RemotePackageSource and TargetRemotePackageSource.
+	Quiet bool
+
 	// When Debug is true, command will run with verbose logging and will not
 	// cleanup the staged packages to assist with debugging.
 	Debug bool
 
+	// Logger receives the diagnostics of the command as structured key and
+	// value pairs at verbosity 1, e.g. the staging directories, the resolved
+	// refs, the invocation of the diff tool and timings. They are kept apart
+	// from the changes written to Output. If it isn't set, the diagnostics are
+	// written to Progress if Debug is set, and discarded otherwise.
+	Logger logr.Logger
+
 	// Output is an io.Writer where command will write the output of the
 	// command.
 	Output io.Writer
 
+	// OutputFile is the path of a file the output of the command is written
+	// to instead of Output. Its parent directories are created if needed.
+	OutputFile string
+
+	// Progress is where the fetches of the upstream packages are reported.
+	// They are only reported if Progress is a terminal or Debug is set, in
+	// which case the fetched commits are included.
+	Progress io.Writer
+
 	// PkgDiffer specifies package differ
 	PkgDiffer PkgDiffer
 
@@ -117,140 +346,684 @@ type Command struct {
 }
 
 func (c *Command) Run(ctx context.Context) error {
+	if err := c.prepareStageDir(); err != nil {
+		return err
+	}
+	if c.OutputFile == "" {
+		c.DefaultValues()
+		return c.withStagedPkgs(ctx, c.diffFunc(ctx))
+	}
+
+	f, err := createOutputFile(c.OutputFile)
+	if err != nil {
+		return err
+	}
+	c.Output = f
 	c.DefaultValues()
+	err = c.withStagedPkgs(ctx, c.diffFunc(ctx))
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = errors.Errorf("failed to write output file: %v", closeErr)
+	}
+	return err
+}
+
+// diffFunc returns the function comparing the staged packages, which lists
+// the names of the differing files if NameOnly or NameStatus is set and runs
+// PkgDiffer otherwise.
+func (c *Command) diffFunc(ctx context.Context) func(pkgs ...string) error {
+	if c.NameOnly || c.NameStatus {
+		return c.printNames
+	}
+	return c.pkgDiff(ctx)
+}
+
+// printNames prints the paths of the files which differ between the first
+// and the last of the staged packages.
+func (c *Command) printNames(pkgs ...string) error {
+	d := c.defaultPkgDiffer()
+	result, err := d.result(pkgs...)
+	if _, ok := err.(*DifferencesFoundError); err != nil && !ok {
+		return err
+	}
+	var stat diffStat
+	for _, f := range result.Files {
+		var status string
+		switch f.Status {
+		case FileAdded:
+			status = "A"
+			stat.Added++
+		case FileRemoved:
+			status = "D"
+			stat.Removed++
+		default:
+			status = "M"
+			stat.Changed++
+		}
+		if c.NameStatus {
+			fmt.Fprintf(c.Output, "%s\t%s\n", status, f.Path)
+		} else {
+			fmt.Fprintln(c.Output, f.Path)
+		}
+	}
+	if c.Stat {
+		fmt.Fprintln(c.Output, stat.String())
+	}
+	return err
+}
+
+// createOutputFile creates the file at path and its parent directories.
+func createOutputFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, errors.Errorf("failed to create output file '%s': %v", path, err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Errorf("failed to create output file '%s': %v", path, err)
+	}
+	return f, nil
+}
+
+// RunWithResult compares the packages like Run, but returns the differences
+// instead of writing them to Output. The differences are computed in-process,
+// so the result doesn't depend on DiffTool or Format. For the 3way diff type,
+// the result compares the local package with the target package.
+func (c *Command) RunWithResult(ctx context.Context) (*DiffResult, error) {
+	if err := c.prepareStageDir(); err != nil {
+		return nil, err
+	}
+	c.DefaultValues()
+	var result *DiffResult
+	err := c.withStagedPkgs(ctx, func(pkgs ...string) error {
+		d := c.defaultPkgDiffer()
+		var err error
+		result, err = d.result(pkgs...)
+		return err
+	})
+	return result, err
+}
+
+// prepareStageDir creates StageDir if it is set and verifies that packages can
+// be written to it. It isn't part of Validate, which doesn't touch the
+// filesystem.
+func (c *Command) prepareStageDir() error {
+	if c.StageDir == "" {
+		return nil
+	}
+	return validateStageDir(c.StageDir)
+}
+
+// withStagedPkgs stages the packages to compare for the diff type and calls
+// diff with the staged packages before cleaning them up.
+func (c *Command) withStagedPkgs(ctx context.Context, diff func(pkgs ...string) error) error {
+	if !c.Credentials.IsEmpty() {
+		ctx = gitutil.WithCredentials(ctx, c.Credentials)
+	}
+	if pg, ok := c.PkgGetter.(*stageDirPkgGetter); ok && c.CleanStageDir {
+		defer pg.clean()
+	}
+	if c.FromRef != "" && c.ToRef != "" {
+		return c.diffRefs(ctx, diff)
+	}
+	if c.ToRef != "" {
+		c.Ref = c.ToRef
+	}
 
 	kptFile, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, c.Path)
 	if err != nil {
 		return errors.Errorf("package missing Kptfile at '%s': %v", c.Path, err)
 	}
+	src, err := c.upstreamSource(kptFile)
+	if err != nil {
+		return err
+	}
+	origRef := src.origRef
+	needsOrig := c.DiffType != TypeCombined
+	if needsOrig && origRef == "" && src.requiresOrigRef {
+		return &pkg.MissingUpstreamError{Path: types.UniquePath(c.Path), Field: "upstream.git.ref"}
+	}
+	needsTarget := c.DiffType != TypeLocal
 
-	// Create a staging directory to store all compared packages
-	stagingDirectory, err := ioutil.TempDir("", "kpt-")
+	stagingDirectory, err := c.createStagingDirectory()
 	if err != nil {
-		return errors.Errorf("failed to create stage dir: %v", err)
+		return err
 	}
-	defer func() {
-		// Cleanup staged content after diff. Ignore cleanup if debugging.
-		if !c.Debug {
-			defer os.RemoveAll(stagingDirectory)
+	defer c.cleanupStagingDirectory(stagingDirectory)
+
+	log := debugLogger(c.Logger)
+	if c.Ref == "" && needsTarget {
+		c.Ref, err = src.defaultRef(ctx)
+		if err != nil {
+			return err
 		}
-	}()
+		log.Info("resolved default ref", "ref", c.Ref)
+	}
+	log.Info("staging packages", "dir", stagingDirectory, "diffType", c.DiffType,
+		"sourceRef", origRef, "targetRef", c.Ref)
+	start := time.Now()
 
+	// The packages below are staged concurrently. Every package is staged
+	// into its own subdirectory, named after its source, so creating them
+	// inside the same parent doesn't race.
 	// Stage current package
 	// This prevents prepareForDiff from modifying the local package
-	localPkgName := NameStagingDirectory(LocalPackageSource,
-		kptFile.Upstream.Git.Ref)
+	localPkgName := NameStagingDirectory(LocalPackageSource, origRef)
 	currPkg, err := stageDirectory(stagingDirectory, localPkgName)
 	if err != nil {
 		return errors.Errorf("failed to create stage dir for current package: %v", err)
 	}
+	upstreamPkgName := NameStagingDirectory(RemotePackageSource, origRef)
+	upstreamTargetPkgName := NameStagingDirectory(TargetRemotePackageSource,
+		c.Ref)
 
-	err = pkgutil.CopyPackage(c.Path, currPkg, true, pkg.Local)
+	var upstreamPkg, upstreamTargetPkg string
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		if err := pkgutil.CopyPackage(c.Path, currPkg, true, pkg.Local); err != nil {
+			return errors.Errorf("failed to stage current package: %v", err)
+		}
+		return nil
+	})
+	if needsOrig {
+		// get the upstreamPkg at current version
+		g.Go(func() error {
+			var err error
+			upstreamPkg, err = src.getPkg(gctx,
+				stagingDirectory,
+				upstreamPkgName,
+				origRef)
+			return err
+		})
+	}
+	if needsTarget {
+		// get the upstream pkg at the target version
+		g.Go(func() error {
+			var err error
+			upstreamTargetPkg, err = src.getPkg(gctx, stagingDirectory,
+				upstreamTargetPkgName,
+				c.Ref)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	log.Info("staged packages", "local", currPkg, "source", upstreamPkg,
+		"target", upstreamTargetPkg, "duration", time.Since(start))
+
+	switch c.DiffType {
+	case TypeLocal:
+		return diff(currPkg, upstreamPkg)
+	case TypeRemote:
+		return diff(upstreamPkg, upstreamTargetPkg)
+	case TypeCombined:
+		return diff(currPkg, upstreamTargetPkg)
+	case Type3Way:
+		return diff(currPkg, upstreamPkg, upstreamTargetPkg)
+	case TypeMerged:
+		mergedPkg, err := stageDirectory(stagingDirectory,
+			NameStagingDirectory(MergedPackageSource, c.Ref))
+		if err != nil {
+			return errors.Errorf("failed to create stage dir for merged package: %v", err)
+		}
+		// the merge result is the local package after the update, so
+		// conflicts are shown in the files rather than failing the diff
+		if err := mergePkgs(currPkg, upstreamPkg, upstreamTargetPkg, mergedPkg); err != nil {
+			return errors.Errorf("failed to merge upstream changes: %v", err)
+		}
+		return diff(currPkg, mergedPkg)
+	default:
+		return errors.Errorf("unsupported diff type '%s'", c.DiffType)
+	}
+}
+
+// diffRefs compares the upstream package at FromRef with the upstream
+// package at ToRef without staging the local package.
+func (c *Command) diffRefs(ctx context.Context, diff func(pkgs ...string) error) error {
+	repo, directory, err := c.refsRepo()
 	if err != nil {
-		return errors.Errorf("failed to stage current package: %v", err)
-	}
-
-	// get the upstreamPkg at current version
-	upstreamPkgName := NameStagingDirectory(RemotePackageSource,
-		kptFile.Upstream.Git.Ref)
-	upstreamPkg, err := c.PkgGetter.GetPkg(ctx,
-		stagingDirectory,
-		upstreamPkgName,
-		kptFile.Upstream.Git.Repo,
-		kptFile.Upstream.Git.Directory,
-		kptFile.Upstream.Git.Ref)
+		return err
+	}
+
+	stagingDirectory, err := c.createStagingDirectory()
 	if err != nil {
 		return err
 	}
+	defer c.cleanupStagingDirectory(stagingDirectory)
 
-	var upstreamTargetPkg string
+	log := debugLogger(c.Logger)
+	log.Info("staging packages", "dir", stagingDirectory, "repo", repo, "directory", directory,
+		"fromRef", c.FromRef, "toRef", c.ToRef)
+	start := time.Now()
+	var fromPkg, toPkg string
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		fromPkg, err = c.PkgGetter.GetPkg(gctx, stagingDirectory,
+			NameStagingDirectory(RemotePackageSource, c.FromRef),
+			repo, directory, c.FromRef)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		toPkg, err = c.PkgGetter.GetPkg(gctx, stagingDirectory,
+			NameStagingDirectory(TargetRemotePackageSource, c.ToRef),
+			repo, directory, c.ToRef)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	if c.Ref == "" {
-		gur, err := gitutil.NewGitUpstreamRepo(ctx, kptFile.UpstreamLock.Git.Repo)
-		if err != nil {
-			return err
+	log.Info("staged packages", "from", fromPkg, "to", toPkg, "duration", time.Since(start))
+	return diff(fromPkg, toPkg)
+}
+
+// defaultRefs caches the default branches of the upstream repositories
+// looked up by defaultRef for the lifetime of the process.
+var defaultRefs sync.Map
+
+// defaultRef returns the default branch of repo. Looking it up requires a
+// network call, so it is only done once per repo.
+func defaultRef(ctx context.Context, repo string) (string, error) {
+	if ref, found := defaultRefs.Load(repo); found {
+		return ref.(string), nil
+	}
+	gur, err := gitutil.NewGitUpstreamRepo(ctx, repo)
+	if err != nil {
+		return "", err
+	}
+	ref, err := gur.GetDefaultBranch(ctx)
+	if err != nil {
+		return "", err
+	}
+	defaultRefs.Store(repo, ref)
+	return ref, nil
+}
+
+// upstreamSource fetches the upstream package of the local package.
+type upstreamSource struct {
+	// origRef is the version of the upstream package recorded in the
+	// Kptfile.
+	origRef string
+	// requiresOrigRef tells whether the upstream package can't be fetched
+	// at its original version without origRef.
+	requiresOrigRef bool
+	// defaultRef returns the version to compare against if Ref isn't set.
+	defaultRef func(ctx context.Context) (string, error)
+	// getPkg fetches the upstream package at ref into targetDir within
+	// stagingDir and returns the directory of the package.
+	getPkg func(ctx context.Context, stagingDir, targetDir, ref string) (string, error)
+}
+
+// upstreamSource returns the upstreamSource of the local package with the
+// Kptfile kf. Upstreams of other types than git are fetched by PkgGetter if
+// it is an UpstreamPkgGetter.
+func (c *Command) upstreamSource(kf *kptfilev1.KptFile) (upstreamSource, error) {
+	if kf.Upstream != nil && kf.Upstream.Type != "" && kf.Upstream.Type != kptfilev1.GitOrigin {
+		return c.nonGitUpstreamSource(kf.Upstream)
+	}
+	repo, directory, err := c.upstreamRepo(kf)
+	if err != nil {
+		return upstreamSource{}, err
+	}
+	src := upstreamSource{
+		requiresOrigRef: true,
+		defaultRef: func(ctx context.Context) (string, error) {
+			return defaultRef(ctx, repo)
+		},
+		getPkg: func(ctx context.Context, stagingDir, targetDir, ref string) (string, error) {
+			return c.PkgGetter.GetPkg(ctx, stagingDir, targetDir, repo, directory, ref)
+		},
+	}
+	// the original version of the upstream package is only recorded in the
+	// Kptfile, the combined diff type doesn't need it.
+	if kf.Upstream != nil && kf.Upstream.Git != nil {
+		src.origRef = kf.Upstream.Git.Ref
+	}
+	return src, nil
+}
+
+// nonGitUpstreamSource returns the upstreamSource of an upstream which isn't
+// a git repository. The version compared against must be set with Ref.
+func (c *Command) nonGitUpstreamSource(upstream *kptfilev1.Upstream) (upstreamSource, error) {
+	pg := c.PkgGetter
+	if sg, ok := pg.(*stageDirPkgGetter); ok {
+		// the packages of other upstreams aren't kept in StageDir
+		pg = sg.PkgGetter
+	}
+	ug, ok := pg.(UpstreamPkgGetter)
+	if !ok {
+		return upstreamSource{}, errors.Errorf("upstream type '%s' of package at '%s' is not supported, "+
+			"only %s upstreams can be compared", upstream.Type, c.Path, kptfilev1.GitOrigin)
+	}
+	if c.Repo != "" {
+		return upstreamSource{}, errors.Errorf("--repo can't be used with upstream type '%s'", upstream.Type)
+	}
+	return upstreamSource{
+		defaultRef: func(context.Context) (string, error) {
+			return "", errors.Errorf("a target version must be specified to compare with "+
+				"upstream type '%s'", upstream.Type)
+		},
+		getPkg: func(ctx context.Context, stagingDir, targetDir, ref string) (string, error) {
+			return ug.GetPkgFromUpstream(ctx, stagingDir, targetDir, upstream, ref)
+		},
+	}, nil
+}
+
+// upstreamRepo returns the repository and package directory of the upstream
+// package of the local package with the Kptfile kf. Repo overrides the
+// upstream recorded in the Kptfile, the directory defaults to the recorded
+// one if Repo doesn't specify it.
+func (c *Command) upstreamRepo(kf *kptfilev1.KptFile) (string, string, error) {
+	if c.Repo == "" {
+		if err := pkg.CheckUpstream(kf, types.UniquePath(c.Path)); err != nil {
+			return "", "", err
 		}
-		c.Ref, err = gur.GetDefaultBranch(ctx)
+		return kf.Upstream.Git.Repo, kf.Upstream.Git.Directory, nil
+	}
+	repo, dir := c.Repo, ""
+	if parse.HasGitSuffix(c.Repo) {
+		var err error
+		repo, dir, _, err = parse.URL(c.Repo)
 		if err != nil {
-			return err
+			return "", "", err
 		}
 	}
+	if dir == "" && kf.Upstream != nil && kf.Upstream.Git != nil {
+		dir = kf.Upstream.Git.Directory
+	}
+	if dir == "" {
+		dir = "/"
+	}
+	return repo, dir, nil
+}
 
-	if c.DiffType == TypeRemote ||
-		c.DiffType == TypeCombined ||
-		c.DiffType == Type3Way {
-		// get the upstream pkg at the target version
-		upstreamTargetPkgName := NameStagingDirectory(TargetRemotePackageSource,
-			c.Ref)
-		upstreamTargetPkg, err = c.PkgGetter.GetPkg(ctx, stagingDirectory,
-			upstreamTargetPkgName,
-			kptFile.Upstream.Git.Repo,
-			kptFile.Upstream.Git.Directory,
-			c.Ref)
+// refsRepo returns the repository and package directory that FromRef and
+// ToRef refer to.
+func (c *Command) refsRepo() (string, string, error) {
+	if c.Repo != "" {
+		if !parse.HasGitSuffix(c.Repo) {
+			return c.Repo, "/", nil
+		}
+		repo, dir, _, err := parse.URL(c.Repo)
 		if err != nil {
-			return err
+			return "", "", err
+		}
+		if dir == "" {
+			dir = "/"
 		}
+		return repo, dir, nil
 	}
+	kptFile, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, c.Path)
+	if err != nil {
+		return "", "", errors.Errorf("package missing Kptfile at '%s': %v", c.Path, err)
+	}
+	if kptFile.Upstream == nil || kptFile.Upstream.Git == nil {
+		return "", "", errors.Errorf("package at '%s' has no git upstream, "+
+			"please provide the repository using --repo", c.Path)
+	}
+	return kptFile.Upstream.Git.Repo, kptFile.Upstream.Git.Directory, nil
+}
 
-	if c.Debug {
-		fmt.Fprintf(c.Output, "diffing currPkg: %v, upstreamPkg: %v, upstreamTargetPkg: %v \n",
-			currPkg, upstreamPkg, upstreamTargetPkg)
+// createStagingDirectory creates a staging directory to store all compared
+// packages. Every run gets its own staging directory, and every package is
+// staged into a subdirectory named by NameStagingDirectory, e.g. for the 3way
+// diff type:
+//
+//	kpt-<random>/
+//	  local-<original ref>/
+//	  remote-<original ref>/
+//	  target-<target ref>/
+//
+// so concurrent runs never stage packages into the same directory.
+func (c *Command) createStagingDirectory() (string, error) {
+	stagingDirectory, err := ioutil.TempDir("", "kpt-")
+	if err != nil {
+		return "", errors.Errorf("failed to create stage dir: %v", err)
 	}
+	return stagingDirectory, nil
+}
 
-	switch c.DiffType {
-	case TypeLocal:
-		return c.PkgDiffer.Diff(currPkg, upstreamPkg)
-	case TypeRemote:
-		return c.PkgDiffer.Diff(upstreamPkg, upstreamTargetPkg)
-	case TypeCombined:
-		return c.PkgDiffer.Diff(currPkg, upstreamTargetPkg)
-	case Type3Way:
-		return c.PkgDiffer.Diff(currPkg, upstreamPkg, upstreamTargetPkg)
-	default:
-		return errors.Errorf("unsupported diff type '%s'", c.DiffType)
+// cleanupStagingDirectory removes staged content after diff. Cleanup is
+// skipped if debugging.
+func (c *Command) cleanupStagingDirectory(stagingDirectory string) {
+	if c.Debug {
+		debugLogger(c.Logger).Info("kept staging directory", "dir", stagingDirectory)
+		return
 	}
+	_ = os.RemoveAll(stagingDirectory)
 }
 
 func (c *Command) Validate() error {
+	if c.FromRef != "" && c.ToRef == "" {
+		return errors.Errorf("--to must be specified together with --from")
+	}
+	if c.Repo != "" {
+		if err := validateRepo(c.Repo); err != nil {
+			return err
+		}
+	}
+	if c.FromRef != "" && c.Repo == "" {
+		if _, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, c.Path); err != nil {
+			return errors.Errorf("--from and --to require --repo if the package at '%s' has no Kptfile",
+				c.Path)
+		}
+	}
+
 	switch c.DiffType {
-	case TypeLocal, TypeCombined, TypeRemote, Type3Way:
+	case TypeLocal, TypeCombined, TypeRemote, Type3Way, TypeMerged:
 	default:
 		return errors.Errorf("invalid diff-type '%s': supported diff-types are: %s",
 			c.DiffType, SupportedDiffTypesLabel())
 	}
 
+	if c.CleanStageDir && c.StageDir == "" {
+		return errors.Errorf("--clean-stage-dir requires --stage-dir")
+	}
+
+	if c.Subpath != "" {
+		clean := path.Clean(filepath.ToSlash(c.Subpath))
+		if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			return errors.Errorf("path-filter '%s' must be a relative path within the package",
+				c.Subpath)
+		}
+		c.Subpath = clean
+	}
+
+	for _, f := range c.IgnoreFields {
+		if _, err := ParseFieldPath(f); err != nil {
+			return err
+		}
+	}
+
+	if c.NameOnly || c.NameStatus {
+		switch {
+		case c.NameOnly && c.NameStatus:
+			return errors.Errorf("--name-only can't be combined with --name-status")
+		case c.Format != "" && c.Format != FormatText:
+			return errors.Errorf("--name-only and --name-status are only supported with output format '%s'",
+				FormatText)
+		}
+		// the names of the files are listed without the diff tool
+		return nil
+	}
+
+	switch c.Format {
+	case "", FormatText:
+	case FormatJSON:
+		if c.DiffType == Type3Way {
+			return errors.Errorf("diff-type '%s' is not supported with output format '%s'",
+				c.DiffType, c.Format)
+		}
+		if c.Stat {
+			return errors.Errorf("--stat is not supported with output format '%s'", c.Format)
+		}
+		// the json format doesn't use the diff tool
+		return nil
+	default:
+		return errors.Errorf("invalid output format '%s': supported formats are: %s",
+			c.Format, SupportedFormatsLabel())
+	}
+
+	switch c.Color {
+	case "", ColorAuto, ColorAlways, ColorNever:
+	default:
+		return errors.Errorf("invalid color '%s': supported values are: %s",
+			c.Color, SupportedColorModesLabel())
+	}
+
+	if c.DiffType == Type3Way && c.DiffTool == "" {
+		// the built-in renderer is used to show the changes
+		return nil
+	}
+
 	path, err := exec.LookPath(c.DiffTool)
 	if err != nil {
 		return errors.Errorf("diff-tool '%s' not found in the PATH", c.DiffTool)
 	}
 	c.DiffTool = path
+	if c.DiffType == Type3Way && !c.SkipToolCheck {
+		return checkThreeWayTool(c.DiffTool, c.DiffToolOpts)
+	}
 	return nil
 }
 
+// scpLikeRepoRegexp matches the scp-like syntax of ssh repositories, e.g.
+// git@github.com:org/repo.git.
+var scpLikeRepoRegexp = regexp.MustCompile(`^([\w.-]+@)?[\w.-]+:[^/\\]`)
+
+// validateRepo verifies that repo is a plausible git repository: a URL with
+// a scheme supported by git, an scp-like ssh address or a local directory.
+func validateRepo(repo string) error {
+	switch {
+	case strings.Contains(repo, "://"):
+		u, err := url.Parse(repo)
+		if err != nil {
+			break
+		}
+		switch u.Scheme {
+		case "http", "https", "ssh", "git", "git+ssh":
+			if u.Host != "" {
+				return nil
+			}
+		case "file":
+			return nil
+		}
+	case scpLikeRepoRegexp.MatchString(repo) && !filepath.IsAbs(repo):
+		return nil
+	default:
+		dirs := []string{repo}
+		if parse.HasGitSuffix(repo) {
+			// the package directory may follow the .git suffix, which may
+			// or may not be part of the name of the repository directory
+			dir, _, _, _ := parse.URL(repo)
+			dirs = append(dirs, dir, dir+".git")
+		}
+		for _, dir := range dirs {
+			if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+				return nil
+			}
+		}
+	}
+	return errors.Errorf("repo '%s' is not a valid git repository, it must be a "+
+		"git URL like https://github.com/org/repo.git or a local directory", repo)
+}
+
 // DefaultValues sets up the default values for the command.
 func (c *Command) DefaultValues() {
 	if c.Output == nil {
 		c.Output = os.Stdout
 	}
+	if c.Logger.GetSink() == nil {
+		c.Logger = newDebugLogger(c.Progress, c.Debug)
+	}
 	if c.PkgGetter == nil {
-		c.PkgGetter = defaultPkgGetter{}
+		c.PkgGetter = defaultPkgGetter{progress: newProgressReporter(c.Progress, c.Debug)}
+		if !c.NoCache {
+			if pg := newCachingPkgGetter(c.PkgGetter); pg != nil {
+				c.PkgGetter = pg
+			}
+		}
+	}
+	if _, ok := c.PkgGetter.(*stageDirPkgGetter); !ok && c.StageDir != "" {
+		c.PkgGetter = &stageDirPkgGetter{PkgGetter: c.PkgGetter, Dir: c.StageDir}
+	}
+	if c.Format == "" {
+		c.Format = FormatText
+	}
+	if c.Credentials.IsEmpty() {
+		c.Credentials = gitutil.Credentials{
+			Username:   os.Getenv(GitUsernameEnv),
+			Token:      os.Getenv(GitTokenEnv),
+			SSHKeyPath: os.Getenv(GitSSHKeyEnv),
+		}
 	}
 	if c.PkgDiffer == nil {
-		c.PkgDiffer = &defaultPkgDiffer{
-			DiffType:     c.DiffType,
-			DiffTool:     c.DiffTool,
-			DiffToolOpts: c.DiffToolOpts,
-			Debug:        c.Debug,
-			Output:       c.Output,
+		d := c.defaultPkgDiffer()
+		switch {
+		case c.Format == FormatJSON:
+			c.PkgDiffer = &jsonPkgDiffer{defaultPkgDiffer: d}
+		case c.DiffType == Type3Way && c.DiffTool == "":
+			c.PkgDiffer = &threeWayPkgDiffer{
+				defaultPkgDiffer: d,
+				Color:            c.Color == ColorAlways || (c.Color != ColorNever && isTerminal(c.Output)),
+			}
+		default:
+			c.PkgDiffer = &d
 		}
 	}
 }
 
+// defaultPkgDiffer returns a defaultPkgDiffer configured from the command.
+func (c *Command) defaultPkgDiffer() defaultPkgDiffer {
+	return defaultPkgDiffer{
+		DiffType:           c.DiffType,
+		DiffTool:           c.DiffTool,
+		DiffToolOpts:       c.DiffToolOpts,
+		IgnoreFields:       c.IgnoreFields,
+		ExcludePatterns:    c.ExcludePatterns,
+		IncludeKptfile:     c.IncludeKptfile,
+		ExcludeSubpackages: c.ExcludeSubpackages,
+		ExitCode:           c.ExitCode,
+		Stat:               c.Stat,
+		Subpath:            c.Subpath,
+		Quick:              c.Quick,
+		Quiet:              c.Quiet,
+		Logger:             c.Logger,
+		Output:             c.Output,
+	}
+}
+
 // PkgDiffer knows how to compare given packages.
 type PkgDiffer interface {
 	Diff(pkgs ...string) error
 }
 
+// ContextPkgDiffer is a PkgDiffer which stops comparing the packages when
+// the context is canceled.
+type ContextPkgDiffer interface {
+	PkgDiffer
+	DiffContext(ctx context.Context, pkgs ...string) error
+}
+
+// pkgDiff returns the function comparing the staged packages with PkgDiffer,
+// which honors the cancellation of ctx if PkgDiffer supports it.
+func (c *Command) pkgDiff(ctx context.Context) func(pkgs ...string) error {
+	if d, ok := c.PkgDiffer.(ContextPkgDiffer); ok {
+		return func(pkgs ...string) error {
+			return d.DiffContext(ctx, pkgs...)
+		}
+	}
+	return c.PkgDiffer.Diff
+}
+
 type defaultPkgDiffer struct {
 	// DiffType specifies the type of changes to show
 	DiffType Type
@@ -261,9 +1034,41 @@ type defaultPkgDiffer struct {
 	// DiffToolOpts refers to the commandline options to for the diffing tool.
 	DiffToolOpts string
 
-	// When Debug is true, command will run with verbose logging and will not
-	// cleanup the staged packages to assist with debugging.
-	Debug bool
+	// IgnoreFields is a list of dotted field paths which are removed from
+	// all resources before comparing the packages.
+	IgnoreFields []string
+
+	// ExcludePatterns is a list of gitignore style patterns for files and
+	// directories which are removed from the packages before comparing.
+	ExcludePatterns []string
+
+	// IncludeKptfile keeps the Kptfile in the packages to compare it.
+	IncludeKptfile bool
+
+	// ExcludeSubpackages removes the subpackages from the packages before
+	// comparing.
+	ExcludeSubpackages bool
+
+	// ExitCode makes Diff return a DifferencesFoundError if the packages
+	// differ.
+	ExitCode bool
+
+	// Stat appends a summary of the changed files to the output.
+	Stat bool
+
+	// Subpath is the slash separated path of the file or directory within
+	// the packages to compare. All other files are removed before comparing.
+	Subpath string
+
+	// Quick skips running the diff tool if the content hashes of the
+	// packages match.
+	Quick bool
+
+	// Quiet suppresses the message telling that the packages don't differ.
+	Quiet bool
+
+	// Logger receives the invocation of the diff tool, see Command.Logger.
+	Logger logr.Logger
 
 	// Output is an io.Writer where command will write the output of the
 	// command.
@@ -271,15 +1076,46 @@ type defaultPkgDiffer struct {
 }
 
 func (d *defaultPkgDiffer) Diff(pkgs ...string) error {
-	// add merge comments before comparing so that there are no unwanted diffs
-	if err := addmergecomment.Process(pkgs...); err != nil {
+	return d.DiffContext(context.Background(), pkgs...)
+}
+
+// DiffContext compares the packages like Diff, but kills the diff tool when
+// ctx is canceled.
+func (d *defaultPkgDiffer) DiffContext(ctx context.Context, pkgs ...string) error {
+	if err := d.prepare(pkgs...); err != nil {
 		return err
 	}
-	for _, pkg := range pkgs {
-		if err := d.prepareForDiff(pkg); err != nil {
+	if d.Quick {
+		identical, err := identicalPkgs(d.excludedPaths(), pkgs...)
+		if err != nil {
 			return err
 		}
+		if identical {
+			d.printNoDifferences(pkgs...)
+			if d.Stat {
+				fmt.Fprintln(d.Output, diffStat{}.String())
+			}
+			return nil
+		}
 	}
+	var stat diffStat
+	if d.Stat {
+		// for the 3way diff type, the stat is relative to the local package
+		var err error
+		stat, err = computeStat(pkgs[0], pkgs[len(pkgs)-1])
+		if err != nil {
+			return err
+		}
+	}
+	err := d.runTool(ctx, pkgs...)
+	if _, ok := err.(*ToolError); !ok && ctx.Err() == nil && d.Stat {
+		fmt.Fprintln(d.Output, stat.String())
+	}
+	return err
+}
+
+// runTool compares the packages using the diff tool.
+func (d *defaultPkgDiffer) runTool(ctx context.Context, pkgs ...string) error {
 	var args []string
 	if d.DiffToolOpts != "" {
 		args = strings.Split(d.DiffToolOpts, " ")
@@ -287,40 +1123,117 @@ func (d *defaultPkgDiffer) Diff(pkgs ...string) error {
 	} else {
 		args = pkgs
 	}
-	cmd := exec.Command(d.DiffTool, args...)
+	cmd := exec.CommandContext(ctx, d.DiffTool, args...)
 	cmd.Stdout = d.Output
 	cmd.Stderr = d.Output
 
-	if d.Debug {
-		fmt.Fprintf(d.Output, "%s\n", strings.Join(cmd.Args, " "))
-	}
+	log := debugLogger(d.Logger)
+	log.Info("running diff tool", "tool", d.DiffTool, "args", cmd.Args[1:])
+	start := time.Now()
 	err := cmd.Run()
-	if err != nil {
-		exitErr, ok := err.(*exec.ExitError)
-		if ok && exitErr.ExitCode() == 1 {
-			// diff tool will exit with return code 1 if there are differences
-			// between two dirs. This suppresses those errors.
-			err = nil
-		} else if ok {
-			// An error occurred but was not one of the excluded ones
-			// Attempt to display help information to assist with resolving
-			fmt.Printf(exitCodeDiffWarning, d.DiffTool, d.DiffType)
+	log.Info("diff tool exited", "tool", d.DiffTool, "exitCode", cmd.ProcessState.ExitCode(),
+		"duration", time.Since(start))
+	if err == nil {
+		// diff tools comparing 3 packages may exit with 0 despite differences
+		if len(pkgs) == 2 {
+			d.printNoDifferences(pkgs...)
 		}
+		return nil
 	}
-	return err
+	if ctx.Err() != nil {
+		// the diff tool was killed
+		return ctx.Err()
+	}
+	exitErr, ok := err.(*exec.ExitError)
+	if ok && exitErr.ExitCode() == 1 {
+		// diff tool will exit with return code 1 if there are differences
+		// between two dirs. This suppresses those errors unless the caller
+		// asked for them.
+		if d.ExitCode {
+			return &DifferencesFoundError{}
+		}
+		return nil
+	}
+	if ok {
+		// An error occurred but was not one of the excluded ones
+		// Attempt to display help information to assist with resolving
+		fmt.Fprintf(d.Output, exitCodeDiffWarning, d.DiffTool, d.DiffType)
+	}
+	return &ToolError{Tool: d.DiffTool, Err: err}
+}
+
+// printNoDifferences tells that the staged packages don't differ, using the
+// names of the staged packages such as local-v1 and target-v2, unless Quiet
+// is set.
+func (d *defaultPkgDiffer) printNoDifferences(pkgs ...string) {
+	if d.Quiet {
+		return
+	}
+	var names []string
+	for _, pkg := range pkgs {
+		names = append(names, filepath.Base(pkg))
+	}
+	last := len(names) - 1
+	fmt.Fprintf(d.Output, "No differences between %s and %s.\n",
+		strings.Join(names[:last], ", "), names[last])
+}
+
+// prepare normalizes the staged packages so that only meaningful
+// differences remain when comparing them.
+func (d *defaultPkgDiffer) prepare(pkgs ...string) error {
+	for _, pkg := range pkgs {
+		if err := keepSubpath(pkg, d.Subpath); err != nil {
+			return err
+		}
+	}
+	// add merge comments before comparing so that there are no unwanted diffs
+	if err := addmergecomment.Process(pkgs...); err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		if err := d.prepareForDiff(pkg); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // prepareForDiff removes metadata such as .git and Kptfile from a staged package
-// to exclude them from diffing.
+// to exclude them from diffing, the Kptfiles are kept if IncludeKptfile is set.
+// It also removes the subpackages if ExcludeSubpackages is set, then the
+// excluded paths and the ignored fields from all remaining resources in the
+// package.
 func (d *defaultPkgDiffer) prepareForDiff(dir string) error {
-	excludePaths := []string{".git", kptfilev1.KptFileName}
-	for _, path := range excludePaths {
+	switch {
+	case d.ExcludeSubpackages:
+		if err := removeSubpackages(dir); err != nil {
+			return err
+		}
+	case !d.IncludeKptfile:
+		if err := removeSubpackageKptfiles(dir); err != nil {
+			return err
+		}
+	}
+	for _, path := range d.excludedPaths() {
 		path = filepath.Join(dir, path)
 		if err := os.RemoveAll(path); err != nil {
 			return err
 		}
 	}
-	return nil
+	if err := removeExcluded(dir, d.ExcludePatterns); err != nil {
+		return err
+	}
+	return clearFields(dir, d.IgnoreFields)
+}
+
+// excludedPaths returns the paths relative to the package root which
+// prepareForDiff removes from the staged packages.
+func (d *defaultPkgDiffer) excludedPaths() []string {
+	paths := []string{".git"}
+	if !d.IncludeKptfile {
+		paths = append(paths, kptfilev1.KptFileName)
+	}
+	return paths
 }
 
 // PkgGetter knows how to fetch a package given a git repo, path and ref.
@@ -328,8 +1241,25 @@ type PkgGetter interface {
 	GetPkg(ctx context.Context, stagingDir, targetDir, repo, path, ref string) (dir string, err error)
 }
 
+// UpstreamPkgGetter is a PkgGetter which can also fetch packages from
+// upstreams of other types than git, e.g. OCI registries, as described by
+// the upstream of the Kptfile. It can be set as Command.PkgGetter, git
+// upstreams are still fetched with GetPkg.
+type UpstreamPkgGetter interface {
+	PkgGetter
+	// GetPkgFromUpstream fetches the package at ref of upstream into
+	// targetDir within stagingDir and returns the directory of the package.
+	// An empty ref refers to the version of the package recorded in
+	// upstream.
+	GetPkgFromUpstream(ctx context.Context, stagingDir, targetDir string,
+		upstream *kptfilev1.Upstream, ref string) (dir string, err error)
+}
+
 // defaultPkgGetter uses fetch.Command abstraction to implement PkgGetter.
-type defaultPkgGetter struct{}
+type defaultPkgGetter struct {
+	// progress reports the fetches, it may be nil.
+	progress *progressReporter
+}
 
 // GetPkg checks out a repository into a temporary directory for diffing
 // and returns the directory containing the checked out package or an error.
@@ -342,8 +1272,7 @@ func (pg defaultPkgGetter) GetPkg(ctx context.Context, stagingDir, targetDir, re
 		return dir, err
 	}
 
-	name := filepath.Base(dir)
-	kf := kptfileutil.DefaultKptfile(name)
+	kf := kptfileutil.DefaultKptfile(stagedPkgName(repo, path))
 	kf.Upstream = &kptfilev1.Upstream{
 		Type: kptfilev1.GitOrigin,
 		Git: &kptfilev1.Git{
@@ -362,28 +1291,64 @@ func (pg defaultPkgGetter) GetPkg(ctx context.Context, stagingDir, targetDir, re
 		return dir, err
 	}
 
+	pg.progress.fetching(repo, ref)
+	start := time.Now()
 	cmdGet := &fetch.Command{
 		Pkg: p,
 	}
-	err = cmdGet.Run(ctx)
-	return dir, err
+	if err := cmdGet.Run(ctx); err != nil {
+		return dir, err
+	}
+	var commit string
+	if kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, dir); err == nil &&
+		kf.UpstreamLock != nil && kf.UpstreamLock.Git != nil {
+		commit = kf.UpstreamLock.Git.Commit
+	}
+	pg.progress.fetched(repo, ref, commit, time.Since(start))
+	return dir, nil
+}
+
+// stagedPkgName returns the name of the package in the directory dir of repo.
+// Staged packages are named after their upstream directory rather than their
+// staging directory, so the names in their Kptfiles don't differ.
+func stagedPkgName(repo, dir string) string {
+	if name := path.Base(path.Clean("/" + dir)); name != "/" {
+		return name
+	}
+	return strings.TrimSuffix(path.Base(strings.TrimSuffix(repo, "/")), ".git")
 }
 
 // stageDirectory creates a subdirectory of the provided path for temporary operations
 // path is the parent staged directory and should already exist
-// subpath is the subdirectory that should be created inside path
+// subpath is the subdirectory that should be created inside path; if subpath
+// already exists, e.g. because two refs have the same last segment, a numeric
+// suffix is appended so staged packages never overwrite each other
 func stageDirectory(path, subpath string) (string, error) {
 	targetPath := filepath.Join(path, subpath)
-	err := os.Mkdir(targetPath, os.ModePerm)
-	return targetPath, err
+	for i := 2; ; i++ {
+		err := os.Mkdir(targetPath, os.ModePerm)
+		if !os.IsExist(err) {
+			return targetPath, err
+		}
+		targetPath = filepath.Join(path, fmt.Sprintf("%s-%d", subpath, i))
+	}
 }
 
-// NameStagingDirectory assigns a name that matches the package source information
+// NameStagingDirectory assigns a name that matches the package source information.
+// Names only contain the last segment of the ref, so refs which only differ
+// before their last "/" are given the same name, e.g. "release/v1" and
+// "hotfix/v1". stageDirectory appends a suffix to names which are already
+// staged. The packages kept in a stage dir or the cache are keyed by
+// repository, directory and commit instead.
 func NameStagingDirectory(source, ref string) string {
 	// Using tags may result in references like /refs/tags/version
 	// To avoid creating additional directory's use only the last name after a /
-	splitRef := strings.Split(ref, "/")
+	splitRef := strings.Split(strings.TrimRight(ref, "/"), "/")
 	reducedRef := splitRef[len(splitRef)-1]
+	// The ref is unknown, e.g. if the Kptfile doesn't specify one
+	if reducedRef == "" {
+		return source
+	}
 
 	return fmt.Sprintf("%s-%s",
 		source,
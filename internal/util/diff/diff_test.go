@@ -22,18 +22,57 @@ package diff_test
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
 	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
 	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
 	"github.com/GoogleContainerTools/kpt/internal/testutil"
 	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
 	. "github.com/GoogleContainerTools/kpt/internal/util/diff"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/go-logr/logr/funcr"
 	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
+func TestMain(m *testing.M) {
+	os.Exit(runWithPkgCache(m))
+}
+
+// runWithPkgCache runs the tests with the package cache in a temporary
+// directory, so the tests don't populate the cache of the user.
+func runWithPkgCache(m *testing.M) int {
+	cacheDir, err := ioutil.TempDir("", "kpt-test-cache-diff-")
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(cacheDir)
+	}()
+	if err := os.Setenv(PkgCacheDirEnv, cacheDir); err != nil {
+		panic(err)
+	}
+	return m.Run()
+}
+
 func TestCommand_Diff(t *testing.T) {
 	testCases := map[string]struct {
 		reposChanges              map[string][]testutil.Content
@@ -87,6 +126,46 @@ func TestCommand_Diff(t *testing.T) {
 `,
 		},
 
+		// 1. add data to the upstream master branch
+		// 2. commit and tag the upstream master branch
+		// 3. add more data to the upstream master branch, commit it
+		// 4. create a local clone at the tag
+		// 5. add more data to the upstream master branch, commit it
+		// 6. Run merged diff between the local fork and the merge result
+		"mergedDiff": {
+			reposChanges: map[string][]testutil.Content{
+				testutil.Upstream: {
+					{
+						Data:   testutil.Dataset2,
+						Branch: "master",
+						Tag:    "v2",
+					},
+					{
+						Data: testutil.Dataset3,
+					},
+				},
+			},
+			fetchRef: "v2",
+			diffRef:  "master",
+			diffType: TypeMerged,
+			diffTool: "diff",
+			diffOpts: "-r -i -w",
+			expDiff: `
+39c39
+<             - containerPort: 80
+---
+>             - containerPort: 8081
+25,27c25,27
+<     - name: "80"
+<       port: 80
+<       targetPort: 80
+---
+>     - name: "8081"
+>       port: 8081
+>       targetPort: 8081
+`,
+		},
+
 		// 1. add data to the upstream master branch
 		// 2. commit and tag the upstream master branch
 		// 3. add more data to the upstream master branch, commit it
@@ -438,69 +517,1506 @@ func TestCommand_Diff3Parameters(t *testing.T) {
 	assert.Contains(t, results[2], TargetRemotePackageSource)
 }
 
-// Tests against directories in different states
-func TestCommand_NotAKptDirectory(t *testing.T) {
-	// Initial test setup
-	dir := t.TempDir()
+func TestCommand_DiffJSON(t *testing.T) {
+	reposChanges := map[string][]testutil.Content{
+		testutil.Upstream: {
+			{
+				Data:   testutil.Dataset2,
+				Branch: "master",
+				Tag:    "v2",
+			},
+			{
+				Data: testutil.Dataset3,
+			},
+		},
+	}
+
+	g := &testutil.TestSetupManager{
+		T:            t,
+		ReposChanges: reposChanges,
+		GetRef:       "v2",
+	}
+	defer g.Clean()
+
+	if !g.Init() {
+		return
+	}
+
+	diffOutput := &bytes.Buffer{}
+	err := (&Command{
+		Path:     g.LocalWorkspace.FullPackagePath(),
+		Ref:      "master",
+		DiffType: TypeRemote,
+		Format:   FormatJSON,
+		Output:   diffOutput,
+	}).Run(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	var result struct {
+		From  string     `json:"from"`
+		To    string     `json:"to"`
+		Files []FileDiff `json:"files"`
+	}
+	if !assert.NoError(t, json.Unmarshal(diffOutput.Bytes(), &result)) {
+		t.FailNow()
+	}
+	assert.Equal(t, NameStagingDirectory(RemotePackageSource, "v2"), result.From)
+	assert.Equal(t, NameStagingDirectory(TargetRemotePackageSource, "master"), result.To)
+	if !assert.Len(t, result.Files, 2) {
+		t.FailNow()
+	}
+	assert.Equal(t, "java/java-deployment.resource.yaml", result.Files[0].Path)
+	assert.Equal(t, FileModified, result.Files[0].Status)
+	assert.Contains(t, result.Files[0].Hunks[0].Lines, "-            - containerPort: 80")
+	assert.Contains(t, result.Files[0].Hunks[0].Lines, "+            - containerPort: 8081")
+	assert.Equal(t, "java/java-service.resource.yaml", result.Files[1].Path)
+	assert.Equal(t, FileModified, result.Files[1].Status)
+}
+
+func TestCommand_OutputFile(t *testing.T) {
+	reposChanges := map[string][]testutil.Content{
+		testutil.Upstream: {
+			{
+				Data:   testutil.Dataset2,
+				Branch: "master",
+				Tag:    "v2",
+			},
+			{
+				Data: testutil.Dataset3,
+			},
+		},
+	}
+
+	g := &testutil.TestSetupManager{
+		T:            t,
+		ReposChanges: reposChanges,
+		GetRef:       "v2",
+	}
+	defer g.Clean()
+
+	if !g.Init() {
+		return
+	}
 
 	testCases := map[string]struct {
-		directory string
+		format   Format
+		expected string
 	}{
-		"Directory Is Not Kpt Package": {directory: dir},
-		"Directory Does Not Exist":     {directory: "/not/a/directory"},
+		"text": {
+			format:   FormatText,
+			expected: "> - containerPort: 8081",
+		},
+		"json": {
+			format:   FormatJSON,
+			expected: `"path": "java/java-deployment.resource.yaml"`,
+		},
 	}
 
 	for tn, tc := range testCases {
 		t.Run(tn, func(t *testing.T) {
 			diffOutput := &bytes.Buffer{}
-			cmdErr := (&Command{
-				Path:         tc.directory,
+			outputFile := filepath.Join(t.TempDir(), "nested", "diff.out")
+			err := (&Command{
+				Path:         g.LocalWorkspace.FullPackagePath(),
 				Ref:          "master",
-				DiffType:     TypeCombined,
+				DiffType:     TypeRemote,
 				DiffTool:     "diff",
 				DiffToolOpts: "-r -i -w",
+				Format:       tc.format,
 				Output:       diffOutput,
+				OutputFile:   outputFile,
 			}).Run(fake.CtxWithDefaultPrinter())
-			assert.Error(t, cmdErr)
-
-			assert.Contains(t, cmdErr.Error(), "no such file or directory")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			b, err := ioutil.ReadFile(outputFile)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Contains(t, strings.Join(strings.Fields(string(b)), " "), tc.expected)
+			assert.Empty(t, diffOutput.String())
 		})
 	}
+
+	// the output file can't be created below a regular file
+	notADir := filepath.Join(t.TempDir(), "file")
+	if !assert.NoError(t, ioutil.WriteFile(notADir, nil, 0600)) {
+		t.FailNow()
+	}
+	err := (&Command{
+		Path:       g.LocalWorkspace.FullPackagePath(),
+		Ref:        "master",
+		DiffType:   TypeRemote,
+		DiffTool:   "diff",
+		OutputFile: filepath.Join(notADir, "diff.out"),
+	}).Run(fake.CtxWithDefaultPrinter())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "failed to create output file")
+	}
 }
 
-// filterDiffMetadata removes information from the diff output that is test-run
-// specific for ex. removing directory name being used.
-func filterDiffMetadata(r io.Reader) string {
-	scanner := bufio.NewScanner(r)
-	b := &bytes.Buffer{}
+func TestCommand_RunWithResult(t *testing.T) {
+	reposChanges := map[string][]testutil.Content{
+		testutil.Upstream: {
+			{
+				Data:   testutil.Dataset2,
+				Branch: "master",
+				Tag:    "v2",
+			},
+			{
+				Data: testutil.Dataset3,
+			},
+		},
+	}
 
-	for scanner.Scan() {
-		text := scanner.Text()
-		// filter out the diff command that contains directory names
-		if strings.HasPrefix(text, "diff ") {
-			continue
+	g := &testutil.TestSetupManager{
+		T:            t,
+		ReposChanges: reposChanges,
+		GetRef:       "v2",
+	}
+	defer g.Clean()
+
+	if !g.Init() {
+		return
+	}
+
+	for _, exitCode := range []bool{false, true} {
+		diffOutput := &bytes.Buffer{}
+		result, err := (&Command{
+			Path:     g.LocalWorkspace.FullPackagePath(),
+			Ref:      "master",
+			DiffType: TypeRemote,
+			// the diff tool isn't used to compute the result
+			DiffTool: "missing-diff-tool",
+			ExitCode: exitCode,
+			Output:   diffOutput,
+		}).RunWithResult(fake.CtxWithDefaultPrinter())
+		if exitCode {
+			assert.IsType(t, &DifferencesFoundError{}, err)
+		} else if !assert.NoError(t, err) {
+			t.FailNow()
 		}
-		b.WriteString(text)
-		b.WriteString("\n")
+		if !assert.NotNil(t, result) {
+			t.FailNow()
+		}
+		assert.Equal(t, NameStagingDirectory(RemotePackageSource, "v2"), result.From)
+		assert.Equal(t, NameStagingDirectory(TargetRemotePackageSource, "master"), result.To)
+		assert.Equal(t, []string{"java/java-deployment.resource.yaml", "java/java-service.resource.yaml"}, result.Changed)
+		assert.Empty(t, result.Added)
+		assert.Empty(t, result.Removed)
+		if assert.Len(t, result.Files, 2) {
+			assert.Contains(t, result.Files[0].Hunks[0].Lines, "+            - containerPort: 8081")
+		}
+		assert.Empty(t, diffOutput.String())
 	}
-	return b.String()
 }
 
-func TestStagingDirectoryNames(t *testing.T) {
-	var tests = []struct {
-		source   string
-		branch   string
-		expected string
+// Validate that concurrent runs against the same repo and stage dir don't
+// interfere with each other
+func TestCommand_RunConcurrently(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T: t,
+		ReposChanges: map[string][]testutil.Content{
+			testutil.Upstream: {
+				{
+					Data:   testutil.Dataset2,
+					Branch: "master",
+					Tag:    "v2",
+				},
+				{
+					Data: testutil.Dataset3,
+				},
+			},
+		},
+		GetRef: "v2",
+	}
+	defer g.Clean()
+	if !g.Init() {
+		return
+	}
+
+	testCases := []struct {
+		diffType Type
+		ref      string
 	}{
-		{"source", "branch", "source-branch"},
-		{"source", "refs/tags/version", "source-version"},
+		{diffType: TypeLocal},
+		{diffType: TypeRemote, ref: "v2"},
+		{diffType: TypeRemote, ref: "master"},
+		{diffType: TypeCombined, ref: "master"},
+		{diffType: Type3Way, ref: "master"},
+	}
+	run := func(diffType Type, ref, stageDir string) (*DiffResult, error) {
+		return (&Command{
+			Path:     g.LocalWorkspace.FullPackagePath(),
+			Ref:      ref,
+			DiffType: diffType,
+			StageDir: stageDir,
+			Output:   &bytes.Buffer{},
+		}).RunWithResult(fake.CtxWithDefaultPrinter())
 	}
 
-	for i := range tests {
-		tt := tests[i]
-		t.Run(tt.expected, func(t *testing.T) {
-			result := NameStagingDirectory(tt.source, tt.branch)
-			assert.Equal(t, tt.expected, result)
-		})
+	expected := make([]*DiffResult, len(testCases))
+	for i, tc := range testCases {
+		var err error
+		expected[i], err = run(tc.diffType, tc.ref, "")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+
+	// the runs of all diff types share the packages in the stage dir
+	const runs = 3
+	stageDir := t.TempDir()
+	results := make([][runs]*DiffResult, len(testCases))
+	errs := make([][runs]error, len(testCases))
+	var wg sync.WaitGroup
+	for i := range testCases {
+		for j := 0; j < runs; j++ {
+			wg.Add(1)
+			go func(i, j int) {
+				defer wg.Done()
+				results[i][j], errs[i][j] = run(testCases[i].diffType, testCases[i].ref, stageDir)
+			}(i, j)
+		}
+	}
+	wg.Wait()
+
+	for i, tc := range testCases {
+		for j := 0; j < runs; j++ {
+			if assert.NoError(t, errs[i][j], "diff type %s at %q", tc.diffType, tc.ref) {
+				assert.Equal(t, expected[i], results[i][j], "diff type %s at %q", tc.diffType, tc.ref)
+			}
+		}
+	}
+}
+
+func TestCommand_RunCanceled(t *testing.T) {
+	reposChanges := map[string][]testutil.Content{
+		testutil.Upstream: {
+			{
+				Data:   testutil.Dataset2,
+				Branch: "master",
+				Tag:    "v2",
+			},
+			{
+				Data: testutil.Dataset3,
+			},
+		},
+	}
+
+	g := &testutil.TestSetupManager{
+		T:            t,
+		ReposChanges: reposChanges,
+		GetRef:       "v2",
+	}
+	defer g.Clean()
+
+	if !g.Init() {
+		return
+	}
+
+	// the diff tool records the staged packages and blocks until killed
+	dir := t.TempDir()
+	staged := filepath.Join(dir, "staged")
+	tool := filepath.Join(dir, "slow-diff")
+	err := ioutil.WriteFile(tool, []byte("#!/bin/sh\necho \"$@\" > "+staged+"\nexec sleep 60\n"), 0700)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	ctx, cancel := context.WithCancel(fake.CtxWithDefaultPrinter())
+	defer cancel()
+	go func() {
+		// cancel once the diff tool has been started
+		for ctx.Err() == nil {
+			if _, err := os.Stat(staged); err == nil {
+				cancel()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	start := time.Now()
+	err = (&Command{
+		Path:     g.LocalWorkspace.FullPackagePath(),
+		Ref:      "master",
+		DiffType: TypeRemote,
+		DiffTool: tool,
+		Output:   &bytes.Buffer{},
+	}).Run(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Less(t, time.Since(start), 30*time.Second)
+
+	// the staging directory is removed
+	b, err := ioutil.ReadFile(staged)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for _, pkg := range strings.Fields(string(b)) {
+		assert.NoDirExists(t, pkg)
+	}
+}
+
+func TestCommand_DiffSubpath(t *testing.T) {
+	testCases := map[string]struct {
+		subpath  string
+		expFiles []FileDiff
+	}{
+		"changed file": {
+			subpath: "java/java-service.resource.yaml",
+			expFiles: []FileDiff{
+				{Path: "java/java-service.resource.yaml", Status: FileModified},
+			},
+		},
+		"missing file": {
+			subpath:  "java/missing.yaml",
+			expFiles: []FileDiff{},
+		},
 	}
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T: t,
+				ReposChanges: map[string][]testutil.Content{
+					testutil.Upstream: {
+						{
+							Data:   testutil.Dataset2,
+							Branch: "master",
+							Tag:    "v2",
+						},
+						{
+							Data: testutil.Dataset3,
+						},
+					},
+				},
+				GetRef: "v2",
+			}
+			defer g.Clean()
+			if !g.Init() {
+				return
+			}
+
+			diffOutput := &bytes.Buffer{}
+			err := (&Command{
+				Path:     g.LocalWorkspace.FullPackagePath(),
+				Ref:      "master",
+				DiffType: TypeRemote,
+				Format:   FormatJSON,
+				Subpath:  tc.subpath,
+				Output:   diffOutput,
+			}).Run(fake.CtxWithDefaultPrinter())
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			var result struct {
+				Files []FileDiff `json:"files"`
+			}
+			if !assert.NoError(t, json.Unmarshal(diffOutput.Bytes(), &result)) {
+				t.FailNow()
+			}
+			for i := range result.Files {
+				result.Files[i].Hunks = nil
+			}
+			assert.Equal(t, tc.expFiles, result.Files)
+		})
+	}
+}
+
+func TestCommand_DiffIncludeKptfile(t *testing.T) {
+	testCases := map[string]struct {
+		includeKptfile bool
+		ignoreFields   []string
+		expKptfile     bool
+		expLines       []string
+	}{
+		"excluded by default": {},
+		"included": {
+			includeKptfile: true,
+			expKptfile:     true,
+			expLines:       []string{"-    ref: v2", "+    ref: master"},
+		},
+		"included without the upstream fields": {
+			includeKptfile: true,
+			ignoreFields:   []string{"upstream", "upstreamLock"},
+		},
+	}
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T: t,
+				ReposChanges: map[string][]testutil.Content{
+					testutil.Upstream: {
+						{
+							Data:   testutil.Dataset2,
+							Branch: "master",
+							Tag:    "v2",
+						},
+						{
+							Data: testutil.Dataset3,
+						},
+					},
+				},
+				GetRef: "v2",
+			}
+			defer g.Clean()
+			if !g.Init() {
+				return
+			}
+
+			diffOutput := &bytes.Buffer{}
+			err := (&Command{
+				Path:           g.LocalWorkspace.FullPackagePath(),
+				Ref:            "master",
+				DiffType:       TypeRemote,
+				Format:         FormatJSON,
+				IncludeKptfile: tc.includeKptfile,
+				IgnoreFields:   tc.ignoreFields,
+				Output:         diffOutput,
+			}).Run(fake.CtxWithDefaultPrinter())
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			var result struct {
+				Files []FileDiff `json:"files"`
+			}
+			if !assert.NoError(t, json.Unmarshal(diffOutput.Bytes(), &result)) {
+				t.FailNow()
+			}
+			var kptfileDiff *FileDiff
+			for i := range result.Files {
+				if result.Files[i].Path == kptfilev1.KptFileName {
+					kptfileDiff = &result.Files[i]
+				}
+			}
+			if !tc.expKptfile {
+				assert.Nil(t, kptfileDiff, diffOutput.String())
+				return
+			}
+			if !assert.NotNil(t, kptfileDiff, diffOutput.String()) {
+				t.FailNow()
+			}
+			assert.Equal(t, FileModified, kptfileDiff.Status)
+			var lines []string
+			for _, h := range kptfileDiff.Hunks {
+				lines = append(lines, h.Lines...)
+			}
+			for _, l := range tc.expLines {
+				assert.Contains(t, lines, l)
+			}
+		})
+	}
+}
+
+func TestCommand_ExitCode(t *testing.T) {
+	testCases := map[string]struct {
+		diffRef  string
+		diffOpts string
+		expErr   interface{}
+		// expWarning is set if the warning about the failure of the diff
+		// tool is expected in the output
+		expWarning bool
+	}{
+		"no differences": {
+			diffRef:  "v2",
+			diffOpts: "-r",
+		},
+		"differences found": {
+			diffRef:  "master",
+			diffOpts: "-r",
+			expErr:   &DifferencesFoundError{},
+		},
+		"diff tool failure": {
+			diffRef:    "master",
+			diffOpts:   "-r --no-such-option",
+			expErr:     &ToolError{},
+			expWarning: true,
+		},
+	}
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T: t,
+				ReposChanges: map[string][]testutil.Content{
+					testutil.Upstream: {
+						{
+							Data:   testutil.Dataset2,
+							Branch: "master",
+							Tag:    "v2",
+						},
+						{
+							Data: testutil.Dataset3,
+						},
+					},
+				},
+				GetRef: "v2",
+			}
+			defer g.Clean()
+			if !g.Init() {
+				return
+			}
+
+			var output bytes.Buffer
+			err := (&Command{
+				Path:         g.LocalWorkspace.FullPackagePath(),
+				Ref:          tc.diffRef,
+				DiffType:     TypeRemote,
+				DiffTool:     "diff",
+				DiffToolOpts: tc.diffOpts,
+				ExitCode:     true,
+				Output:       &output,
+			}).Run(fake.CtxWithDefaultPrinter())
+			assert.Equal(t, tc.expWarning, strings.Contains(output.String(), "The selected diff tool (diff) exited with an error"))
+			if tc.expErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.IsType(t, tc.expErr, err)
+		})
+	}
+}
+
+// Validate that a message tells that the packages don't differ unless Quiet
+// is set
+func TestCommand_NoDifferences(t *testing.T) {
+	testCases := map[string]struct {
+		diffRef   string
+		quiet     bool
+		differs   bool
+		expOutput string
+	}{
+		"no differences": {
+			diffRef:   "v2",
+			expOutput: "No differences between remote-v2 and target-v2.\n",
+		},
+		"no differences with quiet": {
+			diffRef: "v2",
+			quiet:   true,
+		},
+		"differences found": {
+			diffRef: "master",
+			differs: true,
+		},
+	}
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T: t,
+				ReposChanges: map[string][]testutil.Content{
+					testutil.Upstream: {
+						{
+							Data:   testutil.Dataset2,
+							Branch: "master",
+							Tag:    "v2",
+						},
+						{
+							Data: testutil.Dataset3,
+						},
+					},
+				},
+				GetRef: "v2",
+			}
+			defer g.Clean()
+			if !g.Init() {
+				return
+			}
+
+			out := &bytes.Buffer{}
+			err := (&Command{
+				Path:         g.LocalWorkspace.FullPackagePath(),
+				Ref:          tc.diffRef,
+				DiffType:     TypeRemote,
+				DiffTool:     "diff",
+				DiffToolOpts: "-r",
+				Quiet:        tc.quiet,
+				Output:       out,
+			}).Run(fake.CtxWithDefaultPrinter())
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			if tc.differs {
+				assert.NotContains(t, out.String(), "No differences")
+				return
+			}
+			assert.Equal(t, tc.expOutput, out.String())
+		})
+	}
+}
+
+// Validate that the quick mode doesn't run the diff tool for identical packages
+func TestCommand_Quick(t *testing.T) {
+	testCases := map[string]struct {
+		diffRef   string
+		expOutput string
+		expErr    interface{}
+	}{
+		"no differences": {
+			diffRef:   "v2",
+			expOutput: "No differences between remote-v2 and target-v2.\n",
+		},
+		"differences found": {
+			diffRef: "master",
+			expErr:  &ToolError{},
+		},
+	}
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T: t,
+				ReposChanges: map[string][]testutil.Content{
+					testutil.Upstream: {
+						{
+							Data:   testutil.Dataset2,
+							Branch: "master",
+							Tag:    "v2",
+						},
+						{
+							Data: testutil.Dataset3,
+						},
+					},
+				},
+				GetRef: "v2",
+			}
+			defer g.Clean()
+			if !g.Init() {
+				return
+			}
+
+			// the diff tool fails if it is run
+			out := &bytes.Buffer{}
+			err := (&Command{
+				Path:         g.LocalWorkspace.FullPackagePath(),
+				Ref:          tc.diffRef,
+				DiffType:     TypeRemote,
+				DiffTool:     "diff",
+				DiffToolOpts: "-r --no-such-option",
+				Quick:        true,
+				Output:       out,
+			}).Run(fake.CtxWithDefaultPrinter())
+			if tc.expErr != nil {
+				assert.IsType(t, tc.expErr, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expOutput, out.String())
+		})
+	}
+}
+
+// Validate that the names of the changed files are listed without the diff tool
+func TestCommand_NameOnly(t *testing.T) {
+	testCases := map[string]struct {
+		diffType   Type
+		diffRef    string
+		nameStatus bool
+		stat       bool
+		expOutput  string
+	}{
+		"local changes": {
+			diffType: TypeLocal,
+			expOutput: `java/java-service.resource.yaml
+mysql/mysql-configmap.resource.yaml
+new.yaml
+`,
+		},
+		"local changes with status": {
+			diffType:   TypeLocal,
+			nameStatus: true,
+			stat:       true,
+			// the local package is compared with the upstream package
+			expOutput: `M	java/java-service.resource.yaml
+A	mysql/mysql-configmap.resource.yaml
+D	new.yaml
+1 file changed, 1 added, 1 removed
+`,
+		},
+		"remote changes": {
+			diffType:   TypeRemote,
+			diffRef:    "master",
+			nameStatus: true,
+			expOutput: `M	java/java-deployment.resource.yaml
+M	java/java-service.resource.yaml
+`,
+		},
+	}
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T: t,
+				ReposChanges: map[string][]testutil.Content{
+					testutil.Upstream: {
+						{
+							Data:   testutil.Dataset2,
+							Branch: "master",
+							Tag:    "v2",
+						},
+						{
+							Data: testutil.Dataset3,
+						},
+					},
+				},
+				GetRef: "v2",
+			}
+			defer g.Clean()
+			if !g.Init() {
+				return
+			}
+			pkgPath := g.LocalWorkspace.FullPackagePath()
+			if !assert.NoError(t, ioutil.WriteFile(filepath.Join(pkgPath, "new.yaml"), []byte("kind: ConfigMap\n"), 0600)) ||
+				!assert.NoError(t, os.Remove(filepath.Join(pkgPath, "mysql", "mysql-configmap.resource.yaml"))) ||
+				!assert.NoError(t, ioutil.WriteFile(filepath.Join(pkgPath, "java", "java-service.resource.yaml"), []byte("kind: Service\n"), 0600)) {
+				t.FailNow()
+			}
+
+			// the diff tool fails if it is run
+			out := &bytes.Buffer{}
+			err := (&Command{
+				Path:         pkgPath,
+				Ref:          tc.diffRef,
+				DiffType:     tc.diffType,
+				DiffTool:     "diff",
+				DiffToolOpts: "-r --no-such-option",
+				NameOnly:     !tc.nameStatus,
+				NameStatus:   tc.nameStatus,
+				Stat:         tc.stat,
+				Output:       out,
+			}).Run(fake.CtxWithDefaultPrinter())
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expOutput, out.String())
+			}
+		})
+	}
+}
+
+// Validate that two upstream refs can be compared without using the local package
+func TestCommand_DiffRefs(t *testing.T) {
+	reposChanges := map[string][]testutil.Content{
+		testutil.Upstream: {
+			{
+				Data:   testutil.Dataset2,
+				Branch: "master",
+				Tag:    "v2",
+			},
+			{
+				Data: testutil.Dataset3,
+			},
+		},
+	}
+	expDiff := `
+39c39
+<             - containerPort: 80
+---
+>             - containerPort: 8081
+25,27c25,27
+<     - name: "80"
+<       port: 80
+<       targetPort: 80
+---
+>     - name: "8081"
+>       port: 8081
+>       targetPort: 8081
+`
+
+	g := &testutil.TestSetupManager{
+		T:            t,
+		ReposChanges: reposChanges,
+		GetRef:       "v2",
+		LocalChanges: []testutil.Content{{Data: testutil.Dataset3}},
+	}
+	defer g.Clean()
+
+	if !g.Init() {
+		return
+	}
+
+	testCases := map[string]struct {
+		path string
+		repo string
+	}{
+		"repo from Kptfile": {
+			path: g.LocalWorkspace.FullPackagePath(),
+		},
+		"explicit repo without Kptfile": {
+			path: t.TempDir(),
+			repo: g.Repos[testutil.Upstream].RepoDirectory,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			diffOutput := &bytes.Buffer{}
+			cmd := &Command{
+				Path:         tc.path,
+				FromRef:      "v2",
+				ToRef:        "master",
+				Repo:         tc.repo,
+				DiffType:     TypeLocal,
+				DiffTool:     "diff",
+				DiffToolOpts: "-r -i -w",
+				Output:       diffOutput,
+			}
+			if !assert.NoError(t, cmd.Validate()) {
+				t.FailNow()
+			}
+			err := cmd.Run(fake.CtxWithDefaultPrinter())
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, strings.TrimSpace(expDiff)+"\n", filterDiffMetadata(diffOutput))
+		})
+	}
+}
+
+func TestCommand_DiffRefsCredentials(t *testing.T) {
+	execPath, err := exec.Command("git", "--exec-path").Output()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	backend := filepath.Join(strings.TrimSpace(string(execPath)), "git-http-backend")
+	if _, err := os.Stat(backend); err != nil {
+		t.Skipf("git-http-backend is required: %v", err)
+	}
+
+	reposChanges := map[string][]testutil.Content{
+		testutil.Upstream: {
+			{
+				Data:   testutil.Dataset2,
+				Branch: "master",
+				Tag:    "v2",
+			},
+			{
+				Data: testutil.Dataset3,
+			},
+		},
+	}
+	g := &testutil.TestSetupManager{
+		T:            t,
+		ReposChanges: reposChanges,
+		GetRef:       "v2",
+	}
+	defer g.Clean()
+
+	if !g.Init() {
+		return
+	}
+
+	// serve the upstream repository over HTTP and require basic auth
+	repoDir := g.Repos[testutil.Upstream].RepoDirectory
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "kpt" || password != "s3cr3t" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="kpt"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		(&cgi.Handler{
+			Path: backend,
+			Env: []string{
+				"GIT_PROJECT_ROOT=" + filepath.Dir(repoDir),
+				"GIT_HTTP_EXPORT_ALL=1",
+			},
+		}).ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	testCases := map[string]struct {
+		credentials gitutil.Credentials
+		env         map[string]string
+		expErr      bool
+	}{
+		"no credentials": {
+			expErr: true,
+		},
+		"wrong token": {
+			credentials: gitutil.Credentials{Username: "kpt", Token: "wrong"},
+			expErr:      true,
+		},
+		"explicit credentials": {
+			credentials: gitutil.Credentials{Username: "kpt", Token: "s3cr3t"},
+		},
+		"credentials from environment": {
+			env: map[string]string{
+				GitUsernameEnv: "kpt",
+				GitTokenEnv:    "s3cr3t",
+			},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			t.Setenv(gitutil.RepoCacheDirEnv, t.TempDir())
+			diffOutput := &bytes.Buffer{}
+			result, err := (&Command{
+				Path:        t.TempDir(),
+				FromRef:     "v2",
+				ToRef:       "master",
+				Repo:        server.URL + "/" + filepath.Base(repoDir),
+				DiffType:    TypeLocal,
+				DiffTool:    "diff",
+				Credentials: tc.credentials,
+				NoCache:     true,
+				Output:      diffOutput,
+			}).RunWithResult(fake.CtxWithDefaultPrinter())
+			if tc.expErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, []string{"java/java-deployment.resource.yaml", "java/java-service.resource.yaml"}, result.Changed)
+		})
+	}
+}
+
+// emptyPkgGetter stages empty packages, so the upstream repository is only
+// accessed to look up its default branch.
+type emptyPkgGetter struct{}
+
+func (emptyPkgGetter) GetPkg(_ context.Context, stagingDir, targetDir, _, _, _ string) (string, error) {
+	dir := filepath.Join(stagingDir, targetDir)
+	return dir, os.MkdirAll(dir, 0700)
+}
+
+// Validate that the default branch is only looked up when the diff type
+// needs a target and that it is looked up once per repository
+func TestCommand_DefaultRef(t *testing.T) {
+	execPath, err := exec.Command("git", "--exec-path").Output()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	backend := filepath.Join(strings.TrimSpace(string(execPath)), "git-http-backend")
+	if _, err := os.Stat(backend); err != nil {
+		t.Skipf("git-http-backend is required: %v", err)
+	}
+
+	g := &testutil.TestSetupManager{
+		T: t,
+		ReposChanges: map[string][]testutil.Content{
+			testutil.Upstream: {
+				{
+					Data:   testutil.Dataset1,
+					Branch: "master",
+				},
+			},
+		},
+	}
+	defer g.Clean()
+	if !g.Init() {
+		return
+	}
+
+	// serve the upstream repository over HTTP and count the requests
+	repoDir := g.Repos[testutil.Upstream].RepoDirectory
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		(&cgi.Handler{
+			Path: backend,
+			Env: []string{
+				"GIT_PROJECT_ROOT=" + filepath.Dir(repoDir),
+				"GIT_HTTP_EXPORT_ALL=1",
+			},
+		}).ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	pkgPath := t.TempDir()
+	kf := kptfileutil.DefaultKptfile("pkg")
+	kf.Upstream = &kptfilev1.Upstream{
+		Type: kptfilev1.GitOrigin,
+		Git: &kptfilev1.Git{
+			Repo:      server.URL + "/" + filepath.Base(repoDir),
+			Directory: "/",
+			Ref:       "master",
+		},
+	}
+	if !assert.NoError(t, kptfileutil.WriteFile(pkgPath, kf)) {
+		t.FailNow()
+	}
+	t.Setenv(gitutil.RepoCacheDirEnv, t.TempDir())
+
+	run := func(diffType Type) *Command {
+		cmd := &Command{
+			Path:      pkgPath,
+			DiffType:  diffType,
+			DiffTool:  "diff",
+			Output:    &bytes.Buffer{},
+			PkgGetter: emptyPkgGetter{},
+		}
+		if !assert.NoError(t, cmd.Run(fake.CtxWithDefaultPrinter())) {
+			t.FailNow()
+		}
+		return cmd
+	}
+
+	run(TypeLocal)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests), "the local diff type must not access the upstream repository")
+
+	cmd := run(TypeCombined)
+	assert.Equal(t, "master", cmd.Ref)
+	if !assert.NotZero(t, atomic.LoadInt32(&requests)) {
+		t.FailNow()
+	}
+
+	lookupRequests := atomic.LoadInt32(&requests)
+	cmd = run(TypeRemote)
+	assert.Equal(t, "master", cmd.Ref)
+	assert.Equal(t, lookupRequests, atomic.LoadInt32(&requests), "the default branch must only be looked up once")
+}
+
+// Validate that fetching the upstream packages is reported with --debug only
+// if the progress isn't written to a terminal
+func TestCommand_Progress(t *testing.T) {
+	testCases := map[string]struct {
+		debug    bool
+		expected []string
+	}{
+		"not a terminal": {},
+		"debug": {
+			debug: true,
+			expected: []string{
+				`Fetching <UPSTREAM>@v2\.\.\.`,
+				`Fetching <UPSTREAM>@v2: done \(\d+ms\), commit [0-9a-f]{40}`,
+			},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T: t,
+				ReposChanges: map[string][]testutil.Content{
+					testutil.Upstream: {
+						{
+							Data:   testutil.Dataset1,
+							Branch: "master",
+							Tag:    "v2",
+						},
+					},
+				},
+				GetRef: "v2",
+			}
+			defer g.Clean()
+			if !g.Init() {
+				return
+			}
+
+			progress := &bytes.Buffer{}
+			err := (&Command{
+				Path:     g.LocalWorkspace.FullPackagePath(),
+				DiffType: TypeLocal,
+				DiffTool: "diff",
+				NoCache:  true,
+				Debug:    tc.debug,
+				Output:   &bytes.Buffer{},
+				Progress: progress,
+			}).Run(fake.CtxWithDefaultPrinter())
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			out := strings.ReplaceAll(progress.String(), g.Repos[testutil.Upstream].RepoDirectory, "<UPSTREAM>")
+			if len(tc.expected) == 0 {
+				assert.Empty(t, out)
+				return
+			}
+			// the diagnostics logged with --debug are written to the progress too
+			var lines []string
+			for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+				if strings.HasPrefix(line, "Fetching ") {
+					lines = append(lines, line)
+				}
+			}
+			if !assert.Len(t, lines, len(tc.expected)) {
+				t.FailNow()
+			}
+			for i := range lines {
+				assert.Regexp(t, "^"+tc.expected[i]+"$", lines[i])
+			}
+		})
+	}
+}
+
+// Validate that the diagnostics are logged to the Logger, and not to the
+// output of the diff
+func TestCommand_Logger(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T: t,
+		ReposChanges: map[string][]testutil.Content{
+			testutil.Upstream: {
+				{
+					Data:   testutil.Dataset1,
+					Branch: "master",
+					Tag:    "v2",
+				},
+			},
+		},
+		GetRef: "v2",
+	}
+	defer g.Clean()
+	if !g.Init() {
+		return
+	}
+
+	var logs []map[string]interface{}
+	logger := funcr.NewJSON(func(obj string) {
+		var entry map[string]interface{}
+		if assert.NoError(t, json.Unmarshal([]byte(obj), &entry)) {
+			logs = append(logs, entry)
+		}
+	}, funcr.Options{Verbosity: 1})
+	output := &bytes.Buffer{}
+	err := (&Command{
+		Path:         g.LocalWorkspace.FullPackagePath(),
+		DiffType:     TypeLocal,
+		DiffTool:     "diff",
+		DiffToolOpts: "-r",
+		NoCache:      true,
+		Logger:       logger,
+		Output:       output,
+	}).Run(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "No differences between local-v2 and remote-v2.\n", output.String())
+
+	var msgs []string
+	for _, entry := range logs {
+		msgs = append(msgs, entry["msg"].(string))
+	}
+	assert.Equal(t, []string{"staging packages", "staged packages", "running diff tool", "diff tool exited"}, msgs)
+	if !assert.Len(t, logs, 4) {
+		t.FailNow()
+	}
+	assert.Equal(t, "v2", logs[0]["sourceRef"])
+	assert.Contains(t, logs[1], "duration")
+	assert.Equal(t, "diff", logs[2]["tool"])
+	assert.Equal(t, float64(0), logs[3]["exitCode"])
+}
+
+func TestCommand_ValidateRefs(t *testing.T) {
+	testCases := map[string]struct {
+		command Command
+		err     string
+	}{
+		"from without to": {
+			command: Command{FromRef: "v1"},
+			err:     "--to must be specified together with --from",
+		},
+		"from and to without Kptfile or repo": {
+			command: Command{FromRef: "v1", ToRef: "v2"},
+			err:     "--from and --to require --repo if the package at",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			tc.command.Path = t.TempDir()
+			tc.command.DiffType = TypeLocal
+			tc.command.DiffTool = "diff"
+			err := tc.command.Validate()
+			if !assert.Error(t, err) {
+				t.FailNow()
+			}
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestCommand_ValidateRepo(t *testing.T) {
+	repoDir := t.TempDir()
+	testCases := map[string]struct {
+		repo  string
+		valid bool
+	}{
+		"https":                  {repo: "https://github.com/org/repo.git/path/to/pkg", valid: true},
+		"ssh":                    {repo: "ssh://git@github.com/org/repo.git", valid: true},
+		"scp-like":               {repo: "git@github.com:org/repo.git", valid: true},
+		"file":                   {repo: "file://" + repoDir, valid: true},
+		"local directory":        {repo: repoDir, valid: true},
+		"local directory subdir": {repo: repoDir + ".git/path/to/pkg", valid: true},
+		"unknown scheme":         {repo: "htps://github.com/org/repo.git"},
+		"no host":                {repo: "https:///org/repo.git"},
+		"missing directory":      {repo: filepath.Join(repoDir, "missing")},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			err := (&Command{
+				Path:     t.TempDir(),
+				Repo:     tc.repo,
+				DiffType: TypeLocal,
+				DiffTool: "diff",
+			}).Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "is not a valid git repository")
+			}
+		})
+	}
+}
+
+// Validate that Repo overrides a stale upstream repository in the Kptfile
+func TestCommand_DiffRepoOverride(t *testing.T) {
+	testCases := map[string]struct {
+		diffType Type
+		ref      string
+		expDiff  bool
+	}{
+		"local diff type doesn't need a target ref": {
+			diffType: TypeLocal,
+			expDiff:  true,
+		},
+		"combined diff type with target ref": {
+			diffType: TypeCombined,
+			ref:      "master",
+		},
+		"remote diff type with target ref": {
+			diffType: TypeRemote,
+			ref:      "master",
+			expDiff:  true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T: t,
+				ReposChanges: map[string][]testutil.Content{
+					testutil.Upstream: {
+						{
+							Data:   testutil.Dataset2,
+							Branch: "master",
+							Tag:    "v2",
+						},
+						{
+							Data: testutil.Dataset3,
+						},
+					},
+				},
+				GetRef:       "v2",
+				LocalChanges: []testutil.Content{{Data: testutil.Dataset3}},
+			}
+			defer g.Clean()
+			if !g.Init() {
+				return
+			}
+			// make the recorded upstream stale
+			pkgPath := g.LocalWorkspace.FullPackagePath()
+			kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, pkgPath)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			kf.Upstream.Git.Repo = filepath.Join(t.TempDir(), "stale")
+			kf.UpstreamLock.Git.Repo = kf.Upstream.Git.Repo
+			if !assert.NoError(t, kptfileutil.WriteFile(pkgPath, kf)) {
+				t.FailNow()
+			}
+
+			diffOutput := &bytes.Buffer{}
+			cmd := &Command{
+				Path:         pkgPath,
+				Repo:         g.Repos[testutil.Upstream].RepoDirectory,
+				Ref:          tc.ref,
+				DiffType:     tc.diffType,
+				DiffTool:     "diff",
+				DiffToolOpts: "-r -i -w",
+				Output:       diffOutput,
+			}
+			if !assert.NoError(t, cmd.Validate()) {
+				t.FailNow()
+			}
+			if !assert.NoError(t, cmd.Run(fake.CtxWithDefaultPrinter())) {
+				t.FailNow()
+			}
+			if tc.expDiff {
+				assert.Contains(t, diffOutput.String(), "containerPort: 8081")
+			} else {
+				assert.Equal(t, "No differences between local-v2 and target-master.\n", diffOutput.String())
+			}
+		})
+	}
+}
+
+// ociPkgGetter stages packages from a fake OCI upstream, which has a
+// ConfigMap with the version of the package.
+type ociPkgGetter struct {
+	emptyPkgGetter
+	mu sync.Mutex
+	// refs are the versions the packages were fetched at
+	refs []string
+}
+
+func (pg *ociPkgGetter) GetPkgFromUpstream(_ context.Context, stagingDir, targetDir string,
+	upstream *kptfilev1.Upstream, ref string) (string, error) {
+	if ref == "" {
+		// the recorded version
+		ref = "v1"
+	}
+	pg.mu.Lock()
+	pg.refs = append(pg.refs, string(upstream.Type)+"@"+ref)
+	pg.mu.Unlock()
+	dir := filepath.Join(stagingDir, targetDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, ioutil.WriteFile(filepath.Join(dir, "cm.yaml"), []byte("version: "+ref+"\n"), 0600)
+}
+
+// Validate that upstreams of other types than git are fetched by an
+// UpstreamPkgGetter
+func TestCommand_UpstreamPkgGetter(t *testing.T) {
+	testCases := map[string]struct {
+		pkgGetter PkgGetter
+		diffType  Type
+		ref       string
+		expRefs   []string
+		expErr    string
+	}{
+		"local diff type": {
+			pkgGetter: &ociPkgGetter{},
+			diffType:  TypeLocal,
+			expRefs:   []string{"oci@v1"},
+		},
+		"remote diff type": {
+			pkgGetter: &ociPkgGetter{},
+			diffType:  TypeRemote,
+			ref:       "v2",
+			expRefs:   []string{"oci@v1", "oci@v2"},
+		},
+		"remote diff type without target version": {
+			pkgGetter: &ociPkgGetter{},
+			diffType:  TypeRemote,
+			expErr:    "a target version must be specified to compare with upstream type 'oci'",
+		},
+		"not an UpstreamPkgGetter": {
+			pkgGetter: emptyPkgGetter{},
+			diffType:  TypeLocal,
+			expErr:    "upstream type 'oci' of package at",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			pkgPath := t.TempDir()
+			kf := kptfileutil.DefaultKptfile("pkg")
+			kf.Upstream = &kptfilev1.Upstream{Type: "oci"}
+			if !assert.NoError(t, kptfileutil.WriteFile(pkgPath, kf)) ||
+				!assert.NoError(t, ioutil.WriteFile(filepath.Join(pkgPath, "cm.yaml"), []byte("version: local\n"), 0600)) {
+				t.FailNow()
+			}
+
+			result, err := (&Command{
+				Path:      pkgPath,
+				Ref:       tc.ref,
+				DiffType:  tc.diffType,
+				PkgGetter: tc.pkgGetter,
+				Output:    &bytes.Buffer{},
+			}).RunWithResult(fake.CtxWithDefaultPrinter())
+			if tc.expErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expErr)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, []string{"cm.yaml"}, result.Changed)
+			pg := tc.pkgGetter.(*ociPkgGetter)
+			sort.Strings(pg.refs)
+			assert.Equal(t, tc.expRefs, pg.refs)
+		})
+	}
+}
+
+// Tests against directories in different states
+func TestCommand_NotAKptDirectory(t *testing.T) {
+	// Initial test setup
+	dir := t.TempDir()
+
+	testCases := map[string]struct {
+		directory string
+	}{
+		"Directory Is Not Kpt Package": {directory: dir},
+		"Directory Does Not Exist":     {directory: "/not/a/directory"},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			diffOutput := &bytes.Buffer{}
+			cmdErr := (&Command{
+				Path:         tc.directory,
+				Ref:          "master",
+				DiffType:     TypeCombined,
+				DiffTool:     "diff",
+				DiffToolOpts: "-r -i -w",
+				Output:       diffOutput,
+			}).Run(fake.CtxWithDefaultPrinter())
+			assert.Error(t, cmdErr)
+
+			assert.Contains(t, cmdErr.Error(), "no such file or directory")
+		})
+	}
+}
+
+// filterDiffMetadata removes information from the diff output that is test-run
+// specific for ex. removing directory name being used.
+func filterDiffMetadata(r io.Reader) string {
+	scanner := bufio.NewScanner(r)
+	b := &bytes.Buffer{}
+
+	for scanner.Scan() {
+		text := scanner.Text()
+		// filter out the diff command that contains directory names
+		if strings.HasPrefix(text, "diff ") {
+			continue
+		}
+		b.WriteString(text)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestStagingDirectoryNames(t *testing.T) {
+	var tests = []struct {
+		source   string
+		branch   string
+		expected string
+	}{
+		{"source", "branch", "source-branch"},
+		{"source", "refs/tags/version", "source-version"},
+		{"source", "refs/heads/branch/", "source-branch"},
+		{"source", "", "source"},
+		{"source", "/", "source"},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.expected, func(t *testing.T) {
+			result := NameStagingDirectory(tt.source, tt.branch)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestStagingDirectoryNames_sameLastSegment(t *testing.T) {
+	// refs which only differ before their last "/" get the same name for
+	// the same source, but different names for different sources
+	refs := []string{"release/v1", "hotfix/v1"}
+	assert.Equal(t, NameStagingDirectory(RemotePackageSource, refs[0]),
+		NameStagingDirectory(RemotePackageSource, refs[1]))
+	assert.NotEqual(t, NameStagingDirectory(RemotePackageSource, refs[0]),
+		NameStagingDirectory(TargetRemotePackageSource, refs[1]))
 }
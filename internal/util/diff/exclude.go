@@ -0,0 +1,159 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	gitignore "github.com/monochromegane/go-gitignore"
+)
+
+// removeExcluded removes all files and directories in dir matching any of
+// the gitignore style patterns. The same library is used by kyaml to
+// support .krmignore files, so patterns behave the same way.
+func removeExcluded(dir string, patterns []string) error {
+	if len(patterns) == 0 {
+		return nil
+	}
+	matcher := gitignore.NewGitIgnoreFromReader(dir,
+		strings.NewReader(strings.Join(patterns, "\n")))
+
+	var excluded []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if matcher.Match(path, info.IsDir()) {
+			excluded = append(excluded, path)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range excluded {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findSubpackages returns the directories in dir, at any depth, which
+// contain a Kptfile. dir itself isn't included.
+func findSubpackages(dir string) ([]string, error) {
+	var subpackages []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir || !info.IsDir() {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(path, kptfilev1.KptFileName)); err == nil {
+			subpackages = append(subpackages, path)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+	return subpackages, err
+}
+
+// removeSubpackages removes the subpackages of the package in dir with all
+// their content.
+func removeSubpackages(dir string) error {
+	subpackages, err := findSubpackages(dir)
+	if err != nil {
+		return err
+	}
+	for _, path := range subpackages {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeSubpackageKptfiles removes the Kptfiles of the subpackages of the
+// package in dir, but keeps their other content.
+func removeSubpackageKptfiles(dir string) error {
+	subpackages, err := findSubpackages(dir)
+	if err != nil {
+		return err
+	}
+	for _, path := range subpackages {
+		if err := os.Remove(filepath.Join(path, kptfilev1.KptFileName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepSubpath removes all files and directories in dir except for the file
+// or directory at the slash separated subpath and its parent directories.
+// If subpath doesn't exist in dir, dir is left empty.
+func keepSubpath(dir, subpath string) error {
+	if subpath == "" {
+		return nil
+	}
+	var removed []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		switch {
+		case rel == subpath:
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		case info.IsDir() && strings.HasPrefix(subpath, rel+"/"):
+			// a parent directory of subpath
+			return nil
+		}
+		removed = append(removed, path)
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range removed {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
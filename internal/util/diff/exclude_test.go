@@ -0,0 +1,168 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveExcluded(t *testing.T) {
+	testCases := map[string]struct {
+		patterns []string
+		expected []string
+	}{
+		"no patterns": {
+			expected: []string{"app.generated.yaml", "deployment.yaml", "sub/app.generated.yaml", "sub/service.yaml", "vendor/lib.yaml"},
+		},
+		"glob and directory patterns": {
+			patterns: []string{"*.generated.yaml", "vendor/"},
+			expected: []string{"deployment.yaml", "sub/service.yaml"},
+		},
+		"negated pattern": {
+			patterns: []string{"*.generated.yaml", "!sub/app.generated.yaml"},
+			expected: []string{"deployment.yaml", "sub/app.generated.yaml", "sub/service.yaml", "vendor/lib.yaml"},
+		},
+		"rooted pattern": {
+			patterns: []string{"/app.generated.yaml"},
+			expected: []string{"deployment.yaml", "sub/app.generated.yaml", "sub/service.yaml", "vendor/lib.yaml"},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{
+				"deployment.yaml":        "a: 1\n",
+				"app.generated.yaml":     "a: 1\n",
+				"sub/service.yaml":       "a: 1\n",
+				"sub/app.generated.yaml": "a: 1\n",
+				"vendor/lib.yaml":        "a: 1\n",
+			})
+
+			if !assert.NoError(t, removeExcluded(dir, tc.patterns)) {
+				t.FailNow()
+			}
+			files, err := listFiles(dir)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			var paths []string
+			for p := range files {
+				paths = append(paths, p)
+			}
+			sort.Strings(paths)
+			assert.Equal(t, tc.expected, paths)
+		})
+	}
+}
+
+func TestKeepSubpath(t *testing.T) {
+	testCases := map[string]struct {
+		subpath  string
+		expected []string
+	}{
+		"no subpath": {
+			expected: []string{"deployment.yaml", "sub/nested/service.yaml", "sub/service.yaml"},
+		},
+		"file": {
+			subpath:  "deployment.yaml",
+			expected: []string{"deployment.yaml"},
+		},
+		"nested directory": {
+			subpath:  "sub/nested",
+			expected: []string{"sub/nested/service.yaml"},
+		},
+		"missing subpath": {
+			subpath: "sub/missing.yaml",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{
+				"deployment.yaml":         "a: 1\n",
+				"sub/service.yaml":        "a: 1\n",
+				"sub/nested/service.yaml": "a: 1\n",
+			})
+
+			if !assert.NoError(t, keepSubpath(dir, tc.subpath)) {
+				t.FailNow()
+			}
+			files, err := listFiles(dir)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			var paths []string
+			for p := range files {
+				paths = append(paths, p)
+			}
+			sort.Strings(paths)
+			assert.Equal(t, tc.expected, paths)
+		})
+	}
+}
+
+func TestPrepareForDiff_subpackages(t *testing.T) {
+	testCases := map[string]struct {
+		differ   defaultPkgDiffer
+		expected []string
+	}{
+		"subpackage Kptfiles removed": {
+			expected: []string{"deployment.yaml", "sub/nested/service.yaml", "sub/service.yaml"},
+		},
+		"Kptfiles included": {
+			differ: defaultPkgDiffer{IncludeKptfile: true},
+			expected: []string{"Kptfile", "deployment.yaml", "sub/Kptfile", "sub/nested/Kptfile",
+				"sub/nested/service.yaml", "sub/service.yaml"},
+		},
+		"subpackages excluded": {
+			differ:   defaultPkgDiffer{ExcludeSubpackages: true},
+			expected: []string{"deployment.yaml"},
+		},
+		"subpackages excluded with Kptfile included": {
+			differ:   defaultPkgDiffer{ExcludeSubpackages: true, IncludeKptfile: true},
+			expected: []string{"Kptfile", "deployment.yaml"},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{
+				"Kptfile":                 "kind: Kptfile\n",
+				"deployment.yaml":         "a: 1\n",
+				"sub/Kptfile":             "kind: Kptfile\n",
+				"sub/service.yaml":        "a: 1\n",
+				"sub/nested/Kptfile":      "kind: Kptfile\n",
+				"sub/nested/service.yaml": "a: 1\n",
+			})
+
+			if !assert.NoError(t, tc.differ.prepareForDiff(dir)) {
+				t.FailNow()
+			}
+			files, err := listFiles(dir)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			var paths []string
+			for p := range files {
+				paths = append(paths, p)
+			}
+			sort.Strings(paths)
+			assert.Equal(t, tc.expected, paths)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ParseFieldPath parses a dotted field path such as
+// `spec.template.spec.containers[*].image` into its segments. List elements
+// are addressed with `[*]` for all elements or `[N]` for the element at index N.
+func ParseFieldPath(path string) ([]string, error) {
+	var segments []string
+	for _, part := range strings.Split(path, ".") {
+		field := part
+		var indexes []string
+		if i := strings.Index(part, "["); i >= 0 {
+			field = part[:i]
+			rest := part[i:]
+			for rest != "" {
+				end := strings.Index(rest, "]")
+				if !strings.HasPrefix(rest, "[") || end < 0 {
+					return nil, errors.Errorf("invalid field path %q: malformed index in %q", path, part)
+				}
+				idx := rest[1:end]
+				if idx != "*" {
+					if _, err := strconv.Atoi(idx); err != nil {
+						return nil, errors.Errorf("invalid field path %q: index %q must be '*' or a number", path, idx)
+					}
+				}
+				indexes = append(indexes, rest[:end+1])
+				rest = rest[end+1:]
+			}
+		}
+		if field == "" && (len(segments) == 0 || len(indexes) == 0) {
+			return nil, errors.Errorf("invalid field path %q: empty field name", path)
+		}
+		if field != "" {
+			segments = append(segments, field)
+		}
+		segments = append(segments, indexes...)
+	}
+	return segments, nil
+}
+
+// clearFields removes the fields at the given paths from all YAML files and
+// Kptfiles in dir. Files which can't be parsed as YAML are left untouched.
+func clearFields(dir string, paths []string) error {
+	var fieldPaths [][]string
+	for _, p := range paths {
+		segments, err := ParseFieldPath(p)
+		if err != nil {
+			return err
+		}
+		fieldPaths = append(fieldPaths, segments)
+	}
+	if len(fieldPaths) == 0 {
+		return nil
+	}
+
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		ext := filepath.Ext(path)
+		if ext != ".yaml" && ext != ".yml" && info.Name() != kptfilev1.KptFileName {
+			return nil
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		nodes, err := (&kio.ByteReader{
+			Reader:            bytes.NewReader(b),
+			PreserveSeqIndent: true,
+		}).Read()
+		if err != nil {
+			// not a valid YAML file, leave it as is
+			return nil
+		}
+		for _, node := range nodes {
+			for _, segments := range fieldPaths {
+				clearFieldPath(node, segments)
+			}
+		}
+		var out bytes.Buffer
+		if err := (kio.ByteWriter{Writer: &out}).Write(nodes); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, out.Bytes(), info.Mode())
+	})
+}
+
+// clearFieldPath removes the field identified by segments from node. Missing
+// fields are ignored.
+func clearFieldPath(node *yaml.RNode, segments []string) {
+	seg := segments[0]
+	last := len(segments) == 1
+	yn := node.YNode()
+
+	if strings.HasPrefix(seg, "[") {
+		if yn.Kind != yaml.SequenceNode {
+			return
+		}
+		idx := seg[1 : len(seg)-1]
+		var kept []*yaml.Node
+		for i, elem := range yn.Content {
+			if idx != "*" && idx != strconv.Itoa(i) {
+				kept = append(kept, elem)
+				continue
+			}
+			if last {
+				continue
+			}
+			clearFieldPath(yaml.NewRNode(elem), segments[1:])
+			kept = append(kept, elem)
+		}
+		yn.Content = kept
+		return
+	}
+
+	if yn.Kind != yaml.MappingNode {
+		return
+	}
+	if last {
+		_, _ = node.Pipe(yaml.Clear(seg))
+		return
+	}
+	child := node.Field(seg)
+	if child == nil {
+		return
+	}
+	clearFieldPath(child.Value, segments[1:])
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFieldPath(t *testing.T) {
+	testCases := map[string]struct {
+		path     string
+		expected []string
+		err      string
+	}{
+		"simple path": {
+			path:     "metadata.creationTimestamp",
+			expected: []string{"metadata", "creationTimestamp"},
+		},
+		"list wildcard": {
+			path:     "spec.containers[*].image",
+			expected: []string{"spec", "containers", "[*]", "image"},
+		},
+		"nested list index": {
+			path:     "spec.matrix[0][*]",
+			expected: []string{"spec", "matrix", "[0]", "[*]"},
+		},
+		"empty segment": {
+			path: "spec..image",
+			err:  `invalid field path "spec..image": empty field name`,
+		},
+		"invalid index": {
+			path: "spec.containers[foo].image",
+			err:  `invalid field path "spec.containers[foo].image": index "foo" must be '*' or a number`,
+		},
+		"unterminated index": {
+			path: "spec.containers[0",
+			err:  `invalid field path "spec.containers[0": malformed index in "containers[0"`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			segments, err := ParseFieldPath(tc.path)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, segments)
+		})
+	}
+}
+
+func TestClearFields(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"deployment.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  creationTimestamp: "2022-01-01T00:00:00Z"
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.21
+      - name: sidecar
+        image: sidecar:v1
+status:
+  replicas: 1
+`,
+		"README.md": "status: 1\n",
+	})
+
+	err := clearFields(dir, []string{
+		"metadata.creationTimestamp",
+		"status",
+		"spec.template.spec.containers[*].image",
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "deployment.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, strings.TrimSpace(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+      - name: sidecar
+`), strings.TrimSpace(string(b)))
+
+	b, err = ioutil.ReadFile(filepath.Join(dir, "README.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, "status: 1\n", string(b))
+}
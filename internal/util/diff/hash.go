@@ -0,0 +1,112 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// hashPkg returns a content hash of the package in dir. It covers the slash
+// separated relative path, the type and the content of every file, directory
+// and symlink, so two packages have the same hash if and only if the diff of
+// them is empty. The entries are hashed in sorted order of their paths. The
+// top level paths in excludePaths are skipped.
+func hashPkg(dir string, excludePaths []string) (string, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		for _, excluded := range excludePaths {
+			if rel == excluded {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, rel := range paths {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		info, err := os.Lstat(path)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case info.IsDir():
+			fmt.Fprintf(h, "d %s\x00", rel)
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "l %s\x00%s\x00", rel, target)
+		default:
+			fmt.Fprintf(h, "f %s\x00%d\x00", rel, info.Size())
+			if err := hashFile(h, path); err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// identicalPkgs returns true if all the packages have the same content hash.
+func identicalPkgs(excludePaths []string, pkgs ...string) (bool, error) {
+	var first string
+	for i, pkg := range pkgs {
+		hash, err := hashPkg(pkg, excludePaths)
+		if err != nil {
+			return false, err
+		}
+		if i == 0 {
+			first = hash
+		} else if hash != first {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashPkg(t *testing.T) {
+	files := map[string]string{
+		"Kptfile":            "kind: Kptfile\n",
+		"deployment.yaml":    "kind: Deployment\n",
+		"sub/service.yaml":   "kind: Service\n",
+		".git/HEAD":          "ref: refs/heads/main\n",
+		"sub/config/cm.yaml": "kind: ConfigMap\n",
+	}
+	testCases := map[string]struct {
+		modify    func(dir string) error
+		identical bool
+	}{
+		"same content": {
+			modify:    func(string) error { return nil },
+			identical: true,
+		},
+		"excluded paths differ": {
+			modify: func(dir string) error {
+				if err := ioutil.WriteFile(filepath.Join(dir, "Kptfile"), []byte("kind: Other\n"), 0600); err != nil {
+					return err
+				}
+				return os.RemoveAll(filepath.Join(dir, ".git"))
+			},
+			identical: true,
+		},
+		"content differs": {
+			modify: func(dir string) error {
+				return ioutil.WriteFile(filepath.Join(dir, "sub", "service.yaml"), []byte("kind: Other\n"), 0600)
+			},
+		},
+		"file moved": {
+			modify: func(dir string) error {
+				return os.Rename(filepath.Join(dir, "deployment.yaml"), filepath.Join(dir, "sub", "deployment.yaml"))
+			},
+		},
+		"empty directory added": {
+			modify: func(dir string) error {
+				return os.Mkdir(filepath.Join(dir, "empty"), 0700)
+			},
+		},
+		"content moved between files": {
+			modify: func(dir string) error {
+				if err := ioutil.WriteFile(filepath.Join(dir, "deployment.yaml"), []byte("kind: Deployment\nkind: Service\n"), 0600); err != nil {
+					return err
+				}
+				return ioutil.WriteFile(filepath.Join(dir, "sub", "service.yaml"), []byte(""), 0600)
+			},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			var pkgs []string
+			for i := 0; i < 2; i++ {
+				dir := t.TempDir()
+				for path, content := range files {
+					path = filepath.Join(dir, filepath.FromSlash(path))
+					if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700)) ||
+						!assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600)) {
+						t.FailNow()
+					}
+				}
+				pkgs = append(pkgs, dir)
+			}
+			if !assert.NoError(t, tc.modify(pkgs[1])) {
+				t.FailNow()
+			}
+
+			identical, err := identicalPkgs([]string{".git", "Kptfile"}, pkgs...)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.identical, identical)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// hunkContextLines is the number of unchanged lines included around each
+// change in a hunk, matching the default of unified diffs.
+const hunkContextLines = 3
+
+// FileStatus describes how a file differs between two packages.
+type FileStatus string
+
+const (
+	// FileAdded means the file only exists in the second package.
+	FileAdded FileStatus = "added"
+	// FileRemoved means the file only exists in the first package.
+	FileRemoved FileStatus = "removed"
+	// FileModified means the file exists in both packages with different content.
+	FileModified FileStatus = "modified"
+)
+
+// Hunk is a contiguous block of changes within a file. Every entry in Lines
+// is prefixed with ' ' for context, '-' for removed and '+' for added lines.
+type Hunk struct {
+	FromLine  int      `json:"fromLine"`
+	FromCount int      `json:"fromCount"`
+	ToLine    int      `json:"toLine"`
+	ToCount   int      `json:"toCount"`
+	Lines     []string `json:"lines"`
+}
+
+// FileDiff contains the differences of a single file between two packages.
+type FileDiff struct {
+	// Path is the slash separated path of the file relative to the package root.
+	Path   string     `json:"path"`
+	Status FileStatus `json:"status"`
+	Hunks  []Hunk     `json:"hunks,omitempty"`
+}
+
+// jsonDiffOutput is the document written by the json formatter.
+type jsonDiffOutput struct {
+	From  string     `json:"from"`
+	To    string     `json:"to"`
+	Files []FileDiff `json:"files"`
+}
+
+// jsonPkgDiffer compares packages in-process and writes the result as
+// JSON instead of invoking an external diff tool.
+type jsonPkgDiffer struct {
+	defaultPkgDiffer
+}
+
+func (d *jsonPkgDiffer) Diff(pkgs ...string) error {
+	if len(pkgs) != 2 {
+		return errors.Errorf("%s format only supports comparing 2 packages, got %d",
+			FormatJSON, len(pkgs))
+	}
+	result, err := d.result(pkgs...)
+	if _, ok := err.(*DifferencesFoundError); err != nil && !ok {
+		return err
+	}
+	files := result.Files
+	if files == nil {
+		files = []FileDiff{}
+	}
+	enc := json.NewEncoder(d.Output)
+	enc.SetIndent("", "  ")
+	if encErr := enc.Encode(jsonDiffOutput{
+		From:  result.From,
+		To:    result.To,
+		Files: files,
+	}); encErr != nil {
+		return encErr
+	}
+	return err
+}
+
+// DiffContext compares the packages like Diff. The packages are compared
+// in-process, so ctx is only checked before comparing them.
+func (d *jsonPkgDiffer) DiffContext(ctx context.Context, pkgs ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.Diff(pkgs...)
+}
+
+// compareDirs returns the differences between all regular files in the
+// from and to directories, sorted by path.
+func compareDirs(from, to string) ([]FileDiff, error) {
+	fromFiles, err := listFiles(from)
+	if err != nil {
+		return nil, err
+	}
+	toFiles, err := listFiles(to)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := map[string]bool{}
+	for p := range fromFiles {
+		paths[p] = true
+	}
+	for p := range toFiles {
+		paths[p] = true
+	}
+	var sorted []string
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	var diffs []FileDiff
+	for _, p := range sorted {
+		fd := FileDiff{Path: p}
+		switch {
+		case !fromFiles[p]:
+			fd.Status = FileAdded
+		case !toFiles[p]:
+			fd.Status = FileRemoved
+		default:
+			fd.Status = FileModified
+		}
+		fromLines, err := readLines(from, p, fromFiles[p])
+		if err != nil {
+			return nil, err
+		}
+		toLines, err := readLines(to, p, toFiles[p])
+		if err != nil {
+			return nil, err
+		}
+		if fd.Status == FileModified && equalLines(fromLines, toLines) {
+			continue
+		}
+		fd.Hunks = computeHunks(fromLines, toLines)
+		diffs = append(diffs, fd)
+	}
+	return diffs, nil
+}
+
+// listFiles returns the slash separated relative paths of all regular files
+// under dir.
+func listFiles(dir string) (map[string]bool, error) {
+	files := map[string]bool{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	return files, err
+}
+
+// diffStat is a summary of the files which differ between two packages.
+type diffStat struct {
+	Changed int
+	Added   int
+	Removed int
+}
+
+// computeStat counts the files which were modified, added and removed in
+// the to package relative to the from package.
+func computeStat(from, to string) (diffStat, error) {
+	var stat diffStat
+	files, err := compareDirs(from, to)
+	if err != nil {
+		return stat, err
+	}
+	for _, f := range files {
+		switch f.Status {
+		case FileModified:
+			stat.Changed++
+		case FileAdded:
+			stat.Added++
+		case FileRemoved:
+			stat.Removed++
+		}
+	}
+	return stat, nil
+}
+
+// String returns the summary, e.g. `3 files changed, 1 added, 2 removed`.
+func (s diffStat) String() string {
+	files := "files"
+	if s.Changed == 1 {
+		files = "file"
+	}
+	return fmt.Sprintf("%d %s changed, %d added, %d removed",
+		s.Changed, files, s.Added, s.Removed)
+}
+
+// readLines returns the lines of the file at path relative to dir. If the
+// file doesn't exist in dir, it returns no lines.
+func readLines(dir, path string, exists bool) ([]string, error) {
+	if !exists {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(string(b)), nil
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// UnifiedDiff returns the differences between from and to in the unified
+// diff format, with fromName and toName as the names of the compared files.
+// It returns an empty string if from and to are equal.
+func UnifiedDiff(fromName, toName, from, to string) string {
+	hunks := computeHunks(splitLines(from), splitLines(to))
+	if len(hunks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks {
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", h.FromLine, h.FromCount, h.ToLine, h.ToCount)
+		for _, l := range h.Lines {
+			sb.WriteString(l + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// splitLines splits s into lines without the trailing newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// computeHunks groups the line differences between from and to into hunks.
+func computeHunks(from, to []string) []Hunk {
+	if len(from) == 0 && len(to) == 0 {
+		return nil
+	}
+	m := difflib.NewMatcher(from, to)
+	var hunks []Hunk
+	for _, group := range m.GetGroupedOpCodes(hunkContextLines) {
+		first, last := group[0], group[len(group)-1]
+		h := Hunk{
+			FromLine:  first.I1 + 1,
+			FromCount: last.I2 - first.I1,
+			ToLine:    first.J1 + 1,
+			ToCount:   last.J2 - first.J1,
+		}
+		for _, op := range group {
+			if op.Tag == 'e' {
+				for _, l := range from[op.I1:op.I2] {
+					h.Lines = append(h.Lines, " "+l)
+				}
+				continue
+			}
+			if op.Tag == 'r' || op.Tag == 'd' {
+				for _, l := range from[op.I1:op.I2] {
+					h.Lines = append(h.Lines, "-"+l)
+				}
+			}
+			if op.Tag == 'r' || op.Tag == 'i' {
+				for _, l := range to[op.J1:op.J2] {
+					h.Lines = append(h.Lines, "+"+l)
+				}
+			}
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
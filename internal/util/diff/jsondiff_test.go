@@ -0,0 +1,174 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareDirs(t *testing.T) {
+	testCases := map[string]struct {
+		from     map[string]string
+		to       map[string]string
+		expected []FileDiff
+	}{
+		"identical directories": {
+			from: map[string]string{"a.yaml": "a: 1\n"},
+			to:   map[string]string{"a.yaml": "a: 1\n"},
+		},
+		"added, removed and modified files": {
+			from: map[string]string{
+				"removed.yaml":     "a: 1\n",
+				"sub/changed.yaml": "a: 1\nb: 2\nc: 3\n",
+			},
+			to: map[string]string{
+				"added.yaml":       "a: 1\n",
+				"sub/changed.yaml": "a: 1\nb: 3\nc: 3\n",
+			},
+			expected: []FileDiff{
+				{
+					Path:   "added.yaml",
+					Status: FileAdded,
+					Hunks: []Hunk{
+						{FromLine: 1, FromCount: 0, ToLine: 1, ToCount: 1, Lines: []string{"+a: 1"}},
+					},
+				},
+				{
+					Path:   "removed.yaml",
+					Status: FileRemoved,
+					Hunks: []Hunk{
+						{FromLine: 1, FromCount: 1, ToLine: 1, ToCount: 0, Lines: []string{"-a: 1"}},
+					},
+				},
+				{
+					Path:   "sub/changed.yaml",
+					Status: FileModified,
+					Hunks: []Hunk{
+						{FromLine: 1, FromCount: 3, ToLine: 1, ToCount: 3, Lines: []string{" a: 1", "-b: 2", "+b: 3", " c: 3"}},
+					},
+				},
+			},
+		},
+		"added empty file": {
+			from: map[string]string{},
+			to:   map[string]string{"empty.yaml": ""},
+			expected: []FileDiff{
+				{Path: "empty.yaml", Status: FileAdded},
+			},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			from := writeFiles(t, tc.from)
+			to := writeFiles(t, tc.to)
+
+			diffs, err := compareDirs(from, to)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expected, diffs)
+		})
+	}
+}
+
+func TestComputeStat(t *testing.T) {
+	from := writeFiles(t, map[string]string{
+		"unchanged.yaml": "a: 1\n",
+		"modified.yaml":  "a: 1\n",
+		"sub/b.yaml":     "b: 1\n",
+		"removed.yaml":   "c: 1\n",
+		"sub/removed.md": "removed\n",
+	})
+	to := writeFiles(t, map[string]string{
+		"unchanged.yaml": "a: 1\n",
+		"modified.yaml":  "a: 2\n",
+		"sub/b.yaml":     "b: 2\n",
+		"added.yaml":     "d: 1\n",
+	})
+
+	stat, err := computeStat(from, to)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, diffStat{Changed: 2, Added: 1, Removed: 2}, stat)
+	assert.Equal(t, "2 files changed, 1 added, 2 removed", stat.String())
+	assert.Equal(t, "1 file changed, 0 added, 0 removed", diffStat{Changed: 1}.String())
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	assert.Equal(t, "", UnifiedDiff("a", "b", "a: 1\nb: 2\n", "a: 1\nb: 2\n"))
+	assert.Equal(t, `--- a
++++ b
+@@ -1,3 +1,3 @@
+ a: 1
+-b: 2
++b: 3
+ c: 4
+`, UnifiedDiff("a", "b", "a: 1\nb: 2\nc: 4\n", "a: 1\nb: 3\nc: 4\n"))
+	assert.Equal(t, `--- a
++++ b
+@@ -1,0 +1,1 @@
++a: 1
+`, UnifiedDiff("a", "b", "", "a: 1\n"))
+}
+
+func TestDefaultPkgDiffer_Stat(t *testing.T) {
+	if _, err := exec.LookPath("diff"); err != nil {
+		t.Skip("diff is not available")
+	}
+	from := writeFiles(t, map[string]string{
+		"modified.yaml": "a: 1\n",
+		"removed.yaml":  "b: 1\n",
+	})
+	to := writeFiles(t, map[string]string{
+		"modified.yaml": "a: 2\n",
+		"added.yaml":    "c: 1\n",
+	})
+
+	out := &bytes.Buffer{}
+	d := &defaultPkgDiffer{
+		DiffTool:     "diff",
+		DiffToolOpts: "-r",
+		Stat:         true,
+		Output:       out,
+	}
+	if !assert.NoError(t, d.Diff(from, to)) {
+		t.FailNow()
+	}
+	assert.True(t, strings.HasSuffix(out.String(), "\n1 file changed, 1 added, 1 removed\n"), out.String())
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for p, content := range files {
+		p = filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
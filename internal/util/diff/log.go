@@ -0,0 +1,62 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+)
+
+// debugVerbosity is the verbosity the diagnostics of the diff command are
+// logged at, e.g. the staging directories, the resolved refs, the invocation
+// of the diff tool and how long the steps took.
+const debugVerbosity = 1
+
+// newDebugLogger returns the logger used if Command.Logger isn't set. It
+// writes the diagnostics to w as text lines if debug is set, and discards
+// them otherwise.
+func newDebugLogger(w io.Writer, debug bool) logr.Logger {
+	if !debug {
+		return logr.Discard()
+	}
+	if w == nil {
+		w = os.Stderr
+	}
+	// the packages are staged concurrently
+	var mu sync.Mutex
+	return funcr.New(func(prefix, args string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if prefix != "" {
+			fmt.Fprintf(w, "%s: %s\n", prefix, args)
+			return
+		}
+		fmt.Fprintln(w, args)
+	}, funcr.Options{Verbosity: debugVerbosity})
+}
+
+// debugLogger returns the logger for the diagnostics at debugVerbosity. The
+// zero Logger can't be used, so the diagnostics are discarded if l isn't set.
+func debugLogger(l logr.Logger) logr.Logger {
+	if l.GetSink() == nil {
+		return logr.Discard()
+	}
+	return l.V(debugVerbosity)
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+)
+
+// Conflict markers written to files which were changed differently in the
+// local and the target package.
+const (
+	conflictStartMarker = "<<<<<<< " + LocalPackageSource + "\n"
+	conflictSepMarker   = "=======\n"
+	conflictEndMarker   = ">>>>>>> " + TargetRemotePackageSource + "\n"
+)
+
+// mergePkgs writes the result of merging the changes between the remote and
+// target packages into the local package to merged, like the resource-merge
+// update strategy. Files which aren't resources are taken from target if they
+// weren't changed locally. Files with conflicting changes contain both the
+// local and the target version between conflict markers.
+func mergePkgs(local, remote, target, merged string) error {
+	if err := copyutil.CopyDir(local, merged); err != nil {
+		return err
+	}
+	err := merge.Merge3{
+		OriginalPath:       remote,
+		UpdatedPath:        target,
+		DestPath:           merged,
+		MergeOnPath:        true,
+		IncludeSubPackages: false,
+	}.Merge()
+	if err != nil {
+		return err
+	}
+
+	files, err := compareThreeWay(local, remote, target)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := mergeFile(f, local, target, merged); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeFile resolves the changes of a single file which the resource merge
+// doesn't handle: conflicts and files which aren't resources.
+func mergeFile(f fileChanges, local, target, merged string) error {
+	dst := filepath.Join(merged, filepath.FromSlash(f.Path))
+	for _, c := range f.Fields {
+		if c.Conflict {
+			return writeConflict(dst,
+				filepath.Join(local, filepath.FromSlash(f.Path)),
+				filepath.Join(target, filepath.FromSlash(f.Path)))
+		}
+	}
+	for _, c := range f.Fields {
+		if c.Field != contentField || c.Local != c.Remote {
+			continue
+		}
+		// the file isn't a resource and was only changed in target
+		if c.Target == absentValue {
+			return os.RemoveAll(dst)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(target, filepath.FromSlash(f.Path)))
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(dst, b, 0600)
+	}
+	return nil
+}
+
+// writeConflict writes the local and the target version of a file between
+// conflict markers to dst. A version which doesn't exist is left empty.
+func writeConflict(dst, local, target string) error {
+	var buf bytes.Buffer
+	buf.WriteString(conflictStartMarker)
+	for i, path := range []string{local, target} {
+		if i > 0 {
+			buf.WriteString(conflictSepMarker)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		buf.Write(b)
+		if len(b) > 0 && b[len(b)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	buf.WriteString(conflictEndMarker)
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, buf.Bytes(), 0600)
+}
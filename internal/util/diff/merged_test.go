@@ -0,0 +1,70 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func configMap(name, data string) string {
+	return "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\ndata:\n" + data
+}
+
+func TestMergePkgs(t *testing.T) {
+	remote := writeFiles(t, map[string]string{
+		"cm.yaml":       configMap("cm", "  a: '1'\n  b: '1'\n"),
+		"conflict.yaml": configMap("conflict", "  a: '1'\n"),
+		"README.md":     "remote\n",
+		"notes.txt":     "remote\n",
+	})
+	local := writeFiles(t, map[string]string{
+		"cm.yaml":       configMap("cm", "  a: local\n  b: '1'\n"),
+		"conflict.yaml": configMap("conflict", "  a: local\n"),
+		"README.md":     "remote\n",
+		"notes.txt":     "local\n",
+	})
+	target := writeFiles(t, map[string]string{
+		"cm.yaml":       configMap("cm", "  a: '1'\n  b: target\n"),
+		"conflict.yaml": configMap("conflict", "  a: target\n"),
+		"README.md":     "target\n",
+		"notes.txt":     "remote\n",
+		"new.yaml":      configMap("new", "  a: target\n"),
+	})
+	merged := filepath.Join(t.TempDir(), "merged")
+
+	if !assert.NoError(t, mergePkgs(local, remote, target, merged)) {
+		t.FailNow()
+	}
+	read := func(path string) string {
+		b, err := ioutil.ReadFile(filepath.Join(merged, path))
+		assert.NoError(t, err)
+		return string(b)
+	}
+	// non-conflicting changes of resources are merged
+	assert.Contains(t, read("cm.yaml"), "a: local")
+	assert.Contains(t, read("cm.yaml"), "b: 'target'")
+	assert.Contains(t, read("new.yaml"), "name: new")
+	// files which aren't resources are updated unless changed locally
+	assert.Equal(t, "target\n", read("README.md"))
+	assert.Equal(t, "local\n", read("notes.txt"))
+	// conflicting changes are written with markers
+	assert.Equal(t, "<<<<<<< local\n"+configMap("conflict", "  a: local\n")+
+		"=======\n"+configMap("conflict", "  a: target\n")+">>>>>>> target\n",
+		read("conflict.yaml"))
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// progressReporter reports the progress of fetching the upstream packages,
+// which may take a while for large repositories. The packages are fetched
+// concurrently, so every report is a complete line. A nil progressReporter
+// doesn't report anything.
+type progressReporter struct {
+	mu sync.Mutex
+	w  io.Writer
+	// debug includes the commit the packages were fetched at.
+	debug bool
+}
+
+// newProgressReporter returns a progressReporter writing to w, or nil if w
+// isn't a terminal and debug isn't set.
+func newProgressReporter(w io.Writer, debug bool) *progressReporter {
+	if w == nil || (!debug && !isTerminal(w)) {
+		return nil
+	}
+	return &progressReporter{w: w, debug: debug}
+}
+
+// fetching reports that the package at ref of repo is being fetched.
+func (r *progressReporter) fetching(repo, ref string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.w, "Fetching %s@%s...\n", repo, ref)
+}
+
+// fetched reports that the package at ref of repo has been fetched at commit
+// after d.
+func (r *progressReporter) fetched(repo, ref, commit string, d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.debug && commit != "" {
+		fmt.Fprintf(r.w, "Fetching %s@%s: done (%dms), commit %s\n", repo, ref, d.Milliseconds(), commit)
+		return
+	}
+	fmt.Fprintf(r.w, "Fetching %s@%s: done (%dms)\n", repo, ref, d.Milliseconds())
+}
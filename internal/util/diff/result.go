@@ -0,0 +1,75 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"path/filepath"
+)
+
+// DiffResult contains the files which differ between two compared packages.
+type DiffResult struct {
+	// From and To are the names of the staged packages which were compared,
+	// e.g. local-master and target-v2.
+	From string
+	To   string
+
+	// Changed, Added and Removed are the slash separated paths of the files
+	// which were modified, added and removed in To relative to From.
+	Changed []string
+	Added   []string
+	Removed []string
+
+	// Files contains the differences of every file including its hunks,
+	// sorted by path.
+	Files []FileDiff
+}
+
+// Empty returns true if the packages don't differ.
+func (r *DiffResult) Empty() bool {
+	return len(r.Files) == 0
+}
+
+// result prepares the packages and returns the differences between the
+// first and the last package. It returns a DifferencesFoundError together
+// with the result if ExitCode is set and the packages differ.
+func (d *defaultPkgDiffer) result(pkgs ...string) (*DiffResult, error) {
+	if err := d.prepare(pkgs...); err != nil {
+		return nil, err
+	}
+	from, to := pkgs[0], pkgs[len(pkgs)-1]
+	files, err := compareDirs(from, to)
+	if err != nil {
+		return nil, err
+	}
+	result := &DiffResult{
+		From:  filepath.Base(from),
+		To:    filepath.Base(to),
+		Files: files,
+	}
+	for _, f := range files {
+		switch f.Status {
+		case FileModified:
+			result.Changed = append(result.Changed, f.Path)
+		case FileAdded:
+			result.Added = append(result.Added, f.Path)
+		case FileRemoved:
+			result.Removed = append(result.Removed, f.Path)
+		}
+	}
+	if d.ExitCode && !result.Empty() {
+		return result, &DifferencesFoundError{}
+	}
+	return result, nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// stageDirPkgGetter wraps a PkgGetter and keeps the fetched packages in a
+// user provided directory. Like the cache of cachingPkgGetter, the entries
+// are keyed by repo, directory and commit, so a package is only reused if
+// the ref still resolves to the commit it was fetched at. The packages are
+// copied to the staging directory of the diff, as preparing them for the
+// diff modifies them. Several diffs can use the same Dir concurrently.
+type stageDirPkgGetter struct {
+	// PkgGetter fetches the packages which aren't in Dir.
+	PkgGetter PkgGetter
+
+	// Dir is the directory the fetched packages are kept in.
+	Dir string
+
+	// resolveCommit resolves a ref to a commit SHA. Defaults to looking
+	// up the ref in the remote repository.
+	resolveCommit func(ctx context.Context, repo, ref string) (string, bool, error)
+
+	mu sync.Mutex
+	// staged are the packages in Dir used by this diff.
+	staged []string
+}
+
+// GetPkg returns the package from Dir if it has been fetched before at the
+// commit ref resolves to, otherwise it fetches the package into Dir. Refs
+// which can't be resolved, e.g. abbreviated commit SHAs, are always fetched.
+func (pg *stageDirPkgGetter) GetPkg(ctx context.Context, stagingDir, targetDir, repo, path, ref string) (string, error) {
+	resolve := pg.resolveCommit
+	if resolve == nil {
+		resolve = resolveRemoteCommit
+	}
+	var stored string
+	if commit, found, err := resolve(ctx, repo, ref); err == nil && found {
+		entry := filepath.Join(pg.Dir, cacheKey(repo, path, commit))
+		if _, err := os.Stat(entry); err == nil {
+			stored = entry
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	if stored == "" {
+		var err error
+		if stored, err = pg.fetch(ctx, targetDir, repo, path, ref); err != nil {
+			return "", err
+		}
+	}
+	pg.mu.Lock()
+	pg.staged = append(pg.staged, stored)
+	pg.mu.Unlock()
+
+	dir, err := stageDirectory(stagingDir, targetDir)
+	if err != nil {
+		return dir, err
+	}
+	return dir, copyutil.CopyDir(stored, dir)
+}
+
+// fetch fetches the package into Dir and returns its entry, keyed by the
+// commit in its upstream lock. The package is fetched into a temporary
+// directory and moved into place when it is complete, so neither a failed
+// fetch nor a concurrent diff leaves a partially fetched package in Dir. If
+// a concurrent diff stored the package first, its copy is kept.
+func (pg *stageDirPkgGetter) fetch(ctx context.Context, targetDir, repo, path, ref string) (string, error) {
+	tmp, err := ioutil.TempDir(pg.Dir, ".tmp-")
+	if err != nil {
+		return "", errors.Errorf("failed to create temporary dir in stage dir '%s': %v", pg.Dir, err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	dir, err := pg.PkgGetter.GetPkg(ctx, tmp, targetDir, repo, path, ref)
+	if err != nil {
+		return "", err
+	}
+	kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, dir)
+	if err != nil {
+		return "", err
+	}
+	if kf.UpstreamLock == nil || kf.UpstreamLock.Git == nil || kf.UpstreamLock.Git.Commit == "" {
+		return "", errors.Errorf("package fetched from '%s' at '%s' has no upstream commit", repo, ref)
+	}
+	stored := filepath.Join(pg.Dir, cacheKey(repo, path, kf.UpstreamLock.Git.Commit))
+	if err := os.Rename(dir, stored); err != nil {
+		if _, statErr := os.Stat(stored); statErr == nil {
+			return stored, nil
+		}
+		return "", err
+	}
+	return stored, nil
+}
+
+// clean removes the packages in Dir used by this diff. Other content of Dir
+// is left untouched.
+func (pg *stageDirPkgGetter) clean() {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	for _, dir := range pg.staged {
+		_ = os.RemoveAll(dir)
+	}
+	pg.staged = nil
+}
+
+// validateStageDir creates dir if it doesn't exist and verifies that
+// packages can be written to it.
+func validateStageDir(dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return errors.Errorf("failed to create stage dir '%s': %v", dir, err)
+	}
+	f, err := ioutil.TempFile(dir, ".kpt-")
+	if err != nil {
+		return errors.Errorf("stage dir '%s' is not writable: %v", dir, err)
+	}
+	_ = f.Close()
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStageDirPkgGetter(t *testing.T) {
+	fake := &fakePkgGetter{commit: "a"}
+	remoteCommit := "a"
+	pg := &stageDirPkgGetter{
+		PkgGetter: fake,
+		Dir:       t.TempDir(),
+		resolveCommit: func(context.Context, string, string) (string, bool, error) {
+			return remoteCommit, true, nil
+		},
+	}
+	unrelated := filepath.Join(pg.Dir, "unrelated.txt")
+	if !assert.NoError(t, ioutil.WriteFile(unrelated, []byte("keep"), 0600)) {
+		t.FailNow()
+	}
+
+	getPkg := func(target, repo, path string) string {
+		dir, err := pg.GetPkg(context.Background(), t.TempDir(), target, repo, path, "main")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, "commit.txt"))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return string(b)
+	}
+
+	// the first run fetches the package, the second one reuses it
+	assert.Equal(t, "a", getPkg("source-main", "https://repo", "/pkg"))
+	assert.Equal(t, "a", getPkg("target-main", "https://repo", "/pkg"))
+	assert.Equal(t, 1, fake.fetches)
+	assert.DirExists(t, filepath.Join(pg.Dir, cacheKey("https://repo", "/pkg", "a")))
+
+	// the same staging directory name doesn't reuse another package
+	assert.Equal(t, "a", getPkg("source-main", "https://other", "/pkg"))
+	assert.Equal(t, "a", getPkg("source-main", "https://repo", "/other"))
+	assert.Equal(t, 3, fake.fetches)
+
+	// the package is fetched again once the ref moved
+	remoteCommit, fake.commit = "b", "b"
+	assert.Equal(t, "b", getPkg("source-main", "https://repo", "/pkg"))
+	assert.Equal(t, 4, fake.fetches)
+	assert.DirExists(t, filepath.Join(pg.Dir, cacheKey("https://repo", "/pkg", "b")))
+
+	// refs which can't be resolved are always fetched
+	pg.resolveCommit = func(context.Context, string, string) (string, bool, error) {
+		return "", false, nil
+	}
+	assert.Equal(t, "b", getPkg("source-main", "https://repo", "/pkg"))
+	assert.Equal(t, 5, fake.fetches)
+
+	// only the packages fetched by the getter are cleaned up
+	pg.clean()
+	files, err := ioutil.ReadDir(pg.Dir)
+	assert.NoError(t, err)
+	if assert.Len(t, files, 1) {
+		assert.Equal(t, "unrelated.txt", files[0].Name())
+	}
+}
+
+func TestValidateStageDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested", "stage")
+	assert.NoError(t, validateStageDir(dir))
+	assert.DirExists(t, dir)
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	if runtime.GOOS == "windows" || os.Getuid() == 0 {
+		t.Skip("permissions aren't enforced")
+	}
+	readOnly := t.TempDir()
+	if !assert.NoError(t, os.Chmod(readOnly, 0500)) {
+		t.FailNow()
+	}
+	assert.Contains(t, validateStageDir(readOnly).Error(), "is not writable")
+}
+
+func TestStageDirectory_collision(t *testing.T) {
+	// the refs collide on the name of their staging directory, so the
+	// packages are staged into distinct directories
+	dir := t.TempDir()
+	var staged []string
+	for _, ref := range []string{"release/v1", "hotfix/v1", "v1"} {
+		pkg, err := stageDirectory(dir, NameStagingDirectory(RemotePackageSource, ref))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.DirExists(t, pkg)
+		staged = append(staged, filepath.Base(pkg))
+	}
+	assert.Equal(t, []string{
+		RemotePackageSource + "-v1",
+		RemotePackageSource + "-v1-2",
+		RemotePackageSource + "-v1-3",
+	}, staged)
+}
@@ -0,0 +1,334 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiRed    = "\x1b[1;31m"
+
+	// absentValue is shown for fields which don't exist in a package.
+	absentValue = "-"
+	// contentField is the field used for files which aren't valid YAML.
+	contentField = "(content)"
+	// maxColumnWidth is the width after which values are truncated.
+	maxColumnWidth = 40
+)
+
+// fieldChange is a field which differs between the local, remote and target
+// packages of a 3way diff. Values are absentValue if the field doesn't exist.
+type fieldChange struct {
+	Field  string
+	Local  string
+	Remote string
+	Target string
+	// Conflict is true if the field was changed differently in the local
+	// and target packages relative to the remote package.
+	Conflict bool
+}
+
+// fileChanges contains the changed fields of a single file.
+type fileChanges struct {
+	// Path is the slash separated path of the file relative to the package root.
+	Path   string
+	Fields []fieldChange
+}
+
+// threeWayPkgDiffer renders the changes of a 3way diff side by side instead
+// of invoking an external diff tool.
+type threeWayPkgDiffer struct {
+	defaultPkgDiffer
+
+	// Color enables ANSI coloring of the changes.
+	Color bool
+}
+
+func (d *threeWayPkgDiffer) Diff(pkgs ...string) error {
+	if len(pkgs) != 3 {
+		return errors.Errorf("diff-type '%s' compares 3 packages, got %d",
+			Type3Way, len(pkgs))
+	}
+	if err := d.prepare(pkgs...); err != nil {
+		return err
+	}
+	files, err := compareThreeWay(pkgs[0], pkgs[1], pkgs[2])
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		d.render(f)
+	}
+	if d.Stat {
+		stat, err := computeStat(pkgs[0], pkgs[2])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(d.Output, stat.String())
+	}
+	if d.ExitCode && len(files) > 0 {
+		return &DifferencesFoundError{}
+	}
+	return nil
+}
+
+// DiffContext compares the packages like Diff. The packages are compared
+// in-process, so ctx is only checked before comparing them.
+func (d *threeWayPkgDiffer) DiffContext(ctx context.Context, pkgs ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.Diff(pkgs...)
+}
+
+// render writes the changed fields of a file as local/remote/target columns.
+// Conflicting fields are marked with '!' so they stand out without colors.
+func (d *threeWayPkgDiffer) render(f fileChanges) {
+	rows := [][]string{{"FIELD", strings.ToUpper(LocalPackageSource),
+		strings.ToUpper(RemotePackageSource), strings.ToUpper(TargetRemotePackageSource)}}
+	for _, c := range f.Fields {
+		rows = append(rows, []string{c.Field, truncate(c.Local), truncate(c.Remote), truncate(c.Target)})
+	}
+	widths := make([]int, 4)
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	fmt.Fprintf(d.Output, "%s\n", f.Path)
+	for i, row := range rows {
+		var colors []string
+		marker := " "
+		if i > 0 {
+			c := f.Fields[i-1]
+			colors = d.colors(c)
+			if c.Conflict {
+				marker = "!"
+			}
+		}
+		line := marker
+		for j, cell := range row {
+			padded := cell + strings.Repeat(" ", widths[j]-len(cell))
+			if j == len(row)-1 {
+				padded = cell
+			}
+			if colors != nil && colors[j] != "" {
+				padded = colors[j] + padded + ansiReset
+			}
+			line += " " + padded
+		}
+		fmt.Fprintln(d.Output, line)
+	}
+	fmt.Fprintln(d.Output)
+}
+
+// colors returns the color of each column of the row for c.
+func (d *threeWayPkgDiffer) colors(c fieldChange) []string {
+	if !d.Color {
+		return nil
+	}
+	if c.Conflict {
+		return []string{ansiRed, ansiRed, ansiRed, ansiRed}
+	}
+	colors := make([]string, 4)
+	if c.Local != c.Remote {
+		colors[1] = ansiGreen
+	}
+	if c.Target != c.Remote {
+		colors[3] = ansiYellow
+	}
+	return colors
+}
+
+// compareThreeWay returns the fields which differ between the local, remote
+// and target packages, grouped by file and sorted by path.
+func compareThreeWay(local, remote, target string) ([]fileChanges, error) {
+	dirs := []string{local, remote, target}
+	paths := map[string]bool{}
+	var files []map[string]bool
+	for _, dir := range dirs {
+		f, err := listFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		for p := range f {
+			paths[p] = true
+		}
+		files = append(files, f)
+	}
+	var sorted []string
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	var result []fileChanges
+	for _, p := range sorted {
+		var values []map[string]string
+		for i, dir := range dirs {
+			v, err := readFields(dir, p, files[i][p])
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		if changes := compareFields(values[0], values[1], values[2]); len(changes) > 0 {
+			result = append(result, fileChanges{Path: p, Fields: changes})
+		}
+	}
+	return result, nil
+}
+
+// compareFields returns the fields which don't have the same value in all
+// of local, remote and target, sorted by field.
+func compareFields(local, remote, target map[string]string) []fieldChange {
+	fields := map[string]bool{}
+	for _, m := range []map[string]string{local, remote, target} {
+		for f := range m {
+			fields[f] = true
+		}
+	}
+	var sorted []string
+	for f := range fields {
+		sorted = append(sorted, f)
+	}
+	sort.Strings(sorted)
+
+	var changes []fieldChange
+	for _, f := range sorted {
+		l, r, t := fieldValue(local, f), fieldValue(remote, f), fieldValue(target, f)
+		if l == r && r == t {
+			continue
+		}
+		changes = append(changes, fieldChange{
+			Field:    f,
+			Local:    l,
+			Remote:   r,
+			Target:   t,
+			Conflict: l != r && t != r && l != t,
+		})
+	}
+	return changes
+}
+
+func fieldValue(m map[string]string, field string) string {
+	if v, found := m[field]; found {
+		return v
+	}
+	return absentValue
+}
+
+// readFields returns the leaf fields of all resources in the file at path
+// relative to dir, keyed by resource and field path. Files which can't be
+// parsed as YAML are represented by a checksum of their content.
+func readFields(dir, path string, exists bool) (map[string]string, error) {
+	if !exists {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]string{}
+	ext := filepath.Ext(path)
+	var nodes []*yaml.RNode
+	if ext == ".yaml" || ext == ".yml" {
+		nodes, err = (&kio.ByteReader{
+			Reader:                bytes.NewReader(b),
+			OmitReaderAnnotations: true,
+		}).Read()
+	}
+	if err != nil || len(nodes) == 0 {
+		fields[contentField] = fmt.Sprintf("sha256:%x", sha256.Sum256(b))[:15]
+		return fields, nil
+	}
+	for i, node := range nodes {
+		prefix := fmt.Sprintf("[%d]", i)
+		if node.GetKind() != "" && node.GetName() != "" {
+			prefix = node.GetKind() + "/" + node.GetName()
+		}
+		flattenNode(node.YNode(), prefix+" ", "", fields)
+	}
+	return fields, nil
+}
+
+// flattenNode adds all leaf values of node to fields, keyed by prefix
+// followed by the dotted path of the field.
+func flattenNode(node *yaml.Node, prefix, path string, fields map[string]string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			flattenNode(n, prefix, path, fields)
+		}
+	case yaml.AliasNode:
+		flattenNode(node.Alias, prefix, path, fields)
+	case yaml.MappingNode:
+		if len(node.Content) == 0 {
+			fields[prefix+path] = "{}"
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			flattenNode(node.Content[i+1], prefix, key, fields)
+		}
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			fields[prefix+path] = "[]"
+		}
+		for i, n := range node.Content {
+			flattenNode(n, prefix, fmt.Sprintf("%s[%d]", path, i), fields)
+		}
+	default:
+		fields[prefix+path] = node.Value
+	}
+}
+
+// truncate makes v fit on a single line in a column of maxColumnWidth.
+func truncate(v string) string {
+	v = strings.ReplaceAll(v, "\n", "\\n")
+	if len(v) > maxColumnWidth {
+		v = v[:maxColumnWidth-3] + "..."
+	}
+	return v
+}
+
+// isTerminal returns true if w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareThreeWay(t *testing.T) {
+	local := writeFiles(t, map[string]string{
+		"deployment.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.20
+`,
+		"README.md": "hello\n",
+	})
+	remote := writeFiles(t, map[string]string{
+		"deployment.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.20
+`,
+		"README.md": "hello\n",
+	})
+	target := writeFiles(t, map[string]string{
+		"deployment.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.21
+`,
+		"README.md": "hello world\n",
+		"service.yaml": `
+apiVersion: v1
+kind: Service
+metadata:
+  name: nginx
+`,
+	})
+
+	files, err := compareThreeWay(local, remote, target)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, files, 3) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, "README.md", files[0].Path)
+	if assert.Len(t, files[0].Fields, 1) {
+		assert.Equal(t, contentField, files[0].Fields[0].Field)
+		assert.Equal(t, files[0].Fields[0].Local, files[0].Fields[0].Remote)
+		assert.False(t, files[0].Fields[0].Conflict)
+	}
+
+	assert.Equal(t, "deployment.yaml", files[1].Path)
+	assert.Equal(t, []fieldChange{
+		{
+			Field:    "Deployment/nginx spec.replicas",
+			Local:    "3",
+			Remote:   "1",
+			Target:   "5",
+			Conflict: true,
+		},
+		{
+			Field:  "Deployment/nginx spec.template.spec.containers[0].image",
+			Local:  "nginx:1.20",
+			Remote: "nginx:1.20",
+			Target: "nginx:1.21",
+		},
+	}, files[1].Fields)
+
+	assert.Equal(t, "service.yaml", files[2].Path)
+	assert.Equal(t, fieldChange{
+		Field:  "Service/nginx apiVersion",
+		Local:  absentValue,
+		Remote: absentValue,
+		Target: "v1",
+	}, files[2].Fields[0])
+}
+
+func TestThreeWayRender(t *testing.T) {
+	f := fileChanges{
+		Path: "deployment.yaml",
+		Fields: []fieldChange{
+			{Field: "Deployment/nginx spec.replicas", Local: "3", Remote: "1", Target: "5", Conflict: true},
+			{Field: "Deployment/nginx metadata.name", Local: "a", Remote: "nginx", Target: "nginx"},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	(&threeWayPkgDiffer{defaultPkgDiffer: defaultPkgDiffer{Output: out}}).render(f)
+	assert.Equal(t, strings.TrimLeft(`
+deployment.yaml
+  FIELD                          LOCAL REMOTE TARGET
+! Deployment/nginx spec.replicas 3     1      5
+  Deployment/nginx metadata.name a     nginx  nginx
+
+`, "\n"), out.String())
+
+	out.Reset()
+	(&threeWayPkgDiffer{defaultPkgDiffer: defaultPkgDiffer{Output: out}, Color: true}).render(f)
+	assert.Contains(t, out.String(), ansiRed+"3    "+ansiReset)
+	assert.Contains(t, out.String(), ansiGreen+"a    "+ansiReset)
+	assert.NotContains(t, out.String(), ansiYellow)
+}
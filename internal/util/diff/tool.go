@@ -0,0 +1,128 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// threeWayTools are diff tools known to compare 3 directories. They aren't
+// probed, as most of them open a window.
+var threeWayTools = map[string]bool{
+	"bcompare": true,
+	"diffuse":  true,
+	"gvimdiff": true,
+	"kdiff3":   true,
+	"meld":     true,
+	"opendiff": true,
+	"p4merge":  true,
+	"tkdiff":   true,
+	"vimdiff":  true,
+	"xxdiff":   true,
+}
+
+// twoWayTools are diff tools known to compare 2 directories, which are listed
+// by ListTools together with threeWayTools.
+var twoWayTools = []string{"colordiff", "diff"}
+
+// ToolSupport contains the diff types a diff tool found in the PATH supports.
+type ToolSupport struct {
+	// Name is the name of the tool, as given with --diff-tool.
+	Name string
+
+	// Path is the path of the executable of the tool.
+	Path string
+
+	// DiffTypes are the diff types the tool can show the changes of.
+	DiffTypes []Type
+}
+
+// ListTools returns the known diff tools found in the PATH, sorted by name.
+// All of them compare the 2 packages of every diff type but 3way, which is
+// only included for the tools passing the check of checkThreeWayTool.
+func ListTools() []ToolSupport {
+	names := append([]string{}, twoWayTools...)
+	for name := range threeWayTools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tools []ToolSupport
+	for _, name := range names {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+		tool := ToolSupport{Name: name, Path: path}
+		for _, dt := range SupportedDiffTypes {
+			if dt == Type3Way && checkThreeWayTool(path, "") != nil {
+				continue
+			}
+			tool.DiffTypes = append(tool.DiffTypes, dt)
+		}
+		tools = append(tools, tool)
+	}
+	return tools
+}
+
+// toolCheckTimeout is the time the diff tool is given to compare the empty
+// directories of the probe.
+const toolCheckTimeout = 10 * time.Second
+
+// checkThreeWayTool verifies that the diff tool can compare the 3 packages
+// of the 3way diff type. Tools which aren't known to support it are run with
+// 3 empty directories, and must exit with 0 or 1 like the diff command.
+func checkThreeWayTool(tool, toolOpts string) error {
+	name := strings.TrimSuffix(filepath.Base(tool), filepath.Ext(tool))
+	if threeWayTools[name] {
+		return nil
+	}
+
+	dir, err := ioutil.TempDir("", "kpt-tool-check-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	var args []string
+	if toolOpts != "" {
+		args = strings.Split(toolOpts, " ")
+	}
+	for _, name := range []string{"local", "source", "target"} {
+		p := filepath.Join(dir, name)
+		if err := os.Mkdir(p, os.ModePerm); err != nil {
+			return err
+		}
+		args = append(args, p)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), toolCheckTimeout)
+	defer cancel()
+	err = exec.CommandContext(ctx, tool, args...).Run()
+	if exitErr, ok := err.(*exec.ExitError); err == nil || ok && exitErr.ExitCode() == 1 {
+		return nil
+	}
+	return errors.Errorf("diff-tool '%s' doesn't support the diff type '%s' as it can't "+
+		"compare 3 packages: provide a tool supporting it using --diff-tool, e.g. meld, "+
+		"or skip this check with --skip-tool-check", tool, Type3Way)
+}
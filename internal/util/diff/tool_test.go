@@ -0,0 +1,95 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckThreeWayTool(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	writeTool := func(name, script string) string {
+		p := filepath.Join(dir, name)
+		if !assert.NoError(t, ioutil.WriteFile(p, []byte("#!/bin/sh\n"+script), 0700)) {
+			t.FailNow()
+		}
+		return p
+	}
+
+	testCases := map[string]struct {
+		tool     string
+		toolOpts string
+		expErr   bool
+	}{
+		"known tool isn't probed": {
+			tool: writeTool("meld", "exit 2\n"),
+		},
+		"tool comparing 3 directories": {
+			tool: writeTool("three", `[ "$#" -eq 3 ] || exit 2; exit 1`+"\n"),
+		},
+		"tool options are passed": {
+			tool:     writeTool("opts", `[ "$1" = "-r" ] && [ "$#" -eq 4 ] || exit 2`+"\n"),
+			toolOpts: "-r",
+		},
+		"tool comparing 2 directories": {
+			tool:   writeTool("two", `[ "$#" -eq 2 ] || exit 2`+"\n"),
+			expErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			err := checkThreeWayTool(tc.tool, tc.toolOpts)
+			if tc.expErr {
+				assert.Contains(t, err.Error(), "can't compare 3 packages")
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestListTools(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	for name, script := range map[string]string{
+		"diff":      `[ "$#" -eq 2 ] || exit 2`,
+		"colordiff": `[ "$#" -eq 3 ] || exit 2`,
+		"meld":      "exit 2",
+		"unknown":   "exit 0",
+	} {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0700)) {
+			t.FailNow()
+		}
+	}
+	t.Setenv("PATH", dir)
+
+	twoWay := []Type{TypeLocal, TypeRemote, TypeCombined, TypeMerged}
+	assert.Equal(t, []ToolSupport{
+		{Name: "colordiff", Path: filepath.Join(dir, "colordiff"), DiffTypes: SupportedDiffTypes},
+		{Name: "diff", Path: filepath.Join(dir, "diff"), DiffTypes: twoWay},
+		{Name: "meld", Path: filepath.Join(dir, "meld"), DiffTypes: SupportedDiffTypes},
+	}, ListTools())
+}
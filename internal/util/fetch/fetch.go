@@ -132,6 +132,10 @@ func cloneAndCopy(ctx context.Context, r *git.RepoSpec, dest string) error {
 func ClonerUsingGitExec(ctx context.Context, repoSpec *git.RepoSpec) error {
 	const op errors.Op = "fetch.ClonerUsingGitExec"
 
+	// The cached repo is reset to the requested commit below, so packages
+	// from the same repo can't be fetched concurrently.
+	defer gitutil.LockRepoCache(repoSpec.CloneSpec())()
+
 	// Create a local representation of the upstream repo. This will initialize
 	// the cache for the specified repo uri if it isn't already there. It also
 	// fetches and caches all tag and branch refs from the upstream repo.
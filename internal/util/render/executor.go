@@ -66,6 +66,10 @@ type Renderer struct {
 	// AllowExec allow binary executable to be run during pipeline execution
 	AllowExec bool
 
+	// NoDocker rejects container functions before running them, unless a
+	// Runtime is provided.
+	NoDocker bool
+
 	// FileSystem is the input filesystem to operate on
 	FileSystem filesys.FileSystem
 }
@@ -88,6 +92,7 @@ func (e *Renderer) Execute(ctx context.Context) error {
 		fnResults:       fnresult.NewResultList(),
 		imagePullPolicy: e.ImagePullPolicy,
 		allowExec:       e.AllowExec,
+		noDocker:        e.NoDocker,
 		fileSystem:      e.FileSystem,
 		runtime:         e.Runtime,
 	}
@@ -194,6 +199,10 @@ type hydrationContext struct {
 	// imagePullPolicy controls the image pulling behavior.
 	imagePullPolicy fnruntime.ImagePullPolicy
 
+	// noDocker determines if container functions are rejected when using
+	// the standard runner.
+	noDocker bool
+
 	// allowExec determines if function binary executable are allowed
 	// to be run during pipeline execution. Running function binaries is a
 	// privileged operation, so explicit permission is required.
@@ -209,6 +218,26 @@ type hydrationContext struct {
 	runtime fn.FunctionRuntime
 }
 
+// checkContainerFnAllowed returns an error if the container function with the
+// given image can't be run. Docker availability is only checked once.
+func (hctx *hydrationContext) checkContainerFnAllowed(image string) error {
+	if hctx.runtime != nil {
+		// the function runtime decides how to run the function
+		return nil
+	}
+	if hctx.noDocker {
+		return fmt.Errorf("container function %q can't be run with `--no-docker` option", image)
+	}
+	if !hctx.dockerCheckDone {
+		// Check for Docker when using standard runner.
+		if err := cmdutil.DockerCmdAvailable(); err != nil {
+			return err
+		}
+		hctx.dockerCheckDone = true
+	}
+	return nil
+}
+
 //
 // pkgNode represents a package being hydrated. Think of it as a node in the hydration DAG.
 //
@@ -480,15 +509,10 @@ func (pn *pkgNode) runValidators(ctx context.Context, hctx *hydrationContext, in
 		if function.Exec != "" && !hctx.allowExec {
 			return errAllowedExecNotSpecified
 		}
-		if function.Image != "" && !hctx.dockerCheckDone {
-			if hctx.runtime == nil {
-				// Check for Docker when using standard runner.
-				err := cmdutil.DockerCmdAvailable()
-				if err != nil {
-					return err
-				}
+		if function.Image != "" {
+			if err := hctx.checkContainerFnAllowed(function.Image); err != nil {
+				return err
 			}
-			hctx.dockerCheckDone = true
 		}
 		validator, err = fnruntime.NewRunner(ctx, hctx.fileSystem, &function, pn.pkg.UniquePath, hctx.fnResults, hctx.imagePullPolicy, true, displayResourceCount, hctx.runtime)
 		if err != nil {
@@ -597,15 +621,10 @@ func fnChain(ctx context.Context, hctx *hydrationContext, pkgPath types.UniquePa
 		if function.Exec != "" && !hctx.allowExec {
 			return nil, errAllowedExecNotSpecified
 		}
-		if function.Image != "" && !hctx.dockerCheckDone {
-			if hctx.runtime == nil {
-				// Check for Docker when using standard runner.
-				err := cmdutil.DockerCmdAvailable()
-				if err != nil {
-					return nil, err
-				}
+		if function.Image != "" {
+			if err := hctx.checkContainerFnAllowed(function.Image); err != nil {
+				return nil, err
 			}
-			hctx.dockerCheckDone = true
 		}
 		runner, err = fnruntime.NewRunner(ctx, hctx.fileSystem, &function, pkgPath, hctx.fnResults, hctx.imagePullPolicy, true, displayResourceCount, hctx.runtime)
 		if err != nil {
@@ -227,3 +227,33 @@ metadata:
 		})
 	}
 }
+
+func TestCheckContainerFnAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		hctx     *hydrationContext
+		expected string
+	}{
+		{
+			name: "docker already checked",
+			hctx: &hydrationContext{dockerCheckDone: true},
+		},
+		{
+			name:     "no docker",
+			hctx:     &hydrationContext{noDocker: true, dockerCheckDone: true},
+			expected: "container function \"gcr.io/kpt-fn/set-labels:v0.1\" can't be run with `--no-docker` option",
+		},
+	}
+
+	for i := range tests {
+		tc := tests[i]
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.hctx.checkContainerFnAllowed("gcr.io/kpt-fn/set-labels:v0.1")
+			if tc.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// PlannedFunction is a function render would execute, with its image
+// resolved like render resolves it.
+type PlannedFunction struct {
+	// Package is the slash-separated path of the package whose pipeline
+	// contains the function, relative to the root package.
+	Package string `json:"package"`
+	// Type is either mutator or validator.
+	Type string `json:"type"`
+	kptfilev1.Function
+}
+
+// Plan returns the functions of the pipelines of the package at pkgPath and
+// its subpackages, in the order render executes them, without executing
+// them. The Kptfiles are validated like render validates them. The
+// functions of the subpackages come first, as render hydrates them before
+// the package containing them.
+func Plan(ctx context.Context, fsys filesys.FileSystem, pkgPath string) ([]PlannedFunction, error) {
+	root, err := newPkgNode(fsys, pkgPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	plan := []PlannedFunction{}
+	if err := root.plan(ctx, fsys, root, &plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// plan appends the functions of the package and its subpackages to plan.
+func (pn *pkgNode) plan(ctx context.Context, fsys filesys.FileSystem, root *pkgNode, plan *[]PlannedFunction) error {
+	const op errors.Op = "fn.plan"
+	subpkgs, err := pn.pkg.DirectSubpackages()
+	if err != nil {
+		return errors.E(op, pn.pkg.UniquePath, err)
+	}
+	for _, subpkg := range subpkgs {
+		subPkgNode, err := newPkgNode(fsys, "", subpkg)
+		if err != nil {
+			return errors.E(op, subpkg.UniquePath, err)
+		}
+		if err := subPkgNode.plan(ctx, fsys, root, plan); err != nil {
+			return err
+		}
+	}
+	pl, err := pn.pkg.Pipeline()
+	if err != nil {
+		return errors.E(op, pn.pkg.UniquePath, err)
+	}
+	relPath, err := pn.pkg.RelativePathTo(root.pkg)
+	if err != nil {
+		return errors.E(op, pn.pkg.UniquePath, err)
+	}
+	for _, fns := range []struct {
+		fnType string
+		fns    []kptfilev1.Function
+	}{
+		{fnType: "mutator", fns: pl.Mutators},
+		{fnType: "validator", fns: pl.Validators},
+	} {
+		for _, f := range fns.fns {
+			if f.Image != "" {
+				f.Image = fnruntime.AddDefaultImagePathPrefix(ctx, f.Image)
+			}
+			*plan = append(*plan, PlannedFunction{
+				Package:  filepath.ToSlash(relPath),
+				Type:     fns.fnType,
+				Function: f,
+			})
+		}
+	}
+	return nil
+}
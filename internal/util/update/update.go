@@ -118,9 +118,8 @@ func (u Command) Run(ctx context.Context) error {
 		return errors.E(op, u.Pkg.UniquePath, err)
 	}
 
-	if rootKf.Upstream == nil || rootKf.Upstream.Git == nil {
-		return errors.E(op, u.Pkg.UniquePath,
-			fmt.Errorf("package must have an upstream reference"))
+	if err := pkg.CheckUpstream(rootKf, u.Pkg.UniquePath); err != nil {
+		return errors.E(op, u.Pkg.UniquePath, err)
 	}
 	originalRootKfRef := rootKf.Upstream.Git.Ref
 	if u.Ref != "" {
@@ -27,6 +27,8 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/GoogleContainerTools/kpt/internal/errors"
 	"github.com/GoogleContainerTools/kpt/internal/errors/resolver"
@@ -51,7 +53,16 @@ func runMain() int {
 	var logFlags flag.FlagSet
 	var err error
 
-	ctx := context.Background()
+	// Cancel the context on interrupt, so the functions started by kpt are
+	// stopped as well. They run in their own process group and don't receive
+	// the signals sent to kpt by the terminal. A second interrupt terminates
+	// kpt right away.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
 	cmd := run.GetMain(ctx)
 
@@ -77,7 +88,16 @@ func handleErr(cmd *cobra.Command, err error) int {
 	// First attempt to see if we can resolve the error into a specific
 	// error message.
 	if re, resolved := resolver.ResolveError(err); resolved {
-		fmt.Fprintf(cmd.ErrOrStderr(), "%s \n", re.Message)
+		// Some errors, e.g. the differences found by pkg diff --exit-code,
+		// only set the exit code as their output has already been written.
+		if re.Message == "" {
+			return re.ExitCode
+		}
+		msg := re.Message
+		if resolver.ColorEnabled(cmdutil.Color, cmd.ErrOrStderr()) {
+			msg = resolver.Colorize(msg)
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "%s \n", msg)
 		return re.ExitCode
 	}
 
@@ -0,0 +1,53 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/diff"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleErr(t *testing.T) {
+	testCases := map[string]struct {
+		err      error
+		exitCode int
+		stderr   string
+	}{
+		"differences found": {
+			err:      &diff.DifferencesFoundError{},
+			exitCode: diff.ExitCodeDifferences,
+		},
+		"diff tool error": {
+			err:      &diff.ToolError{Tool: "diff", Err: fmt.Errorf("exit status 2")},
+			exitCode: diff.ExitCodeToolFailure,
+			stderr:   "Error: diff tool \"diff\" failed: exit status 2 \n",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			stderr := &bytes.Buffer{}
+			cmd := &cobra.Command{}
+			cmd.SetErr(stderr)
+			assert.Equal(t, tc.exitCode, handleErr(cmd, tc.err))
+			assert.Equal(t, tc.stderr, stderr.String())
+		})
+	}
+}
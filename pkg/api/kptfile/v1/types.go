@@ -19,6 +19,8 @@ package v1
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
@@ -324,6 +326,36 @@ func (s Selector) IsEmpty() bool {
 		len(s.Annotations) == 0
 }
 
+// String returns the criteria of the selector, e.g.
+// kind=Deployment,labels.app=foo.
+func (s Selector) String() string {
+	var criteria []string
+	for _, c := range []struct{ name, value string }{
+		{"apiVersion", s.APIVersion},
+		{"kind", s.Kind},
+		{"name", s.Name},
+		{"namespace", s.Namespace},
+	} {
+		if c.value != "" {
+			criteria = append(criteria, c.name+"="+c.value)
+		}
+	}
+	criteria = append(criteria, mapCriteria("labels", s.Labels)...)
+	criteria = append(criteria, mapCriteria("annotations", s.Annotations)...)
+	return strings.Join(criteria, ",")
+}
+
+// mapCriteria returns the criteria of the labels or annotations in m, sorted
+// by key.
+func mapCriteria(name string, m map[string]string) []string {
+	var criteria []string
+	for k, v := range m {
+		criteria = append(criteria, fmt.Sprintf("%s.%s=%s", name, k, v))
+	}
+	sort.Strings(criteria)
+	return criteria
+}
+
 // Inventory encapsulates the parameters for the inventory resource applied to a cluster.
 // All of the the parameters are required if any are set.
 type Inventory struct {
@@ -66,6 +66,21 @@ func (p *Pipeline) validate(fsys filesys.FileSystem, pkgPath types.UniquePath) e
 	return nil
 }
 
+// ValidateFunctions validates functions which are declared outside of a
+// Kptfile with the same schema as the functions of the pipeline, e.g. in a
+// pipeline file. fnType is the pipeline field the functions are reported as,
+// and their configPath is relative to pkgPath.
+func ValidateFunctions(fsys filesys.FileSystem, fnType string, fns []Function, pkgPath types.UniquePath) error {
+	for i := range fns {
+		f := fns[i]
+		err := f.validate(fsys, fnType, i, pkgPath)
+		if err != nil {
+			return fmt.Errorf("function %q: %w", f.Image, err)
+		}
+	}
+	return nil
+}
+
 func (f *Function) validate(fsys filesys.FileSystem, fnType string, idx int, pkgPath types.UniquePath) error {
 	if f.Image == "" && f.Exec == "" {
 		return &ValidateError{
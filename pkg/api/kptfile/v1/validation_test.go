@@ -165,6 +165,30 @@ func TestKptfileValidate(t *testing.T) {
 	}
 }
 
+func TestValidateFunctions(t *testing.T) {
+	dir := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`), 0600)
+	assert.NoError(t, err)
+
+	err = ValidateFunctions(filesys.FileSystemOrOnDisk{}, "mutators", []Function{
+		{Image: "set-labels"},
+		{Exec: "./fn", ConfigPath: "config.yaml"},
+	}, types.UniquePath(dir))
+	assert.NoError(t, err)
+
+	err = ValidateFunctions(filesys.FileSystemOrOnDisk{}, "mutators", []Function{
+		{Image: "set-labels"},
+		{Exec: "./fn", ConfigPath: "missing.yaml"},
+	}, types.UniquePath(dir))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "pipeline.mutators[1].configPath")
+	}
+}
+
 func TestValidateFunctionName(t *testing.T) {
 	type input struct {
 		Name  string
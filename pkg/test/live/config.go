@@ -15,9 +15,12 @@
 package live
 
 import (
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"regexp"
 	"testing"
+	"time"
 
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
@@ -33,9 +36,46 @@ type TestCaseConfig struct {
 	// Default: ""
 	StdOut string `yaml:"stdOut,omitempty"`
 
+	// StdOutContains are substrings which must be present in the standard
+	// output, and StdOutNotContains are substrings which must not be. They
+	// are checked against the output after the substitutions, instead of
+	// comparing it with StdOut, so they can't be used together with StdOut.
+	// Default: the standard output is compared with StdOut
+	StdOutContains    []string `yaml:"stdOutContains,omitempty"`
+	StdOutNotContains []string `yaml:"stdOutNotContains,omitempty"`
+
+	// StdErrContains and StdErrNotContains are the same as StdOutContains
+	// and StdOutNotContains for the standard error output, and can't be
+	// used together with StdErr.
+	StdErrContains    []string `yaml:"stdErrContains,omitempty"`
+	StdErrNotContains []string `yaml:"stdErrNotContains,omitempty"`
+
+	// ExpectedStatuses is the expected final status of resources, as
+	// reported by the status events in the standard output. The status
+	// events are still removed before comparing the output with StdOut.
+	// Requires the kpt command to print status events, e.g. by using
+	// --show-status-events. Default: statuses aren't verified
+	ExpectedStatuses []ResourceStatus `yaml:"expectedStatuses,omitempty"`
+
+	// Counts are the expected numbers of resources in the summaries of the
+	// standard output of the kpt command, which are compared numerically
+	// and independently of the wording of the summaries. If Counts is set
+	// and StdOut is empty, the standard output isn't compared with StdOut.
+	// Default: counts aren't verified
+	Counts *Counts `yaml:"counts,omitempty"`
+
 	// Inventory is the expected list of resource present in the inventory.
 	Inventory []InventoryEntry `yaml:"inventory,omitempty"`
 
+	// InventorySubset treats Inventory as a subset of the resources which
+	// have to be present in the inventory, other resources in the inventory
+	// are allowed. Default: the inventory has to match Inventory exactly
+	InventorySubset bool `yaml:"inventorySubset,omitempty"`
+
+	// Assertions is a list of resources in the cluster with the expected
+	// values of their fields after running the kpt command.
+	Assertions []Assertion `yaml:"assertions,omitempty"`
+
 	// NoResourceGroup defines whether the RG CRD should be present in the cluster
 	// when the test starts.
 	NoResourceGroup bool `yaml:"noResourceGroup,omitempty"`
@@ -48,6 +88,60 @@ type TestCaseConfig struct {
 	// KptArgs is a list of args that will be provided to the kpt command
 	// when running the test.
 	KptArgs []string `yaml:"kptArgs,omitempty"`
+
+	// DryRun runs the kpt command with --dry-run, so only its output is
+	// verified. The pre-apply resources aren't applied and the inventory
+	// isn't verified, as nothing is applied to the cluster.
+	DryRun bool `yaml:"dryRun,omitempty"`
+
+	// Destroy runs `kpt live destroy` after the kpt command has been
+	// verified, and verifies that the inventory and the resources it
+	// contained are gone. It's ignored with DryRun. Default: false
+	Destroy bool `yaml:"destroy,omitempty"`
+
+	// Timeout is the maximum duration of a single run of the kpt command
+	// and of `kpt live destroy`, e.g. 5m. Default: no timeout
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// Substitutions are applied to the output of the kpt command after
+	// timestamps, UIDs and resourceVersions have been replaced, so
+	// nondeterministic values like generated names can be masked.
+	Substitutions []Substitution `yaml:"substitutions,omitempty"`
+
+	// Retries is the number of times the kpt command is run again if it
+	// exits with a nonzero exit code other than ExitCode. Default: 0
+	Retries int `yaml:"retries,omitempty"`
+}
+
+// ResourceStatus defines the expected status of a resource.
+type ResourceStatus struct {
+	// Resource identifies the resource the same way as the kpt output, e.g.
+	// deployment.apps/nginx.
+	Resource string `yaml:"resource,omitempty"`
+
+	// Status is the expected status, e.g. Current.
+	Status string `yaml:"status,omitempty"`
+}
+
+// Counts defines the expected numbers of resources in the summaries of the
+// kpt command, e.g. `2 resource(s) applied. 2 created, 0 unchanged, 0
+// configured, 0 failed` or the completed events of the json output. Counts
+// which aren't set aren't verified.
+type Counts struct {
+	Applied    *int `yaml:"applied,omitempty"`
+	Pruned     *int `yaml:"pruned,omitempty"`
+	Reconciled *int `yaml:"reconciled,omitempty"`
+
+	// Failed is the total number of resources which failed to be applied,
+	// pruned or reconciled.
+	Failed *int `yaml:"failed,omitempty"`
+}
+
+// Substitution replaces all matches of the regular expression Pattern with
+// Replacement, which can refer to submatches with $1 etc.
+type Substitution struct {
+	Pattern     string `yaml:"pattern,omitempty"`
+	Replacement string `yaml:"replacement,omitempty"`
 }
 
 // InventoryEntry defines an entry in an inventory list.
@@ -58,6 +152,20 @@ type InventoryEntry struct {
 	Namespace string `yaml:"namespace,omitempty"`
 }
 
+// Assertion defines the expected field values of a resource in the cluster.
+type Assertion struct {
+	Group     string `yaml:"group,omitempty"`
+	Version   string `yaml:"version,omitempty"`
+	Kind      string `yaml:"kind,omitempty"`
+	Name      string `yaml:"name,omitempty"`
+	Namespace string `yaml:"namespace,omitempty"`
+
+	// Fields maps JSONPath expressions, e.g. `.spec.replicas` or
+	// `{.status.conditions[0].type}`, to the expected value. Timestamps and
+	// UIDs in the values are replaced with <TIMESTAMP> and <UID>.
+	Fields map[string]string `yaml:"fields,omitempty"`
+}
+
 func ReadTestCaseConfig(t *testing.T, path string) TestCaseConfig {
 	configPath := filepath.Join(path, "config.yaml")
 	b, err := ioutil.ReadFile(configPath)
@@ -70,5 +178,45 @@ func ReadTestCaseConfig(t *testing.T, path string) TestCaseConfig {
 	if err != nil {
 		t.Fatalf("unable to unmarshal test config file %s: %v", configPath, err)
 	}
+	if err := config.validate(); err != nil {
+		t.Fatalf("invalid test config file %s: %v", configPath, err)
+	}
 	return config
 }
+
+// validate returns an error if the config combines mutually exclusive
+// fields or contains invalid substitutions.
+func (c TestCaseConfig) validate() error {
+	if c.StdOut != "" && c.matchesStdOutSubstrings() {
+		return fmt.Errorf("stdOut can't be used together with stdOutContains or stdOutNotContains")
+	}
+	if c.StdErr != "" && c.matchesStdErrSubstrings() {
+		return fmt.Errorf("stdErr can't be used together with stdErrContains or stdErrNotContains")
+	}
+	_, err := c.compileSubstitutions()
+	return err
+}
+
+// matchesStdOutSubstrings returns true if the standard output is checked for
+// substrings instead of being compared with StdOut.
+func (c TestCaseConfig) matchesStdOutSubstrings() bool {
+	return len(c.StdOutContains) != 0 || len(c.StdOutNotContains) != 0
+}
+
+// matchesStdErrSubstrings returns true if the standard error output is
+// checked for substrings instead of being compared with StdErr.
+func (c TestCaseConfig) matchesStdErrSubstrings() bool {
+	return len(c.StdErrContains) != 0 || len(c.StdErrNotContains) != 0
+}
+
+func (c TestCaseConfig) compileSubstitutions() ([]Pattern, error) {
+	var subs []Pattern
+	for _, s := range c.Substitutions {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid substitution pattern %q: %w", s.Pattern, err)
+		}
+		subs = append(subs, Pattern{Regexp: re, Replacement: s.Replacement})
+	}
+	return subs, nil
+}
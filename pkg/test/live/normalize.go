@@ -0,0 +1,104 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package live
+
+import "regexp"
+
+// NormalizeOptions configures which nondeterministic values NormalizeOutput
+// replaces in the output of kpt. The zero value replaces the timestamps, the
+// UIDs and the resourceVersions.
+type NormalizeOptions struct {
+	// KeepTimestamps keeps the timestamps instead of replacing them with
+	// <TIMESTAMP>.
+	KeepTimestamps bool
+
+	// KeepUIDs keeps the UIDs instead of replacing them with <UID>.
+	KeepUIDs bool
+
+	// KeepResourceVersions keeps the resourceVersions instead of replacing
+	// them with <RV>.
+	KeepResourceVersions bool
+
+	// Namespace is replaced with NamespacePlaceholder if both are set.
+	Namespace            string
+	NamespacePlaceholder string
+
+	// Patterns are applied in order after all other substitutions.
+	Patterns []Pattern
+}
+
+// Pattern replaces all matches of Regexp with Replacement, which can refer
+// to submatches with $1 etc.
+type Pattern struct {
+	Regexp      *regexp.Regexp
+	Replacement string
+}
+
+// NormalizeOutput replaces the nondeterministic values in s with
+// placeholders, so the output of kpt can be compared with expected output.
+func NormalizeOutput(s string, opts NormalizeOptions) string {
+	if !opts.KeepTimestamps {
+		s = substituteTimestamps(s)
+	}
+	if !opts.KeepUIDs {
+		s = substituteUIDs(s)
+	}
+	if !opts.KeepResourceVersions {
+		s = substituteResourceVersion(s)
+	}
+	if opts.NamespacePlaceholder != "" {
+		s = substituteNamespace(s, opts.Namespace, opts.NamespacePlaceholder)
+	}
+	for _, p := range opts.Patterns {
+		s = p.Regexp.ReplaceAllString(s, p.Replacement)
+	}
+	return s
+}
+
+var timestampRegexp = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z`)
+
+func substituteTimestamps(text string) string {
+	return timestampRegexp.ReplaceAllString(text, "<TIMESTAMP>")
+}
+
+var uidRegexp = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+func substituteUIDs(text string) string {
+	return uidRegexp.ReplaceAllLiteralString(text, "<UID>")
+}
+
+var resourceVersionRegexp = regexp.MustCompile(`resourceVersion: "[0-9]+"`)
+
+func substituteResourceVersion(text string) string {
+	return resourceVersionRegexp.ReplaceAllLiteralString(text, "resourceVersion: \"<RV>\"")
+}
+
+var namespaceRegexp = regexp.MustCompile(`[a-z0-9-]+`)
+
+// substituteNamespace replaces ns with placeholder in text. Only whole
+// namespace names are replaced, not names which contain ns, e.g. the name
+// of a resource which starts with the namespace.
+func substituteNamespace(text, ns, placeholder string) string {
+	if ns == "" {
+		return text
+	}
+	return namespaceRegexp.ReplaceAllStringFunc(text, func(s string) string {
+		if s == ns {
+			return placeholder
+		}
+		return s
+	})
+}
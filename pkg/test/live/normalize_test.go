@@ -0,0 +1,69 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package live
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeOutput(t *testing.T) {
+	output := `pod/nginx-x7k2p in test created at 2022-01-01T00:00:00Z
+uid: 8f3c2d1e-4b5a-4c6d-9e8f-0a1b2c3d4e5f
+resourceVersion: "1234"`
+
+	testCases := map[string]struct {
+		opts     NormalizeOptions
+		expected string
+	}{
+		"defaults": {
+			expected: `pod/nginx-x7k2p in test created at <TIMESTAMP>
+uid: <UID>
+resourceVersion: "<RV>"`,
+		},
+		"keep all": {
+			opts: NormalizeOptions{
+				KeepTimestamps:       true,
+				KeepUIDs:             true,
+				KeepResourceVersions: true,
+			},
+			expected: output,
+		},
+		"namespace and patterns": {
+			opts: NormalizeOptions{
+				KeepUIDs:             true,
+				Namespace:            "test",
+				NamespacePlaceholder: "<NAMESPACE>",
+				Patterns: []Pattern{
+					{Regexp: regexp.MustCompile(`nginx-[a-z0-9]{5}`), Replacement: "nginx-<SUFFIX>"},
+					// patterns are applied after the other substitutions
+					{Regexp: regexp.MustCompile(`<RV>`), Replacement: "<VERSION>"},
+				},
+			},
+			expected: `pod/nginx-<SUFFIX> in <NAMESPACE> created at <TIMESTAMP>
+uid: 8f3c2d1e-4b5a-4c6d-9e8f-0a1b2c3d4e5f
+resourceVersion: "<VERSION>"`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NormalizeOutput(output, tc.opts))
+		})
+	}
+}
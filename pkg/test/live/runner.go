@@ -17,17 +17,25 @@ package live
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
 	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
@@ -39,22 +47,124 @@ type Runner struct {
 
 	// Path provides the path to the test files.
 	Path string
+
+	// KptBin is the kpt binary used to run the test. Default: kpt from
+	// the PATH
+	KptBin string
+
+	// KubectlBin is the kubectl binary used to set up and verify the test.
+	// Default: kubectl from the PATH
+	KubectlBin string
+
+	// NamespacePlaceholder replaces the namespace of the test in the output
+	// of the kpt command before it is compared with the expected output, e.g.
+	// <NAMESPACE>, so the expected output doesn't depend on the namespace.
+	// Default: the namespace isn't replaced
+	NamespacePlaceholder string
+
+	substitutions []Pattern
+}
+
+func (r *Runner) kptBin() string {
+	if r.KptBin != "" {
+		return r.KptBin
+	}
+	return "kpt"
+}
+
+func (r *Runner) kubectlBin() string {
+	if r.KubectlBin != "" {
+		return r.KubectlBin
+	}
+	return "kubectl"
 }
 
 // Run executes the test.
 func (r *Runner) Run(t *testing.T) {
-	testName := filepath.Base(r.Path)
-	r.RunPreApply(t)
+	if err := r.Config.validate(); err != nil {
+		t.Fatalf("invalid test config: %v", err)
+	}
+	r.compileSubstitutions(t)
+	ns := r.Namespace()
+	defer r.dumpEventsOnFailure(t, ns)
+	if !r.Config.DryRun {
+		r.RunPreApply(t)
+	}
 
 	stdout, stderr, err := r.RunApply(t)
 	r.VerifyExitCode(t, err)
-	r.VerifyStdout(t, stdout)
+	if r.Config.Counts == nil || r.Config.StdOut != "" || r.Config.matchesStdOutSubstrings() {
+		r.VerifyStdout(t, stdout)
+	}
 	r.VerifyStderr(t, stderr)
-	if len(r.Config.Inventory) != 0 {
-		r.VerifyInventory(t, testName, testName)
+	if len(r.Config.ExpectedStatuses) != 0 {
+		r.VerifyStatuses(t, stdout)
+	}
+	if r.Config.Counts != nil {
+		r.VerifyCounts(t, stdout)
+	}
+	if len(r.Config.Inventory) != 0 && !r.Config.DryRun {
+		r.VerifyInventory(t, ns, ns)
+	}
+	if len(r.Config.Assertions) != 0 {
+		r.VerifyAssertions(t)
+	}
+	if r.Config.Destroy && !r.Config.DryRun {
+		// the resources in the inventory must be deleted by destroy
+		resources, _ := r.getInventory(t, ns, ns)
+		_, stderr, err := r.RunDestroy(t)
+		if err != nil {
+			t.Fatalf("error destroying the resources: %v: %s", err, strings.TrimSpace(stderr))
+		}
+		r.VerifyDestroyed(t, ns, ns, resources)
 	}
 }
 
+// DumpEventsEnv is the name of the environment variable which, if set, makes
+// the runner log the events in the namespace of a failed test.
+const DumpEventsEnv = "KPT_TEST_DUMP_EVENTS"
+
+// dumpEventsOnFailure logs the events in the namespace ns if the test failed
+// and DumpEventsEnv is set, to help finding out why the cluster rejected
+// the resources.
+func (r *Runner) dumpEventsOnFailure(t *testing.T, ns string) {
+	if !t.Failed() || os.Getenv(DumpEventsEnv) == "" {
+		return
+	}
+	events, err := r.events(ns)
+	if err != nil {
+		t.Logf("error getting events in namespace %s: %v", ns, err)
+		return
+	}
+	t.Logf("Events in namespace %s:\n%s", ns, events)
+}
+
+// events returns the events in the namespace ns, sorted by their last
+// timestamp.
+func (r *Runner) events(ns string) (string, error) {
+	cmd := exec.Command(r.kubectlBin(), "get", "events", "-n", ns, "--sort-by=.lastTimestamp")
+	var outBuf bytes.Buffer
+	var errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(errBuf.String()))
+	}
+	return outBuf.String(), nil
+}
+
+var invalidNamespaceChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Namespace returns the namespace of the test, which is derived from the
+// name of the test directory.
+func (r *Runner) Namespace() string {
+	ns := invalidNamespaceChars.ReplaceAllString(strings.ToLower(filepath.Base(r.Path)), "-")
+	if len(ns) > 63 {
+		ns = ns[:63]
+	}
+	return strings.Trim(ns, "-")
+}
+
 func (r *Runner) RunPreApply(t *testing.T) {
 	preApplyDir := filepath.Join(r.Path, "pre-apply")
 	fi, err := os.Stat(preApplyDir)
@@ -65,15 +175,64 @@ func (r *Runner) RunPreApply(t *testing.T) {
 		return
 	}
 	t.Log("Applying resources in pre-apply directory")
-	cmd := exec.Command("kubectl", "apply", "-f", preApplyDir)
+	cmd := exec.Command(r.kubectlBin(), "apply", "-f", preApplyDir)
 	if err := cmd.Run(); err != nil {
 		t.Fatalf("error applying pre-apply dir: %v", err)
 	}
 }
 
+// RunApply runs the kpt command of the test. If the command exits with an
+// unexpected nonzero exit code or times out, it is run again up to
+// Config.Retries times.
 func (r *Runner) RunApply(t *testing.T) (string, string, error) {
-	t.Logf("Running command: kpt %s", strings.Join(r.Config.KptArgs, " "))
-	cmd := exec.Command("kpt", r.Config.KptArgs...)
+	attempts := r.Config.Retries + 1
+	for attempt := 1; ; attempt++ {
+		stdout, stderr, err := r.runApplyOnce(t)
+		exitCode := exitCodeOf(err)
+		if attempt >= attempts || exitCode == 0 || exitCode == r.Config.ExitCode {
+			return stdout, stderr, err
+		}
+		t.Logf("Attempt %d of %d failed with exit code %d, retrying", attempt, attempts, exitCode)
+	}
+}
+
+func (r *Runner) runApplyOnce(t *testing.T) (string, string, error) {
+	ctx := context.Background()
+	if r.Config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Config.Timeout)
+		defer cancel()
+	}
+
+	args := r.kptArgs()
+	t.Logf("Running command: %s %s", r.kptBin(), strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, r.kptBin(), args...)
+	cmd.Dir = filepath.Join(r.Path, "resources")
+
+	var outBuf bytes.Buffer
+	var errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		t.Logf("Command timed out after %s", r.Config.Timeout)
+	}
+	return outBuf.String(), errBuf.String(), err
+}
+
+// RunDestroy runs `kpt live destroy` on the resources of the test.
+func (r *Runner) RunDestroy(t *testing.T) (string, string, error) {
+	ctx := context.Background()
+	if r.Config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Config.Timeout)
+		defer cancel()
+	}
+
+	args := []string{"live", "destroy"}
+	t.Logf("Running command: %s %s", r.kptBin(), strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, r.kptBin(), args...)
 	cmd.Dir = filepath.Join(r.Path, "resources")
 
 	var outBuf bytes.Buffer
@@ -85,35 +244,185 @@ func (r *Runner) RunApply(t *testing.T) (string, string, error) {
 	return outBuf.String(), errBuf.String(), err
 }
 
+// kptArgs returns the args of the kpt command, including --dry-run in
+// dry-run mode.
+func (r *Runner) kptArgs() []string {
+	args := append([]string{}, r.Config.KptArgs...)
+	if !r.Config.DryRun {
+		return args
+	}
+	for _, arg := range args {
+		if arg == "--dry-run" || strings.HasPrefix(arg, "--dry-run=") {
+			return args
+		}
+	}
+	return append(args, "--dry-run")
+}
+
 func (r *Runner) VerifyExitCode(t *testing.T, err error) {
-	exitCode := 0
+	if want, got := r.Config.ExitCode, exitCodeOf(err); want != got {
+		t.Errorf("expected exit code %d, but got %d", want, got)
+	}
+}
+
+// exitCodeOf returns the exit code of a command from the error returned
+// when running it.
+func exitCodeOf(err error) int {
 	var exitErr *exec.ExitError
 	if errors.As(err, &exitErr) {
-		exitCode = exitErr.ExitCode()
-	}
-	if want, got := r.Config.ExitCode, exitCode; want != got {
-		t.Errorf("expected exit code %d, but got %d", want, got)
+		return exitErr.ExitCode()
 	}
+	return 0
 }
 
+// UpdateExpectedEnv is the name of the environment variable which, if set,
+// makes the runner write the captured output to the test config instead of
+// verifying it against the expected output.
+const UpdateExpectedEnv = "UPDATE_EXPECTED"
+
 func (r *Runner) VerifyStdout(t *testing.T, stdout string) {
-	assert.Equal(t, strings.TrimSpace(r.Config.StdOut), prepOutput(t, stdout))
+	got := r.prepOutput(t, stdout)
+	if r.Config.matchesStdOutSubstrings() {
+		verifySubstrings(t, "stdout", got, r.Config.StdOutContains, r.Config.StdOutNotContains)
+		return
+	}
+	if os.Getenv(UpdateExpectedEnv) != "" {
+		r.updateExpected(t, "stdOut", got)
+		r.Config.StdOut = got
+		return
+	}
+	assert.Equal(t, strings.TrimSpace(r.Config.StdOut), got)
 }
 
 func (r *Runner) VerifyStderr(t *testing.T, stderr string) {
-	assert.Equal(t, strings.TrimSpace(r.Config.StdErr), prepOutput(t, stderr))
+	got := r.prepOutput(t, stderr)
+	if r.Config.matchesStdErrSubstrings() {
+		verifySubstrings(t, "stderr", got, r.Config.StdErrContains, r.Config.StdErrNotContains)
+		return
+	}
+	if os.Getenv(UpdateExpectedEnv) != "" {
+		r.updateExpected(t, "stdErr", got)
+		r.Config.StdErr = got
+		return
+	}
+	assert.Equal(t, strings.TrimSpace(r.Config.StdErr), got)
+}
+
+// verifySubstrings verifies that the output of the stream contains all the
+// substrings of contains and none of notContains.
+func verifySubstrings(t assert.TestingT, stream, output string, contains, notContains []string) {
+	for _, s := range contains {
+		assert.Contains(t, output, s, "%s must contain %q", stream, s)
+	}
+	for _, s := range notContains {
+		assert.NotContains(t, output, s, "%s must not contain %q", stream, s)
+	}
 }
 
-func prepOutput(t *testing.T, s string) string {
-	txt := removeStatusEvents(t, s)
-	txt = substituteTimestamps(txt)
-	txt = substituteUIDs(txt)
-	txt = substituteResourceVersion(txt)
+// updateExpected sets field in the config file of the test to value. The
+// rest of the file, including comments, is left unchanged.
+func (r *Runner) updateExpected(t *testing.T, field, value string) {
+	configPath := filepath.Join(r.Path, "config.yaml")
+	b, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("unable to read test config at %s", configPath)
+	}
+	nodes, err := (&kio.ByteReader{
+		Reader:            bytes.NewReader(b),
+		PreserveSeqIndent: true,
+	}).Read()
+	if err != nil || len(nodes) != 1 {
+		t.Fatalf("unable to parse test config file %s: %v", configPath, err)
+	}
+	node := nodes[0]
+	if value == "" {
+		_, err = node.Pipe(yaml.Clear(field))
+	} else {
+		err = node.PipeE(yaml.SetField(field, yaml.NewRNode(&yaml.Node{
+			Kind:  yaml.ScalarNode,
+			Style: yaml.LiteralStyle,
+			Value: value + "\n",
+		})))
+	}
+	if err != nil {
+		t.Fatalf("unable to update %s in test config file %s: %v", field, configPath, err)
+	}
+	var out bytes.Buffer
+	if err := (kio.ByteWriter{Writer: &out}).Write(nodes); err != nil {
+		t.Fatalf("unable to serialize test config file %s: %v", configPath, err)
+	}
+	if err := ioutil.WriteFile(configPath, out.Bytes(), 0644); err != nil {
+		t.Fatalf("unable to write test config file %s: %v", configPath, err)
+	}
+	t.Logf("Updated %s in %s", field, configPath)
+}
+
+func (r *Runner) compileSubstitutions(t *testing.T) {
+	subs, err := r.Config.compileSubstitutions()
+	if err != nil {
+		t.Fatalf("invalid test config: %v", err)
+	}
+	r.substitutions = subs
+}
+
+func (r *Runner) prepOutput(t *testing.T, s string) string {
+	if r.substitutions == nil {
+		r.compileSubstitutions(t)
+	}
+	txt := NormalizeOutput(removeStatusEvents(t, s), NormalizeOptions{
+		Namespace:            r.Namespace(),
+		NamespacePlaceholder: r.NamespacePlaceholder,
+		Patterns:             r.substitutions,
+	})
 	return strings.TrimSpace(txt)
 }
 
 func (r *Runner) VerifyInventory(t *testing.T, name, namespace string) {
-	rgExec := exec.Command("kubectl", "get", "resourcegroups.kpt.dev",
+	inventory, found := r.getInventory(t, name, namespace)
+	if !found {
+		t.Errorf("inventory with namespace %s and name %s not found",
+			namespace, name)
+		return
+	}
+	compareInventory(t, r.Config.Inventory, inventory, r.Config.InventorySubset)
+}
+
+// VerifyDestroyed verifies that neither the inventory with the given name
+// and namespace nor any of the resources exist after destroying them.
+func (r *Runner) VerifyDestroyed(t *testing.T, name, namespace string, resources []InventoryEntry) {
+	if _, found := r.getInventory(t, name, namespace); found {
+		t.Errorf("inventory with namespace %s and name %s still exists after destroy",
+			namespace, name)
+	}
+	for _, e := range resources {
+		resource := e.Kind
+		if e.Group != "" {
+			resource += "." + e.Group
+		}
+		args := []string{"get", resource, e.Name, "--ignore-not-found", "-o", "name"}
+		if e.Namespace != "" {
+			args = append(args, "-n", e.Namespace)
+		}
+		cmd := exec.Command(r.kubectlBin(), args...)
+		var outBuf bytes.Buffer
+		var errBuf bytes.Buffer
+		cmd.Stdout = &outBuf
+		cmd.Stderr = &errBuf
+		if err := cmd.Run(); err != nil {
+			t.Errorf("error looking up %s %s/%s: %v: %s", resource, e.Namespace, e.Name,
+				err, errBuf.String())
+			continue
+		}
+		if strings.TrimSpace(outBuf.String()) != "" {
+			t.Errorf("%s %s/%s still exists after destroy", resource, e.Namespace, e.Name)
+		}
+	}
+}
+
+// getInventory returns the entries of the ResourceGroup inventory with the
+// given name and namespace, and false if the inventory doesn't exist.
+func (r *Runner) getInventory(t *testing.T, name, namespace string) ([]InventoryEntry, bool) {
+	rgExec := exec.Command(r.kubectlBin(), "get", "resourcegroups.kpt.dev",
 		"-n", namespace, name, "-oyaml")
 	var outBuf bytes.Buffer
 	var errBuf bytes.Buffer
@@ -121,9 +430,7 @@ func (r *Runner) VerifyInventory(t *testing.T, name, namespace string) {
 	rgExec.Stderr = &errBuf
 	err := rgExec.Run()
 	if strings.Contains(errBuf.String(), "NotFound") {
-		t.Errorf("inventory with namespace %s and name %s not found",
-			namespace, name)
-		return
+		return nil, false
 	}
 	if err != nil {
 		t.Fatalf("error looking up resource group: %v", err)
@@ -150,12 +457,75 @@ func (r *Runner) VerifyInventory(t *testing.T, name, namespace string) {
 			}
 		}
 	}
+	return inventory, true
+}
 
-	expectedInventory := r.Config.Inventory
-	sort.Slice(expectedInventory, inventorySortFunc(expectedInventory))
+// compareInventory verifies that inventory contains exactly the expected
+// entries, or at least the expected entries if subset is true. The order of
+// the entries doesn't matter.
+func compareInventory(t assert.TestingT, expected, inventory []InventoryEntry, subset bool) bool {
+	if subset {
+		return assert.Subset(t, inventory, expected)
+	}
+	sort.Slice(expected, inventorySortFunc(expected))
 	sort.Slice(inventory, inventorySortFunc(inventory))
 
-	assert.Equal(t, expectedInventory, inventory)
+	return assert.Equal(t, expected, inventory)
+}
+
+// VerifyAssertions looks up every resource in Config.Assertions in the
+// cluster and verifies the values of the listed fields.
+func (r *Runner) VerifyAssertions(t *testing.T) {
+	for _, a := range r.Config.Assertions {
+		resource := a.Kind
+		if a.Group != "" {
+			if a.Version != "" {
+				resource += "." + a.Version
+			}
+			resource += "." + a.Group
+		}
+		args := []string{"get", resource, a.Name, "-o", "json"}
+		if a.Namespace != "" {
+			args = append(args, "-n", a.Namespace)
+		}
+		cmd := exec.Command(r.kubectlBin(), args...)
+		var outBuf bytes.Buffer
+		var errBuf bytes.Buffer
+		cmd.Stdout = &outBuf
+		cmd.Stderr = &errBuf
+		if err := cmd.Run(); err != nil {
+			t.Errorf("error looking up %s %s/%s: %v: %s", resource, a.Namespace, a.Name,
+				err, errBuf.String())
+			continue
+		}
+		var obj interface{}
+		if err := json.Unmarshal(outBuf.Bytes(), &obj); err != nil {
+			t.Fatalf("error unmarshalling %s %s/%s: %v", resource, a.Namespace, a.Name, err)
+		}
+
+		var paths []string
+		for p := range a.Fields {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			expr := p
+			if !strings.HasPrefix(expr, "{") {
+				expr = "{" + expr + "}"
+			}
+			jp := jsonpath.New(p)
+			if err := jp.Parse(expr); err != nil {
+				t.Fatalf("invalid JSONPath %q: %v", p, err)
+			}
+			var value bytes.Buffer
+			if err := jp.Execute(&value, obj); err != nil {
+				t.Errorf("error evaluating %q for %s %s/%s: %v", p, resource, a.Namespace, a.Name, err)
+				continue
+			}
+			got := substituteUIDs(substituteTimestamps(value.String()))
+			assert.Equal(t, a.Fields[p], got, "field %q of %s %s/%s", p, resource, a.Namespace, a.Name)
+		}
+	}
 }
 
 func inventorySortFunc(inv []InventoryEntry) func(i, j int) bool {
@@ -176,31 +546,183 @@ func inventorySortFunc(inv []InventoryEntry) func(i, j int) bool {
 	}
 }
 
-var timestampRegexp = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z`)
+var statuses = []status.Status{
+	status.InProgressStatus,
+	status.CurrentStatus,
+	status.FailedStatus,
+	status.TerminatingStatus,
+	status.UnknownStatus,
+	status.NotFoundStatus,
+}
 
-func substituteTimestamps(text string) string {
-	return timestampRegexp.ReplaceAllString(text, "<TIMESTAMP>")
+// VerifyStatuses verifies that the last status reported for every resource
+// in Config.ExpectedStatuses matches the expected status.
+func (r *Runner) VerifyStatuses(t *testing.T, stdout string) {
+	observed := collectStatuses(t, stdout)
+	for _, s := range r.Config.ExpectedStatuses {
+		got, found := observed[s.Resource]
+		if !found {
+			t.Errorf("no status events found for %s", s.Resource)
+			continue
+		}
+		assert.Equal(t, s.Status, got, "status of %s", s.Resource)
+	}
 }
 
-var uidRegexp = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+var statusEventRegexp = regexp.MustCompile(`^(\S+) is (\w+): `)
 
-func substituteUIDs(text string) string {
-	return uidRegexp.ReplaceAllLiteralString(text, "<UID>")
+// collectStatuses returns the last status reported for every resource in
+// the output. Both the events and the json output formats are supported.
+func collectStatuses(t *testing.T, text string) map[string]string {
+	observed := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := scanner.Text()
+		var e struct {
+			Type   string `json:"type"`
+			Group  string `json:"group"`
+			Kind   string `json:"kind"`
+			Name   string `json:"name"`
+			Status string `json:"status"`
+		}
+		if json.Unmarshal([]byte(line), &e) == nil {
+			if e.Type == "status" && e.Status != "" {
+				gk := schema.GroupKind{Group: e.Group, Kind: e.Kind}
+				observed[strings.ToLower(gk.String())+"/"+e.Name] = e.Status
+			}
+			continue
+		}
+		if m := statusEventRegexp.FindStringSubmatch(line); m != nil && isStatus(m[2]) {
+			observed[m[1]] = m[2]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("error scanning output: %v", err)
+	}
+	return observed
 }
 
-var resourceVersionRegexp = regexp.MustCompile(`resourceVersion: "[0-9]+"`)
+// VerifyCounts verifies that the summaries in the output contain the numbers
+// of resources in Config.Counts.
+func (r *Runner) VerifyCounts(t *testing.T, stdout string) {
+	observed := collectCounts(t, stdout)
+	for _, c := range []struct {
+		name     string
+		expected *int
+	}{
+		{name: countApplied, expected: r.Config.Counts.Applied},
+		{name: countPruned, expected: r.Config.Counts.Pruned},
+		{name: countReconciled, expected: r.Config.Counts.Reconciled},
+		{name: countFailed, expected: r.Config.Counts.Failed},
+	} {
+		if c.expected == nil {
+			continue
+		}
+		got, found := observed[c.name]
+		if !found {
+			t.Errorf("no %s count found in the output", c.name)
+			continue
+		}
+		assert.Equal(t, *c.expected, got, "%s count", c.name)
+	}
+}
+
+// Names of the counts in the summaries.
+const (
+	countApplied    = "applied"
+	countPruned     = "pruned"
+	countReconciled = "reconciled"
+	countFailed     = "failed"
+)
+
+// summaryCountRegexp matches the counts in a summary line, with the number
+// either before or after the name of the count, e.g. `3 resource(s)
+// applied`, `0 failed to prune` or `failed: 0`.
+var summaryCountRegexp = regexp.MustCompile(
+	`(?i)(\d+)\s+(?:resources?(?:\(s\))?\s+)?(applied|pruned|reconciled|failed)\b|` +
+		`\b(applied|pruned|reconciled|failed)\s*[:=]?\s*(\d+)`)
 
-func substituteResourceVersion(text string) string {
-	return resourceVersionRegexp.ReplaceAllLiteralString(text, "resourceVersion: \"<RV>\"")
+// collectCounts returns the counts of the summaries in the output, summed
+// up if there are multiple summaries of the same kind. The failed count
+// is the sum of the failures of all summaries. Both the events and the json
+// output formats are supported.
+func collectCounts(t *testing.T, text string) map[string]int {
+	observed := make(map[string]int)
+	add := func(name string, n int) {
+		observed[name] += n
+	}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := scanner.Text()
+		var e struct {
+			Type       string `json:"type"`
+			EventType  string `json:"eventType"`
+			Count      int    `json:"count"`
+			Failed     int    `json:"failed"`
+			FailedCnt  int    `json:"failedCount"`
+			Pruned     int    `json:"pruned"`
+			Reconciled int    `json:"reconciled"`
+		}
+		if json.Unmarshal([]byte(line), &e) == nil {
+			if e.EventType != "completed" {
+				continue
+			}
+			switch e.Type {
+			case "apply":
+				add(countApplied, e.Count)
+				add(countFailed, e.FailedCnt)
+			case "prune":
+				add(countPruned, e.Pruned)
+				add(countFailed, e.Failed)
+			case "wait":
+				add(countReconciled, e.Reconciled)
+				add(countFailed, e.Failed)
+			}
+			continue
+		}
+		if !strings.Contains(strings.ToLower(line), "resource") {
+			// only summary lines count resources
+			continue
+		}
+		kind := ""
+		counts := map[string]int{}
+		for _, m := range summaryCountRegexp.FindAllStringSubmatch(line, -1) {
+			num, name := m[1], m[2]
+			if num == "" {
+				num, name = m[4], m[3]
+			}
+			n, err := strconv.Atoi(num)
+			if err != nil {
+				continue
+			}
+			name = strings.ToLower(name)
+			if _, found := counts[name]; found {
+				continue
+			}
+			counts[name] = n
+			if kind == "" && name != countFailed {
+				kind = name
+			}
+		}
+		if kind == "" {
+			continue
+		}
+		add(kind, counts[kind])
+		add(countFailed, counts[countFailed])
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("error scanning output: %v", err)
+	}
+	return observed
 }
 
-var statuses = []status.Status{
-	status.InProgressStatus,
-	status.CurrentStatus,
-	status.FailedStatus,
-	status.TerminatingStatus,
-	status.UnknownStatus,
-	status.NotFoundStatus,
+func isStatus(s string) bool {
+	for _, st := range statuses {
+		if st.String() == s {
+			return true
+		}
+	}
+	return false
 }
 
 func removeStatusEvents(t *testing.T, text string) string {
@@ -0,0 +1,485 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package live
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFakeBin writes an executable shell script with the given body.
+func writeFakeBin(t *testing.T, name, body string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake binaries are shell scripts")
+	}
+	p := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(p, []byte("#!/bin/sh\n"+body+"\n"), 0700); err != nil {
+		t.Fatalf("error writing fake binary: %v", err)
+	}
+	return p
+}
+
+func newTestDir(t *testing.T) string {
+	dir := t.TempDir()
+	for _, d := range []string{"resources", "pre-apply"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0700); err != nil {
+			t.Fatalf("error creating test dir: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestRunner_Bins(t *testing.T) {
+	dir := newTestDir(t)
+	marker := filepath.Join(t.TempDir(), "kubectl-args")
+
+	r := &Runner{
+		Config: TestCaseConfig{
+			KptArgs: []string{"live", "apply"},
+		},
+		Path:       dir,
+		KptBin:     writeFakeBin(t, "kpt", `echo "fake kpt $@ in $(basename $PWD)"`),
+		KubectlBin: writeFakeBin(t, "kubectl", `echo "$@" > `+marker),
+	}
+
+	r.RunPreApply(t)
+	b, err := ioutil.ReadFile(marker)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "apply -f "+filepath.Join(dir, "pre-apply"), strings.TrimSpace(string(b)))
+
+	stdout, _, err := r.RunApply(t)
+	assert.NoError(t, err)
+	assert.Equal(t, "fake kpt live apply in resources\n", stdout)
+}
+
+func TestRunner_DryRun(t *testing.T) {
+	dir := newTestDir(t)
+	marker := filepath.Join(t.TempDir(), "kubectl-args")
+
+	r := &Runner{
+		Config: TestCaseConfig{
+			KptArgs: []string{"live", "apply"},
+			DryRun:  true,
+			StdOut:  "fake kpt live apply --dry-run",
+			Inventory: []InventoryEntry{
+				{Kind: "ConfigMap", Name: "cm", Namespace: "test"},
+			},
+		},
+		Path:       dir,
+		KptBin:     writeFakeBin(t, "kpt", `echo "fake kpt $@"`),
+		KubectlBin: writeFakeBin(t, "kubectl", `echo "$@" >> `+marker),
+	}
+	r.Run(t)
+
+	// neither the pre-apply resources nor the inventory are looked at
+	_, err := os.Stat(marker)
+	assert.True(t, os.IsNotExist(err), "kubectl must not be run")
+
+	// --dry-run isn't added twice
+	r.Config.KptArgs = []string{"live", "apply", "--dry-run=true"}
+	assert.Equal(t, []string{"live", "apply", "--dry-run=true"}, r.kptArgs())
+}
+
+func TestRunner_Events(t *testing.T) {
+	r := &Runner{
+		KubectlBin: writeFakeBin(t, "kubectl", `echo "kubectl $@"`),
+	}
+	events, err := r.events("test")
+	assert.NoError(t, err)
+	assert.Equal(t, "kubectl get events -n test --sort-by=.lastTimestamp\n", events)
+
+	r.KubectlBin = writeFakeBin(t, "kubectl", `echo "No resources found" >&2; exit 1`)
+	_, err = r.events("test")
+	if assert.Error(t, err) {
+		assert.Equal(t, "exit status 1: No resources found", err.Error())
+	}
+}
+
+func TestRunner_VerifyAssertions(t *testing.T) {
+	kubectl := writeFakeBin(t, "kubectl", `cat <<EOF
+{
+  "apiVersion": "apps/v1",
+  "kind": "Deployment",
+  "metadata": {
+    "name": "$3",
+    "namespace": "$7",
+    "uid": "8f3c2d1e-4b5a-4c6d-9e8f-0a1b2c3d4e5f",
+    "creationTimestamp": "2022-01-01T00:00:00Z"
+  },
+  "spec": {"replicas": 3},
+  "status": {"conditions": [{"type": "Available", "status": "True"}]}
+}
+EOF`)
+
+	r := &Runner{
+		Config: TestCaseConfig{
+			Assertions: []Assertion{
+				{
+					Group:     "apps",
+					Kind:      "Deployment",
+					Name:      "nginx",
+					Namespace: "test",
+					Fields: map[string]string{
+						".spec.replicas":               "3",
+						"{.status.conditions[0].type}": "Available",
+						".metadata.namespace":          "test",
+						".metadata.uid":                "<UID>",
+						".metadata.creationTimestamp":  "<TIMESTAMP>",
+					},
+				},
+			},
+		},
+		KubectlBin: kubectl,
+	}
+	r.VerifyAssertions(t)
+}
+
+// recordingT records the errors of failed assertions.
+type recordingT struct {
+	errors []string
+}
+
+func (rt *recordingT) Errorf(format string, args ...interface{}) {
+	rt.errors = append(rt.errors, fmt.Sprintf(format, args...))
+}
+
+func TestCompareInventory(t *testing.T) {
+	inventory := []InventoryEntry{
+		{Kind: "ConfigMap", Name: "cm", Namespace: "test"},
+		{Group: "apps", Kind: "Deployment", Name: "nginx", Namespace: "test"},
+		{Kind: "Namespace", Name: "test"},
+	}
+
+	testCases := map[string]struct {
+		expected []InventoryEntry
+		subset   bool
+		ok       bool
+	}{
+		"exact match in a different order": {
+			expected: []InventoryEntry{
+				{Kind: "Namespace", Name: "test"},
+				{Kind: "ConfigMap", Name: "cm", Namespace: "test"},
+				{Group: "apps", Kind: "Deployment", Name: "nginx", Namespace: "test"},
+			},
+			ok: true,
+		},
+		"subset without subset mode": {
+			expected: []InventoryEntry{
+				{Kind: "ConfigMap", Name: "cm", Namespace: "test"},
+			},
+		},
+		"subset": {
+			expected: []InventoryEntry{
+				{Kind: "ConfigMap", Name: "cm", Namespace: "test"},
+			},
+			subset: true,
+			ok:     true,
+		},
+		"subset with missing entry": {
+			expected: []InventoryEntry{
+				{Kind: "ConfigMap", Name: "cm", Namespace: "test"},
+				{Kind: "ConfigMap", Name: "missing", Namespace: "test"},
+			},
+			subset: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			rt := &recordingT{}
+			actual := append([]InventoryEntry{}, inventory...)
+			assert.Equal(t, tc.ok, compareInventory(rt, tc.expected, actual, tc.subset))
+			assert.Equal(t, tc.ok, len(rt.errors) == 0, rt.errors)
+		})
+	}
+}
+
+func TestRunner_UpdateExpected(t *testing.T) {
+	dir := newTestDir(t)
+	config := `# Copyright 2022 Google LLC
+
+parallel: true
+
+kptArgs:
+  - "live"
+  - "apply"
+
+stdOut: |
+  outdated
+stdErr: |
+  outdated
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0600); err != nil {
+		t.Fatalf("error writing config: %v", err)
+	}
+	t.Setenv(UpdateExpectedEnv, "true")
+
+	r := &Runner{
+		Config: ReadTestCaseConfig(t, dir),
+		Path:   dir,
+	}
+	r.VerifyStdout(t, "deployment.apps/nginx created\ncreated at 2022-01-01T00:00:00Z\n")
+	r.VerifyStderr(t, "")
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "config.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `# Copyright 2022 Google LLC
+
+parallel: true
+kptArgs:
+  - "live"
+  - "apply"
+stdOut: |
+  deployment.apps/nginx created
+  created at <TIMESTAMP>
+`, string(b))
+	assert.Equal(t, "deployment.apps/nginx created\ncreated at <TIMESTAMP>",
+		strings.TrimSpace(ReadTestCaseConfig(t, dir).StdOut))
+}
+
+func TestRunner_Substitutions(t *testing.T) {
+	r := &Runner{
+		Config: TestCaseConfig{
+			Substitutions: []Substitution{
+				{Pattern: `nginx-[a-z0-9]{5}`, Replacement: "nginx-<SUFFIX>"},
+				{Pattern: `(\d{1,3}\.){3}\d{1,3}`, Replacement: "<IP>"},
+			},
+		},
+	}
+	assert.Equal(t, "pod/nginx-<SUFFIX> created at <TIMESTAMP> with ip <IP>",
+		r.prepOutput(t, "pod/nginx-x7k2p created at 2022-01-01T00:00:00Z with ip 10.244.0.12\n"))
+
+	_, err := TestCaseConfig{
+		Substitutions: []Substitution{{Pattern: "nginx-[a-z"}},
+	}.compileSubstitutions()
+	assert.EqualError(t, err, "invalid substitution pattern \"nginx-[a-z\": "+
+		"error parsing regexp: missing closing ]: `[a-z`")
+}
+
+func TestRunner_NamespacePlaceholder(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "apply-in-namespace")
+	stdout := `namespace/apply-in-namespace unchanged
+configmap/apply-in-namespace-config created
+deployment.apps/nginx created in namespace apply-in-namespace
+{"type":"apply","name":"nginx","namespace":"apply-in-namespace"}
+2 resource(s) applied. 1 created, 1 unchanged, 0 configured, 0 failed
+`
+
+	r := &Runner{
+		Config: TestCaseConfig{
+			StdOut: `namespace/<NAMESPACE> unchanged
+configmap/apply-in-namespace-config created
+deployment.apps/nginx created in namespace <NAMESPACE>
+{"type":"apply","name":"nginx","namespace":"<NAMESPACE>"}
+2 resource(s) applied. 1 created, 1 unchanged, 0 configured, 0 failed`,
+		},
+		Path:                 dir,
+		NamespacePlaceholder: "<NAMESPACE>",
+	}
+	r.VerifyStdout(t, stdout)
+
+	// the namespace is kept without a placeholder
+	r = &Runner{Path: dir}
+	assert.Equal(t, strings.TrimSpace(stdout), r.prepOutput(t, stdout))
+}
+
+func TestRunner_VerifyStatuses(t *testing.T) {
+	stdout := `deployment.apps/nginx created
+deployment.apps/nginx is InProgress: Replicas: 0/1
+deployment.apps/nginx is Current: Deployment is available. Replicas: 1
+{"type":"status","eventType":"resourceStatus","group":"","kind":"ConfigMap","name":"cm","namespace":"test","status":"Current","message":"Resource is always ready"}
+1 resource(s) applied. 1 created, 0 unchanged, 0 configured, 0 failed
+`
+	assert.Equal(t, map[string]string{
+		"deployment.apps/nginx": "Current",
+		"configmap/cm":          "Current",
+	}, collectStatuses(t, stdout))
+
+	r := &Runner{
+		Config: TestCaseConfig{
+			StdOut: `deployment.apps/nginx created
+1 resource(s) applied. 1 created, 0 unchanged, 0 configured, 0 failed`,
+			ExpectedStatuses: []ResourceStatus{
+				{Resource: "deployment.apps/nginx", Status: "Current"},
+				{Resource: "configmap/cm", Status: "Current"},
+			},
+		},
+	}
+	r.VerifyStdout(t, stdout)
+	r.VerifyStatuses(t, stdout)
+}
+
+func TestRunner_VerifyCounts(t *testing.T) {
+	testCases := map[string]struct {
+		stdout   string
+		expected map[string]int
+	}{
+		"events": {
+			stdout: `configmap/cm created
+1 resource(s) applied. 1 created, 0 unchanged, 0 configured, 0 failed
+deployment.apps/second-nginx pruned
+deployment.apps/first-nginx pruned
+2 resource(s) pruned, 0 skipped, 1 failed to prune
+deployment.apps/first-nginx reconciled
+3 resource(s) reconciled, 0 skipped, 2 failed to reconcile, 0 timed out
+`,
+			expected: map[string]int{
+				"applied":    1,
+				"pruned":     2,
+				"reconciled": 3,
+				"failed":     3,
+			},
+		},
+		"reworded summaries": {
+			stdout: `Resources: applied=4, failed=1
+pruned: 0 resources, skipped: 0
+`,
+			expected: map[string]int{
+				"applied": 4,
+				"pruned":  0,
+				"failed":  1,
+			},
+		},
+		"json": {
+			stdout: `{"eventType":"resourceApplied","group":"","kind":"ConfigMap","name":"cm","namespace":"test","operation":"Created","type":"apply"}
+{"configuredCount":0,"count":2,"createdCount":2,"eventType":"completed","failedCount":0,"serverSideCount":0,"type":"apply","unchangedCount":0}
+{"eventType":"completed","failed":0,"pruned":2,"skipped":0,"type":"prune"}
+{"eventType":"completed","failed":1,"reconciled":4,"skipped":0,"timeout":0,"type":"wait"}
+`,
+			expected: map[string]int{
+				"applied":    2,
+				"pruned":     2,
+				"reconciled": 4,
+				"failed":     1,
+			},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tc.expected, collectCounts(t, tc.stdout))
+		})
+	}
+
+	applied, failed := 1, 0
+	r := &Runner{
+		Config: TestCaseConfig{
+			Counts: &Counts{Applied: &applied, Failed: &failed},
+		},
+	}
+	r.VerifyCounts(t, `configmap/cm created
+1 resource(s) applied. 1 created, 0 unchanged, 0 configured, 0 failed
+`)
+}
+
+func TestRunner_Destroy(t *testing.T) {
+	dir := newTestDir(t)
+	state := filepath.Join(t.TempDir(), "applied")
+
+	r := &Runner{
+		Config: TestCaseConfig{
+			KptArgs: []string{"live", "apply"},
+			StdOut:  "applied",
+			Inventory: []InventoryEntry{
+				{Kind: "ConfigMap", Name: "cm", Namespace: "test"},
+				{Group: "apps", Kind: "Deployment", Name: "nginx", Namespace: "test"},
+			},
+			Destroy: true,
+		},
+		Path: dir,
+		KptBin: writeFakeBin(t, "kpt", `if [ "$2" = destroy ]; then
+  [ "$(basename $PWD)" = resources ] || exit 1
+  rm `+state+`
+else
+  touch `+state+`
+  echo applied
+fi`),
+		// the resources exist until they are destroyed
+		KubectlBin: writeFakeBin(t, "kubectl", `[ "$1" = get ] || exit 0
+if [ ! -f `+state+` ]; then
+  [ "$2" = resourcegroups.kpt.dev ] || exit 0
+  echo "NotFound" >&2
+  exit 1
+fi
+if [ "$2" = resourcegroups.kpt.dev ]; then cat <<EOF
+spec:
+  resources:
+  - group: ""
+    kind: ConfigMap
+    name: cm
+    namespace: test
+  - group: apps
+    kind: Deployment
+    name: nginx
+    namespace: test
+EOF
+else
+  echo "$2/$3"
+fi`),
+	}
+	r.Run(t)
+	_, err := os.Stat(state)
+	assert.True(t, os.IsNotExist(err), "kpt live destroy must be run")
+}
+
+func TestRunner_VerifySubstrings(t *testing.T) {
+	stdout := `configmap/cm created at 2022-01-01T00:00:00Z
+deployment.apps/nginx created
+2 resource(s) applied. 2 created, 0 unchanged, 0 configured, 0 failed
+`
+	r := &Runner{
+		Config: TestCaseConfig{
+			StdOutContains:    []string{"cm created at <TIMESTAMP>", "2 resource(s) applied"},
+			StdOutNotContains: []string{"failed to apply"},
+			StdErrContains:    []string{"warning"},
+		},
+	}
+	r.VerifyStdout(t, stdout)
+	r.VerifyStderr(t, "warning: unknown field\n")
+
+	rt := &recordingT{}
+	verifySubstrings(rt, "stdout", r.prepOutput(t, stdout),
+		[]string{"pruned"}, []string{"nginx created"})
+	assert.Len(t, rt.errors, 2)
+
+	err := TestCaseConfig{
+		StdOut:         "configmap/cm created",
+		StdOutContains: []string{"created"},
+	}.validate()
+	assert.EqualError(t, err, "stdOut can't be used together with stdOutContains or stdOutNotContains")
+
+	err = TestCaseConfig{
+		StdErr:            "warning",
+		StdErrNotContains: []string{"error"},
+	}.validate()
+	assert.EqualError(t, err, "stdErr can't be used together with stdErrContains or stdErrNotContains")
+
+	// the streams are validated independently
+	assert.NoError(t, TestCaseConfig{
+		StdOut:         "configmap/cm created",
+		StdErrContains: []string{"warning"},
+	}.validate())
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package live
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// DefaultMaxParallel is the default number of tests a SuiteRunner runs at
+// the same time.
+const DefaultMaxParallel = 4
+
+// SuiteRunner runs a set of tests against the same cluster. Tests with
+// Parallel set in their config run concurrently after all other tests
+// have run.
+type SuiteRunner struct {
+	// Runners are the tests in the suite.
+	Runners []*Runner
+
+	// MaxParallel is the maximum number of tests that run at the same time.
+	// Default: DefaultMaxParallel
+	MaxParallel int
+}
+
+// Run executes all tests in the suite. Every test runs as a subtest in its
+// own namespace, so a failing test doesn't stop the other tests. The
+// failed tests are reported at the end.
+func (s *SuiteRunner) Run(t *testing.T) {
+	maxParallel := s.MaxParallel
+	if maxParallel <= 0 {
+		maxParallel = DefaultMaxParallel
+	}
+	sem := make(chan struct{}, maxParallel)
+
+	var mu sync.Mutex
+	var failed []string
+
+	// The parallel subtests only run once the function passed to t.Run
+	// returns, and t.Run waits for them to complete.
+	t.Run("suite", func(t *testing.T) {
+		for i := range s.Runners {
+			r := s.Runners[i]
+			name := filepath.Base(r.Path)
+			t.Run(name, func(t *testing.T) {
+				if r.Config.Parallel {
+					t.Parallel()
+				}
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				defer func() {
+					if t.Failed() {
+						mu.Lock()
+						failed = append(failed, name)
+						mu.Unlock()
+					}
+				}()
+				s.runTest(t, r)
+			})
+		}
+	})
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		t.Errorf("%d of %d tests failed: %s", len(failed), len(s.Runners),
+			strings.Join(failed, ", "))
+	}
+}
+
+func (s *SuiteRunner) runTest(t *testing.T, r *Runner) {
+	if r.Config.NoResourceGroup {
+		if r.Config.Parallel {
+			t.Fatalf("parallel tests can not modify the ResourceGroup CRD")
+		}
+		if CheckIfResourceGroupInstalled(t) {
+			RemoveResourceGroup(t)
+		}
+	} else {
+		InstallResourceGroup(t)
+	}
+
+	ns := r.Namespace()
+	CreateNamespace(t, ns)
+	defer RemoveNamespace(t, ns)
+
+	r.Run(t)
+}
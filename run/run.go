@@ -25,9 +25,11 @@ import (
 
 	kptcommands "github.com/GoogleContainerTools/kpt/commands"
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/overview"
+	"github.com/GoogleContainerTools/kpt/internal/errors/resolver"
 	"github.com/GoogleContainerTools/kpt/internal/printer"
 	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
 	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/slice"
 	"sigs.k8s.io/kustomize/kyaml/commandutil"
 )
 
@@ -94,6 +96,17 @@ func GetMain(ctx context.Context) *cobra.Command {
 	cmd.PersistentFlags().BoolVar(&cmdutil.StackOnError, "stack-trace", false,
 		"Print a stack-trace on failure")
 
+	// colored error messages
+	cmd.PersistentFlags().StringVar(&cmdutil.Color, "color", resolver.ColorAuto,
+		fmt.Sprintf("Colorize the output, e.g. error messages. One of %s", strings.Join(resolver.SupportedColorModes, ", ")))
+	cmd.PersistentPreRunE = func(*cobra.Command, []string) error {
+		if !slice.ContainsString(resolver.SupportedColorModes, cmdutil.Color, nil) {
+			return fmt.Errorf("invalid color %q: supported values are: %s",
+				cmdutil.Color, strings.Join(resolver.SupportedColorModes, ", "))
+		}
+		return nil
+	}
+
 	if _, err := exec.LookPath("git"); err != nil {
 		fmt.Fprintf(os.Stderr, "kpt requires that `git` is installed and on the PATH")
 		os.Exit(1)
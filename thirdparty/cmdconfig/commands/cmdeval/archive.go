@@ -0,0 +1,170 @@
+// Copyright 2022 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmdeval
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// archiveExtensions are the extensions of the gzipped tarballs fn eval
+// extracts packages from.
+var archiveExtensions = []string{".tar.gz", ".tgz"}
+
+// isArchive returns true if path refers to a gzipped tarball by its extension.
+func isArchive(path string) bool {
+	lower := strings.ToLower(path)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractArchive extracts the gzipped tarball archive into dir. Only
+// directories and regular files are supported, and entries must not refer to
+// paths outside of dir.
+func extractArchive(archive, dir string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("cannot read archive %q: %w", archive, err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("cannot read archive %q: %w", archive, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read archive %q: %w", archive, err)
+		}
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("archive %q contains %q outside of the package", archive, hdr.Name)
+		}
+		target := filepath.Join(dir, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := writeArchiveFile(tr, target, hdr.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("archive %q contains %q, which is neither a file nor a directory", archive, hdr.Name)
+		}
+	}
+}
+
+func writeArchiveFile(r io.Reader, path string, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// packageInArchive returns the directory of the package extracted into dir.
+// Packages are usually archived with their directory, so if dir only
+// contains a single directory, it is the package.
+func packageInArchive(dir string) (string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name()), nil
+	}
+	return dir, nil
+}
+
+// writeArchive writes the content of dir as a gzipped tarball to archive.
+// The archive is written to a temporary file first, so an existing archive
+// is only replaced once the new one is complete. The archive keeps the mode
+// of the existing archive, or gets mode 0644 if it is new.
+func writeArchive(dir, archive string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(archive); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(archive), ".kpt-"+filepath.Base(archive)+"-")
+	if err != nil {
+		return fmt.Errorf("cannot write archive %q: %w", archive, err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	for _, c := range []io.Closer{tw, gz, tmp} {
+		if closeErr := c.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil {
+		// the temporary file is only readable by the user
+		err = os.Chmod(tmp.Name(), mode)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot write archive %q: %w", archive, err)
+	}
+	if err := os.Rename(tmp.Name(), archive); err != nil {
+		return fmt.Errorf("cannot write archive %q: %w", archive, err)
+	}
+	return nil
+}
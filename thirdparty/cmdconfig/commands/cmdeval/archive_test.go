@@ -0,0 +1,134 @@
+// Copyright 2022 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmdeval
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsArchive(t *testing.T) {
+	assert.True(t, isArchive("pkg.tar.gz"))
+	assert.True(t, isArchive("dir/pkg.TGZ"))
+	assert.False(t, isArchive("pkg.tar"))
+	assert.False(t, isArchive("pkg"))
+	assert.False(t, isArchive("-"))
+}
+
+func TestWriteArchive(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"pkg/Kptfile":         "kind: Kptfile\n",
+		"pkg/sub/deploy.yaml": "kind: Deployment\n",
+	}
+	for path, content := range files {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700)) ||
+			!assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+	archive := filepath.Join(t.TempDir(), "pkg.tar.gz")
+	if !assert.NoError(t, writeArchive(dir, archive)) {
+		t.FailNow()
+	}
+
+	extracted := t.TempDir()
+	if !assert.NoError(t, extractArchive(archive, extracted)) {
+		t.FailNow()
+	}
+	for path, content := range files {
+		b, err := ioutil.ReadFile(filepath.Join(extracted, filepath.FromSlash(path)))
+		if assert.NoError(t, err) {
+			assert.Equal(t, content, string(b))
+		}
+	}
+	pkg, err := packageInArchive(extracted)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(extracted, "pkg"), pkg)
+
+	// an archive of the package content is the package itself
+	pkg, err = packageInArchive(filepath.Join(extracted, "pkg"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(extracted, "pkg"), pkg)
+}
+
+func TestWriteArchive_mode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes aren't supported")
+	}
+	dir := t.TempDir()
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Kptfile"), []byte("kind: Kptfile\n"), 0600)) {
+		t.FailNow()
+	}
+	archive := filepath.Join(t.TempDir(), "pkg.tar.gz")
+
+	// a new archive is readable by everyone
+	if !assert.NoError(t, writeArchive(dir, archive)) {
+		t.FailNow()
+	}
+	info, err := os.Stat(archive)
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	}
+
+	// an existing archive keeps its mode
+	if !assert.NoError(t, os.Chmod(archive, 0640)) ||
+		!assert.NoError(t, writeArchive(dir, archive)) {
+		t.FailNow()
+	}
+	info, err = os.Stat(archive)
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	}
+}
+
+func TestExtractArchive_invalidEntries(t *testing.T) {
+	testCases := map[string]struct {
+		hdr      tar.Header
+		expected string
+	}{
+		"outside of the package": {
+			hdr:      tar.Header{Name: "../escape.yaml", Typeflag: tar.TypeReg, Mode: 0600},
+			expected: `contains "../escape.yaml" outside of the package`,
+		},
+		"absolute path": {
+			hdr:      tar.Header{Name: "/etc/escape.yaml", Typeflag: tar.TypeReg, Mode: 0600},
+			expected: `contains "/etc/escape.yaml" outside of the package`,
+		},
+		"symlink": {
+			hdr:      tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+			expected: `contains "link", which is neither a file nor a directory`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), "pkg.tgz")
+			f, err := os.Create(archive)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			gz := gzip.NewWriter(f)
+			tw := tar.NewWriter(gz)
+			hdr := tc.hdr
+			assert.NoError(t, tw.WriteHeader(&hdr))
+			assert.NoError(t, tw.Close())
+			assert.NoError(t, gz.Close())
+			assert.NoError(t, f.Close())
+
+			err = extractArchive(archive, t.TempDir())
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.expected)
+			}
+		})
+	}
+}
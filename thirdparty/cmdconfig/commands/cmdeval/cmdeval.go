@@ -8,34 +8,63 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	kpterrors "github.com/GoogleContainerTools/kpt/internal/errors"
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
 	"github.com/GoogleContainerTools/kpt/internal/pkg"
 	"github.com/GoogleContainerTools/kpt/internal/printer"
 	"github.com/GoogleContainerTools/kpt/internal/util/argutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/diff"
 	"github.com/GoogleContainerTools/kpt/internal/util/pathutil"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	kptfile "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 	"github.com/GoogleContainerTools/kpt/thirdparty/cmdconfig/commands/runner"
 	"github.com/GoogleContainerTools/kpt/thirdparty/kyaml/runfn"
-	"github.com/google/shlex"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 	"sigs.k8s.io/kustomize/kyaml/errors"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
 	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
+// allowExecEnv is the environment variable which allows exec functions like
+// --allow-exec if it's true.
+const allowExecEnv = "KPT_FN_ALLOW_EXEC"
+
+// imagePrefixEnv is the environment variable --image-prefix defaults to, e.g.
+// to use a mirror of the function catalog.
+const imagePrefixEnv = "KPT_FN_IMAGE_PREFIX"
+
+// errExecNotAllowed is returned for exec functions without --allow-exec, so
+// local executables are only run if that's deliberately enabled.
+var errExecNotAllowed = fmt.Errorf("exec functions are disabled; pass --allow-exec to enable")
+
+// The formats the results of the functions are printed in.
+const (
+	textResultsFormat = "text"
+	jsonResultsFormat = "json"
+)
+
 // GetEvalFnRunner returns a EvalFnRunner.
 func GetEvalFnRunner(ctx context.Context, parent string) *EvalFnRunner {
 	r := &EvalFnRunner{Ctx: ctx}
 	c := &cobra.Command{
-		Use:     "eval [DIR | -] [flags] [--fn-args]",
+		Use:     "eval [DIR... | -] [flags] [--fn-args]",
 		Short:   docs.EvalShort,
 		Long:    docs.EvalShort + "\n" + docs.EvalLong,
 		Example: docs.EvalExamples,
@@ -44,7 +73,9 @@ func GetEvalFnRunner(ctx context.Context, parent string) *EvalFnRunner {
 	}
 	r.Command = c
 	r.Command.Flags().StringVarP(&r.Dest, "output", "o", "",
-		fmt.Sprintf("output resources are written to provided location. Allowed values: %s|%s|<OUT_DIR_PATH>", cmdutil.Stdout, cmdutil.Unwrap))
+		fmt.Sprintf("output resources are written to provided location. Allowed values: %s|%s|%s|<OUT_DIR_PATH>", cmdutil.Stdout, cmdutil.Unwrap, cmdutil.ResourceList))
+	r.Command.Flags().BoolVar(&r.SortOutput, "sort-output", false,
+		"order the output resources by apiVersion, kind, namespace and name, and write each resource to its own file in an output directory")
 	r.Command.Flags().StringVarP(
 		&r.Image, "image", "i", "", "run this image as a function")
 	_ = r.Command.RegisterFlagCompletionFunc("image", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -63,40 +94,126 @@ func GetEvalFnRunner(ctx context.Context, parent string) *EvalFnRunner {
 	r.Command.Flags().BoolVarP(
 		&r.SaveFn, "save", "s", false,
 		"save the function and its arguments to Kptfile")
+	r.Command.Flags().BoolVar(
+		&r.UpdateFn, "update", false,
+		"with --save, update the config and selectors of the function already in the Kptfile instead of replacing it")
+	r.Command.Flags().StringVar(
+		&r.SaveAs, "save-as", "",
+		"`mutator` or `validator`. save the function as this type instead of detecting it from the labels of the image")
+	_ = r.Command.RegisterFlagCompletionFunc("save-as", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"mutator", "validator"}, cobra.ShellCompDirectiveDefault
+	})
 	r.Command.Flags().StringVar(
 		&r.Exec, "exec", "", "run an executable as a function")
+	r.Command.Flags().StringVar(
+		&r.PipelinePath, "pipeline", "", "run the list of functions in this file in order, like the pipeline of a Kptfile")
 	r.Command.Flags().StringVar(
 		&r.FnConfigPath, "fn-config", "", "path to the function config file")
+	r.Command.Flags().BoolVar(
+		&r.FnConfigRelative, "fn-config-relative", false, "resolve a relative --fn-config path against the package directory instead of the current directory")
+	r.Command.Flags().StringVar(
+		&r.FnConfigKind, "fn-config-kind", "", "kind of the function config created from the function arguments (default ConfigMap)")
+	r.Command.Flags().StringVar(
+		&r.FnConfigAPIVersion, "fn-config-api-version", "", "apiVersion of the function config created from the function arguments (default v1)")
 	r.Command.Flags().BoolVarP(
 		&r.IncludeMetaResources, "include-meta-resources", "m", false, "include package meta resources in function input")
 	r.Command.Flags().StringVar(
 		&r.ResultsDir, "results-dir", "", "write function results to this dir")
+	r.Command.Flags().StringVar(
+		&r.JUnitReport, "junit-report", "", "write function results to this file as a JUnit XML report")
+	r.Command.Flags().StringVar(
+		&r.ResultsFormat, "results-format", textResultsFormat,
+		"format to print the function results in, `text` or `json`. json prints the results to stderr once the functions completed instead of their status")
 	r.Command.Flags().BoolVar(
 		&r.Network, "network", false, "enable network access for functions that declare it")
 	r.Command.Flags().StringArrayVar(
 		&r.Mounts, "mount", []string{},
-		"a list of storage options read from the filesystem")
+		"a list of bind mounts in the format type=bind,src=<path>,dst=<path>[,rw=true], read-only by default")
+	r.Command.Flags().StringVar(
+		&r.ContextDir, "context-dir", "",
+		fmt.Sprintf("directory mounted read-only at %s as the working directory of container functions, so they can read files from outside of the package", runfn.ContextDirMountPath))
+	r.Command.Flags().BoolVar(
+		&r.IgnoreMountErrors, "ignore-mount-errors", false,
+		"skip mounts whose source doesn't exist with a warning instead of failing")
 	r.Command.Flags().StringArrayVarP(
 		&r.Env, "env", "e", []string{},
 		"a list of environment variables to be used by functions")
+	r.Command.Flags().BoolVar(
+		&r.AllowExec, "allow-exec", false,
+		fmt.Sprintf("allow exec functions, which run local executables, also allowed if $%s is true", allowExecEnv))
+	r.Command.Flags().StringArrayVar(
+		&r.ExecEnv, "exec-env", nil,
+		"a list of environment variables to be used by exec functions, ignored for image functions")
+	r.Command.Flags().BoolVar(
+		&r.PrintEnv, "print-env", false,
+		"print the environment variables and the mounts of the container functions before executing them, with secret values masked")
+	r.Command.Flags().BoolVar(
+		&r.InjectPackagePath, "inject-package-path", false,
+		"export the absolute path of the package to the functions in the KPT_PACKAGE_PATH environment variable")
 	r.Command.Flags().BoolVar(
 		&r.AsCurrentUser, "as-current-user", false, "use the uid and gid that kpt is running with to run the function in the container")
+	r.Command.Flags().BoolVar(
+		&r.NoDocker, "no-docker", false, "fail instead of running container functions, only exec functions are allowed")
+	r.Command.Flags().BoolVar(
+		&r.Verbose, "verbose", false, "print what successful functions write to stderr")
+	r.Command.Flags().BoolVar(
+		&r.Quiet, "quiet", false, "don't print informational messages such as the progress of functions, errors are still printed")
+	r.Command.Flags().BoolVar(
+		&r.Strict, "strict", false,
+		"fail if the function returns results with warning severity, not only with error severity")
+	r.Command.Flags().BoolVar(
+		&r.CheckIdempotent, "check-idempotent", false,
+		"execute the function again on its output and fail if that changes the resources, nothing is written")
+	r.Command.Flags().BoolVar(
+		&r.FailFast, "fail-fast", false, "stop at the first package that fails when multiple directories are specified")
+	r.Command.Flags().BoolVar(
+		&r.FailOnEmpty, "fail-on-empty", false, "fail if the function selects no resources or doesn't output any resources")
+	r.Command.Flags().IntVar(
+		&r.MaxResults, "max-results", 0, "maximum number of function results to print and write to the results dir, 0 means unlimited")
+	r.Command.Flags().StringVar(
+		&r.ResultsIgnore, "results-ignore", "",
+		"path to a file listing the signatures of known function results, which are neither printed nor counted as failures")
+	r.Command.Flags().BoolVar(
+		&r.PreserveUnchanged, "preserve-unchanged", true, "don't rewrite files whose content isn't changed by the function")
 	r.Command.Flags().StringVar(&r.ImagePullPolicy, "image-pull-policy", string(fnruntime.IfNotPresentPull),
 		fmt.Sprintf("pull image before running the container. It must be one of %s, %s and %s.", fnruntime.AlwaysPull, fnruntime.IfNotPresentPull, fnruntime.NeverPull))
+	r.Command.Flags().StringVar(
+		&r.ImagePrefix, "image-prefix", "",
+		fmt.Sprintf("registry path short image names are resolved against, defaults to $%s or %s", imagePrefixEnv, fnruntime.DefaultImagePathPrefix))
+	r.Command.Flags().IntVar(
+		&r.PullRetries, "pull-retries", 0,
+		"number of times pulling the image is retried with exponential backoff after transient failures such as network errors")
+	r.Command.Flags().StringVar(
+		&r.RegistryAuth, "registry-auth", "",
+		"path to a docker config JSON file with the registry credentials to pull the images of container functions with")
+	r.Command.Flags().StringVar(
+		&r.CacheDir, "cache-dir", "", "directory to cache the outputs of container functions in and reuse them from for the same image digest and input")
+	r.Command.Flags().BoolVar(
+		&r.NoCache, "no-cache", false, "execute the function even if its output is cached in --cache-dir, and replace the cached output")
 
 	// selector flags
 	r.Command.Flags().StringVar(
 		&r.Selector.APIVersion, "match-api-version", "", "select resources matching the given apiVersion")
 	r.Command.Flags().StringVar(
 		&r.Selector.Kind, "match-kind", "", "select resources matching the given kind")
+	_ = r.Command.RegisterFlagCompletionFunc("match-kind", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return cmdutil.SuggestKinds(args), cobra.ShellCompDirectiveNoFileComp
+	})
 	r.Command.Flags().StringVar(
 		&r.Selector.Name, "match-name", "", "select resources matching the given name")
+	_ = r.Command.RegisterFlagCompletionFunc("match-name", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return cmdutil.SuggestNames(args, cmd.Flag("match-kind").Value.String()), cobra.ShellCompDirectiveNoFileComp
+	})
 	r.Command.Flags().StringVar(
 		&r.Selector.Namespace, "match-namespace", "", "select resources matching the given namespace")
 	r.Command.Flags().StringArrayVar(
 		&r.selectorAnnotations, "match-annotations", []string{}, "select resources matching the given annotations")
 	r.Command.Flags().StringArrayVar(
 		&r.selectorLabels, "match-labels", []string{}, "select resources matching the given labels")
+	r.Command.Flags().StringVar(
+		&r.FilePathSelector, "match-file", "", "select resources in files matching the given glob pattern, relative to the package root")
+	r.Command.Flags().StringVar(
+		&r.ChangedSince, "changed-since", "", "select resources in files added or modified since the given git ref")
 
 	// exclusion flags
 	r.Command.Flags().StringVar(
@@ -127,27 +244,81 @@ func EvalCommand(ctx context.Context, name string) *cobra.Command {
 type EvalFnRunner struct {
 	Command              *cobra.Command
 	Dest                 string
+	SortOutput           bool
 	OutContent           bytes.Buffer
 	FromStdin            bool
 	Image                string
 	SaveFn               bool
+	UpdateFn             bool
+	SaveAs               string
 	Keywords             []string
 	FnType               string
 	Exec                 string
+	AllowExec            bool
+	PipelinePath         string
 	FnConfigPath         string
+	FnConfigRelative     bool
+	FnConfigKind         string
+	FnConfigAPIVersion   string
 	RunFns               runfn.RunFns
 	ResultsDir           string
+	JUnitReport          string
+	ResultsFormat        string
 	ImagePullPolicy      string
+	ImagePrefix          string
+	PullRetries          int
+	RegistryAuth         string
+	CacheDir             string
+	NoCache              bool
 	Network              bool
 	Mounts               []string
+	ContextDir           string
+	IgnoreMountErrors    bool
 	Env                  []string
+	ExecEnv              []string
+	InjectPackagePath    bool
+	PrintEnv             bool
+	Strict               bool
 	AsCurrentUser        bool
+	NoDocker             bool
+	PreserveUnchanged    bool
+	MaxResults           int
+	ResultsIgnore        string
+	FailFast             bool
+	FailOnEmpty          bool
+	CheckIdempotent      bool
+	Verbose              bool
+	Quiet                bool
 	IncludeMetaResources bool
 	Ctx                  context.Context
 	Selector             kptfile.Selector
 	Exclusion            kptfile.Selector
+	FilePathSelector     string
+	ChangedSince         string
 	dataItems            []string
 
+	// Progress is called as each function completes. If it isn't set, the
+	// progress of pipelines is printed when stderr is a terminal, unless
+	// Quiet is set or the results are printed as json.
+	Progress fnruntime.ProgressFunc
+
+	// paths are the package directories the function is executed on
+	paths []string
+
+	// registryConfigDir is the temporary docker config directory containing
+	// the RegistryAuth file, which is removed after the execution
+	registryConfigDir string
+
+	// junitReport accumulates the results of the packages for JUnitReport
+	junitReport *fnruntime.JUnitReport
+
+	// archive is the gzipped tarball the package is read from, which is
+	// extracted to archiveDir. The package is written back to archiveDest,
+	// unless the resources are written to stdout.
+	archive     string
+	archiveDir  string
+	archiveDest string
+
 	// we will need to parse these values into Selector and Exclusion
 	selectorLabels      []string
 	selectorAnnotations []string
@@ -156,20 +327,306 @@ type EvalFnRunner struct {
 }
 
 func (r *EvalFnRunner) runE(c *cobra.Command, _ []string) error {
-	err := runner.HandleError(r.Ctx, r.RunFns.Execute())
+	defer r.removeRegistryConfigDir()
+	if r.PrintEnv {
+		r.printEnv()
+	}
+	if r.archive != "" {
+		return r.runArchive()
+	}
+	if len(r.paths) > 1 {
+		return r.runPkgs()
+	}
+	return r.runPkg()
+}
+
+// runPkgs executes the function on each of the package directories. Failures
+// don't stop the execution on the remaining packages unless FailFast is set.
+func (r *EvalFnRunner) runPkgs() error {
+	pr := printer.FromContextOrDie(r.Ctx)
+	resultsDir := r.RunFns.ResultsDir
+	var failed []string
+	for i, path := range r.paths {
+		pr.OptPrintf(printer.NewOpt().Informational(), "Package %q:\n", path)
+		r.RunFns.Path = path
+		r.RunFns.FnConfigPath = r.fnConfigPath(path)
+		if resultsDir != "" {
+			// keep the results of the packages apart
+			r.RunFns.ResultsDir = filepath.Join(resultsDir, fmt.Sprintf("%d-%s", i, filepath.Base(path)))
+			if err := os.MkdirAll(r.RunFns.ResultsDir, 0755); err != nil {
+				return fmt.Errorf("cannot create results dir %q: %w", r.RunFns.ResultsDir, err)
+			}
+		}
+		if err := r.runPkg(); err != nil {
+			if r.FailFast {
+				return err
+			}
+			if !kpterrors.Is(err, kpterrors.ErrAlreadyHandled) {
+				pr.Printf("Error: %v\n", err)
+			}
+			failed = append(failed, path)
+		}
+	}
+	pr.OptPrintf(printer.NewOpt().Informational(), "Successfully executed function on %d of %d package(s).\n",
+		len(r.paths)-len(failed), len(r.paths))
+	if len(failed) > 0 {
+		return fmt.Errorf("function failed on package(s): %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// runArchive executes the function on the package extracted from the archive
+// and writes the package to archiveDest. The extracted package is removed
+// even if the function fails, in which case no archive is written.
+func (r *EvalFnRunner) runArchive() error {
+	defer r.removeArchiveDir()
+	if err := r.runPkg(); err != nil {
+		return err
+	}
+	if r.archiveDest == "" {
+		return nil
+	}
+	return writeArchive(r.archiveDir, r.archiveDest)
+}
+
+// prepareArchive extracts the package from the gzipped tarball archive to a
+// temporary directory and returns the directory of the package.
+func (r *EvalFnRunner) prepareArchive(archive string) (string, error) {
+	if r.SaveFn {
+		return "", errors.Errorf("--save can't be used with an archive, as its Kptfile isn't kept")
+	}
+	if r.ChangedSince != "" {
+		return "", errors.Errorf("--changed-since can't be used with an archive, as it isn't in a git repository")
+	}
+	switch r.Dest {
+	case "":
+		r.archiveDest = archive
+	case cmdutil.Stdout, cmdutil.Unwrap, cmdutil.ResourceList:
+	default:
+		if !isArchive(r.Dest) {
+			return "", errors.Errorf("--output must be %s, %s, %s or an archive ending with %s when "+
+				"the package is an archive", cmdutil.Stdout, cmdutil.Unwrap, cmdutil.ResourceList,
+				strings.Join(archiveExtensions, " or "))
+		}
+		// the package is modified in place before being archived
+		r.archiveDest = r.Dest
+		r.Dest = ""
+	}
+	dir, err := ioutil.TempDir("", "kpt-fn-eval-")
+	if err != nil {
+		return "", fmt.Errorf("cannot create directory to extract archive %q: %w", archive, err)
+	}
+	r.archive = archive
+	r.archiveDir = dir
+	if err := extractArchive(archive, dir); err != nil {
+		return "", err
+	}
+	return packageInArchive(dir)
+}
+
+// removeArchiveDir removes the package extracted from the archive.
+func (r *EvalFnRunner) removeArchiveDir() {
+	if r.archiveDir != "" {
+		_ = os.RemoveAll(r.archiveDir)
+		r.archiveDir = ""
+	}
+}
+
+// runPkg executes the function on the package in RunFns.Path, or on the
+// resources from stdin.
+func (r *EvalFnRunner) runPkg() error {
+	if r.ChangedSince != "" {
+		files, err := diff.ChangedFiles(r.Ctx, r.RunFns.Path, r.ChangedSince)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			printer.FromContextOrDie(r.Ctx).OptPrintf(printer.NewOpt().Informational(),
+				"No files changed since %q, the function isn't executed.\n", r.ChangedSince)
+			return nil
+		}
+		r.RunFns.FilePaths = files
+	}
+	if r.CheckIdempotent {
+		return r.checkIdempotent()
+	}
+	ignored := r.RunFns.ResultsFilter.Ignored()
+	fns := r.RunFns
+	fns.Progress = r.progress()
+	if r.ResultsFormat == jsonResultsFormat {
+		// the results are printed as json instead of the status of the functions
+		pr := printer.FromContextOrDie(r.Ctx)
+		fns.Ctx = printer.WithContext(fns.Ctx, printer.New(pr.OutStream(), ioutil.Discard))
+	}
+	result, err := evalRunFns(fns)
+	if r.RunFns.ResultsFilter != nil {
+		printer.FromContextOrDie(r.Ctx).OptPrintf(printer.NewOpt().Informational(),
+			"Results listed in %q ignored: %d\n", r.ResultsIgnore, r.RunFns.ResultsFilter.Ignored()-ignored)
+	}
+	if r.JUnitReport != "" {
+		if reportErr := r.writeJUnitReport(result.Results); reportErr != nil {
+			return reportErr
+		}
+	}
+	if r.ResultsFormat == jsonResultsFormat {
+		if printErr := r.printJSONResults(result.Results); printErr != nil {
+			return printErr
+		}
+	}
+	err = runner.HandleError(r.Ctx, err)
 	if err != nil {
 		return err
 	}
-	if err = cmdutil.WriteFnOutput(r.Dest, r.OutContent.String(), r.FromStdin,
+	var strictErr error
+	if r.Strict {
+		if n := fnruntime.CountResults(result.Results, framework.Warning); n > 0 {
+			strictErr = fmt.Errorf("function returned %d warning result(s), which are failures with --strict", n)
+		}
+	}
+	var fnConfig *yaml.RNode
+	if r.Dest == cmdutil.ResourceList {
+		if fnConfig, err = r.functionConfig(); err != nil {
+			return err
+		}
+	}
+	content := r.OutContent.String()
+	if r.SortOutput {
+		isDir := r.Dest != "" && r.Dest != cmdutil.Stdout && r.Dest != cmdutil.Unwrap && r.Dest != cmdutil.ResourceList
+		if content, err = cmdutil.SortFnOutput(content, isDir); err != nil {
+			return err
+		}
+	}
+	if err = cmdutil.WriteFnOutput(r.Dest, content, r.FromStdin, fnConfig,
 		printer.FromContextOrDie(r.Ctx).OutStream()); err != nil {
 		return err
 	}
+	if strictErr != nil {
+		// the resources are written, so the warnings can be inspected
+		return runner.HandleError(r.Ctx, strictErr)
+	}
 	if r.SaveFn {
 		r.SaveFnToKptfile()
 	}
 	return nil
 }
 
+// printJSONResults prints the results to stderr as a FunctionResultList in
+// JSON on a single line.
+func (r *EvalFnRunner) printJSONResults(results *fnresult.ResultList) error {
+	b, err := yaml.Marshal(results)
+	if err != nil {
+		return err
+	}
+	node, err := yaml.Parse(string(b))
+	if err != nil {
+		return err
+	}
+	j, err := node.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(printer.FromContextOrDie(r.Ctx).ErrStream(), "%s\n", j)
+	return err
+}
+
+// progress returns the ProgressFunc of the functions. It's Progress if set,
+// otherwise the progress of pipelines is printed to a terminal.
+func (r *EvalFnRunner) progress() fnruntime.ProgressFunc {
+	if r.Progress != nil {
+		return r.Progress
+	}
+	if r.Quiet || r.ResultsFormat == jsonResultsFormat {
+		return nil
+	}
+	return func(e fnruntime.ProgressEvent) {
+		if isTerminal(printer.FromContextOrDie(r.Ctx).ErrStream()) {
+			r.printProgress(e)
+		}
+	}
+}
+
+// printProgress prints how many of the functions of a pipeline completed. The
+// progress of a single function is shown by its status already.
+func (r *EvalFnRunner) printProgress(e fnruntime.ProgressEvent) {
+	if e.Total <= 1 {
+		return
+	}
+	printer.FromContextOrDie(r.Ctx).OptPrintf(printer.NewOpt().Informational(),
+		"[PROGRESS] %d/%d functions completed\n", e.Index, e.Total)
+}
+
+// isTerminal returns true if w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// writeJUnitReport adds the results of the package in RunFns.Path to the
+// JUnit report and writes the report, so it contains the results of all the
+// packages executed so far.
+func (r *EvalFnRunner) writeJUnitReport(results *fnresult.ResultList) error {
+	if r.junitReport == nil {
+		r.junitReport = fnruntime.NewJUnitReport()
+	}
+	pkg := r.RunFns.Path
+	switch {
+	case r.FromStdin:
+		pkg = "stdin"
+	case r.archive != "":
+		pkg = r.archive
+	}
+	r.junitReport.Add(pkg, results, r.Strict)
+	var out bytes.Buffer
+	if err := r.junitReport.Write(&out); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(r.JUnitReport, out.Bytes(), 0644); err != nil {
+		return fmt.Errorf("cannot write JUnit report %q: %w", r.JUnitReport, err)
+	}
+	return nil
+}
+
+// checkIdempotent executes the function on the resources and then again on
+// its output, and returns an error if the second execution changes the
+// resources. The differences are printed. Neither the package nor the results
+// are written.
+func (r *EvalFnRunner) checkIdempotent() error {
+	fns := r.RunFns
+	fns.ResultsDir = ""
+	var outputs [2]string
+	for i := range outputs {
+		var out bytes.Buffer
+		fns.Output = &out
+		result, err := evalRunFns(fns)
+		if err = runner.HandleError(r.Ctx, err); err != nil {
+			return err
+		}
+		// the reader annotations are left out as they depend on the input
+		var resources bytes.Buffer
+		if err := (kio.ByteWriter{Writer: &resources}).Write(result.Resources); err != nil {
+			return err
+		}
+		outputs[i] = resources.String()
+		fns.Input = bytes.NewReader(out.Bytes())
+	}
+	pr := printer.FromContextOrDie(r.Ctx)
+	if d := diff.UnifiedDiff("first execution", "second execution", outputs[0], outputs[1]); d != "" {
+		pr.Printf("%s", d)
+		return fmt.Errorf("function is not idempotent, executing it on its output changed the resources")
+	}
+	pr.OptPrintf(printer.NewOpt().Informational(), "Function is idempotent.\n")
+	return nil
+}
+
+// functionConfig returns the functionConfig the function is executed with,
+// either read from the --fn-config file or created from the function arguments.
+func (r *EvalFnRunner) functionConfig() (*yaml.RNode, error) {
+	if r.RunFns.FnConfigPath != "" {
+		return kptfile.GetValidatedFnConfigFromPath(filesys.FileSystemOrOnDisk{}, "", r.RunFns.FnConfigPath)
+	}
+	return r.RunFns.FnConfig, nil
+}
+
 // NewFunction creates a Kptfile.Function object which has the evaluated fn configurations.
 // This object can be written to Kptfile `pipeline.mutators`.
 func (r *EvalFnRunner) NewFunction() *kptfile.Function {
@@ -186,7 +643,7 @@ func (r *EvalFnRunner) NewFunction() *kptfile.Function {
 		newFn.Exclusions = []kptfile.Selector{r.Exclusion}
 	}
 	if r.FnConfigPath != "" {
-		fnConfigAbsPath, _, _ := pathutil.ResolveAbsAndRelPaths(r.FnConfigPath)
+		fnConfigAbsPath, _, _ := pathutil.ResolveAbsAndRelPaths(r.fnConfigPath(r.RunFns.Path))
 		pkgAbsPath, _, _ := pathutil.ResolveAbsAndRelPaths(r.RunFns.Path)
 		newFn.ConfigPath, _ = filepath.Rel(pkgAbsPath, fnConfigAbsPath)
 	} else {
@@ -207,7 +664,7 @@ func (r *EvalFnRunner) NewFunction() *kptfile.Function {
 
 // Add the evaluated function to the kptfile.Function list, this Function can either be
 // `pipeline.mutators` or `pipeline.validators`
-func (r *EvalFnRunner) updateFnList(oldFNs []kptfile.Function) ([]kptfile.Function, string) {
+func (r *EvalFnRunner) updateFnList(oldFNs []kptfile.Function, fnType string) ([]kptfile.Function, string) {
 	var newFns []kptfile.Function
 	found := false
 	newFn := r.NewFunction()
@@ -215,13 +672,13 @@ func (r *EvalFnRunner) updateFnList(oldFNs []kptfile.Function) ([]kptfile.Functi
 	for _, m := range oldFNs {
 		switch {
 		case m.Image != "" && m.Image == r.Image:
-			newFns = append(newFns, *newFn)
+			newFns = append(newFns, r.savedFunction(m, newFn))
 			found = true
-			message = fmt.Sprintf("Updated %q as %v in the Kptfile.\n", r.Image, r.FnType)
+			message = fmt.Sprintf("Updated %q as %v in the Kptfile.\n", r.Image, fnType)
 		case m.Exec != "" && m.Exec == r.Exec:
-			newFns = append(newFns, *newFn)
+			newFns = append(newFns, r.savedFunction(m, newFn))
 			found = true
-			message = fmt.Sprintf("Updated %q as %v in the Kptfile.\n", r.Exec, r.FnType)
+			message = fmt.Sprintf("Updated %q as %v in the Kptfile.\n", r.Exec, fnType)
 		default:
 			newFns = append(newFns, m)
 		}
@@ -229,14 +686,50 @@ func (r *EvalFnRunner) updateFnList(oldFNs []kptfile.Function) ([]kptfile.Functi
 	if !found {
 		newFns = append(newFns, *newFn)
 		if newFn.Image != "" {
-			message = fmt.Sprintf("Added %q as %v in the Kptfile.\n", r.Image, r.FnType)
+			message = fmt.Sprintf("Added %q as %v in the Kptfile.\n", r.Image, fnType)
 		} else if newFn.Exec != "" {
-			message = fmt.Sprintf("Added %q as %v in the Kptfile.\n", r.Exec, r.FnType)
+			message = fmt.Sprintf("Added %q as %v in the Kptfile.\n", r.Exec, fnType)
 		}
 	}
 	return newFns, message
 }
 
+// savedFunction returns the function replacing oldFn in the Kptfile, which
+// is newFn unless UpdateFn is set. In that case the config, selectors and
+// exclusions of newFn are merged into oldFn, keeping its other fields such as
+// the name. The keys of the configMap are merged too, and the config passed
+// last replaces a configPath with a configMap or vice versa.
+func (r *EvalFnRunner) savedFunction(oldFn kptfile.Function, newFn *kptfile.Function) kptfile.Function {
+	if !r.UpdateFn {
+		return *newFn
+	}
+	fn := oldFn
+	switch {
+	case newFn.ConfigPath != "":
+		fn.ConfigPath = newFn.ConfigPath
+		fn.ConfigMap = nil
+	case len(newFn.ConfigMap) > 0:
+		configMap := map[string]string{}
+		if fn.ConfigPath == "" {
+			for k, v := range fn.ConfigMap {
+				configMap[k] = v
+			}
+		}
+		for k, v := range newFn.ConfigMap {
+			configMap[k] = v
+		}
+		fn.ConfigMap = configMap
+		fn.ConfigPath = ""
+	}
+	if len(newFn.Selectors) > 0 {
+		fn.Selectors = newFn.Selectors
+	}
+	if len(newFn.Exclusions) > 0 {
+		fn.Exclusions = newFn.Exclusions
+	}
+	return fn
+}
+
 // SaveFnToKptfile adds the evaluated function and its arguments to Kptfile `pipeline.mutators` or `pipeline.validators` .
 func (r *EvalFnRunner) SaveFnToKptfile() {
 	pr := printer.FromContextOrDie(r.Ctx)
@@ -249,11 +742,11 @@ func (r *EvalFnRunner) SaveFnToKptfile() {
 		kf.Pipeline = &kptfile.Pipeline{}
 	}
 	var usrMsg string
-	switch r.FnType {
+	switch fnType := r.saveFnType(); fnType {
 	case "mutator":
-		kf.Pipeline.Mutators, usrMsg = r.updateFnList(kf.Pipeline.Mutators)
+		kf.Pipeline.Mutators, usrMsg = r.updateFnList(kf.Pipeline.Mutators, fnType)
 	case "validator":
-		kf.Pipeline.Validators, usrMsg = r.updateFnList(kf.Pipeline.Validators)
+		kf.Pipeline.Validators, usrMsg = r.updateFnList(kf.Pipeline.Validators, fnType)
 	}
 	// When saving function to Kptfile, the functionConfig should be the relative path
 	// to the kpt package, not the relative path to the current working dir.
@@ -262,7 +755,30 @@ func (r *EvalFnRunner) SaveFnToKptfile() {
 		pr.Printf("function is not added to Kptfile: %v\n", err)
 		return
 	}
-	pr.Printf(usrMsg)
+	pr.OptPrintf(printer.NewOpt().Informational(), usrMsg)
+}
+
+// saveFnType returns the type the function is saved to the Kptfile as. The
+// --save-as and --type flags take precedence over the FunctionTypeLabel of the
+// function image. Functions are saved as mutators if the type isn't known.
+func (r *EvalFnRunner) saveFnType() string {
+	switch {
+	case r.SaveAs != "":
+		return r.SaveAs
+	case r.FnType != "":
+		return r.FnType
+	case r.Image == "":
+		return "mutator"
+	}
+	fnType, err := fnruntime.ImageFunctionType(r.Ctx, r.Image)
+	if err != nil {
+		printer.FromContextOrDie(r.Ctx).Printf("[WARN] cannot detect the type of function %q, saving it as mutator: %v\n", r.Image, err)
+		return "mutator"
+	}
+	if fnType != "mutator" && fnType != "validator" {
+		return "mutator"
+	}
+	return fnType
 }
 
 // getCLIFunctionConfig parses the commandline flags and arguments into explicit
@@ -287,6 +803,12 @@ data: {}
 	// default the function config kind to ConfigMap, this may be overridden
 	var kind = "ConfigMap"
 	var version = "v1"
+	if r.FnConfigKind != "" {
+		kind = r.FnConfigKind
+	}
+	if r.FnConfigAPIVersion != "" {
+		version = r.FnConfigAPIVersion
+	}
 
 	// populate the function config with data.  this is a convention for functions
 	// to be more commandline friendly
@@ -299,6 +821,9 @@ data: {}
 			kv := strings.SplitN(s, "=", 2)
 			if i == 0 && len(kv) == 1 {
 				// first argument may be the kind
+				if r.FnConfigKind != "" {
+					return nil, fmt.Errorf("function config kind can be specified either using --fn-config-kind or the first function argument, not both")
+				}
 				kind = s
 				continue
 			}
@@ -325,42 +850,333 @@ data: {}
 	return rc, nil
 }
 
-func (r *EvalFnRunner) getFunctionSpec() (*runtimeutil.FunctionSpec, []string, error) {
-	fn := &runtimeutil.FunctionSpec{}
-	var execArgs []string
-	if r.Image != "" {
-		if err := kptfile.ValidateFunctionImageURL(r.Image); err != nil {
-			return nil, nil, err
-		}
-		fn.Container.Image = r.Image
-	} else if r.Exec != "" {
-		// check the flags that doesn't make sense with exec function
-		// --mount, --as-current-user, --network and --env are
-		// only used with container functions
-		if r.AsCurrentUser || r.Network ||
-			len(r.Mounts) != 0 || len(r.Env) != 0 {
-			return nil, nil, fmt.Errorf("--mount, --as-current-user, --network and --env can only be used with container functions")
-		}
-		s, err := shlex.Split(r.Exec)
-		if err != nil {
-			return nil, nil, fmt.Errorf("exec command %q must be valid: %w", r.Exec, err)
-		}
-		if len(s) > 0 {
-			fn.Exec.Path = s[0]
-			execArgs = s[1:]
+// validateFunction checks the flags which only apply to one kind of function
+// and that the exec function exists.
+func (r *EvalFnRunner) validateFunction(fn *runtimeutil.FunctionSpec) error {
+	if r.Exec == "" || fn.Exec.Path == "" {
+		return nil
+	}
+	return lookExec(fn.Exec.Path)
+}
+
+// lookExec checks that the executable of an exec function exists.
+func lookExec(path string) error {
+	// relative paths are resolved against the current directory
+	if _, err := exec.LookPath(path); err != nil {
+		if strings.ContainsRune(path, filepath.Separator) || strings.ContainsRune(path, '/') {
+			return fmt.Errorf("exec function %q not found", path)
 		}
+		return fmt.Errorf("exec function %q not found in PATH", path)
+	}
+	return nil
+}
+
+// validatePipelineFlags checks that no flags configuring a single function
+// are used with --pipeline, the functions are configured in the pipeline file.
+func (r *EvalFnRunner) validatePipelineFlags() error {
+	if r.SaveFn {
+		return errors.Errorf("--save can't be used with --pipeline")
 	}
-	return fn, execArgs, nil
+	if r.FnConfigPath != "" || len(r.dataItems) > 0 || r.FnConfigKind != "" || r.FnConfigAPIVersion != "" {
+		return errors.Errorf("function config can't be specified with --pipeline, use configPath or configMap in the pipeline file")
+	}
+	if !r.Selector.IsEmpty() || !r.Exclusion.IsEmpty() || r.FilePathSelector != "" {
+		return errors.Errorf("--match-* and --exclude-* flags can't be used with --pipeline, use selectors and exclude in the pipeline file")
+	}
+	if r.ChangedSince != "" {
+		return errors.Errorf("--changed-since can't be used with --pipeline")
+	}
+	return nil
 }
 
-func toStorageMounts(mounts []string) []runtimeutil.StorageMount {
-	var sms []runtimeutil.StorageMount
+func toStorageMounts(mounts []string) []fnruntime.StorageMount {
+	var sms []fnruntime.StorageMount
 	for _, mount := range mounts {
-		sms = append(sms, runtimeutil.StringToStorageMount(mount))
+		// the mounts are validated already
+		sm, _ := parseMount(mount)
+		sms = append(sms, sm)
 	}
 	return sms
 }
 
+// mountFormats are the formats of the mounts of each type.
+var mountFormats = map[string]string{
+	"bind":   "type=bind,src=<path>,dst=<path>[,rw=true]",
+	"volume": "type=volume,src=<name>,dst=<path>[,rw=true]",
+	"tmpfs":  "type=tmpfs,dst=<path>[,size=<size>]",
+}
+
+// validateMount checks that mount is a bind mount, a named volume or a tmpfs
+// mount in one of the mountFormats.
+func validateMount(mount string) error {
+	_, err := parseMount(mount)
+	return err
+}
+
+// parseMount parses mount in one of the mountFormats. Mounts without a type
+// are bind mounts.
+func parseMount(mount string) (fnruntime.StorageMount, error) {
+	sm := fnruntime.StorageMount{StorageMount: runtimeutil.StorageMount{MountType: "bind"}}
+	var hasSrc, hasRW, hasSize bool
+	for _, token := range strings.Split(mount, ",") {
+		kv := strings.SplitN(token, "=", 2)
+		if len(kv) != 2 {
+			return sm, fmt.Errorf("invalid --mount %q: option %q must be in key=value format", mount, token)
+		}
+		switch key, value := kv[0], kv[1]; key {
+		case "type":
+			if _, found := mountFormats[value]; !found {
+				return sm, fmt.Errorf("invalid --mount %q: type %q is not supported, it must be one of bind, volume and tmpfs", mount, value)
+			}
+			sm.MountType = value
+		case "src", "source":
+			sm.Src = value
+			hasSrc = true
+		case "dst", "target":
+			sm.DstPath = value
+		case "rw":
+			if value != "true" && value != "false" {
+				return sm, fmt.Errorf("invalid --mount %q: option %q must be either rw=true or rw=false", mount, token)
+			}
+			sm.ReadWriteMode = value == "true"
+			hasRW = true
+		case "size":
+			size, err := parseSize(value)
+			if err != nil {
+				return sm, fmt.Errorf("invalid --mount %q: %w", mount, err)
+			}
+			sm.TmpfsSize = size
+			hasSize = true
+		default:
+			return sm, fmt.Errorf("invalid --mount %q: unknown option %q", mount, token)
+		}
+	}
+	switch {
+	case sm.MountType == "tmpfs" && hasSrc:
+		return sm, fmt.Errorf("invalid --mount %q: tmpfs mounts can't have a source", mount)
+	case sm.MountType == "tmpfs" && hasRW:
+		return sm, fmt.Errorf("invalid --mount %q: tmpfs mounts are always writable, they can't have the rw option", mount)
+	case sm.MountType != "tmpfs" && hasSize:
+		return sm, fmt.Errorf("invalid --mount %q: only tmpfs mounts can have a size", mount)
+	}
+	if (sm.MountType != "tmpfs" && sm.Src == "") || sm.DstPath == "" {
+		return sm, fmt.Errorf("invalid --mount %q: must be in the format %s", mount, mountFormats[sm.MountType])
+	}
+	return sm, nil
+}
+
+// sizeUnits are the multipliers of the units of tmpfs sizes.
+var sizeUnits = map[string]int64{
+	"":  1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+}
+
+// parseSize parses the size of a tmpfs mount in bytes, or with a k, m or g
+// unit, e.g. 64m.
+func parseSize(size string) (int64, error) {
+	lower := strings.ToLower(size)
+	number := strings.TrimRight(lower, "kmg")
+	unit, found := sizeUnits[lower[len(number):]]
+	n, err := strconv.ParseInt(number, 10, 64)
+	if !found || err != nil || n <= 0 || n > math.MaxInt64/unit {
+		return 0, fmt.Errorf("size %q must be a positive number of bytes, optionally with a k, m or g unit", size)
+	}
+	return n * unit, nil
+}
+
+// resolveMounts returns the storage mounts of the --mount flags with the
+// sources of the bind mounts resolved to absolute paths without symlinks, as
+// docker doesn't accept relative sources. Bind mounts of sources which don't
+// exist are skipped with a warning if IgnoreMountErrors is set.
+func (r *EvalFnRunner) resolveMounts() ([]fnruntime.StorageMount, error) {
+	var sms []fnruntime.StorageMount
+	for i, sm := range toStorageMounts(r.Mounts) {
+		if sm.MountType != "bind" {
+			// volumes and tmpfs mounts aren't paths on the host
+			sms = append(sms, sm)
+			continue
+		}
+		src, missing, err := resolveMountSource(sm.Src)
+		if err != nil {
+			if missing && r.IgnoreMountErrors {
+				printer.FromContextOrDie(r.Ctx).Printf("[WARN] skipping --mount %q: %v\n", r.Mounts[i], err)
+				continue
+			}
+			return nil, fmt.Errorf("invalid --mount %q: %w", r.Mounts[i], err)
+		}
+		sm.Src = src
+		sms = append(sms, sm)
+	}
+	return sms, nil
+}
+
+// resolveContextDir returns the absolute path of ContextDir with its symlinks
+// resolved, or an empty string if ContextDir isn't set. A warning is printed
+// if it overlaps any of the package directories in paths, as the functions
+// can read the files of the package through it, which aren't updated by the
+// functions executed before.
+func (r *EvalFnRunner) resolveContextDir(paths []string) (string, error) {
+	if r.ContextDir == "" {
+		return "", nil
+	}
+	dir, _, err := resolveMountSource(r.ContextDir)
+	if err != nil {
+		return "", fmt.Errorf("invalid --context-dir %q: %w", r.ContextDir, err)
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return "", fmt.Errorf("--context-dir %q must be a directory", r.ContextDir)
+	}
+	for _, p := range paths {
+		pkgDir, err := filepath.Abs(p)
+		if err != nil {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(pkgDir); err == nil {
+			pkgDir = resolved
+		}
+		if isSubdir(dir, pkgDir) || isSubdir(pkgDir, dir) {
+			printer.FromContextOrDie(r.Ctx).Printf("[WARN] --context-dir %q overlaps the package %q\n", r.ContextDir, p)
+		}
+	}
+	return dir, nil
+}
+
+// isSubdir returns true if dir is parent or a subdirectory of parent.
+func isSubdir(parent, dir string) bool {
+	rel, err := filepath.Rel(parent, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolveMountSource returns the absolute path of the mount source src with
+// its symlinks resolved. missing is true if the error is caused by src, or
+// the target of a symlink, not existing.
+func resolveMountSource(src string) (resolved string, missing bool, err error) {
+	if _, err := os.Lstat(src); err != nil {
+		if os.IsNotExist(err) {
+			return "", true, fmt.Errorf("source %q doesn't exist", src)
+		}
+		return "", false, fmt.Errorf("source %q can't be accessed: %w", src, err)
+	}
+	resolved, err = filepath.EvalSymlinks(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			target, _ := os.Readlink(src)
+			return "", true, fmt.Errorf("source %q is a symlink to %q which doesn't exist", src, target)
+		}
+		return "", false, fmt.Errorf("source %q can't be resolved: %w", src, err)
+	}
+	if _, err := os.Stat(resolved); err != nil {
+		return "", false, fmt.Errorf("source %q can't be accessed: %w", src, err)
+	}
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		return "", false, err
+	}
+	return resolved, false, nil
+}
+
+// isEnvPattern returns true if the --env value env inherits the host
+// environment variables matching a glob pattern. Values in KEY=VALUE format
+// are literal.
+func isEnvPattern(env string) bool {
+	return !strings.Contains(env, "=") && strings.ContainsAny(env, "*?[")
+}
+
+// expandEnv returns env with the glob patterns replaced by the keys of the
+// variables in environ matching them, in sorted order. The other values are
+// kept as they are. Patterns are expected to be valid.
+func expandEnv(env, environ []string) []string {
+	var keys []string
+	for _, e := range environ {
+		// on windows, environ contains variables like =C:=C:\ without a key
+		if key := strings.SplitN(e, "=", 2)[0]; key != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	expanded := []string{}
+	for _, e := range env {
+		if !isEnvPattern(e) {
+			expanded = append(expanded, e)
+			continue
+		}
+		for _, key := range keys {
+			if matched, _ := path.Match(e, key); matched {
+				expanded = append(expanded, key)
+			}
+		}
+	}
+	return expanded
+}
+
+// secretEnvPatterns are the glob patterns of the keys of the environment
+// variables whose values are masked by --print-env. Keys are matched case
+// insensitively.
+var secretEnvPatterns = []string{"*TOKEN*", "*SECRET*", "*PASSWORD*"}
+
+// maskedEnvValue replaces the values of secret environment variables.
+const maskedEnvValue = "******"
+
+// printEnv prints the environment variables and the mounts the container
+// functions are executed with. The values of the variables inherited from the
+// host are looked up, and the values of secret variables are masked.
+func (r *EvalFnRunner) printEnv() {
+	pr := printer.FromContextOrDie(r.Ctx)
+	pr.Printf("Environment of the container functions:\n")
+	if len(r.RunFns.Env) == 0 && !r.InjectPackagePath && r.RunFns.ContextDir == "" {
+		pr.Printf("  none\n")
+	}
+	if r.InjectPackagePath {
+		pr.Printf("  %s is set to the absolute path of the package\n", runfn.PackagePathEnv)
+	}
+	for _, e := range r.RunFns.Env {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) == 1 {
+			value, found := os.LookupEnv(kv[0])
+			if !found {
+				pr.Printf("  %s is inherited from the host, where it isn't set\n", kv[0])
+				continue
+			}
+			kv = append(kv, value)
+		}
+		pr.Printf("  %s=%s\n", kv[0], maskEnvValue(kv[0], kv[1]))
+	}
+	if r.RunFns.ContextDir != "" {
+		pr.Printf("  %s=%s\n", runfn.ContextDirEnv, runfn.ContextDirMountPath)
+	}
+	pr.Printf("Mounts of the container functions:\n")
+	mounts := r.RunFns.ContainerMounts()
+	if len(mounts) == 0 {
+		pr.Printf("  none\n")
+	}
+	for _, sm := range mounts {
+		pr.Printf("  %s\n", sm.String())
+	}
+}
+
+// maskEnvValue returns maskedEnvValue if the key of the environment variable
+// matches any of the secretEnvPatterns, otherwise value.
+func maskEnvValue(key, value string) string {
+	for _, pattern := range secretEnvPatterns {
+		if matched, _ := path.Match(pattern, strings.ToUpper(key)); matched {
+			return maskedEnvValue
+		}
+	}
+	return value
+}
+
+// fnConfigPath returns the path of the function config file for the package
+// at pkgPath. A relative FnConfigPath is relative to the package if
+// FnConfigRelative is set, and to the current directory otherwise.
+func (r *EvalFnRunner) fnConfigPath(pkgPath string) string {
+	if r.FnConfigPath == "" || !r.FnConfigRelative || filepath.IsAbs(r.FnConfigPath) {
+		return r.FnConfigPath
+	}
+	return filepath.Join(pkgPath, r.FnConfigPath)
+}
+
 func checkFnConfigPathExistence(path string) error {
 	// check does fn config file exist
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -369,6 +1185,69 @@ func checkFnConfigPathExistence(path string) error {
 	return nil
 }
 
+// Validate checks that the flags of the runner are consistent with each
+// other. It doesn't access the filesystem or docker, so whether the package,
+// the function config, the mounts and the function exist is checked when the
+// command is executed. The function arguments and the label and annotation
+// selectors are expected to be parsed already. The flags which are options of
+// Eval are checked by EvalOptions.Validate, like Eval does.
+func (r *EvalFnRunner) Validate() error {
+	// separate the optional flag validation to fix linter issue: cyclomatic complexity
+	if err := r.validateOptionalFlags(); err != nil {
+		return err
+	}
+	if err := r.validateFunctionFlags(); err != nil {
+		return err
+	}
+	if err := r.flagOptions().Validate(); err != nil {
+		return err
+	}
+	if r.PipelinePath != "" {
+		return r.validatePipelineFlags()
+	}
+	return nil
+}
+
+// flagOptions returns the EvalOptions set by the flags for validating them.
+// The options which are only known after accessing the filesystem, like the
+// sources of the mounts, aren't resolved.
+func (r *EvalFnRunner) flagOptions() EvalOptions {
+	return EvalOptions{
+		Image:            r.Image,
+		Exec:             r.Exec,
+		PipelinePath:     r.PipelinePath,
+		FilePathSelector: r.FilePathSelector,
+		Network:          r.Network,
+		StorageMounts:    toStorageMounts(r.Mounts),
+		ContextDir:       r.ContextDir,
+		Env:              r.Env,
+		ExecEnv:          r.ExecEnv,
+		AsCurrentUser:    r.AsCurrentUser,
+		PullRetries:      r.PullRetries,
+		MaxResults:       r.MaxResults,
+		AllowExec:        r.AllowExec,
+		NoDocker:         r.NoDocker,
+	}
+}
+
+// validateFunctionFlags checks that the flags which aren't options of Eval
+// are supported by the kind of the function.
+func (r *EvalFnRunner) validateFunctionFlags() error {
+	if r.Exec != "" && r.PrintEnv {
+		return fmt.Errorf("--print-env can only be used with container functions")
+	}
+	if r.Exec != "" && r.RegistryAuth != "" {
+		return fmt.Errorf("--registry-auth can only be used with container functions")
+	}
+	if len(r.dataItems) > 0 && r.FnConfigPath != "" {
+		return fmt.Errorf("function arguments can only be specified without function config file")
+	}
+	if (r.FnConfigKind != "" || r.FnConfigAPIVersion != "") && r.FnConfigPath != "" {
+		return fmt.Errorf("--fn-config-kind and --fn-config-api-version can only be specified without function config file")
+	}
+	return nil
+}
+
 func (r *EvalFnRunner) validateOptionalFlags() error {
 	// Let users know that --include-meta-resources is no longer necessary
 	// since meta resources are included by default.
@@ -377,71 +1256,159 @@ func (r *EvalFnRunner) validateOptionalFlags() error {
 	}
 	// SaveFn stores function to Kptfile. If not enabled, only make in-place changes.
 	if r.SaveFn {
-		if r.FnType == "" {
-			return fmt.Errorf("--type must be specified if saving functions to Kptfile (--save=true)")
-		}
-		if r.FnType != "mutator" && r.FnType != "validator" {
+		if r.FnType != "" && r.FnType != "mutator" && r.FnType != "validator" {
 			return fmt.Errorf("--type must be either `mutator` or `validator`")
 		}
+		if r.SaveAs != "" && r.SaveAs != "mutator" && r.SaveAs != "validator" {
+			return fmt.Errorf("--save-as must be either `mutator` or `validator`")
+		}
+	} else if r.SaveAs != "" {
+		return fmt.Errorf("--save-as can only be used when saving functions to Kptfile (--save=true)")
+	} else if r.UpdateFn {
+		return fmt.Errorf("--update can only be used when saving functions to Kptfile (--save=true)")
 	}
-	// ResultsDir stores the hydrated output in a structured format to result dir. If not specified, only make
-	// in-place changes.
-	if r.ResultsDir != "" {
-		err := os.MkdirAll(r.ResultsDir, 0755)
-		if err != nil {
-			return fmt.Errorf("cannot read or create results dir %q: %w", r.ResultsDir, err)
+	if r.ResultsFormat != "" && r.ResultsFormat != textResultsFormat && r.ResultsFormat != jsonResultsFormat {
+		return fmt.Errorf("--results-format must be either `text` or `json`")
+	}
+	if r.FnConfigRelative && r.FnConfigPath == "" {
+		return fmt.Errorf("--fn-config-relative can only be used with --fn-config")
+	}
+	// CheckIdempotent only executes the function in memory.
+	if r.CheckIdempotent {
+		switch {
+		case r.SaveFn:
+			return fmt.Errorf("--save can't be used with --check-idempotent")
+		case r.Dest != "":
+			return fmt.Errorf("--output can't be used with --check-idempotent")
+		case r.ResultsDir != "":
+			return fmt.Errorf("--results-dir can't be used with --check-idempotent")
+		case r.Strict:
+			return fmt.Errorf("--strict can't be used with --check-idempotent")
+		case r.JUnitReport != "":
+			return fmt.Errorf("--junit-report can't be used with --check-idempotent")
+		case r.SortOutput:
+			return fmt.Errorf("--sort-output can't be used with --check-idempotent")
+		case r.ResultsFormat == jsonResultsFormat:
+			return fmt.Errorf("--results-format can't be used with --check-idempotent")
 		}
 	}
-
 	if err := cmdutil.ValidateImagePullPolicyValue(r.ImagePullPolicy); err != nil {
 		return err
 	}
+	if r.NoCache && r.CacheDir == "" {
+		return fmt.Errorf("--no-cache can only be used with --cache-dir")
+	}
+	for _, mount := range r.Mounts {
+		if err := validateMount(mount); err != nil {
+			return err
+		}
+	}
+	for _, env := range r.Env {
+		if !isEnvPattern(env) {
+			continue
+		}
+		if _, err := path.Match(env, ""); err != nil {
+			return fmt.Errorf("--env pattern %q must be valid: %w", env, err)
+		}
+	}
+	// the file path isn't part of the function selectors in the Kptfile
+	if r.FilePathSelector != "" && r.SaveFn {
+		return fmt.Errorf("--match-file can't be used when saving functions to Kptfile (--save=true)")
+	}
+	if r.ChangedSince != "" && r.SaveFn {
+		return fmt.Errorf("--changed-since can't be used when saving functions to Kptfile (--save=true)")
+	}
 	return nil
 }
 
 func (r *EvalFnRunner) preRunE(c *cobra.Command, args []string) error {
-	// separate the optional flag validation to fix linter issue: cyclomatic complexity
-	if err := r.validateOptionalFlags(); err != nil {
+	err := r.preRun(c, args)
+	if err != nil {
+		// runE isn't called to remove the package extracted from an archive
+		// and the registry credentials
+		r.removeArchiveDir()
+		r.removeRegistryConfigDir()
+	}
+	return err
+}
+
+func (r *EvalFnRunner) preRun(c *cobra.Command, args []string) error {
+	// the status of the functions would interleave with the json results
+	if r.Quiet || r.ResultsFormat == jsonResultsFormat {
+		pr := printer.FromContextOrDie(r.Ctx)
+		r.Ctx = printer.WithContext(r.Ctx, printer.NewWithVerbosity(pr.OutStream(), pr.ErrStream(), printer.QuietVerbosity))
+	}
+	var dataItems []string
+	if c.ArgsLenAtDash() >= 0 {
+		dataItems = append(dataItems, args[c.ArgsLenAtDash():]...)
+		args = args[:c.ArgsLenAtDash()]
+	}
+	r.dataItems = dataItems
+	r.parseSelectors()
+	if !r.AllowExec {
+		if v := os.Getenv(allowExecEnv); v != "" {
+			allow, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("$%s must be true or false: %w", allowExecEnv, err)
+			}
+			r.AllowExec = allow
+		}
+	}
+	if r.ImagePrefix == "" {
+		r.ImagePrefix = os.Getenv(imagePrefixEnv)
+	}
+	if err := r.Validate(); err != nil {
 		return err
 	}
-	if r.Dest != "" && r.Dest != cmdutil.Stdout && r.Dest != cmdutil.Unwrap {
-		if err := cmdutil.CheckDirectoryNotPresent(r.Dest); err != nil {
-			return err
+
+	// ResultsDir stores the hydrated output in a structured format to result dir. If not specified, only make
+	// in-place changes.
+	if r.ResultsDir != "" {
+		err := os.MkdirAll(r.ResultsDir, 0755)
+		if err != nil {
+			return fmt.Errorf("cannot read or create results dir %q: %w", r.ResultsDir, err)
 		}
 	}
-	if r.Image == "" && r.Exec == "" {
-		return errors.Errorf("must specify --image or --exec")
+	if r.Dest != "" && r.Dest != cmdutil.Stdout && r.Dest != cmdutil.Unwrap && r.Dest != cmdutil.ResourceList {
+		if err := cmdutil.CheckOutputDirectory(r.Dest); err != nil {
+			return err
+		}
 	}
 	if r.Image != "" {
-		r.Image = fnruntime.AddDefaultImagePathPrefix(c.Context(), r.Image)
+		r.Image = fnruntime.AddImagePathPrefix(c.Context(), r.Image, r.ImagePrefix)
 		err := cmdutil.DockerCmdAvailable()
 		if err != nil {
 			return err
 		}
 	}
-	var dataItems []string
-	if c.ArgsLenAtDash() >= 0 {
-		dataItems = append(dataItems, args[c.ArgsLenAtDash():]...)
-		args = args[:c.ArgsLenAtDash()]
-	}
 	if len(args) == 0 {
 		// default to current working directory
 		args = append(args, ".")
 	}
 	if len(args) > 1 {
-		return errors.Errorf("0 or 1 arguments supported, function arguments go after '--'")
-	}
-	if len(dataItems) > 0 && r.FnConfigPath != "" {
-		return fmt.Errorf("function arguments can only be specified without function config file")
+		for _, arg := range args {
+			if arg == "-" {
+				return errors.Errorf("'-' reads resources from stdin and can't be combined with directories, function arguments go after '--'")
+			}
+			if isArchive(arg) {
+				return errors.Errorf("archive %q can't be combined with other packages", arg)
+			}
+		}
+		if r.Dest != "" {
+			return errors.Errorf("--output can't be used with multiple directories")
+		}
 	}
 	fnConfig, err := r.getCLIFunctionConfig(dataItems)
 	if err != nil {
 		return err
 	}
-	r.dataItems = dataItems
-	fnSpec, execArgs, err := r.getFunctionSpec()
-	if err != nil {
-		return err
+
+	if len(args) == 1 && isArchive(args[0]) {
+		dir, err := r.prepareArchive(args[0])
+		if err != nil {
+			return err
+		}
+		args = []string{dir}
 	}
 
 	// set the output to stdout if in dry-run mode or no arguments are specified
@@ -455,66 +1422,113 @@ func (r *EvalFnRunner) preRunE(c *cobra.Command, args []string) error {
 
 		// clear args as it indicates stdin and not path
 		args = []string{}
+		if r.FnConfigRelative {
+			return fmt.Errorf("--fn-config-relative can't be used when reading resources from stdin")
+		}
+		if r.ChangedSince != "" {
+			return fmt.Errorf("--changed-since can't be used when reading resources from stdin")
+		}
 	} else if r.Dest != "" {
 		output = &r.OutContent
 	}
-
-	// set the path if specified as an argument
-	var path string
-	if len(args) == 1 {
-		// argument is the directory
-		path = args[0]
+	if r.SortOutput && output == nil {
+		return fmt.Errorf("--sort-output can only be used with --output or when reading resources from stdin")
 	}
 
+	// the arguments are the package directories
+	paths := args
+
 	// parse mounts to set storageMounts
-	storageMounts := toStorageMounts(r.Mounts)
+	storageMounts, err := r.resolveMounts()
+	if err != nil {
+		return err
+	}
+	contextDir, err := r.resolveContextDir(paths)
+	if err != nil {
+		return err
+	}
+	if r.RegistryAuth != "" {
+		if err := r.prepareRegistryAuth(r.RegistryAuth); err != nil {
+			return err
+		}
+	}
 
 	if r.FnConfigPath != "" {
-		err = checkFnConfigPathExistence(r.FnConfigPath)
-		if err != nil {
-			return err
+		pkgPaths := paths
+		if len(pkgPaths) == 0 {
+			pkgPaths = []string{""}
+		}
+		for _, p := range pkgPaths {
+			if err := checkFnConfigPathExistence(r.fnConfigPath(p)); err != nil {
+				return err
+			}
 		}
 	}
 
-	if path != "" {
-		path, err = argutil.ResolveSymlink(r.Ctx, path)
+	for i := range paths {
+		paths[i], err = argutil.ResolveSymlink(r.Ctx, paths[i])
 		if err != nil {
 			return err
 		}
 	}
+	r.paths = paths
+	var path string
+	if len(paths) > 0 {
+		path = paths[0]
+	}
 	if r.SaveFn && r.FnConfigPath != "" {
-		fnConfigAbsPath, _, _ := pathutil.ResolveAbsAndRelPaths(r.FnConfigPath)
-		pkgAbsPath, _, _ := pathutil.ResolveAbsAndRelPaths(path)
-		if !strings.HasPrefix(fnConfigAbsPath, pkgAbsPath) {
-			return fmt.Errorf("--fn-config must be under %v if saving functions to Kptfile (--save=true)",
-				pkgAbsPath)
+		pkgPaths := paths
+		if len(pkgPaths) == 0 {
+			pkgPaths = []string{""}
+		}
+		for _, p := range pkgPaths {
+			fnConfigAbsPath, _, _ := pathutil.ResolveAbsAndRelPaths(r.fnConfigPath(p))
+			pkgAbsPath, _, _ := pathutil.ResolveAbsAndRelPaths(p)
+			if !strings.HasPrefix(fnConfigAbsPath, pkgAbsPath) {
+				return fmt.Errorf("--fn-config must be under %v if saving functions to Kptfile (--save=true)",
+					pkgAbsPath)
+			}
 		}
 	}
-	r.parseSelectors()
-	r.RunFns = runfn.RunFns{
-		Ctx:             r.Ctx,
-		Function:        fnSpec,
-		ExecArgs:        execArgs,
-		OriginalExec:    r.Exec,
-		Output:          output,
-		Input:           input,
-		Path:            path,
-		Network:         r.Network,
-		StorageMounts:   storageMounts,
-		ResultsDir:      r.ResultsDir,
-		Env:             r.Env,
-		AsCurrentUser:   r.AsCurrentUser,
-		FnConfig:        fnConfig,
-		FnConfigPath:    r.FnConfigPath,
-		ImagePullPolicy: cmdutil.StringToImagePullPolicy(r.ImagePullPolicy),
-		// fn eval should remove all files when all resources
-		// are deleted.
-		ContinueOnEmptyResult: true,
-		Selector:              r.Selector,
-		Exclusion:             r.Exclusion,
+	opts := EvalOptions{
+		Image:             r.Image,
+		Exec:              r.Exec,
+		FnConfig:          fnConfig,
+		FnConfigPath:      r.fnConfigPath(path),
+		Path:              path,
+		Input:             input,
+		Output:            output,
+		Selector:          r.Selector,
+		Exclusion:         r.Exclusion,
+		FilePathSelector:  r.FilePathSelector,
+		Network:           r.Network,
+		StorageMounts:     storageMounts,
+		ContextDir:        contextDir,
+		Env:               expandEnv(r.Env, os.Environ()),
+		ExecEnv:           r.ExecEnv,
+		InjectPackagePath: r.InjectPackagePath,
+		AsCurrentUser:     r.AsCurrentUser,
+		ImagePullPolicy:   cmdutil.StringToImagePullPolicy(r.ImagePullPolicy),
+		ImagePrefix:       r.ImagePrefix,
+		PullRetries:       r.PullRetries,
+		RegistryConfigDir: r.registryConfigDir,
+		CacheDir:          r.CacheDir,
+		RefreshCache:      r.NoCache,
+		ResultsDir:        r.ResultsDir,
+		PreserveUnchanged: r.PreserveUnchanged,
+		FailOnEmpty:       r.FailOnEmpty,
+		MaxResults:        r.MaxResults,
+		ResultsIgnorePath: r.ResultsIgnore,
+		PipelinePath:      r.PipelinePath,
+		Verbose:           r.Verbose,
+		AllowExec:         r.AllowExec,
+		NoDocker:          r.NoDocker,
 	}
-
-	return nil
+	r.RunFns, err = opts.runFns(r.Ctx)
+	if err != nil {
+		return err
+	}
+	return r.validateFunction(r.RunFns.Function)
 }
 
 // parses annotation and label based selectors and exclusion from the command line input
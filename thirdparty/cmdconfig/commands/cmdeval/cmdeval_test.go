@@ -6,19 +6,27 @@ package cmdeval
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
 	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
 	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	kptfile "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/thirdparty/kyaml/runfn"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
 )
 
@@ -35,6 +43,34 @@ func TestRunFnCommand_preRunE(t *testing.T) {
 	defer testutil.Chdir(t, filepath.Dir(tempDir))()
 	dir := filepath.Base(tempDir)
 
+	// exec functions must exist, make execPath available on the PATH
+	binDir := t.TempDir()
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(binDir, "execPath"), []byte("#!/bin/sh\n"), 0700)) {
+		t.FailNow()
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	// mount sources are resolved to absolute paths without symlinks
+	mountSrc, err := filepath.EvalSymlinks(tempDir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, os.Symlink(tempDir, filepath.Join(binDir, "link"))) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, os.Symlink(filepath.Join(binDir, "missing"), filepath.Join(binDir, "broken-link"))) {
+		t.FailNow()
+	}
+
+	pipeline := filepath.Join(dir, "pipeline.yaml")
+	if !assert.NoError(t, ioutil.WriteFile(pipeline, []byte("- exec: execPath\n"), 0600)) {
+		t.FailNow()
+	}
+	imagePipeline := filepath.Join(dir, "image-pipeline.yaml")
+	if !assert.NoError(t, ioutil.WriteFile(imagePipeline, []byte("- exec: execPath\n- image: foo:bar\n"), 0600)) {
+		t.FailNow()
+	}
+
 	tests := []struct {
 		name             string
 		args             []string
@@ -49,6 +85,7 @@ func TestRunFnCommand_preRunE(t *testing.T) {
 		fnConfigPath     string
 		network          bool
 		mount            []string
+		expectedPaths    []string
 	}{
 		{
 			name: "config map",
@@ -101,6 +138,24 @@ metadata:
 data: {a: b, c: d, e: f}
 kind: ConfigMap
 apiVersion: v1
+`,
+		},
+		{
+			name:   "config map resourcelist output",
+			args:   []string{"eval", dir, "--image", "foo:bar", "-o", "resourcelist", "--", "a=b"},
+			output: &bytes.Buffer{},
+			path:   dir,
+			expectedFn: &runtimeutil.FunctionSpec{
+				Container: runtimeutil.ContainerSpec{
+					Image: "gcr.io/kpt-fn/foo:bar",
+				},
+			},
+			expectedFnConfig: `
+metadata:
+  name: function-input
+data: {a: b}
+kind: ConfigMap
+apiVersion: v1
 `,
 		},
 		{
@@ -190,15 +245,76 @@ kind: Foo
 apiVersion: v1
 `,
 		},
+		{
+			name: "custom kind flag",
+			args: []string{"eval", dir, "--image", "foo:bar", "--fn-config-kind", "Foo", "--", "g=h"},
+			path: dir,
+			expectedFn: &runtimeutil.FunctionSpec{
+				Container: runtimeutil.ContainerSpec{
+					Image: "gcr.io/kpt-fn/foo:bar",
+				},
+			},
+			expectedFnConfig: `
+metadata:
+  name: function-input
+data: {g: h}
+kind: Foo
+apiVersion: v1
+`,
+		},
+		{
+			name: "custom kind and apiVersion flags",
+			args: []string{"eval", dir, "--image", "foo:bar", "--fn-config-kind", "Foo",
+				"--fn-config-api-version", "fn.kpt.dev/v1alpha1", "--", "g=h"},
+			path: dir,
+			expectedFn: &runtimeutil.FunctionSpec{
+				Container: runtimeutil.ContainerSpec{
+					Image: "gcr.io/kpt-fn/foo:bar",
+				},
+			},
+			expectedFnConfig: `
+metadata:
+  name: function-input
+data: {g: h}
+kind: Foo
+apiVersion: fn.kpt.dev/v1alpha1
+`,
+		},
+		{
+			name: "apiVersion flag with custom kind argument",
+			args: []string{"eval", dir, "--image", "foo:bar", "--fn-config-api-version", "fn.kpt.dev/v1alpha1", "--", "Foo", "g=h"},
+			path: dir,
+			expectedFn: &runtimeutil.FunctionSpec{
+				Container: runtimeutil.ContainerSpec{
+					Image: "gcr.io/kpt-fn/foo:bar",
+				},
+			},
+			expectedFnConfig: `
+metadata:
+  name: function-input
+data: {g: h}
+kind: Foo
+apiVersion: fn.kpt.dev/v1alpha1
+`,
+		},
+		{
+			name: "custom kind flag and argument",
+			args: []string{"eval", dir, "--image", "foo:bar", "--fn-config-kind", "Foo", "--", "Bar", "g=h"},
+			err:  "function config kind can be specified either using --fn-config-kind or the first function argument, not both",
+		},
+		{
+			name: "custom kind flag with --fn-config",
+			args: []string{"eval", dir, "--image", "foo:bar", "--fn-config-kind", "Foo", "--fn-config", "a/b/c"},
+			err:  "--fn-config-kind and --fn-config-api-version can only be specified without function config file",
+		},
 		{
 			name: "custom kind with storage mounts",
 			args: []string{
-				"eval", dir, "--mount", "type=bind,src=/mount/path,dst=/local/",
-				"--mount", "type=volume,src=myvol,dst=/local/",
-				"--mount", "type=tmpfs,dst=/local/",
+				"eval", dir, "--mount", "type=bind,src=" + dir + ",dst=/local/",
+				"--mount", "type=bind,source=" + dir + ",target=/other/,rw=true",
 				"--image", "foo:bar", "--", "Foo", "g=h", "i=j=k"},
 			path:  dir,
-			mount: []string{"type=bind,src=/mount/path,dst=/local/", "type=volume,src=myvol,dst=/local/", "type=tmpfs,dst=/local/"},
+			mount: []string{"type=bind,src=" + mountSrc + ",dst=/local/", "type=bind,source=" + mountSrc + ",target=/other/,rw=true"},
 			expectedFn: &runtimeutil.FunctionSpec{
 				Container: runtimeutil.ContainerSpec{
 					Image: "gcr.io/kpt-fn/foo:bar",
@@ -212,6 +328,120 @@ kind: Foo
 apiVersion: v1
 `,
 		},
+		{
+			name: "max results",
+			args: []string{"eval", dir, "--image", "foo:bar", "--max-results", "10"},
+			path: dir,
+			expectedStruct: &runfn.RunFns{
+				Path:                  dir,
+				ImagePullPolicy:       fnruntime.IfNotPresentPull,
+				Env:                   []string{},
+				ContinueOnEmptyResult: true,
+				PreserveUnchanged:     true,
+				MaxResults:            10,
+				StderrMode:            fnruntime.SeparateStderr,
+				Ctx:                   context.TODO(),
+			},
+			expectedFn: &runtimeutil.FunctionSpec{
+				Container: runtimeutil.ContainerSpec{
+					Image: "gcr.io/kpt-fn/foo:bar",
+				},
+			},
+		},
+		{
+			name: "negative max results",
+			args: []string{"eval", dir, "--image", "foo:bar", "--max-results", "-1"},
+			err:  "--max-results must not be negative",
+		},
+		{
+			name: "cache dir",
+			args: []string{"eval", dir, "--image", "foo:bar", "--cache-dir", "/tmp/cache", "--no-cache"},
+			path: dir,
+			expectedStruct: &runfn.RunFns{
+				Path:                  dir,
+				ImagePullPolicy:       fnruntime.IfNotPresentPull,
+				Env:                   []string{},
+				ContinueOnEmptyResult: true,
+				PreserveUnchanged:     true,
+				CacheDir:              "/tmp/cache",
+				RefreshCache:          true,
+				StderrMode:            fnruntime.SeparateStderr,
+				Ctx:                   context.TODO(),
+			},
+			expectedFn: &runtimeutil.FunctionSpec{
+				Container: runtimeutil.ContainerSpec{
+					Image: "gcr.io/kpt-fn/foo:bar",
+				},
+			},
+		},
+		{
+			name: "no cache without cache dir",
+			args: []string{"eval", dir, "--image", "foo:bar", "--no-cache"},
+			err:  "--no-cache can only be used with --cache-dir",
+		},
+		{
+			name: "mount with missing source",
+			args: []string{"eval", dir, "--mount", "type=bind,src=/missing/path,dst=/local/", "--image", "foo:bar"},
+			err:  `invalid --mount "type=bind,src=/missing/path,dst=/local/": source "/missing/path" doesn't exist`,
+		},
+		{
+			name:  "mount with symlinked source",
+			args:  []string{"eval", dir, "--mount", "type=bind,src=" + filepath.Join(binDir, "link") + ",dst=/local/", "--image", "foo:bar"},
+			path:  dir,
+			mount: []string{"type=bind,src=" + mountSrc + ",dst=/local/"},
+		},
+		{
+			name: "mount with broken symlink source",
+			args: []string{"eval", dir, "--mount", "type=bind,src=" + filepath.Join(binDir, "broken-link") + ",dst=/local/", "--image", "foo:bar"},
+			err: `invalid --mount "type=bind,src=` + filepath.Join(binDir, "broken-link") + `,dst=/local/": source "` +
+				filepath.Join(binDir, "broken-link") + `" is a symlink to "` + filepath.Join(binDir, "missing") + `" which doesn't exist`,
+		},
+		{
+			name: "mount with unknown key",
+			args: []string{"eval", dir, "--mount", "type=bind,src=" + dir + ",dst=/local/,readonly=true", "--image", "foo:bar"},
+			err:  `invalid --mount "type=bind,src=` + dir + `,dst=/local/,readonly=true": unknown option "readonly=true"`,
+		},
+		{
+			name: "mount without destination",
+			args: []string{"eval", dir, "--mount", "type=bind,src=" + dir, "--image", "foo:bar"},
+			err:  `invalid --mount "type=bind,src=` + dir + `": must be in the format type=bind,src=<path>,dst=<path>[,rw=true]`,
+		},
+		{
+			name: "mount with unsupported type",
+			args: []string{"eval", dir, "--mount", "type=npipe,dst=/local/", "--image", "foo:bar"},
+			err:  `invalid --mount "type=npipe,dst=/local/": type "npipe" is not supported, it must be one of bind, volume and tmpfs`,
+		},
+		{
+			name: "image prefix",
+			args: []string{"eval", dir, "--image", "foo:bar", "--image-prefix", "registry.example.com/kpt-fn"},
+			path: dir,
+			expectedStruct: &runfn.RunFns{
+				Path:                  dir,
+				ImagePullPolicy:       fnruntime.IfNotPresentPull,
+				ImagePrefix:           "registry.example.com/kpt-fn",
+				Env:                   []string{},
+				ContinueOnEmptyResult: true,
+				PreserveUnchanged:     true,
+				StderrMode:            fnruntime.SeparateStderr,
+				Ctx:                   context.TODO(),
+			},
+			expectedFn: &runtimeutil.FunctionSpec{
+				Container: runtimeutil.ContainerSpec{
+					Image: "registry.example.com/kpt-fn/foo:bar",
+				},
+			},
+		},
+		{
+			name:  "tmpfs and volume mounts",
+			args:  []string{"eval", dir, "--mount", "type=tmpfs,dst=/scratch,size=64m", "--mount", "type=volume,src=myvol,dst=/data", "--image", "foo:bar"},
+			path:  dir,
+			mount: []string{"type=tmpfs,dst=/scratch,size=64m", "type=volume,src=myvol,dst=/data"},
+		},
+		{
+			name: "tmpfs mount with source",
+			args: []string{"eval", dir, "--mount", "type=tmpfs,src=" + dir + ",dst=/scratch", "--image", "foo:bar"},
+			err:  `invalid --mount "type=tmpfs,src=` + dir + `,dst=/scratch": tmpfs mounts can't have a source`,
+		},
 		{
 			name: "results_dir",
 			args: []string{"eval", dir, "--results-dir", "foo/", "--image", "foo:bar", "--", "a=b", "c=d", "e=f"},
@@ -222,6 +452,8 @@ apiVersion: v1
 				ImagePullPolicy:       fnruntime.IfNotPresentPull,
 				Env:                   []string{},
 				ContinueOnEmptyResult: true,
+				PreserveUnchanged:     true,
+				StderrMode:            fnruntime.SeparateStderr,
 				Ctx:                   context.TODO(),
 			},
 			expectedFn: &runtimeutil.FunctionSpec{
@@ -238,9 +470,30 @@ apiVersion: v1
 `,
 		},
 		{
-			name: "config map multi args",
+			name:          "config map multi args",
+			args:          []string{"eval", dir, dir, "--image", "foo:bar", "--", "a=b", "c=d", "e=f"},
+			path:          dir,
+			expectedPaths: []string{dir, dir},
+			expectedFn: &runtimeutil.FunctionSpec{
+				Container: runtimeutil.ContainerSpec{
+					Image: "gcr.io/kpt-fn/foo:bar",
+				},
+			},
+		},
+		{
+			name: "multi args missing dir",
 			args: []string{"eval", dir, "dir2", "--image", "foo:bar", "--", "a=b", "c=d", "e=f"},
-			err:  "0 or 1 arguments supported",
+			err:  "lstat dir2: no such file or directory",
+		},
+		{
+			name: "multi args with stdin",
+			args: []string{"eval", dir, "-", "--image", "foo:bar"},
+			err:  "'-' reads resources from stdin and can't be combined with directories",
+		},
+		{
+			name: "multi args with output",
+			args: []string{"eval", dir, dir, "-o", "stdout", "--image", "foo:bar"},
+			err:  "--output can't be used with multiple directories",
 		},
 		{
 			name: "config map not image",
@@ -252,6 +505,21 @@ apiVersion: v1
 			args: []string{"eval", dir, "--image", "foo:bar", "--", "a=b", "c", "e=f"},
 			err:  "must have keys and values separated by",
 		},
+		{
+			name: "match-file with save",
+			args: []string{"eval", dir, "--match-file", "*.yaml", "--save", "--type", "mutator", "--image", "foo:bar"},
+			err:  "--match-file can't be used when saving functions to Kptfile",
+		},
+		{
+			name: "match-file bad pattern",
+			args: []string{"eval", dir, "--match-file", "[", "--image", "foo:bar"},
+			err:  `--match-file pattern "[" must be valid`,
+		},
+		{
+			name: "changed-since with save",
+			args: []string{"eval", dir, "--changed-since", "main", "--save", "--type", "mutator", "--image", "foo:bar"},
+			err:  "--changed-since can't be used when saving functions to Kptfile",
+		},
 		{
 			name: "envs",
 			args: []string{"eval", dir, "--env", "FOO=BAR", "-e", "BAR", "--image", "foo:bar"},
@@ -261,6 +529,8 @@ apiVersion: v1
 				ImagePullPolicy:       fnruntime.IfNotPresentPull,
 				Env:                   []string{"FOO=BAR", "BAR"},
 				ContinueOnEmptyResult: true,
+				PreserveUnchanged:     true,
+				StderrMode:            fnruntime.SeparateStderr,
 				Ctx:                   context.TODO(),
 			},
 			expectedFn: &runtimeutil.FunctionSpec{
@@ -286,6 +556,8 @@ apiVersion: v1
 				ImagePullPolicy:       fnruntime.IfNotPresentPull,
 				Env:                   []string{},
 				ContinueOnEmptyResult: true,
+				PreserveUnchanged:     true,
+				StderrMode:            fnruntime.SeparateStderr,
 				Ctx:                   context.TODO(),
 			},
 			expectedFn: &runtimeutil.FunctionSpec{
@@ -313,7 +585,7 @@ apiVersion: v1
 		},
 		{
 			name: "exec args",
-			args: []string{"eval", dir, "--exec", "execPath arg1 'arg2 arg3'", "--", "a=b", "c=d", "e=f"},
+			args: []string{"eval", dir, "--allow-exec", "--exec", "execPath arg1 'arg2 arg3'", "--", "a=b", "c=d", "e=f"},
 			path: dir,
 			expectedFn: &runtimeutil.FunctionSpec{
 				Exec: runtimeutil.ExecSpec{
@@ -329,6 +601,149 @@ kind: ConfigMap
 apiVersion: v1
 `,
 		},
+		{
+			name: "exec env",
+			args: []string{"eval", dir, "--allow-exec", "--exec", "execPath", "--exec-env", "FOO=BAR", "--exec-env", "BAR"},
+			path: dir,
+			expectedStruct: &runfn.RunFns{
+				Path:                  dir,
+				ImagePullPolicy:       fnruntime.IfNotPresentPull,
+				Env:                   []string{},
+				ExecEnv:               []string{"FOO=BAR", "BAR"},
+				ExecArgs:              []string{},
+				OriginalExec:          "execPath",
+				ContinueOnEmptyResult: true,
+				PreserveUnchanged:     true,
+				StderrMode:            fnruntime.SeparateStderr,
+				Ctx:                   context.TODO(),
+			},
+			expectedFn: &runtimeutil.FunctionSpec{
+				Exec: runtimeutil.ExecSpec{
+					Path: "execPath",
+				},
+			},
+		},
+		{
+			name: "inject package path",
+			args: []string{"eval", dir, "--image", "foo:bar", "--inject-package-path"},
+			path: dir,
+			expectedStruct: &runfn.RunFns{
+				Path:                  dir,
+				ImagePullPolicy:       fnruntime.IfNotPresentPull,
+				Env:                   []string{},
+				InjectPackagePath:     true,
+				ContinueOnEmptyResult: true,
+				PreserveUnchanged:     true,
+				StderrMode:            fnruntime.SeparateStderr,
+				Ctx:                   context.TODO(),
+			},
+			expectedFn: &runtimeutil.FunctionSpec{
+				Container: runtimeutil.ContainerSpec{
+					Image: "gcr.io/kpt-fn/foo:bar",
+				},
+			},
+		},
+		{
+			name: "exec not in PATH",
+			args: []string{"eval", dir, "--allow-exec", "--exec", "missing-fn arg1"},
+			err:  `exec function "missing-fn" not found in PATH`,
+		},
+		{
+			name: "relative exec path not found",
+			args: []string{"eval", dir, "--allow-exec", "--exec", "./missing-fn"},
+			err:  `exec function "./missing-fn" not found`,
+		},
+		{
+			name: "no docker with exec",
+			args: []string{"eval", dir, "--allow-exec", "--exec", "execPath", "--no-docker"},
+			path: dir,
+			expectedStruct: &runfn.RunFns{
+				Path:                  dir,
+				ImagePullPolicy:       fnruntime.IfNotPresentPull,
+				Env:                   []string{},
+				ExecArgs:              []string{},
+				OriginalExec:          "execPath",
+				ContinueOnEmptyResult: true,
+				PreserveUnchanged:     true,
+				StderrMode:            fnruntime.SeparateStderr,
+				Ctx:                   context.TODO(),
+			},
+			expectedFn: &runtimeutil.FunctionSpec{
+				Exec: runtimeutil.ExecSpec{
+					Path: "execPath",
+				},
+			},
+		},
+		{
+			name: "no docker with image",
+			args: []string{"eval", dir, "--image", "foo:bar", "--no-docker"},
+			err:  `container function "foo:bar" can't be run with --no-docker, use --exec instead`,
+		},
+		{
+			name: "env with exec",
+			args: []string{"eval", dir, "--allow-exec", "--exec", "execPath", "--env", "FOO=BAR"},
+			err:  "--mount, --as-current-user, --network and --env can only be used with container functions",
+		},
+		{
+			name: "pipeline",
+			args: []string{"eval", dir, "--pipeline", pipeline, "--allow-exec"},
+			path: dir,
+			expectedStruct: &runfn.RunFns{
+				Path:                  dir,
+				ImagePullPolicy:       fnruntime.IfNotPresentPull,
+				Env:                   []string{},
+				Pipeline:              []kptfile.Function{{Exec: "execPath"}},
+				ContinueOnEmptyResult: true,
+				PreserveUnchanged:     true,
+				StderrMode:            fnruntime.SeparateStderr,
+				Ctx:                   context.TODO(),
+			},
+		},
+		{
+			name: "pipeline without allow exec",
+			args: []string{"eval", dir, "--pipeline", pipeline},
+			err:  `cannot run exec function "execPath" of the pipeline: exec functions are disabled; pass --allow-exec to enable`,
+		},
+		{
+			name: "pipeline with context dir",
+			args: []string{"eval", dir, "--pipeline", pipeline, "--allow-exec", "--context-dir", binDir},
+			err:  `--context-dir can't be used with exec function "execPath" of the pipeline`,
+		},
+		{
+			name: "pipeline with image",
+			args: []string{"eval", dir, "--pipeline", pipeline, "--image", "foo:bar"},
+			err:  "--pipeline can't be combined with --image or --exec",
+		},
+		{
+			name: "pipeline with function arguments",
+			args: []string{"eval", dir, "--pipeline", pipeline, "--", "a=b"},
+			err:  "function config can't be specified with --pipeline",
+		},
+		{
+			name: "pipeline with selector",
+			args: []string{"eval", dir, "--pipeline", pipeline, "--match-kind", "Deployment"},
+			err:  "--match-* and --exclude-* flags can't be used with --pipeline",
+		},
+		{
+			name: "pipeline with changed-since",
+			args: []string{"eval", dir, "--pipeline", pipeline, "--changed-since", "main"},
+			err:  "--changed-since can't be used with --pipeline",
+		},
+		{
+			name: "pipeline with save",
+			args: []string{"eval", dir, "--pipeline", pipeline, "--save", "--type", "mutator"},
+			err:  "--save can't be used with --pipeline",
+		},
+		{
+			name: "pipeline with image and no docker",
+			args: []string{"eval", dir, "--pipeline", imagePipeline, "--no-docker", "--allow-exec"},
+			err:  `container function "foo:bar" can't be run with --no-docker, use --exec instead`,
+		},
+		{
+			name: "missing pipeline",
+			args: []string{"eval", dir, "--pipeline", "missing.yaml"},
+			err:  `failed to read pipeline file "missing.yaml"`,
+		},
 	}
 
 	for i := range tests {
@@ -420,6 +835,12 @@ apiVersion: v1
 				}
 			}
 
+			if tt.expectedPaths != nil {
+				if !assert.Equal(t, tt.expectedPaths, r.paths) {
+					t.FailNow()
+				}
+			}
+
 			// check if exec arguments were set
 			if len(tt.expectedExecArgs) != 0 {
 				if !assert.EqualValues(t, tt.expectedExecArgs, r.RunFns.ExecArgs) {
@@ -461,5 +882,2012 @@ func TestCmd_flagAndArgParsing_Symlink(t *testing.T) {
 	assert.Equal(t, filepath.Join("path", "to", "pkg", "dir"), r.RunFns.Path)
 }
 
-// NoOpRunE is a noop function to replace the run function of a command.  Useful for testing argument parsing.
-var NoOpRunE = func(cmd *cobra.Command, args []string) error { return nil }
+func TestCmd_ignoreMountErrors(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	src, err := filepath.EvalSymlinks(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	missing := filepath.Join(dir, "missing")
+
+	// the fake docker only passes the check that docker is running, the
+	// function isn't run
+	bin := t.TempDir()
+	docker := "#!/bin/sh\n[ \"$1\" = version ] && echo 20.10.7\n"
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(bin, "docker"), []byte(docker), 0700)) {
+		t.FailNow()
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	out := &bytes.Buffer{}
+	r := GetEvalFnRunner(fake.CtxWithPrinter(out, out), "kpt")
+	r.Command.RunE = NoOpRunE
+	r.Command.SilenceErrors = true
+	r.Command.SilenceUsage = true
+	r.Command.SetArgs([]string{"-", "--image", "foo:bar", "--ignore-mount-errors",
+		"--mount", "type=bind,src=" + missing + ",dst=/cache/",
+		"--mount", "type=bind,src=" + dir + ",dst=/local/"})
+	if !assert.NoError(t, r.Command.Execute()) {
+		t.FailNow()
+	}
+	assert.Equal(t, []fnruntime.StorageMount{{StorageMount: runtimeutil.StorageMount{MountType: "bind", Src: src, DstPath: "/local/"}}},
+		r.RunFns.StorageMounts)
+	assert.Contains(t, out.String(), `[WARN] skipping --mount "type=bind,src=`+missing+`,dst=/cache/": source "`+missing+`" doesn't exist`)
+}
+
+func TestCmd_multiplePackages(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+
+	// the function fails for resources containing "fail"
+	fn := filepath.Join(dir, "fn.sh")
+	err := ioutil.WriteFile(fn, []byte("#!/bin/sh\ninput=$(cat)\necho \"$input\" | grep -q fail && exit 1\necho \"$input\"\n"), 0700)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for _, pkg := range []string{"a", "b", "c"} {
+		if !assert.NoError(t, os.Mkdir(pkg, 0700)) {
+			t.FailNow()
+		}
+		err := ioutil.WriteFile(filepath.Join(pkg, "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: `+pkg+`
+data:
+  value: `+map[string]string{"b": "fail"}[pkg]+`
+`), 0600)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+
+	testCases := map[string]struct {
+		failFast bool
+		expected []string
+		err      string
+	}{
+		"continue on failures": {
+			expected: []string{`Package "a":`, `Package "b":`, `Package "c":`,
+				"Successfully executed function on 2 of 3 package(s)."},
+			err: "function failed on package(s): b",
+		},
+		"fail fast": {
+			failFast: true,
+			expected: []string{`Package "a":`, `Package "b":`},
+			err:      `function "` + fn + `" failed with exit code 1`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			r := GetEvalFnRunner(fake.CtxWithPrinter(out, out), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			args := []string{"a", "b", "c", "--allow-exec", "--exec", fn}
+			if tc.failFast {
+				args = append(args, "--fail-fast")
+			}
+			r.Command.SetArgs(args)
+
+			err := r.Command.Execute()
+			if assert.Error(t, err) {
+				assert.Equal(t, tc.err, err.Error())
+			}
+			var lines []string
+			for _, l := range strings.Split(out.String(), "\n") {
+				if strings.HasPrefix(l, "Package") || strings.HasPrefix(l, "Successfully") {
+					lines = append(lines, l)
+				}
+			}
+			assert.Equal(t, tc.expected, lines, out.String())
+		})
+	}
+}
+
+func TestCmd_quiet(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+
+	fn := filepath.Join(dir, "fn.sh")
+	if !assert.NoError(t, ioutil.WriteFile(fn, []byte("#!/bin/sh\ncat\n"), 0700)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, os.Mkdir("pkg", 0700)) {
+		t.FailNow()
+	}
+	err := ioutil.WriteFile(filepath.Join("pkg", "Kptfile"), []byte(`apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: pkg
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	err = ioutil.WriteFile(filepath.Join("pkg", "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	for _, quiet := range []bool{false, true} {
+		t.Run(fmt.Sprintf("quiet=%t", quiet), func(t *testing.T) {
+			out := &bytes.Buffer{}
+			errOut := &bytes.Buffer{}
+			r := GetEvalFnRunner(fake.CtxWithPrinter(out, errOut), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			args := []string{"pkg", "--allow-exec", "--exec", fn, "--save", "--type", "mutator", "--output", "stdout"}
+			if quiet {
+				args = append(args, "--quiet")
+			}
+			r.Command.SetArgs(args)
+
+			if !assert.NoError(t, r.Command.Execute()) {
+				t.FailNow()
+			}
+			assert.Contains(t, out.String(), "name: cm")
+			for _, msg := range []string{"[RUNNING]", "[PASS]", "as mutator in the Kptfile"} {
+				assert.Equal(t, !quiet, strings.Contains(errOut.String(), msg), errOut.String())
+			}
+		})
+	}
+}
+
+func TestCmd_progress(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+
+	fn := filepath.Join(dir, "fn.sh")
+	err := ioutil.WriteFile(fn, []byte(`#!/bin/sh
+cat
+printf 'results:\n- message: done\n  severity: info\n'
+`), 0700)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	err = ioutil.WriteFile("pipeline.yaml", []byte("- exec: "+fn+"\n- exec: "+fn+"\n"), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	input := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"
+
+	run := func(r *EvalFnRunner, args ...string) {
+		r.Command.SilenceErrors = true
+		r.Command.SilenceUsage = true
+		r.Command.SetIn(strings.NewReader(input))
+		r.Command.SetArgs(append([]string{"-", "--allow-exec", "--pipeline", "pipeline.yaml"}, args...))
+		if !assert.NoError(t, r.Command.Execute()) {
+			t.FailNow()
+		}
+	}
+
+	t.Run("hook", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		errOut := &bytes.Buffer{}
+		r := GetEvalFnRunner(fake.CtxWithPrinter(out, errOut), "kpt")
+		var events []fnruntime.ProgressEvent
+		r.Progress = func(e fnruntime.ProgressEvent) {
+			// the resources are written once all the functions completed
+			assert.Empty(t, out.String())
+			events = append(events, e)
+		}
+		run(r)
+		if assert.Len(t, events, 2) {
+			for i, e := range events {
+				assert.Equal(t, fn, e.Name)
+				assert.Equal(t, i+1, e.Index)
+				assert.Equal(t, 2, e.Total)
+				assert.NoError(t, e.Err)
+			}
+		}
+		assert.Contains(t, out.String(), "name: cm")
+	})
+
+	t.Run("not a terminal", func(t *testing.T) {
+		errOut := &bytes.Buffer{}
+		run(GetEvalFnRunner(fake.CtxWithPrinter(&bytes.Buffer{}, errOut), "kpt"))
+		assert.Contains(t, errOut.String(), "[PASS]")
+		assert.NotContains(t, errOut.String(), "[PROGRESS]")
+	})
+
+	t.Run("json results", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		errOut := &bytes.Buffer{}
+		run(GetEvalFnRunner(fake.CtxWithPrinter(out, errOut), "kpt"), "--results-format", "json")
+		assert.Contains(t, out.String(), "name: cm")
+		var results struct {
+			Kind  string `json:"kind"`
+			Items []struct {
+				Exec string `json:"exec"`
+			} `json:"items"`
+		}
+		if assert.NoError(t, json.Unmarshal(errOut.Bytes(), &results), errOut.String()) {
+			assert.Equal(t, "FunctionResultList", results.Kind)
+			assert.Len(t, results.Items, 2)
+		}
+	})
+
+	t.Run("invalid results format", func(t *testing.T) {
+		r := GetEvalFnRunner(fake.CtxWithDefaultPrinter(), "kpt")
+		r.Command.SilenceErrors = true
+		r.Command.SilenceUsage = true
+		r.Command.SetArgs([]string{"-", "--allow-exec", "--exec", fn, "--results-format", "yaml"})
+		assert.EqualError(t, r.Command.Execute(), "--results-format must be either `text` or `json`")
+	})
+}
+
+func TestEvalFnRunner_printProgress(t *testing.T) {
+	errOut := &bytes.Buffer{}
+	r := &EvalFnRunner{Ctx: fake.CtxWithPrinter(&bytes.Buffer{}, errOut)}
+	r.printProgress(fnruntime.ProgressEvent{Name: "fn", Index: 1, Total: 1})
+	assert.Empty(t, errOut.String())
+	r.printProgress(fnruntime.ProgressEvent{Name: "fn", Index: 1, Total: 3})
+	assert.Equal(t, "[PROGRESS] 1/3 functions completed\n", errOut.String())
+}
+
+func TestCmd_allowExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+
+	fn := filepath.Join(dir, "fn.sh")
+	if !assert.NoError(t, ioutil.WriteFile(fn, []byte("#!/bin/sh\ncat\n"), 0700)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, os.Mkdir("pkg", 0700)) {
+		t.FailNow()
+	}
+	err := ioutil.WriteFile(filepath.Join("pkg", "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	testCases := map[string]struct {
+		args []string
+		env  string
+		err  string
+	}{
+		"disabled by default": {
+			err: "exec functions are disabled; pass --allow-exec to enable",
+		},
+		"allowed by flag": {
+			args: []string{"--allow-exec"},
+		},
+		"allowed by env": {
+			env: "true",
+		},
+		"disabled by env": {
+			env: "false",
+			err: "exec functions are disabled; pass --allow-exec to enable",
+		},
+		"flag overrides env": {
+			args: []string{"--allow-exec"},
+			env:  "false",
+		},
+		"invalid env": {
+			env: "yes",
+			err: "$KPT_FN_ALLOW_EXEC must be true or false",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			t.Setenv(allowExecEnv, tc.env)
+			out := &bytes.Buffer{}
+			r := GetEvalFnRunner(fake.CtxWithPrinter(out, out), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			r.Command.SetArgs(append([]string{"pkg", "--exec", fn, "--output", "stdout"}, tc.args...))
+
+			err := r.Command.Execute()
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.err)
+				}
+				assert.NotContains(t, out.String(), "[RUNNING]")
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Contains(t, out.String(), "name: cm")
+			}
+		})
+	}
+}
+
+func TestCmd_imagePrefix(t *testing.T) {
+	testCases := map[string]struct {
+		args     []string
+		env      string
+		expected string
+		prefix   string
+	}{
+		"default": {
+			expected: "gcr.io/kpt-fn/foo:bar",
+		},
+		"env": {
+			env:      "mirror.example.com/fns",
+			expected: "mirror.example.com/fns/foo:bar",
+			prefix:   "mirror.example.com/fns",
+		},
+		"flag overrides env": {
+			args:     []string{"--image-prefix", "registry.example.com/kpt-fn"},
+			env:      "mirror.example.com/fns",
+			expected: "registry.example.com/kpt-fn/foo:bar",
+			prefix:   "registry.example.com/kpt-fn",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			t.Setenv(imagePrefixEnv, tc.env)
+			r := GetEvalFnRunner(context.TODO(), "kpt")
+			// Don't run the actual command
+			r.Command.RunE = func(cmd *cobra.Command, args []string) error { return nil }
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			r.Command.SetArgs(append([]string{"-", "--image", "foo:bar"}, tc.args...))
+
+			if assert.NoError(t, r.Command.Execute()) {
+				assert.Equal(t, tc.expected, r.Image)
+				// the functions of --pipeline are resolved against the same prefix
+				assert.Equal(t, tc.prefix, r.RunFns.ImagePrefix)
+			}
+		})
+	}
+}
+
+func TestCmd_saveFnType(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+
+	// the fake docker runs functions which don't change the resources and
+	// prints the labels of the images
+	docker := `#!/bin/sh
+case "$1" in
+  version) echo "20.10.7" ;;
+  run) cat ;;
+  image)
+    case "$5" in
+      example.com/validator:v1) echo '{"org.kpt.function.type":"validator"}' ;;
+      example.com/unlabeled:v1) echo 'null' ;;
+      *) exit 1 ;;
+    esac ;;
+esac
+`
+	bin := filepath.Join(dir, "bin")
+	if !assert.NoError(t, os.Mkdir(bin, 0700)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(bin, "docker"), []byte(docker), 0700)) {
+		t.FailNow()
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	testCases := map[string]struct {
+		args     []string
+		expected string
+		warning  string
+	}{
+		"labeled as validator": {
+			args: []string{"--image", "example.com/validator:v1"},
+			expected: `  validators:
+    - image: example.com/validator:v1
+`,
+		},
+		"unlabeled": {
+			args: []string{"--image", "example.com/unlabeled:v1"},
+			expected: `  mutators:
+    - image: example.com/unlabeled:v1
+`,
+		},
+		"save-as overrides label": {
+			args: []string{"--image", "example.com/validator:v1", "--save-as", "mutator"},
+			expected: `  mutators:
+    - image: example.com/validator:v1
+`,
+		},
+		"inspect fails": {
+			args: []string{"--image", "example.com/missing:v1"},
+			expected: `  mutators:
+    - image: example.com/missing:v1
+`,
+			warning: `[WARN] cannot detect the type of function "example.com/missing:v1", saving it as mutator: cannot inspect image "example.com/missing:v1": exit status 1`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			pkgDir := filepath.Join(dir, strings.ReplaceAll(tn, " ", "-"))
+			if !assert.NoError(t, os.Mkdir(pkgDir, 0700)) {
+				t.FailNow()
+			}
+			err := ioutil.WriteFile(filepath.Join(pkgDir, "Kptfile"), []byte(`apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: pkg
+`), 0600)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			out := &bytes.Buffer{}
+			r := GetEvalFnRunner(fake.CtxWithPrinter(out, out), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			r.Command.SetArgs(append([]string{pkgDir, "--save"}, tc.args...))
+			if !assert.NoError(t, r.Command.Execute(), out.String()) {
+				t.FailNow()
+			}
+
+			b, err := ioutil.ReadFile(filepath.Join(pkgDir, "Kptfile"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Contains(t, string(b), tc.expected)
+			if tc.warning != "" {
+				assert.Contains(t, out.String(), tc.warning)
+			}
+		})
+	}
+}
+
+func TestCmd_saveUpdate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+	fn := filepath.Join(dir, "fn.sh")
+	if !assert.NoError(t, ioutil.WriteFile(fn, []byte("#!/bin/sh\ncat\n"), 0700)) {
+		t.FailNow()
+	}
+
+	testCases := map[string]struct {
+		args     []string
+		expected kptfile.Function
+	}{
+		"replace by default": {
+			args: []string{"--", "b=3"},
+			expected: kptfile.Function{
+				Exec:      fn,
+				ConfigMap: map[string]string{"b": "3"},
+			},
+		},
+		"update config map": {
+			args: []string{"--update", "--", "b=3"},
+			expected: kptfile.Function{
+				Name:      "my-fn",
+				Exec:      fn,
+				ConfigMap: map[string]string{"a": "1", "b": "3"},
+				Selectors: []kptfile.Selector{{Kind: "ConfigMap"}},
+			},
+		},
+		"update selectors": {
+			args: []string{"--update", "--match-kind", "Deployment", "--exclude-name", "cm"},
+			expected: kptfile.Function{
+				Name:       "my-fn",
+				Exec:       fn,
+				ConfigMap:  map[string]string{"a": "1", "b": "2"},
+				Selectors:  []kptfile.Selector{{Kind: "Deployment"}},
+				Exclusions: []kptfile.Selector{{Name: "cm"}},
+			},
+		},
+		"update config path": {
+			args: []string{"--update", "--fn-config", "config.yaml", "--fn-config-relative"},
+			expected: kptfile.Function{
+				Name:       "my-fn",
+				Exec:       fn,
+				ConfigPath: "config.yaml",
+				Selectors:  []kptfile.Selector{{Kind: "ConfigMap"}},
+			},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			pkgDir := filepath.Join(dir, strings.ReplaceAll(tn, " ", "-"))
+			if !assert.NoError(t, os.Mkdir(pkgDir, 0700)) {
+				t.FailNow()
+			}
+			for name, content := range map[string]string{
+				"Kptfile": `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: pkg
+pipeline:
+  mutators:
+    - name: my-fn
+      exec: ` + fn + `
+      configMap:
+        a: "1"
+        b: "2"
+      selectors:
+        - kind: ConfigMap
+`,
+				"config.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  annotations:
+    config.kubernetes.io/local-config: "true"
+`,
+			} {
+				if !assert.NoError(t, ioutil.WriteFile(filepath.Join(pkgDir, name), []byte(content), 0600)) {
+					t.FailNow()
+				}
+			}
+
+			out := &bytes.Buffer{}
+			r := GetEvalFnRunner(fake.CtxWithPrinter(out, out), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			r.Command.SetArgs(append([]string{pkgDir, "--allow-exec", "--exec", fn, "--save"}, tc.args...))
+			if !assert.NoError(t, r.Command.Execute(), out.String()) {
+				t.FailNow()
+			}
+			assert.Contains(t, out.String(), fmt.Sprintf("Updated %q as mutator in the Kptfile.", fn))
+
+			kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, pkgDir)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, []kptfile.Function{tc.expected}, kf.Pipeline.Mutators)
+		})
+	}
+
+	r := GetEvalFnRunner(fake.CtxWithPrinter(&bytes.Buffer{}, &bytes.Buffer{}), "kpt")
+	r.Command.SilenceErrors = true
+	r.Command.SilenceUsage = true
+	r.Command.SetArgs([]string{dir, "--allow-exec", "--exec", fn, "--update"})
+	assert.EqualError(t, r.Command.Execute(), "--update can only be used when saving functions to Kptfile (--save=true)")
+}
+
+func TestCmd_checkIdempotent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+
+	resources := map[string]string{
+		"cm.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+data:
+  value: a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+data:
+  value: b
+`,
+		"other.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: c
+data:
+  value: c
+`,
+	}
+	if !assert.NoError(t, os.Mkdir("pkg", 0700)) {
+		t.FailNow()
+	}
+	for name, content := range resources {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join("pkg", name), []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+
+	testCases := map[string]struct {
+		script   string
+		expected []string
+		err      string
+	}{
+		"idempotent": {
+			script:   "#!/bin/sh\nsed 's/value: [a-z]*$/value: set/'\n",
+			expected: []string{"Function is idempotent."},
+		},
+		"not idempotent": {
+			script: "#!/bin/sh\nsed 's/value: \\([a-z]*\\)$/value: \\1x/'\n",
+			expected: []string{
+				"--- first execution\n+++ second execution\n",
+				"-  value: ax\n+  value: axx\n",
+				"-  value: cx\n+  value: cxx\n",
+			},
+			err: "function is not idempotent, executing it on its output changed the resources",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			fn := filepath.Join(dir, strings.ReplaceAll(tn, " ", "-")+".sh")
+			if !assert.NoError(t, ioutil.WriteFile(fn, []byte(tc.script), 0700)) {
+				t.FailNow()
+			}
+
+			out := &bytes.Buffer{}
+			r := GetEvalFnRunner(fake.CtxWithPrinter(out, out), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			r.Command.SetArgs([]string{"pkg", "--allow-exec", "--exec", fn, "--check-idempotent"})
+			err := r.Command.Execute()
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.err, err.Error())
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+			for _, e := range tc.expected {
+				assert.Contains(t, out.String(), e)
+			}
+
+			// the package isn't modified
+			for name, content := range resources {
+				b, err := ioutil.ReadFile(filepath.Join("pkg", name))
+				if assert.NoError(t, err) {
+					assert.Equal(t, content, string(b))
+				}
+			}
+		})
+	}
+}
+
+// NoOpRunE is a noop function to replace the run function of a command.  Useful for testing argument parsing.
+var NoOpRunE = func(cmd *cobra.Command, args []string) error { return nil }
+
+func TestCmd_strict(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+
+	if !assert.NoError(t, os.Mkdir("pkg", 0700)) {
+		t.FailNow()
+	}
+	err := ioutil.WriteFile(filepath.Join("pkg", "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// the fixture functions return the resources with a result of their
+	// severity
+	for _, severity := range []string{"error", "warning", "info"} {
+		fn := fmt.Sprintf("#!/bin/sh\ncat\nprintf 'results:\\n- message: %s result\\n  severity: %s\\n'\n", severity, severity)
+		if severity == "error" {
+			fn += "exit 1\n"
+		}
+		if !assert.NoError(t, ioutil.WriteFile(severity+".sh", []byte(fn), 0700)) {
+			t.FailNow()
+		}
+	}
+
+	testCases := map[string]struct {
+		severity string
+		strict   bool
+		err      string
+	}{
+		"error": {
+			severity: "error",
+			err:      "failed with exit code 1",
+		},
+		"error with strict": {
+			severity: "error",
+			strict:   true,
+			err:      "failed with exit code 1",
+		},
+		"warning": {
+			severity: "warning",
+		},
+		"warning with strict": {
+			severity: "warning",
+			strict:   true,
+			err:      "function returned 1 warning result(s), which are failures with --strict",
+		},
+		"info": {
+			severity: "info",
+		},
+		"info with strict": {
+			severity: "info",
+			strict:   true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			errOut := &bytes.Buffer{}
+			r := GetEvalFnRunner(fake.CtxWithPrinter(out, errOut), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			args := []string{"pkg", "--allow-exec", "--exec", filepath.Join(dir, tc.severity+".sh"), "--output", "stdout"}
+			if tc.strict {
+				args = append(args, "--strict")
+			}
+			r.Command.SetArgs(args)
+
+			err := r.Command.Execute()
+			assert.Contains(t, errOut.String(), tc.severity+" result")
+			if tc.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestCmd_resultsIgnore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+
+	if !assert.NoError(t, os.Mkdir("pkg", 0700)) {
+		t.FailNow()
+	}
+	err := ioutil.WriteFile(filepath.Join("pkg", "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// the fixture functions return the resources with a result of their
+	// severity about the ConfigMap
+	for _, severity := range []string{"error", "warning"} {
+		fn := fmt.Sprintf("#!/bin/sh\ncat\nprintf 'results:\\n- message: %s result\\n  severity: %s\\n"+
+			"  resourceRef:\\n    apiVersion: v1\\n    kind: ConfigMap\\n    name: cm\\n'\n", severity, severity)
+		if severity == "error" {
+			fn += "exit 1\n"
+		}
+		if !assert.NoError(t, ioutil.WriteFile(severity+".sh", []byte(fn), 0700)) {
+			t.FailNow()
+		}
+	}
+
+	testCases := map[string]struct {
+		severity string
+		strict   bool
+		ignore   string
+		ignored  int
+		err      string
+	}{
+		"error ignored by message": {
+			severity: "error",
+			ignore:   "- message: ^error\n",
+			ignored:  1,
+		},
+		"error ignored by function and resource": {
+			severity: "error",
+			ignore: fmt.Sprintf("- function: %s\n  resource:\n    kind: ConfigMap\n    name: cm\n",
+				filepath.Join(dir, "error.sh")),
+			ignored: 1,
+		},
+		"error of another resource": {
+			severity: "error",
+			ignore:   "- resource:\n    kind: ConfigMap\n    name: other\n",
+			err:      "failed with exit code 1",
+		},
+		"error of another function": {
+			severity: "error",
+			ignore:   "- function: '*/warning.sh'\n  message: result\n",
+			err:      "failed with exit code 1",
+		},
+		"warning ignored with strict": {
+			severity: "warning",
+			strict:   true,
+			ignore:   "- message: warning result\n",
+			ignored:  1,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			ignoreFile := filepath.Join(t.TempDir(), "ignore.yaml")
+			if !assert.NoError(t, ioutil.WriteFile(ignoreFile, []byte(tc.ignore), 0600)) {
+				t.FailNow()
+			}
+			out := &bytes.Buffer{}
+			errOut := &bytes.Buffer{}
+			r := GetEvalFnRunner(fake.CtxWithPrinter(out, errOut), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			args := []string{"pkg", "--allow-exec", "--exec", filepath.Join(dir, tc.severity+".sh"), "--output", "stdout",
+				"--results-ignore", ignoreFile}
+			if tc.strict {
+				args = append(args, "--strict")
+			}
+			r.Command.SetArgs(args)
+
+			err := r.Command.Execute()
+			assert.Contains(t, errOut.String(), fmt.Sprintf("Results listed in %q ignored: %d\n", ignoreFile, tc.ignored))
+			if tc.err == "" {
+				assert.NoError(t, err)
+				assert.NotContains(t, errOut.String(), tc.severity+" result")
+				assert.Contains(t, out.String(), "name: cm")
+				return
+			}
+			assert.Contains(t, errOut.String(), tc.severity+" result")
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestCmd_registryAuth(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+
+	// the fake docker emulates a registry requiring basic auth with the
+	// credentials user:s3cr3t, and records the config directories it is
+	// run with
+	configDirs := filepath.Join(dir, "config-dirs")
+	docker := `#!/bin/sh
+config=
+if [ "$1" = "--config" ]; then config=$2; echo "$config" >> ` + configDirs + `; shift 2; fi
+authorized() { grep -q '"auth": *"dXNlcjpzM2NyM3Q="' "$config/config.json" 2>/dev/null; }
+case "$1" in
+  version) echo "20.10.7" ;;
+  image) exit 1 ;;
+  manifest) exit 1 ;;
+  pull) authorized || { echo "Error response from daemon: unauthorized: authentication required" >&2; exit 1; } ;;
+  run) authorized || { echo "docker: Error response from daemon: unauthorized: authentication required." >&2; exit 125; }; cat ;;
+esac
+`
+	bin := filepath.Join(dir, "bin")
+	if !assert.NoError(t, os.Mkdir(bin, 0700)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(bin, "docker"), []byte(docker), 0700)) {
+		t.FailNow()
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	if !assert.NoError(t, os.Mkdir("pkg", 0700)) {
+		t.FailNow()
+	}
+	err := ioutil.WriteFile(filepath.Join("pkg", "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for name, auth := range map[string]string{
+		"auth.json":  "dXNlcjpzM2NyM3Q=",
+		"wrong.json": "dXNlcjp3cm9uZw==",
+	} {
+		b := fmt.Sprintf(`{"auths": {"example.com": {"auth": %q}}}`, auth)
+		if !assert.NoError(t, ioutil.WriteFile(name, []byte(b), 0600)) {
+			t.FailNow()
+		}
+	}
+
+	testCases := map[string]struct {
+		args []string
+		err  string
+	}{
+		"credentials": {
+			args: []string{"--registry-auth", "auth.json"},
+		},
+		"credentials with pull retries": {
+			args: []string{"--registry-auth", "auth.json", "--pull-retries", "1"},
+		},
+		"wrong credentials": {
+			args: []string{"--registry-auth", "wrong.json", "--pull-retries", "1"},
+			err:  "unauthorized: authentication required",
+		},
+		"no credentials": {
+			err: "unauthorized: authentication required",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			_ = os.Remove(configDirs)
+			out := &bytes.Buffer{}
+			errOut := &bytes.Buffer{}
+			r := GetEvalFnRunner(fake.CtxWithPrinter(out, errOut), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			r.Command.SetArgs(append([]string{"pkg", "--image", "example.com/private/fn:v1", "--verbose",
+				"--output", "stdout"}, tc.args...))
+
+			err := r.Command.Execute()
+			// neither the credentials nor their encoding are printed
+			for _, s := range []string{"s3cr3t", "dXNlcjpzM2NyM3Q=", "dXNlcjp3cm9uZw=="} {
+				assert.NotContains(t, out.String()+errOut.String(), s)
+				if err != nil {
+					assert.NotContains(t, err.Error(), s)
+				}
+			}
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error()+errOut.String(), tc.err)
+				}
+			} else if assert.NoError(t, err) {
+				assert.Contains(t, out.String(), "name: cm")
+			}
+
+			// the config directory is removed after the execution
+			b, _ := ioutil.ReadFile(configDirs)
+			if len(tc.args) == 0 {
+				assert.Empty(t, b)
+				return
+			}
+			for _, d := range strings.Fields(string(b)) {
+				assert.NoDirExists(t, d)
+			}
+			assert.NotEmpty(t, b)
+		})
+	}
+}
+
+func TestCmd_contextDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	if resolved, err := filepath.EvalSymlinks(dir); assert.NoError(t, err) {
+		dir = resolved
+	}
+	defer testutil.Chdir(t, dir)()
+
+	// the fake docker runs functions which don't change the resources and
+	// records the arguments they are run with
+	runArgs := filepath.Join(dir, "run-args")
+	docker := `#!/bin/sh
+case "$1" in
+  version) echo "20.10.7" ;;
+  run) echo "$@" > ` + runArgs + `; cat ;;
+esac
+`
+	bin := filepath.Join(dir, "bin")
+	if !assert.NoError(t, os.Mkdir(bin, 0700)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(bin, "docker"), []byte(docker), 0700)) {
+		t.FailNow()
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	for _, d := range []string{"pkg", "templates"} {
+		if !assert.NoError(t, os.Mkdir(d, 0700)) {
+			t.FailNow()
+		}
+	}
+	err := ioutil.WriteFile(filepath.Join("pkg", "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	testCases := map[string]struct {
+		contextDir string
+		mountSrc   string
+		warning    string
+		err        string
+	}{
+		"sibling directory": {
+			contextDir: "templates",
+			mountSrc:   filepath.Join(dir, "templates"),
+		},
+		"parent of the package": {
+			contextDir: ".",
+			mountSrc:   dir,
+			warning:    `[WARN] --context-dir "." overlaps the package "pkg"`,
+		},
+		"package": {
+			contextDir: "pkg",
+			mountSrc:   filepath.Join(dir, "pkg"),
+			warning:    `[WARN] --context-dir "pkg" overlaps the package "pkg"`,
+		},
+		"missing": {
+			contextDir: "missing",
+			err:        `invalid --context-dir "missing": source "missing" doesn't exist`,
+		},
+		"file": {
+			contextDir: filepath.Join("pkg", "cm.yaml"),
+			err:        `--context-dir "pkg/cm.yaml" must be a directory`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			_ = os.Remove(runArgs)
+			out := &bytes.Buffer{}
+			errOut := &bytes.Buffer{}
+			r := GetEvalFnRunner(fake.CtxWithPrinter(out, errOut), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			r.Command.SetArgs([]string{"pkg", "--image", "example.com/fn:v1", "--context-dir", tc.contextDir,
+				"--output", "stdout"})
+
+			err := r.Command.Execute()
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.err)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Contains(t, out.String(), "name: cm")
+			if tc.warning != "" {
+				assert.Contains(t, errOut.String(), tc.warning)
+			} else {
+				assert.NotContains(t, errOut.String(), "[WARN]")
+			}
+			b, err := ioutil.ReadFile(runArgs)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Contains(t, string(b), "--mount type=bind,source="+tc.mountSrc+",target=/kpt-context,readonly ")
+			assert.Contains(t, string(b), "-e KPT_CONTEXT_DIR=/kpt-context ")
+			assert.Contains(t, string(b), "--workdir /kpt-context example.com/fn:v1")
+		})
+	}
+}
+
+func TestCmd_junitReport(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+
+	for _, pkg := range []string{"a", "b"} {
+		if !assert.NoError(t, os.Mkdir(pkg, 0700)) {
+			t.FailNow()
+		}
+		err := ioutil.WriteFile(filepath.Join(pkg, "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`), 0600)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+	fn := "#!/bin/sh\ncat\nprintf 'results:\\n" +
+		"- message: missing label\\n  severity: error\\n" +
+		"  resourceRef: {apiVersion: v1, kind: ConfigMap, name: cm}\\n  field: {path: metadata.labels}\\n" +
+		"- message: deprecated\\n  severity: warning\\n'\n"
+	if !assert.NoError(t, ioutil.WriteFile("fn.sh", []byte(fn), 0700)) {
+		t.FailNow()
+	}
+
+	testCases := map[string]struct {
+		strict   bool
+		failures int
+	}{
+		"errors fail": {
+			failures: 2,
+		},
+		"warnings fail with strict": {
+			strict:   true,
+			failures: 4,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			report := filepath.Join(dir, "report.xml")
+			r := GetEvalFnRunner(fake.CtxWithPrinter(&bytes.Buffer{}, &bytes.Buffer{}), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			args := []string{"a", "b", "--allow-exec", "--exec", filepath.Join(dir, "fn.sh"), "--junit-report", report, "--results-dir", dir}
+			if tc.strict {
+				args = append(args, "--strict")
+			}
+			r.Command.SetArgs(args)
+			// the functions don't fail as they exit with 0
+			err := r.Command.Execute()
+			if tc.strict {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			b, err := ioutil.ReadFile(report)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			var got fnruntime.JUnitReport
+			if !assert.NoError(t, xml.Unmarshal(b, &got)) {
+				t.FailNow()
+			}
+			assert.Equal(t, 4, got.Tests)
+			assert.Equal(t, tc.failures, got.Failures)
+			if !assert.Len(t, got.Suites, 2) {
+				t.FailNow()
+			}
+			for i, pkg := range []string{"a", "b"} {
+				cases := got.Suites[i].TestCases
+				if !assert.Len(t, cases, 2) {
+					t.FailNow()
+				}
+				assert.Equal(t, pkg, cases[0].ClassName)
+				assert.Equal(t, "v1/ConfigMap/cm: metadata.labels", cases[0].Name)
+				if assert.NotNil(t, cases[0].Failure) {
+					assert.Equal(t, "missing label", cases[0].Failure.Message)
+				}
+				assert.Equal(t, "deprecated", cases[1].Name)
+				assert.Equal(t, tc.strict, cases[1].Failure != nil)
+			}
+		})
+	}
+}
+
+func TestCmd_archive(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+	fn := filepath.Join(dir, "fn.sh")
+	err := ioutil.WriteFile(fn, []byte("#!/bin/sh\nsed 's/name: cm$/name: renamed/'\n"), 0700)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	failingFn := filepath.Join(dir, "fail.sh")
+	if !assert.NoError(t, ioutil.WriteFile(failingFn, []byte("#!/bin/sh\nexit 1\n"), 0700)) {
+		t.FailNow()
+	}
+
+	testCases := map[string]struct {
+		args        []string
+		expectedErr string
+		// archives maps the archives to the expected name of the ConfigMap
+		archives map[string]string
+		stdout   string
+	}{
+		"in place": {
+			args:     []string{"pkg.tar.gz", "--allow-exec", "--exec", fn},
+			archives: map[string]string{"pkg.tar.gz": "renamed"},
+		},
+		"output archive": {
+			args:     []string{"pkg.tar.gz", "--allow-exec", "--exec", fn, "--output", "out.tgz"},
+			archives: map[string]string{"pkg.tar.gz": "cm", "out.tgz": "renamed"},
+		},
+		"output to stdout": {
+			args:     []string{"pkg.tar.gz", "--allow-exec", "--exec", fn, "--output", "unwrap"},
+			archives: map[string]string{"pkg.tar.gz": "cm"},
+			stdout:   "name: renamed",
+		},
+		"output directory": {
+			args:        []string{"pkg.tar.gz", "--allow-exec", "--exec", fn, "--output", "out"},
+			expectedErr: "--output must be stdout, unwrap, resourcelist or an archive ending with .tar.gz or .tgz when the package is an archive",
+		},
+		"save": {
+			args:        []string{"pkg.tar.gz", "--allow-exec", "--exec", fn, "--save"},
+			expectedErr: "--save can't be used with an archive, as its Kptfile isn't kept",
+		},
+		"multiple packages": {
+			args:        []string{"pkg.tar.gz", "other", "--allow-exec", "--exec", fn},
+			expectedErr: `archive "pkg.tar.gz" can't be combined with other packages`,
+		},
+		"failing function": {
+			args:        []string{"pkg.tar.gz", "--allow-exec", "--exec", failingFn},
+			expectedErr: "failed with exit code 1",
+			archives:    map[string]string{"pkg.tar.gz": "cm"},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+			pkgDir := t.TempDir()
+			if !assert.NoError(t, os.Mkdir(filepath.Join(pkgDir, "pkg"), 0700)) {
+				t.FailNow()
+			}
+			err := ioutil.WriteFile(filepath.Join(pkgDir, "pkg", "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`), 0600)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			for _, f := range []string{"pkg.tar.gz", "out.tgz"} {
+				_ = os.Remove(filepath.Join(dir, f))
+			}
+			if !assert.NoError(t, writeArchive(pkgDir, filepath.Join(dir, "pkg.tar.gz"))) {
+				t.FailNow()
+			}
+
+			out := &bytes.Buffer{}
+			r := GetEvalFnRunner(fake.CtxWithPrinter(out, &bytes.Buffer{}), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			r.Command.SetArgs(tc.args)
+			err = r.Command.Execute()
+			if tc.expectedErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectedErr)
+				}
+			} else if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			for archive, name := range tc.archives {
+				extracted := t.TempDir()
+				if !assert.NoError(t, extractArchive(filepath.Join(dir, archive), extracted)) {
+					continue
+				}
+				b, err := ioutil.ReadFile(filepath.Join(extracted, "pkg", "cm.yaml"))
+				if assert.NoError(t, err) {
+					assert.Contains(t, string(b), "name: "+name+"\n")
+				}
+			}
+			assert.Contains(t, out.String(), tc.stdout)
+
+			// the extracted package is removed
+			entries, err := ioutil.ReadDir(tmp)
+			assert.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestCmd_fnConfigRelative(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+	fn := filepath.Join(dir, "fn.sh")
+	if !assert.NoError(t, ioutil.WriteFile(fn, []byte("#!/bin/sh\ncat\n"), 0700)) {
+		t.FailNow()
+	}
+	absConfig := filepath.Join(t.TempDir(), "config.yaml")
+	for path, value := range map[string]string{
+		"config.yaml":     "cwd",
+		"pkg/config.yaml": "pkg",
+		absConfig:         "abs",
+	} {
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700)) {
+			t.FailNow()
+		}
+		err := ioutil.WriteFile(path, []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  annotations:
+    config.kubernetes.io/local-config: "true"
+data:
+  from: `+value+`
+`), 0600)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+
+	testCases := map[string]struct {
+		fnConfig    string
+		relative    bool
+		expected    string
+		expectedErr string
+	}{
+		"absolute": {
+			fnConfig: absConfig,
+			expected: "abs",
+		},
+		"absolute with relative": {
+			fnConfig: absConfig,
+			relative: true,
+			expected: "abs",
+		},
+		"relative to current directory": {
+			fnConfig: "config.yaml",
+			expected: "cwd",
+		},
+		"relative to package": {
+			fnConfig: "config.yaml",
+			relative: true,
+			expected: "pkg",
+		},
+		"missing in package": {
+			fnConfig:    "pkg/config.yaml",
+			relative:    true,
+			expectedErr: "missing function config file: " + filepath.Join("pkg", "pkg", "config.yaml"),
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			r := GetEvalFnRunner(fake.CtxWithPrinter(out, &bytes.Buffer{}), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			args := []string{"pkg", "--allow-exec", "--exec", fn, "--fn-config", tc.fnConfig, "--output", "resourcelist"}
+			if tc.relative {
+				args = append(args, "--fn-config-relative")
+			}
+			r.Command.SetArgs(args)
+			err := r.Command.Execute()
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Contains(t, out.String(), "from: "+tc.expected+"\n")
+		})
+	}
+
+	r := GetEvalFnRunner(fake.CtxWithPrinter(&bytes.Buffer{}, &bytes.Buffer{}), "kpt")
+	r.Command.SilenceErrors = true
+	r.Command.SilenceUsage = true
+	r.Command.SetArgs([]string{"-", "--allow-exec", "--exec", fn, "--fn-config", "config.yaml", "--fn-config-relative"})
+	r.Command.SetIn(strings.NewReader(""))
+	assert.EqualError(t, r.Command.Execute(), "--fn-config-relative can't be used when reading resources from stdin")
+
+	r = GetEvalFnRunner(fake.CtxWithPrinter(&bytes.Buffer{}, &bytes.Buffer{}), "kpt")
+	r.Command.SilenceErrors = true
+	r.Command.SilenceUsage = true
+	r.Command.SetArgs([]string{"pkg", "--allow-exec", "--exec", fn, "--fn-config-relative"})
+	assert.EqualError(t, r.Command.Execute(), "--fn-config-relative can only be used with --fn-config")
+}
+
+func TestCmd_changedSince(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=kpt", "-c", "user.email=kpt@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if !assert.NoError(t, err, string(out)) {
+			t.FailNow()
+		}
+	}
+	fn := filepath.Join(t.TempDir(), "fn.sh")
+	if !assert.NoError(t, ioutil.WriteFile(fn, []byte("#!/bin/sh\nsed 's/value: .*/value: evaluated/'\n"), 0700)) {
+		t.FailNow()
+	}
+	writeConfigMap := func(path, value string) {
+		err := ioutil.WriteFile(path, []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: `+strings.TrimSuffix(filepath.Base(path), ".yaml")+`
+data:
+  value: `+value+`
+`), 0600)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+	if !assert.NoError(t, os.Mkdir("pkg", 0700)) {
+		t.FailNow()
+	}
+	writeConfigMap(filepath.Join("pkg", "a.yaml"), "a")
+	writeConfigMap(filepath.Join("pkg", "b.yaml"), "b")
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	git("tag", "v1")
+
+	run := func(args ...string) (string, error) {
+		out := &bytes.Buffer{}
+		r := GetEvalFnRunner(fake.CtxWithPrinter(out, out), "kpt")
+		r.Command.SilenceErrors = true
+		r.Command.SilenceUsage = true
+		r.Command.SetArgs(append([]string{"pkg", "--allow-exec", "--exec", fn}, args...))
+		err := r.Command.Execute()
+		return out.String(), err
+	}
+	read := func(path string) string {
+		b, err := ioutil.ReadFile(path)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return string(b)
+	}
+
+	out, err := run("--changed-since", "v1")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, out, `No files changed since "v1"`)
+	assert.Contains(t, read(filepath.Join("pkg", "a.yaml")), "value: a\n")
+	assert.Contains(t, read(filepath.Join("pkg", "b.yaml")), "value: b\n")
+
+	writeConfigMap(filepath.Join("pkg", "b.yaml"), "modified")
+	writeConfigMap(filepath.Join("pkg", "c.yaml"), "added")
+	_, err = run("--changed-since", "v1")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, read(filepath.Join("pkg", "a.yaml")), "value: a\n")
+	assert.Contains(t, read(filepath.Join("pkg", "b.yaml")), "value: evaluated\n")
+	assert.Contains(t, read(filepath.Join("pkg", "c.yaml")), "value: evaluated\n")
+
+	_, err = run("--changed-since", "missing")
+	assert.Error(t, err)
+}
+
+func TestEvalFnRunner_Validate(t *testing.T) {
+	resultsDir := filepath.Join(t.TempDir(), "results")
+	testCases := map[string]struct {
+		runner      EvalFnRunner
+		expectedErr string
+	}{
+		"image": {
+			runner: EvalFnRunner{Image: "foo:bar", Network: true, ResultsDir: resultsDir},
+		},
+		"exec with data items": {
+			runner: EvalFnRunner{Exec: "my-fn", AllowExec: true, dataItems: []string{"a=b"}},
+		},
+		"pipeline": {
+			runner: EvalFnRunner{PipelinePath: "pipeline.yaml"},
+		},
+		"no function": {
+			expectedErr: "must specify --image, --exec or --pipeline",
+		},
+		"image and exec": {
+			runner:      EvalFnRunner{Image: "foo:bar", Exec: "my-fn"},
+			expectedErr: "--image can't be combined with --exec",
+		},
+		"pipeline and image": {
+			runner:      EvalFnRunner{Image: "foo:bar", PipelinePath: "pipeline.yaml"},
+			expectedErr: "--pipeline can't be combined with --image or --exec",
+		},
+		"exec with env": {
+			runner:      EvalFnRunner{Exec: "my-fn", Env: []string{"FOO=bar"}},
+			expectedErr: "--mount, --as-current-user, --network and --env can only be used with container functions",
+		},
+		"exec with mount": {
+			runner:      EvalFnRunner{Exec: "my-fn", Mounts: []string{"type=bind,src=/a,dst=/b"}},
+			expectedErr: "--mount, --as-current-user, --network and --env can only be used with container functions",
+		},
+		"data items with fn config": {
+			runner:      EvalFnRunner{Image: "foo:bar", FnConfigPath: "config.yaml", dataItems: []string{"a=b"}},
+			expectedErr: "function arguments can only be specified without function config file",
+		},
+		"fn config kind with fn config": {
+			runner:      EvalFnRunner{Image: "foo:bar", FnConfigPath: "config.yaml", FnConfigKind: "Foo"},
+			expectedErr: "--fn-config-kind and --fn-config-api-version can only be specified without function config file",
+		},
+		"invalid pull policy": {
+			runner:      EvalFnRunner{Image: "foo:bar", ImagePullPolicy: "sometimes"},
+			expectedErr: "pull policy must be one of",
+		},
+		"invalid results format": {
+			runner:      EvalFnRunner{Image: "foo:bar", ResultsFormat: "yaml"},
+			expectedErr: "--results-format must be either `text` or `json`",
+		},
+		"env pattern": {
+			runner: EvalFnRunner{Image: "foo:bar", Env: []string{"MYFN_*", "FOO=*"}},
+		},
+		"invalid env pattern": {
+			runner:      EvalFnRunner{Image: "foo:bar", Env: []string{"MYFN_[*"}},
+			expectedErr: `--env pattern "MYFN_[*" must be valid`,
+		},
+		"env pattern with exec": {
+			runner:      EvalFnRunner{Exec: "my-fn", Env: []string{"MYFN_*"}},
+			expectedErr: "--mount, --as-current-user, --network and --env can only be used with container functions",
+		},
+		"print env with exec": {
+			runner:      EvalFnRunner{Exec: "my-fn", PrintEnv: true},
+			expectedErr: "--print-env can only be used with container functions",
+		},
+		"exec without allow exec": {
+			runner:      EvalFnRunner{Exec: "my-fn"},
+			expectedErr: "exec functions are disabled; pass --allow-exec to enable",
+		},
+		"context dir with exec": {
+			runner:      EvalFnRunner{Exec: "my-fn", AllowExec: true, ContextDir: "templates"},
+			expectedErr: "--context-dir can only be used with container functions",
+		},
+		"registry auth with exec": {
+			runner:      EvalFnRunner{Exec: "my-fn", RegistryAuth: "auth.json"},
+			expectedErr: "--registry-auth can only be used with container functions",
+		},
+		"exec env pattern": {
+			runner:      EvalFnRunner{Exec: "my-fn", ExecEnv: []string{"MYFN_*"}},
+			expectedErr: `--exec-env "MYFN_*" can't be a glob pattern`,
+		},
+		"pipeline with selector": {
+			runner:      EvalFnRunner{PipelinePath: "pipeline.yaml", Selector: kptfile.Selector{Kind: "Deployment"}},
+			expectedErr: "--match-* and --exclude-* flags can't be used with --pipeline",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			if tc.runner.ImagePullPolicy == "" {
+				tc.runner.ImagePullPolicy = "IfNotPresent"
+			}
+			err := tc.runner.Validate()
+			if tc.expectedErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectedErr)
+				}
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+	// validating doesn't create the results dir
+	assert.NoDirExists(t, resultsDir)
+}
+
+func TestExpandEnv(t *testing.T) {
+	environ := []string{"MYFN_B=2", "PATH=/bin", "MYFN_A=1", "=C:=C:\\", "OTHER_MYFN=3"}
+	testCases := map[string]struct {
+		env      []string
+		expected []string
+	}{
+		"pattern": {
+			env:      []string{"MYFN_*"},
+			expected: []string{"MYFN_A", "MYFN_B"},
+		},
+		"single character pattern": {
+			env:      []string{"MYFN_?", "PAT[HT]"},
+			expected: []string{"MYFN_A", "MYFN_B", "PATH"},
+		},
+		"literal values": {
+			env:      []string{"FOO=*", "PATH", "MYFN_*=bar"},
+			expected: []string{"FOO=*", "PATH", "MYFN_*=bar"},
+		},
+		"no matches": {
+			env:      []string{"NONE_*", "FOO=bar"},
+			expected: []string{"FOO=bar"},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tc.expected, expandEnv(tc.env, environ))
+		})
+	}
+}
+
+func TestCmd_failOnEmpty(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+
+	if !assert.NoError(t, os.Mkdir("pkg", 0700)) {
+		t.FailNow()
+	}
+	err := ioutil.WriteFile(filepath.Join("pkg", "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// the copy function returns its input, the drop function removes all the
+	// resources
+	for name, fn := range map[string]string{
+		"copy.sh": "#!/bin/sh\ncat\n",
+		"drop.sh": "#!/bin/sh\nprintf 'apiVersion: config.kubernetes.io/v1\\nkind: ResourceList\\nitems: []\\n'\n",
+	} {
+		if !assert.NoError(t, ioutil.WriteFile(name, []byte(fn), 0700)) {
+			t.FailNow()
+		}
+	}
+
+	testCases := map[string]struct {
+		fn          string
+		args        []string
+		failOnEmpty bool
+		err         string
+	}{
+		"selector matches": {
+			fn:          "copy.sh",
+			args:        []string{"--match-kind", "ConfigMap"},
+			failOnEmpty: true,
+		},
+		"selector matches nothing": {
+			fn:   "copy.sh",
+			args: []string{"--match-kind", "Deployment"},
+		},
+		"selector matches nothing with fail on empty": {
+			fn:          "copy.sh",
+			args:        []string{"--match-kind", "Deployment"},
+			failOnEmpty: true,
+			err:         `function "` + filepath.Join(dir, "copy.sh") + `" selected no resources`,
+		},
+		"no output": {
+			fn: "drop.sh",
+		},
+		"no output with fail on empty": {
+			fn:          "drop.sh",
+			failOnEmpty: true,
+			err:         `function "` + filepath.Join(dir, "drop.sh") + `" produced no resources from the 1 resource(s) it selected`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			r := GetEvalFnRunner(fake.CtxWithPrinter(&bytes.Buffer{}, &bytes.Buffer{}), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			args := append([]string{"pkg", "--allow-exec", "--exec", filepath.Join(dir, tc.fn), "--output", "stdout"}, tc.args...)
+			if tc.failOnEmpty {
+				args = append(args, "--fail-on-empty")
+			}
+			r.Command.SetArgs(args)
+
+			err := r.Command.Execute()
+			if tc.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestCmd_verboseSelection(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+
+	if !assert.NoError(t, os.Mkdir("pkg", 0700)) {
+		t.FailNow()
+	}
+	for name, content := range map[string]string{
+		"cm.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+  namespace: default
+data:
+  key: a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: other
+  namespace: default
+data:
+  key: c
+`,
+		"deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+`,
+	} {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join("pkg", name), []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+	fn := filepath.Join(dir, "fn.sh")
+	if !assert.NoError(t, ioutil.WriteFile(fn, []byte("#!/bin/sh\nsed 's/key: a/key: b/'\n"), 0700)) {
+		t.FailNow()
+	}
+
+	testCases := map[string]struct {
+		args     []string
+		expected []string
+	}{
+		"verbose": {
+			args: []string{"--verbose"},
+			expected: []string{
+				"  ConfigMap default/cm (cm.yaml): selected by kind=ConfigMap, modified\n",
+				"  ConfigMap default/other (cm.yaml): selected by kind=ConfigMap, unchanged\n",
+				"  Deployment nginx (deploy.yaml): not selected, no selector matches\n",
+			},
+		},
+		"verbose with exclusion": {
+			args: []string{"--verbose", "--exclude-name", "other"},
+			expected: []string{
+				"  ConfigMap default/cm (cm.yaml): selected by kind=ConfigMap, modified\n",
+				"  ConfigMap default/other (cm.yaml): not selected, excluded by name=other\n",
+				"  Deployment nginx (deploy.yaml): not selected, no selector matches\n",
+			},
+		},
+		"not verbose": {},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			errOut := &bytes.Buffer{}
+			r := GetEvalFnRunner(fake.CtxWithPrinter(out, errOut), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			r.Command.SetArgs(append([]string{"pkg", "--allow-exec", "--exec", fn, "--output", "stdout", "--match-kind", "ConfigMap"}, tc.args...))
+
+			if !assert.NoError(t, r.Command.Execute()) {
+				t.FailNow()
+			}
+			assert.Contains(t, out.String(), "key: b")
+			for _, line := range tc.expected {
+				assert.Contains(t, errOut.String(), line)
+			}
+			if len(tc.expected) == 0 {
+				assert.NotContains(t, errOut.String(), "selected")
+			}
+		})
+	}
+}
+
+func TestCmd_sortOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	defer testutil.Chdir(t, dir)()
+
+	if !assert.NoError(t, os.Mkdir("pkg", 0700)) {
+		t.FailNow()
+	}
+	err := ioutil.WriteFile(filepath.Join("pkg", "all.yaml"), []byte(`apiVersion: v1
+kind: Service
+metadata:
+  name: nginx-svc
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	fn := filepath.Join(dir, "fn.sh")
+	if !assert.NoError(t, ioutil.WriteFile(fn, []byte("#!/bin/sh\ncat\n"), 0700)) {
+		t.FailNow()
+	}
+
+	testCases := map[string]struct {
+		args   []string
+		sorted bool
+		files  []string
+		err    string
+	}{
+		"default order": {
+			args: []string{"--output", "stdout"},
+		},
+		"sorted stdout": {
+			args:   []string{"--output", "stdout", "--sort-output"},
+			sorted: true,
+		},
+		"sorted directory": {
+			args:  []string{"--output", "out", "--sort-output"},
+			files: []string{"deployment_nginx.yaml", "service_nginx-svc.yaml"},
+		},
+		"in place": {
+			args: []string{"--sort-output"},
+			err:  "--sort-output can only be used with --output or when reading resources from stdin",
+		},
+		"check idempotent": {
+			args: []string{"--sort-output", "--check-idempotent"},
+			err:  "--sort-output can't be used with --check-idempotent",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			defer os.RemoveAll("out")
+			out := &bytes.Buffer{}
+			r := GetEvalFnRunner(fake.CtxWithPrinter(out, &bytes.Buffer{}), "kpt")
+			r.Command.SilenceErrors = true
+			r.Command.SilenceUsage = true
+			r.Command.SetArgs(append([]string{"pkg", "--allow-exec", "--exec", fn}, tc.args...))
+
+			err := r.Command.Execute()
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.err)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			if tc.files != nil {
+				for _, f := range tc.files {
+					assert.FileExists(t, filepath.Join("out", f))
+				}
+				return
+			}
+			deployment := strings.Index(out.String(), "kind: Deployment")
+			service := strings.Index(out.String(), "kind: Service")
+			assert.Equal(t, tc.sorted, deployment < service)
+		})
+	}
+}
+
+func TestParseMount(t *testing.T) {
+	testCases := map[string]struct {
+		mount    string
+		expected fnruntime.StorageMount
+		err      string
+	}{
+		"bind mount": {
+			mount:    "type=bind,src=/src,dst=/dst,rw=true",
+			expected: fnruntime.StorageMount{StorageMount: runtimeutil.StorageMount{MountType: "bind", Src: "/src", DstPath: "/dst", ReadWriteMode: true}},
+		},
+		"bind mount by default": {
+			mount:    "source=/src,target=/dst",
+			expected: fnruntime.StorageMount{StorageMount: runtimeutil.StorageMount{MountType: "bind", Src: "/src", DstPath: "/dst"}},
+		},
+		"volume": {
+			mount:    "type=volume,src=myvol,dst=/data",
+			expected: fnruntime.StorageMount{StorageMount: runtimeutil.StorageMount{MountType: "volume", Src: "myvol", DstPath: "/data"}},
+		},
+		"tmpfs": {
+			mount:    "type=tmpfs,dst=/scratch",
+			expected: fnruntime.StorageMount{StorageMount: runtimeutil.StorageMount{MountType: "tmpfs", DstPath: "/scratch"}},
+		},
+		"tmpfs with size": {
+			mount:    "type=tmpfs,dst=/scratch,size=64m",
+			expected: fnruntime.StorageMount{StorageMount: runtimeutil.StorageMount{MountType: "tmpfs", DstPath: "/scratch"}, TmpfsSize: 64 << 20},
+		},
+		"tmpfs with size in bytes": {
+			mount:    "type=tmpfs,dst=/scratch,size=4096",
+			expected: fnruntime.StorageMount{StorageMount: runtimeutil.StorageMount{MountType: "tmpfs", DstPath: "/scratch"}, TmpfsSize: 4096},
+		},
+		"tmpfs with upper case unit": {
+			mount:    "type=tmpfs,dst=/scratch,size=1G",
+			expected: fnruntime.StorageMount{StorageMount: runtimeutil.StorageMount{MountType: "tmpfs", DstPath: "/scratch"}, TmpfsSize: 1 << 30},
+		},
+		"tmpfs with invalid unit": {
+			mount: "type=tmpfs,dst=/scratch,size=64mb",
+			err:   `invalid --mount "type=tmpfs,dst=/scratch,size=64mb": size "64mb" must be a positive number of bytes, optionally with a k, m or g unit`,
+		},
+		"tmpfs with zero size": {
+			mount: "type=tmpfs,dst=/scratch,size=0",
+			err:   `size "0" must be a positive number of bytes`,
+		},
+		"tmpfs with too large size": {
+			mount: "type=tmpfs,dst=/scratch,size=9223372036854775807g",
+			err:   `size "9223372036854775807g" must be a positive number of bytes`,
+		},
+		"tmpfs with rw": {
+			mount: "type=tmpfs,dst=/scratch,rw=true",
+			err:   `invalid --mount "type=tmpfs,dst=/scratch,rw=true": tmpfs mounts are always writable, they can't have the rw option`,
+		},
+		"tmpfs without destination": {
+			mount: "type=tmpfs,size=64m",
+			err:   `invalid --mount "type=tmpfs,size=64m": must be in the format type=tmpfs,dst=<path>[,size=<size>]`,
+		},
+		"bind mount with size": {
+			mount: "type=bind,src=/src,dst=/dst,size=64m",
+			err:   `invalid --mount "type=bind,src=/src,dst=/dst,size=64m": only tmpfs mounts can have a size`,
+		},
+		"volume without name": {
+			mount: "type=volume,dst=/data",
+			err:   `invalid --mount "type=volume,dst=/data": must be in the format type=volume,src=<name>,dst=<path>[,rw=true]`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			sm, err := parseMount(tc.mount)
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.err)
+				}
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expected, sm)
+			}
+		})
+	}
+}
+
+func TestEvalFnRunner_printEnv(t *testing.T) {
+	t.Setenv("HOST_VAR", "host value")
+	t.Setenv("GITHUB_TOKEN", "ghp_secret")
+	testCases := map[string]struct {
+		runner   EvalFnRunner
+		expected string
+	}{
+		"env and mounts": {
+			runner: EvalFnRunner{RunFns: runfn.RunFns{
+				Env: []string{"FOO=bar", "HOST_VAR", "GITHUB_TOKEN", "db_password=hunter2", "MISSING_VAR"},
+				StorageMounts: []fnruntime.StorageMount{
+					{StorageMount: runtimeutil.StorageMount{MountType: "bind", Src: "/src", DstPath: "/dst"}},
+					{StorageMount: runtimeutil.StorageMount{MountType: "tmpfs", DstPath: "/scratch"}, TmpfsSize: 1024},
+				},
+			}},
+			expected: `Environment of the container functions:
+  FOO=bar
+  HOST_VAR=host value
+  GITHUB_TOKEN=******
+  db_password=******
+  MISSING_VAR is inherited from the host, where it isn't set
+Mounts of the container functions:
+  type=bind,source=/src,target=/dst,readonly
+  type=tmpfs,target=/scratch,tmpfs-size=1024
+`,
+		},
+		"package path": {
+			runner: EvalFnRunner{InjectPackagePath: true},
+			expected: `Environment of the container functions:
+  KPT_PACKAGE_PATH is set to the absolute path of the package
+Mounts of the container functions:
+  none
+`,
+		},
+		"context dir": {
+			runner: EvalFnRunner{RunFns: runfn.RunFns{ContextDir: "/templates"}},
+			expected: `Environment of the container functions:
+  KPT_CONTEXT_DIR=/kpt-context
+Mounts of the container functions:
+  type=bind,source=/templates,target=/kpt-context,readonly
+`,
+		},
+		"nothing": {
+			expected: `Environment of the container functions:
+  none
+Mounts of the container functions:
+  none
+`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			errOut := &bytes.Buffer{}
+			tc.runner.Ctx = fake.CtxWithPrinter(&bytes.Buffer{}, errOut)
+			tc.runner.printEnv()
+			assert.Equal(t, tc.expected, errOut.String())
+		})
+	}
+}
@@ -0,0 +1,381 @@
+// Copyright 2022 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmdeval
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfile "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/thirdparty/kyaml/runfn"
+	"github.com/google/shlex"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// EvalOptions configures the function executed by Eval and the resources it
+// is executed on.
+type EvalOptions struct {
+	// Image is the container image of the function. If the image doesn't
+	// contain a registry, gcr.io/kpt-fn/ is added as prefix.
+	// Image, Exec and PipelinePath are mutually exclusive.
+	Image string
+
+	// Exec is the executable of the function followed by its arguments.
+	// Image, Exec and PipelinePath are mutually exclusive.
+	Exec string
+
+	// PipelinePath is the path to a file containing a list of functions in
+	// the format of the Kptfile pipeline, which are executed in order instead
+	// of a single function. The configPath of the functions is relative to
+	// the directory of the file. Image, Exec and PipelinePath are mutually
+	// exclusive.
+	PipelinePath string
+
+	// FnConfig is the functionConfig of the function.
+	FnConfig *yaml.RNode
+
+	// FnConfigPath is the path to the file containing the functionConfig of
+	// the function. It is used instead of FnConfig if set.
+	FnConfigPath string
+
+	// Path is the package directory containing the resources.
+	Path string
+
+	// Input can be set to read the resources from Input rather than from Path.
+	Input io.Reader
+
+	// Output can be set to write the resources to Output as a ResourceList
+	// rather than back to Path.
+	Output io.Writer
+
+	// Selector and Exclusion select the resources the function is executed on.
+	Selector  kptfile.Selector
+	Exclusion kptfile.Selector
+
+	// FilePathSelector selects the resources in files matching the glob pattern.
+	FilePathSelector string
+
+	// Network enables network access for container functions.
+	Network bool
+
+	// StorageMounts are mounted into the container of container functions.
+	StorageMounts []fnruntime.StorageMount
+
+	// ContextDir is the absolute path of a directory which is mounted
+	// read-only into the container functions as their working directory, see
+	// runfn.RunFns.ContextDir.
+	ContextDir string
+
+	// Env are the environment variables exported to container functions.
+	Env []string
+
+	// ExecEnv are the environment variables set for exec functions.
+	ExecEnv []string
+
+	// InjectPackagePath exports the path of the package to the functions in
+	// the KPT_PACKAGE_PATH environment variable.
+	InjectPackagePath bool
+
+	// AsCurrentUser runs container functions with the uid and gid of the
+	// current user.
+	AsCurrentUser bool
+
+	// ImagePullPolicy controls when the image of container functions is pulled.
+	ImagePullPolicy fnruntime.ImagePullPolicy
+
+	// ImagePrefix is the registry path short image names are resolved
+	// against. The default prefix is used if it's empty.
+	ImagePrefix string
+
+	// PullRetries is the number of times pulling the image of container
+	// functions is retried after transient failures.
+	PullRetries int
+
+	// RegistryConfigDir is the docker config directory containing the
+	// registry credentials the images of container functions are pulled
+	// with. The default docker config is used if it's empty.
+	RegistryConfigDir string
+
+	// CacheDir is the directory the outputs of container functions are cached
+	// in, keyed on the image digest and the input.
+	CacheDir string
+
+	// RefreshCache runs container functions even if their output is cached.
+	RefreshCache bool
+
+	// ResultsDir is the directory the function results are written to.
+	ResultsDir string
+
+	// PreserveUnchanged skips writing files whose content isn't changed.
+	PreserveUnchanged bool
+
+	// FailOnEmpty fails the execution if a function selects no resources or
+	// doesn't output any resources.
+	FailOnEmpty bool
+
+	// MaxResults is the number of function results which are retained. All
+	// results are retained if it is 0.
+	MaxResults int
+
+	// ResultsIgnorePath is the path to a file containing a list of
+	// fnruntime.ResultSignatures. The results matching them are dropped, so
+	// they are neither printed nor written, and a function failing only
+	// because of them passes.
+	ResultsIgnorePath string
+
+	// Verbose prints the stderr of successful functions. The stderr of failed
+	// functions is always part of the returned error.
+	Verbose bool
+
+	// AllowExec allows exec functions to be executed. They are rejected
+	// otherwise, as they run local executables.
+	AllowExec bool
+
+	// NoDocker rejects container functions, so only exec functions are
+	// executed.
+	NoDocker bool
+
+	// Progress is called as each function completes, e.g. to report the
+	// progress of a pipeline while it is executed.
+	Progress fnruntime.ProgressFunc
+}
+
+// EvalResult contains the outcome of executing a function with Eval.
+type EvalResult struct {
+	// Resources are the resources of the package after executing the function.
+	Resources []*yaml.RNode
+
+	// Results are the results returned by the function.
+	Results *fnresult.ResultList
+}
+
+// Eval executes the function configured by opts on the resources. The status
+// of the function is printed to the printer in ctx.
+func Eval(ctx context.Context, opts EvalOptions) (EvalResult, error) {
+	if err := opts.Validate(); err != nil {
+		return EvalResult{}, err
+	}
+	fns, err := opts.runFns(ctx)
+	if err != nil {
+		return EvalResult{}, err
+	}
+	return evalRunFns(fns)
+}
+
+// Validate checks that the options are consistent with each other, like the
+// flags of fn eval, which the errors refer to. It doesn't access the
+// filesystem or docker.
+func (opts EvalOptions) Validate() error {
+	if opts.MaxResults < 0 {
+		return fmt.Errorf("--max-results must not be negative")
+	}
+	if opts.PullRetries < 0 {
+		return fmt.Errorf("--pull-retries must not be negative")
+	}
+	for _, env := range opts.ExecEnv {
+		if isEnvPattern(env) {
+			return fmt.Errorf("--exec-env %q can't be a glob pattern, patterns are only supported by --env for container functions", env)
+		}
+	}
+	if opts.FilePathSelector != "" {
+		if _, err := path.Match(opts.FilePathSelector, ""); err != nil {
+			return fmt.Errorf("--match-file pattern %q must be valid: %w", opts.FilePathSelector, err)
+		}
+	}
+	if opts.PipelinePath != "" && (opts.Image != "" || opts.Exec != "") {
+		return errors.Errorf("--pipeline can't be combined with --image or --exec")
+	}
+	if opts.Image != "" && opts.Exec != "" {
+		return errors.Errorf("--image can't be combined with --exec")
+	}
+	if opts.Image == "" && opts.Exec == "" && opts.PipelinePath == "" {
+		return errors.Errorf("must specify --image, --exec or --pipeline")
+	}
+	if opts.Exec != "" && (opts.AsCurrentUser || opts.Network ||
+		len(opts.StorageMounts) != 0 || len(opts.Env) != 0) {
+		return fmt.Errorf("--mount, --as-current-user, --network and --env can only be used with container functions")
+	}
+	if opts.Exec != "" && opts.ContextDir != "" {
+		return fmt.Errorf("--context-dir can only be used with container functions")
+	}
+	if opts.Exec != "" && !opts.AllowExec {
+		return errExecNotAllowed
+	}
+	if opts.Image != "" && opts.NoDocker {
+		return errors.Errorf("container function %q can't be run with --no-docker, use --exec instead", opts.Image)
+	}
+	return nil
+}
+
+// runFns returns the RunFns executing the function configured by opts, which
+// are expected to be valid.
+func (opts EvalOptions) runFns(ctx context.Context) (runfn.RunFns, error) {
+	fn := &runtimeutil.FunctionSpec{}
+	var execArgs []string
+	var pipeline []kptfile.Function
+	switch {
+	case opts.PipelinePath != "":
+		var err error
+		if pipeline, err = readPipeline(opts.PipelinePath); err != nil {
+			return runfn.RunFns{}, err
+		}
+		if err := opts.validatePipeline(pipeline); err != nil {
+			return runfn.RunFns{}, err
+		}
+		fn = nil
+	case opts.Image != "":
+		fn.Container.Image = fnruntime.AddImagePathPrefix(ctx, opts.Image, opts.ImagePrefix)
+		if err := kptfile.ValidateFunctionImageURL(fn.Container.Image); err != nil {
+			return runfn.RunFns{}, err
+		}
+	case opts.Exec != "":
+		s, err := shlex.Split(opts.Exec)
+		if err != nil {
+			return runfn.RunFns{}, fmt.Errorf("exec command %q must be valid: %w", opts.Exec, err)
+		}
+		if len(s) > 0 {
+			fn.Exec.Path = s[0]
+			execArgs = s[1:]
+		}
+	}
+	var resultsFilter *fnruntime.ResultsFilter
+	if opts.ResultsIgnorePath != "" {
+		var err error
+		if resultsFilter, err = fnruntime.ReadResultsFilter(opts.ResultsIgnorePath); err != nil {
+			return runfn.RunFns{}, err
+		}
+	}
+	stderrMode := fnruntime.SeparateStderr
+	if opts.Verbose {
+		stderrMode = fnruntime.VerboseStderr
+	}
+	return runfn.RunFns{
+		Ctx:               ctx,
+		Function:          fn,
+		Pipeline:          pipeline,
+		ExecArgs:          execArgs,
+		OriginalExec:      opts.Exec,
+		Output:            opts.Output,
+		Input:             opts.Input,
+		Path:              opts.Path,
+		Network:           opts.Network,
+		StorageMounts:     opts.StorageMounts,
+		ContextDir:        opts.ContextDir,
+		ResultsDir:        opts.ResultsDir,
+		Env:               opts.Env,
+		ExecEnv:           opts.ExecEnv,
+		InjectPackagePath: opts.InjectPackagePath,
+		AsCurrentUser:     opts.AsCurrentUser,
+		FnConfig:          opts.FnConfig,
+		FnConfigPath:      opts.FnConfigPath,
+		ImagePullPolicy:   opts.ImagePullPolicy,
+		ImagePrefix:       opts.ImagePrefix,
+		PullRetries:       opts.PullRetries,
+		RegistryConfigDir: opts.RegistryConfigDir,
+		CacheDir:          opts.CacheDir,
+		RefreshCache:      opts.RefreshCache,
+		// fn eval should remove all files when all resources
+		// are deleted.
+		ContinueOnEmptyResult: true,
+		FailOnEmpty:           opts.FailOnEmpty,
+		Selector:              opts.Selector,
+		Exclusion:             opts.Exclusion,
+		FilePathSelector:      opts.FilePathSelector,
+		PreserveUnchanged:     opts.PreserveUnchanged,
+		MaxResults:            opts.MaxResults,
+		ResultsFilter:         resultsFilter,
+		StderrMode:            stderrMode,
+		Progress:              opts.Progress,
+	}, nil
+}
+
+// readPipeline reads the functions of the pipeline file at path and validates
+// them like the functions of a Kptfile pipeline. The configPath of the
+// returned functions is absolute.
+func readPipeline(path string) ([]kptfile.Function, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline file %q: %w", path, err)
+	}
+	var fns []kptfile.Function
+	d := yaml.NewDecoder(bytes.NewReader(b))
+	d.KnownFields(true)
+	if err := d.Decode(&fns); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid pipeline file %q: %w", path, err)
+	}
+	if len(fns) == 0 {
+		return nil, fmt.Errorf("pipeline file %q doesn't contain any functions", path)
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	if err := kptfile.ValidateFunctions(filesys.FileSystemOrOnDisk{}, "mutators", fns, types.UniquePath(dir)); err != nil {
+		return nil, fmt.Errorf("invalid pipeline file %q: %w", path, err)
+	}
+	for i := range fns {
+		if fns[i].ConfigPath != "" {
+			fns[i].ConfigPath = filepath.Join(dir, filepath.FromSlash(fns[i].ConfigPath))
+		}
+	}
+	return fns, nil
+}
+
+// validatePipeline checks that the container functions of the pipeline can be
+// run and that its exec functions are allowed and exist.
+func (opts EvalOptions) validatePipeline(fns []kptfile.Function) error {
+	for _, f := range fns {
+		if f.Image != "" {
+			if opts.NoDocker {
+				return errors.Errorf("container function %q can't be run with --no-docker, use --exec instead", f.Image)
+			}
+			if err := cmdutil.DockerCmdAvailable(); err != nil {
+				return err
+			}
+			continue
+		}
+		if !opts.AllowExec {
+			return fmt.Errorf("cannot run exec function %q of the pipeline: %w", f.Exec, errExecNotAllowed)
+		}
+		if opts.ContextDir != "" {
+			return errors.Errorf("--context-dir can't be used with exec function %q of the pipeline, it can only be used with container functions", f.Exec)
+		}
+		s, err := shlex.Split(f.Exec)
+		if err != nil {
+			return fmt.Errorf("exec command %q must be valid: %w", f.Exec, err)
+		}
+		path := f.Exec
+		if len(s) > 0 {
+			path = s[0]
+		}
+		if err := lookExec(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evalRunFns executes fns and collects the output resources and results.
+func evalRunFns(fns runfn.RunFns) (EvalResult, error) {
+	result := EvalResult{Results: fnresult.NewResultList()}
+	buff := &kio.PackageBuffer{}
+	fns.Results = result.Results
+	fns.ResourceWriter = buff
+	err := fns.Execute()
+	result.Resources = buff.Nodes
+	return result, err
+}
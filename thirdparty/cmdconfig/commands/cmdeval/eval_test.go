@@ -0,0 +1,436 @@
+// Copyright 2022 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmdeval
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+)
+
+func TestEval(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+
+	// the function sets the value of the ConfigMap and returns a result
+	fn := filepath.Join(dir, "fn.sh")
+	err := ioutil.WriteFile(fn, []byte(`#!/bin/sh
+sed 's/value: old/value: new/'
+printf 'results:\n- message: value updated\n  severity: info\n'
+`), 0700)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	pkg := filepath.Join(dir, "pkg")
+	if !assert.NoError(t, os.Mkdir(pkg, 0700)) {
+		t.FailNow()
+	}
+	err = ioutil.WriteFile(filepath.Join(pkg, "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  value: old
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	out := &bytes.Buffer{}
+	result, err := Eval(fake.CtxWithPrinter(out, out), EvalOptions{
+		Exec:      fn,
+		AllowExec: true,
+		Path:      pkg,
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if assert.Len(t, result.Resources, 1) {
+		value, err := result.Resources[0].GetString("data.value")
+		assert.NoError(t, err)
+		assert.Equal(t, "new", value)
+	}
+	if assert.Len(t, result.Results.Items, 1) {
+		assert.Equal(t, framework.Results{
+			{Message: "value updated", Severity: framework.Info},
+		}, result.Results.Items[0].Results)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(pkg, "cm.yaml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "value: new")
+	assert.Contains(t, out.String(), "[PASS]")
+}
+
+func TestEval_input(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "fn.sh")
+	err := ioutil.WriteFile(fn, []byte("#!/bin/sh\nsed 's/value: old/value: new/'\n"), 0700)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	out := &bytes.Buffer{}
+	output := &bytes.Buffer{}
+	result, err := Eval(fake.CtxWithPrinter(out, out), EvalOptions{
+		Exec:      fn,
+		AllowExec: true,
+		Input: strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  value: old
+`),
+		Output: output,
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if assert.Len(t, result.Resources, 1) {
+		assert.Equal(t, "cm", result.Resources[0].GetName())
+	}
+	assert.Contains(t, output.String(), "kind: ResourceList")
+	assert.Contains(t, output.String(), "value: new")
+}
+
+func TestEval_pipeline(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"fn1.sh": "#!/bin/sh\nsed 's/value: old/value: new/'\n",
+		"fn2.sh": "#!/bin/sh\nsed 's/value: new/value: newer/'\n",
+	} {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0700)) {
+			t.FailNow()
+		}
+	}
+	fnDir := filepath.Join(dir, "fns")
+	if !assert.NoError(t, os.Mkdir(fnDir, 0700)) {
+		t.FailNow()
+	}
+	err := ioutil.WriteFile(filepath.Join(fnDir, "config.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// the config path is relative to the pipeline file
+	pipeline := filepath.Join(fnDir, "pipeline.yaml")
+	err = ioutil.WriteFile(pipeline, []byte(`- exec: `+filepath.Join(dir, "fn1.sh")+`
+  selectors:
+  - name: cm
+- exec: `+filepath.Join(dir, "fn2.sh")+`
+  configPath: config.yaml
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	out := &bytes.Buffer{}
+	var completed []string
+	result, err := Eval(fake.CtxWithPrinter(out, out), EvalOptions{
+		PipelinePath: pipeline,
+		AllowExec:    true,
+		Progress: func(e fnruntime.ProgressEvent) {
+			completed = append(completed, fmt.Sprintf("%d/%d %s", e.Index, e.Total, filepath.Base(e.Name)))
+		},
+		Input: strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  value: old
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: other
+data:
+  value: old
+`),
+		Output: &bytes.Buffer{},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	values := map[string]string{}
+	for _, r := range result.Resources {
+		values[r.GetName()], err = r.GetString("data.value")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, map[string]string{"cm": "newer", "other": "old"}, values)
+	assert.Equal(t, []string{"1/2 fn1.sh", "2/2 fn2.sh"}, completed)
+	assert.Equal(t, 2, strings.Count(out.String(), "[PASS]"))
+	assert.Contains(t, out.String(), "on 1 resource(s)")
+}
+
+func TestEval_invalidPipeline(t *testing.T) {
+	testCases := map[string]struct {
+		pipeline string
+		expected string
+	}{
+		"unknown field": {
+			pipeline: "- imag: set-labels:v0.1\n",
+			expected: "field imag not found",
+		},
+		"no function": {
+			pipeline: "- configMap:\n    a: b\n",
+			expected: "must specify a functon (`image` or `exec`) to execute",
+		},
+		"missing config": {
+			pipeline: "- image: set-labels:v0.1\n  configPath: missing.yaml\n",
+			expected: "pipeline.mutators[0].configPath",
+		},
+		"empty": {
+			pipeline: "",
+			expected: "doesn't contain any functions",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			pipeline := filepath.Join(t.TempDir(), "pipeline.yaml")
+			if !assert.NoError(t, ioutil.WriteFile(pipeline, []byte(tc.pipeline), 0600)) {
+				t.FailNow()
+			}
+			out := &bytes.Buffer{}
+			_, err := Eval(fake.CtxWithPrinter(out, out), EvalOptions{PipelinePath: pipeline, Path: "."})
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.expected)
+			}
+		})
+	}
+}
+
+func TestEval_stderr(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	// the function fails for resources containing "fail"
+	fn := filepath.Join(dir, "fn.sh")
+	err := ioutil.WriteFile(fn, []byte(`#!/bin/sh
+input=$(cat)
+echo "message from fn" >&2
+echo "$input" | grep -q fail && exit 1
+echo "$input"
+`), 0700)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	block := "---- stderr from " + fn + " ----\nmessage from fn\n---- end of stderr ----"
+
+	testCases := map[string]struct {
+		value    string
+		verbose  bool
+		expected string
+		err      string
+	}{
+		"success": {
+			value: "ok",
+		},
+		"success verbose": {
+			value:    "ok",
+			verbose:  true,
+			expected: block,
+		},
+		"failure": {
+			value: "fail",
+			err:   `function "` + fn + `" failed with exit code 1` + "\n" + block,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			_, err := Eval(fake.CtxWithPrinter(out, out), EvalOptions{
+				Exec:      fn,
+				AllowExec: true,
+				Input:     strings.NewReader("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\ndata:\n  value: " + tc.value + "\n"),
+				Output:    &bytes.Buffer{},
+				Verbose:   tc.verbose,
+			})
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.err)
+				}
+				assert.NotContains(t, out.String(), "message from fn")
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			if tc.expected == "" {
+				assert.NotContains(t, out.String(), "message from fn")
+			} else {
+				assert.Contains(t, out.String(), tc.expected)
+			}
+		})
+	}
+}
+
+func TestEval_canceled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "fn.sh")
+	err := ioutil.WriteFile(fn, []byte("#!/bin/sh\nsleep 60\n"), 0700)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	out := &bytes.Buffer{}
+	ctx, cancel := context.WithTimeout(fake.CtxWithPrinter(out, out), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = Eval(ctx, EvalOptions{
+		Exec:      fn,
+		AllowExec: true,
+		Input:     strings.NewReader("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"),
+		Output:    &bytes.Buffer{},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+	}
+	assert.Less(t, time.Since(start), 30*time.Second)
+}
+
+func TestEval_errors(t *testing.T) {
+	testCases := map[string]struct {
+		opts     EvalOptions
+		expected string
+	}{
+		"no function": {
+			opts:     EvalOptions{Path: "."},
+			expected: "must specify --image, --exec or --pipeline",
+		},
+		"image and exec": {
+			opts:     EvalOptions{Image: "set-labels:v0.1", Exec: "my-fn", Path: "."},
+			expected: "--image can't be combined with --exec",
+		},
+		"pipeline and exec": {
+			opts:     EvalOptions{Exec: "my-fn", PipelinePath: "pipeline.yaml", Path: "."},
+			expected: "--pipeline can't be combined with --image or --exec",
+		},
+		"exec without allow exec": {
+			opts:     EvalOptions{Exec: "my-fn", Path: "."},
+			expected: "exec functions are disabled; pass --allow-exec to enable",
+		},
+		"exec with network": {
+			opts:     EvalOptions{Exec: "my-fn", AllowExec: true, Network: true, Path: "."},
+			expected: "--mount, --as-current-user, --network and --env can only be used with container functions",
+		},
+		"image with no docker": {
+			opts:     EvalOptions{Image: "set-labels:v0.1", NoDocker: true, Path: "."},
+			expected: `container function "set-labels:v0.1" can't be run with --no-docker, use --exec instead`,
+		},
+		"invalid exec": {
+			opts:     EvalOptions{Exec: `my-fn "arg`, AllowExec: true, Path: "."},
+			expected: `exec command "my-fn \"arg" must be valid: EOF found when expecting closing quote`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			_, err := Eval(fake.CtxWithPrinter(out, out), tc.opts)
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestEval_validate(t *testing.T) {
+	// the flags of fn eval and the options of Eval are validated the same way
+	resultsDir := filepath.Join(t.TempDir(), "results")
+	testCases := map[string]struct {
+		runner   EvalFnRunner
+		opts     EvalOptions
+		expected string
+	}{
+		"image and exec": {
+			runner:   EvalFnRunner{Image: "foo:bar", Exec: "my-fn"},
+			opts:     EvalOptions{Image: "foo:bar", Exec: "my-fn"},
+			expected: "--image can't be combined with --exec",
+		},
+		"exec with mount": {
+			runner: EvalFnRunner{Exec: "my-fn", AllowExec: true, Mounts: []string{"type=tmpfs,dst=/tmp"}},
+			opts: EvalOptions{Exec: "my-fn", AllowExec: true, StorageMounts: []fnruntime.StorageMount{
+				{StorageMount: runtimeutil.StorageMount{MountType: "tmpfs", DstPath: "/tmp"}},
+			}},
+			expected: "--mount, --as-current-user, --network and --env can only be used with container functions",
+		},
+		"exec with context dir": {
+			runner:   EvalFnRunner{Exec: "my-fn", AllowExec: true, ContextDir: "templates"},
+			opts:     EvalOptions{Exec: "my-fn", AllowExec: true, ContextDir: "templates"},
+			expected: "--context-dir can only be used with container functions",
+		},
+		"exec without allow exec": {
+			runner:   EvalFnRunner{Exec: "my-fn", ResultsDir: resultsDir},
+			opts:     EvalOptions{Exec: "my-fn", ResultsDir: resultsDir},
+			expected: "exec functions are disabled; pass --allow-exec to enable",
+		},
+		"image with no docker": {
+			runner:   EvalFnRunner{Image: "foo:bar", NoDocker: true},
+			opts:     EvalOptions{Image: "foo:bar", NoDocker: true},
+			expected: `container function "foo:bar" can't be run with --no-docker, use --exec instead`,
+		},
+		"negative max results": {
+			runner:   EvalFnRunner{Image: "foo:bar", MaxResults: -1},
+			opts:     EvalOptions{Image: "foo:bar", MaxResults: -1},
+			expected: "--max-results must not be negative",
+		},
+		"exec env pattern": {
+			runner:   EvalFnRunner{Exec: "my-fn", AllowExec: true, ExecEnv: []string{"MYFN_*"}},
+			opts:     EvalOptions{Exec: "my-fn", AllowExec: true, ExecEnv: []string{"MYFN_*"}},
+			expected: `--exec-env "MYFN_*" can't be a glob pattern`,
+		},
+		"invalid match file": {
+			runner:   EvalFnRunner{Image: "foo:bar", FilePathSelector: "[*"},
+			opts:     EvalOptions{Image: "foo:bar", FilePathSelector: "[*"},
+			expected: `--match-file pattern "[*" must be valid`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			tc.runner.ImagePullPolicy = "IfNotPresent"
+			if err := tc.runner.Validate(); assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.expected)
+			}
+
+			out := &bytes.Buffer{}
+			tc.opts.Path = "."
+			if _, err := Eval(fake.CtxWithPrinter(out, out), tc.opts); assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.expected)
+			}
+			// the function isn't executed
+			assert.Empty(t, out.String())
+		})
+	}
+	// validating doesn't create the results dir
+	assert.NoDirExists(t, resultsDir)
+}
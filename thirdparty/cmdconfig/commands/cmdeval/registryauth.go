@@ -0,0 +1,61 @@
+// Copyright 2022 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmdeval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// readRegistryAuth reads the docker config file at path containing the
+// registry credentials. The errors don't contain the content of the file, so
+// the credentials are never printed.
+func readRegistryAuth(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read registry auth file %q: %w", path, err)
+	}
+	var config struct {
+		Auths       map[string]json.RawMessage `json:"auths"`
+		CredHelpers map[string]string          `json:"credHelpers"`
+		CredsStore  string                     `json:"credsStore"`
+	}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("registry auth file %q must be a docker config file in JSON format", path)
+	}
+	if len(config.Auths) == 0 && len(config.CredHelpers) == 0 && config.CredsStore == "" {
+		return nil, fmt.Errorf("registry auth file %q must contain auths, credHelpers or credsStore", path)
+	}
+	return b, nil
+}
+
+// prepareRegistryAuth copies the docker config file at path to a temporary
+// docker config directory, which container functions pull their images with.
+func (r *EvalFnRunner) prepareRegistryAuth(path string) error {
+	b, err := readRegistryAuth(path)
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "kpt-fn-eval-auth-")
+	if err != nil {
+		return fmt.Errorf("cannot create docker config directory for registry auth file %q: %w", path, err)
+	}
+	r.registryConfigDir = dir
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), b, 0600); err != nil {
+		return fmt.Errorf("cannot write docker config for registry auth file %q: %w", path, err)
+	}
+	return nil
+}
+
+// removeRegistryConfigDir removes the docker config directory containing the
+// registry credentials.
+func (r *EvalFnRunner) removeRegistryConfigDir() {
+	if r.registryConfigDir != "" {
+		_ = os.RemoveAll(r.registryConfigDir)
+		r.registryConfigDir = ""
+	}
+}
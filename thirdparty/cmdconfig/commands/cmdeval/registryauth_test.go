@@ -0,0 +1,56 @@
+// Copyright 2022 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmdeval
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadRegistryAuth(t *testing.T) {
+	testCases := map[string]struct {
+		content string
+		err     string
+	}{
+		"auths": {
+			content: `{"auths": {"example.com": {"auth": "dXNlcjpzM2NyM3Q="}}}`,
+		},
+		"credential helpers": {
+			content: `{"credHelpers": {"example.com": "gcloud"}}`,
+		},
+		"no credentials": {
+			content: `{"auths": {}}`,
+			err:     "must contain auths, credHelpers or credsStore",
+		},
+		"invalid JSON": {
+			content: `{"auths": {"example.com": {"auth": "dXNlcjpzM2NyM3Q="}}`,
+			err:     "must be a docker config file in JSON format",
+		},
+		"invalid type": {
+			content: `{"credsStore": ["dXNlcjpzM2NyM3Q="]}`,
+			err:     "must be a docker config file in JSON format",
+		},
+	}
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "auth.json")
+			if !assert.NoError(t, ioutil.WriteFile(path, []byte(tc.content), 0600)) {
+				t.FailNow()
+			}
+			b, err := readRegistryAuth(path)
+			if tc.err == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.content, string(b))
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+				assert.NotContains(t, err.Error(), "dXNlcjpzM2NyM3Q=")
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@
 package runfn
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -13,10 +14,13 @@ import (
 
 	"github.com/GoogleContainerTools/kpt/internal/pkg"
 	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/google/shlex"
+	"k8s.io/kubectl/pkg/util/slice"
 	"sigs.k8s.io/kustomize/kyaml/errors"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
 	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
@@ -31,7 +35,13 @@ import (
 type RunFns struct {
 	Ctx context.Context
 
-	StorageMounts []runtimeutil.StorageMount
+	StorageMounts []fnruntime.StorageMount
+
+	// ContextDir is the absolute path of a directory which is mounted
+	// read-only at ContextDirMountPath into the container functions and is
+	// their working directory, so they can read files from outside of the
+	// package. Its path in the container is exported in ContextDirEnv.
+	ContextDir string
 
 	// Path is the path to the directory containing functions
 	Path string
@@ -50,6 +60,12 @@ type RunFns struct {
 	// FnConfig is the configurations passed from command line
 	FnConfig *yaml.RNode
 
+	// Pipeline is a list of functions run in order against the input instead
+	// of Function, like the mutators of a Kptfile pipeline. Each function is
+	// run on the output of the previous one. The configPath of the functions
+	// can be absolute or relative to kpt working directory.
+	Pipeline []kptfile.Function
+
 	// Input can be set to read the Resources from Input rather than from a directory
 	Input io.Reader
 
@@ -76,6 +92,15 @@ type RunFns struct {
 	// Env contains environment variables that will be exported to container
 	Env []string
 
+	// ExecEnv contains environment variables that will be set for exec
+	// functions
+	ExecEnv []string
+
+	// InjectPackagePath exports the absolute path of the package to the
+	// functions in the PackagePathEnv environment variable. It isn't set
+	// when the resources are read from Input.
+	InjectPackagePath bool
+
 	// ContinueOnEmptyResult configures what happens when the underlying pipeline
 	// returns an empty result.
 	// If it is false (default), subsequent functions will be skipped and the
@@ -84,6 +109,10 @@ type RunFns struct {
 	// function in the list.
 	ContinueOnEmptyResult bool
 
+	// FailOnEmpty fails the run with an EmptyResultError if a function
+	// selects no resources or its output doesn't contain any resources.
+	FailOnEmpty bool
+
 	// ExecArgs are the arguments for exec commands
 	ExecArgs []string
 
@@ -92,9 +121,87 @@ type RunFns struct {
 
 	ImagePullPolicy fnruntime.ImagePullPolicy
 
+	// ImagePrefix is the registry path short image names of the Pipeline
+	// functions are resolved against. The default prefix is used if it's
+	// empty.
+	ImagePrefix string
+
+	// PullRetries is the number of times pulling the image of container
+	// functions is retried after transient failures. Retries are printed
+	// with VerboseStderr.
+	PullRetries int
+
+	// RegistryConfigDir is the docker config directory containing the
+	// registry credentials the images of container functions are pulled
+	// with. The default docker config is used if it's empty.
+	RegistryConfigDir string
+
+	// CacheDir is the directory the outputs of container functions are cached
+	// in. If it's empty, nothing is cached.
+	CacheDir string
+
+	// RefreshCache runs container functions even if their output is cached
+	// in CacheDir, and replaces the cached output.
+	RefreshCache bool
+
 	Selector kptfile.Selector
 
 	Exclusion kptfile.Selector
+
+	// FilePathSelector is a glob pattern which restricts the function to
+	// resources in matching files, relative to the package root.
+	FilePathSelector string
+
+	// FilePaths restricts the function to resources in these files, which
+	// are slash separated paths relative to the package root, if it isn't
+	// nil. It can be combined with FilePathSelector.
+	FilePaths []string
+
+	// PreserveUnchanged configures whether files whose content isn't changed
+	// by the function are left untouched when writing back to the package,
+	// so their modification time is preserved.
+	PreserveUnchanged bool
+
+	// Results receives the results of the function if set.
+	Results *fnresult.ResultList
+
+	// ResourceWriter receives the output resources if set, in addition to
+	// them being written to Output or back to the directory.
+	ResourceWriter kio.Writer
+
+	// MaxResults is the number of results of the function which are printed
+	// and written to ResultsDir. All results are kept if it is 0.
+	MaxResults int
+
+	// ResultsFilter drops the known results of the functions before they are
+	// printed and written to the results dir.
+	ResultsFilter *fnruntime.ResultsFilter
+
+	// StderrMode controls how the stderr of the functions is reported.
+	StderrMode fnruntime.StderrMode
+
+	// Progress is called as each function completes, with the position of
+	// the function among the functions run.
+	Progress fnruntime.ProgressFunc
+}
+
+// unchangedFilesFS is a filesystem which skips writing files that already
+// have the content being written.
+type unchangedFilesFS struct {
+	filesys.FileSystem
+}
+
+func (fs unchangedFilesFS) WriteFile(path string, data []byte) error {
+	if current, err := fs.FileSystem.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	return fs.FileSystem.WriteFile(path, data)
+}
+
+// hasSelection returns true if the function is only applied to a subset
+// of the resources.
+func (r RunFns) hasSelection() bool {
+	return !r.Selector.IsEmpty() || !r.Exclusion.IsEmpty() || r.FilePathSelector != "" || r.FilePaths != nil
 }
 
 // Execute runs the command
@@ -104,15 +211,15 @@ func (r RunFns) Execute() error {
 	if err != nil {
 		return err
 	}
-	nodes, fltrs, output, err := r.getNodesAndFilters()
+	nodes, steps, output, err := r.getNodesAndSteps()
 	if err != nil {
 		return err
 	}
-	return r.runFunctions(nodes, output, fltrs)
+	return r.runFunctions(nodes, output, steps)
 }
 
-func (r RunFns) getNodesAndFilters() (
-	*kio.PackageBuffer, []kio.Filter, *kio.LocalPackageReadWriter, error) {
+func (r RunFns) getNodesAndSteps() (
+	*kio.PackageBuffer, []fnStep, *kio.LocalPackageReadWriter, error) {
 	// Read Resources from Directory or Input
 	buff := &kio.PackageBuffer{}
 	p := kio.Pipeline{Outputs: []kio.Writer{buff}}
@@ -129,6 +236,11 @@ func (r RunFns) getNodesAndFilters() (
 			IncludeSubpackages: true,
 			WrapBareSeqNode:    true,
 		}
+		if r.PreserveUnchanged {
+			outputPkg.FileSystem = filesys.FileSystemOrOnDisk{
+				FileSystem: unchangedFilesFS{FileSystem: filesys.MakeFsOnDisk()},
+			}
+		}
 	}
 
 	if r.Input == nil {
@@ -140,11 +252,101 @@ func (r RunFns) getNodesAndFilters() (
 		return nil, nil, outputPkg, err
 	}
 
-	fltrs, err := r.getFilters()
+	steps, err := r.getSteps()
 	if err != nil {
 		return nil, nil, outputPkg, err
 	}
-	return buff, fltrs, outputPkg, nil
+	return buff, steps, outputPkg, nil
+}
+
+// fnStep is a step of RunFns running filters on the resources selected by
+// selectors, exclusions and filePathSelector.
+type fnStep struct {
+	// name is the image or the exec command of the function.
+	name             string
+	filters          []kio.Filter
+	selectors        []kptfile.Selector
+	exclusions       []kptfile.Selector
+	filePathSelector string
+	filePaths        []string
+}
+
+// hasSelection returns true if the step is only applied to a subset of the
+// resources.
+func (s fnStep) hasSelection() bool {
+	return len(s.selectors) > 0 || len(s.exclusions) > 0 || s.filePathSelector != "" || s.filePaths != nil
+}
+
+// getSteps returns a step for each function of Pipeline, or a single step
+// running Function.
+func (r RunFns) getSteps() ([]fnStep, error) {
+	if len(r.Pipeline) > 0 {
+		return r.getPipelineSteps()
+	}
+	fltrs, err := r.getFilters()
+	if err != nil {
+		return nil, err
+	}
+	step := fnStep{filters: fltrs}
+	if r.Function != nil {
+		step.name = r.Function.Container.Image
+		if step.name == "" {
+			step.name = r.OriginalExec
+		}
+	}
+	if r.hasSelection() {
+		step.selectors = []kptfile.Selector{r.Selector}
+		step.exclusions = []kptfile.Selector{r.Exclusion}
+		step.filePathSelector = r.FilePathSelector
+		step.filePaths = r.FilePaths
+	}
+	return []fnStep{step}, nil
+}
+
+// getPipelineSteps returns a step for each function of Pipeline, the
+// functions are run like the functions of a Kptfile pipeline.
+func (r RunFns) getPipelineSteps() ([]fnStep, error) {
+	var steps []fnStep
+	for i := range r.Pipeline {
+		f := r.Pipeline[i]
+		spec := runtimeutil.FunctionSpec{}
+		var execArgs []string
+		switch {
+		case f.Image != "":
+			spec.Container.Image = fnruntime.AddImagePathPrefix(r.Ctx, f.Image, r.ImagePrefix)
+		case f.Exec != "":
+			s, err := shlex.Split(f.Exec)
+			if err != nil {
+				return nil, fmt.Errorf("exec command %q must be valid: %w", f.Exec, err)
+			}
+			spec.Exec.Path = f.Exec
+			if len(s) > 0 {
+				spec.Exec.Path = s[0]
+				execArgs = s[1:]
+			}
+		default:
+			return nil, fmt.Errorf("must specify `exec` or `image` to execute a function")
+		}
+		spec.Container.Env = r.mergeContainerEnv(spec.Container.Env)
+		// the config path is absolute or relative to the working directory
+		fnConfig, err := fnruntime.NewFnConfig(filesys.FileSystemOrOnDisk{}, &f, "")
+		if err != nil {
+			return nil, err
+		}
+		selected := len(f.Selectors) > 0 || len(f.Exclusions) > 0
+		fltr, err := r.newFnFilter(spec, fnConfig, execArgs, f.Exec, selected, user.Current)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, fnStep{
+			// only one of them is set
+			name:       spec.Container.Image + f.Exec,
+			filters:    []kio.Filter{fltr},
+			selectors:  f.Selectors,
+			exclusions: f.Exclusions,
+		})
+	}
+	return steps, nil
 }
 
 func (r RunFns) getFilters() ([]kio.Filter, error) {
@@ -166,8 +368,8 @@ func (r RunFns) getFilters() ([]kio.Filter, error) {
 	return []kio.Filter{c}, nil
 }
 
-// runFunctions runs the fltrs against the input and writes to either r.Output or output
-func (r RunFns) runFunctions(input kio.Reader, output kio.Writer, fltrs []kio.Filter) error {
+// runFunctions runs the steps against the input and writes to either r.Output or output
+func (r RunFns) runFunctions(input kio.Reader, output kio.Writer, steps []fnStep) error {
 	// use the previously read Resources as input
 	var outputs []kio.Writer
 	if r.Output == nil {
@@ -184,74 +386,259 @@ func (r RunFns) runFunctions(input kio.Reader, output kio.Writer, fltrs []kio.Fi
 		})
 	}
 
-	inputResources, err := input.Read()
+	outputResources, err := input.Read()
 	if err != nil {
 		return err
 	}
+	for _, step := range steps {
+		outputResources, err = r.runStep(step, outputResources)
+		if err != nil || len(outputResources) == 0 && !r.ContinueOnEmptyResult {
+			break
+		}
+	}
 
-	selectedInput := inputResources
+	if err == nil {
+		if r.ResourceWriter != nil {
+			// the resources are copied as writing them to the output
+			// may modify their annotations
+			var resources []*yaml.RNode
+			for _, node := range outputResources {
+				resources = append(resources, node.Copy())
+			}
+			if writeErr := r.ResourceWriter.Write(resources); writeErr != nil {
+				return writeErr
+			}
+		}
+		writeErr := outputs[0].Write(outputResources)
+		if writeErr != nil {
+			return writeErr
+		}
+	}
+	resultsFile, resultErr := fnruntime.SaveResults(filesys.FileSystemOrOnDisk{}, r.ResultsDir, r.fnResults)
+	if err != nil {
+		// function fails
+		if resultErr == nil {
+			r.printFnResultsStatus(resultsFile)
+		}
+		return err
+	}
+	if resultErr == nil {
+		r.printFnResultsStatus(resultsFile)
+	}
+	return nil
+}
 
-	if !r.Selector.IsEmpty() || !r.Exclusion.IsEmpty() {
-		err = fnruntime.SetResourceIds(inputResources)
+// runStep runs the filters of step on the resources it selects from input and
+// returns input with the selected resources replaced by the output.
+func (r RunFns) runStep(step fnStep, input []*yaml.RNode) ([]*yaml.RNode, error) {
+	selectedInput := input
+	var decisions *fnruntime.SelectionDecisions
+	if step.hasSelection() {
+		err := fnruntime.SetResourceIds(input)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if r.StderrMode == fnruntime.VerboseStderr {
+			decisions = fnruntime.NewSelectionDecisions()
 		}
 
 		// select the resources on which function should be applied
 		selectedInput, err = fnruntime.SelectInput(
-			inputResources,
-			[]kptfile.Selector{r.Selector},
-			[]kptfile.Selector{r.Exclusion},
-			&fnruntime.SelectionContext{RootPackagePath: r.uniquePath})
+			input,
+			step.selectors,
+			step.exclusions,
+			&fnruntime.SelectionContext{RootPackagePath: r.uniquePath, Decisions: decisions})
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if step.filePathSelector != "" {
+			selected, err := fnruntime.SelectByFilePath(selectedInput, step.filePathSelector)
+			if err != nil {
+				return nil, err
+			}
+			recordUnselected(decisions, selectedInput, selected,
+				fmt.Sprintf("not selected, not in a file matching %q", step.filePathSelector))
+			selectedInput = selected
+		}
+		if step.filePaths != nil {
+			selected, err := fnruntime.SelectByFilePaths(selectedInput, step.filePaths)
+			if err != nil {
+				return nil, err
+			}
+			recordUnselected(decisions, selectedInput, selected, "not selected, not in a changed file")
+			selectedInput = selected
 		}
 	}
+	selectedStrings := resourceStrings(decisions, selectedInput)
 
+	if r.FailOnEmpty && len(selectedInput) == 0 {
+		return nil, &EmptyResultError{Function: step.name}
+	}
 	pb := &kio.PackageBuffer{}
 	pipeline := kio.Pipeline{
 		Inputs:                []kio.Reader{&kio.PackageBuffer{Nodes: selectedInput}},
-		Filters:               fltrs,
+		Filters:               step.filters,
 		Outputs:               []kio.Writer{pb},
 		ContinueOnEmptyResult: r.ContinueOnEmptyResult,
 	}
-	err = pipeline.Execute()
-	outputResources := pb.Nodes
+	if err := pipeline.Execute(); err != nil {
+		r.printSelection(decisions, nil, nil)
+		return nil, err
+	}
+	r.printSelection(decisions, selectedStrings, pb.Nodes)
+	if r.FailOnEmpty && len(pb.Nodes) == 0 {
+		return nil, &EmptyResultError{Function: step.name, Selected: len(selectedInput)}
+	}
+	if !step.hasSelection() {
+		return pb.Nodes, nil
+	}
+	output := fnruntime.MergeWithInput(pb.Nodes, selectedInput, input)
+	return output, fnruntime.DeleteResourceIds(output)
+}
 
-	if !r.Selector.IsEmpty() || !r.Exclusion.IsEmpty() {
-		outputResources = fnruntime.MergeWithInput(pb.Nodes, selectedInput, inputResources)
-		deleteAnnoErr := fnruntime.DeleteResourceIds(outputResources)
-		if deleteAnnoErr != nil {
-			return deleteAnnoErr
+// recordUnselected records the resources of before which aren't in after as
+// not selected for reason.
+func recordUnselected(decisions *fnruntime.SelectionDecisions, before, after []*yaml.RNode, reason string) {
+	if decisions == nil {
+		return
+	}
+	kept := map[*yaml.RNode]bool{}
+	for _, node := range after {
+		kept[node] = true
+	}
+	for _, node := range before {
+		if !kept[node] {
+			decisions.Record(node, false, reason)
 		}
 	}
+}
 
-	if err == nil {
-		writeErr := outputs[0].Write(outputResources)
-		if writeErr != nil {
-			return writeErr
-		}
+// resourceStrings returns the selected resources as strings by resource id,
+// so printSelection can tell whether the function modified them. It returns
+// nil if the decisions aren't recorded.
+func resourceStrings(decisions *fnruntime.SelectionDecisions, selected []*yaml.RNode) map[string]string {
+	if decisions == nil {
+		return nil
 	}
-	resultsFile, resultErr := fnruntime.SaveResults(filesys.FileSystemOrOnDisk{}, r.ResultsDir, r.fnResults)
-	if err != nil {
-		// function fails
-		if resultErr == nil {
-			r.printFnResultsStatus(resultsFile)
+	strs := map[string]string{}
+	for _, node := range selected {
+		strs[node.GetAnnotations()[fnruntime.ResourceIDAnnotation]] = node.MustString()
+	}
+	return strs
+}
+
+// printSelection prints a line for each resource considered by a step,
+// telling whether it was selected and by which criteria, and whether the
+// function modified or deleted the selected resources. The changes aren't
+// reported if the function failed, i.e. selected is nil.
+func (r RunFns) printSelection(decisions *fnruntime.SelectionDecisions, selected map[string]string, output []*yaml.RNode) {
+	if decisions == nil {
+		return
+	}
+	outputStrings := map[string]string{}
+	for _, node := range output {
+		outputStrings[node.GetAnnotations()[fnruntime.ResourceIDAnnotation]] = node.MustString()
+	}
+	pr := printer.FromContextOrDie(r.Ctx)
+	for _, d := range decisions.List() {
+		line := fmt.Sprintf("  %s: %s", resourceDescription(d.Node), d.Reason)
+		if d.Selected && selected != nil {
+			id := d.Node.GetAnnotations()[fnruntime.ResourceIDAnnotation]
+			out, found := outputStrings[id]
+			switch {
+			case !found:
+				line += ", deleted"
+			case out != selected[id]:
+				line += ", modified"
+			default:
+				line += ", unchanged"
+			}
 		}
-		return err
+		pr.Printf("%s\n", line)
 	}
-	if resultErr == nil {
-		r.printFnResultsStatus(resultsFile)
+}
+
+// resourceDescription returns the kind, namespace, name and file of the
+// resource, e.g. "Deployment default/nginx (deploy.yaml)".
+func resourceDescription(node *yaml.RNode) string {
+	name := node.GetName()
+	if ns := node.GetNamespace(); ns != "" {
+		name = ns + "/" + name
 	}
-	return nil
+	desc := node.GetKind() + " " + name
+	if p, _, err := kioutil.GetFileAnnotations(node); err == nil && p != "" {
+		desc += " (" + p + ")"
+	}
+	return desc
+}
+
+// EmptyResultError is returned with FailOnEmpty if a function selects no
+// resources, or if its output doesn't contain any resources.
+type EmptyResultError struct {
+	// Function is the image or the exec command of the function.
+	Function string
+	// Selected is the number of resources the function was executed on. It
+	// is 0 if the function isn't executed as it doesn't select any resources.
+	Selected int
+}
+
+func (e *EmptyResultError) Error() string {
+	if e.Selected == 0 {
+		return fmt.Sprintf("function %q selected no resources", e.Function)
+	}
+	return fmt.Sprintf("function %q produced no resources from the %d resource(s) it selected", e.Function, e.Selected)
 }
 
 func (r RunFns) printFnResultsStatus(resultsFile string) {
 	printerutil.PrintFnResultInfo(r.Ctx, resultsFile, true)
 }
 
+// PackagePathEnv is the environment variable the path of the package is
+// exported in if RunFns.InjectPackagePath is set.
+const PackagePathEnv = "KPT_PACKAGE_PATH"
+
+// packagePathEnv returns the PackagePathEnv variable if it is exported to the
+// functions.
+func (r RunFns) packagePathEnv() []string {
+	if !r.InjectPackagePath || r.uniquePath == "" {
+		return nil
+	}
+	return []string{PackagePathEnv + "=" + r.uniquePath.String()}
+}
+
+// ContextDirEnv is the environment variable the path of RunFns.ContextDir in
+// the container functions is exported in.
+const ContextDirEnv = "KPT_CONTEXT_DIR"
+
+// ContextDirMountPath is the path RunFns.ContextDir is mounted at in the
+// container functions.
+const ContextDirMountPath = "/kpt-context"
+
+// contextDirEnv returns the ContextDirEnv variable if ContextDir is set.
+func (r RunFns) contextDirEnv() []string {
+	if r.ContextDir == "" {
+		return nil
+	}
+	return []string{ContextDirEnv + "=" + ContextDirMountPath}
+}
+
+// ContainerMounts returns the StorageMounts of the container functions,
+// including the mount of ContextDir.
+func (r RunFns) ContainerMounts() []fnruntime.StorageMount {
+	if r.ContextDir == "" {
+		return r.StorageMounts
+	}
+	sms := append([]fnruntime.StorageMount{}, r.StorageMounts...)
+	return append(sms, fnruntime.StorageMount{StorageMount: runtimeutil.StorageMount{
+		MountType: "bind",
+		Src:       r.ContextDir,
+		DstPath:   ContextDirMountPath,
+	}})
+}
+
 // mergeContainerEnv will merge the envs specified by command line (imperative) and config
 // file (declarative). If they have same key, the imperative value will be respected.
+// The injected package path and context dir can be overridden by both.
 func (r RunFns) mergeContainerEnv(envs []string) []string {
 	imperative := fnruntime.NewContainerEnvFromStringSlice(r.Env)
 	declarative := fnruntime.NewContainerEnvFromStringSlice(envs)
@@ -263,6 +650,13 @@ func (r RunFns) mergeContainerEnv(envs []string) []string {
 		declarative.AddKey(key)
 	}
 
+	injected := fnruntime.NewContainerEnvFromStringSlice(append(r.packagePathEnv(), r.contextDirEnv()...))
+	for key, value := range injected.EnvVars {
+		if _, found := declarative.EnvVars[key]; !found && !slice.ContainsString(declarative.VarsToExport, key, nil) {
+			declarative.AddKeyValue(key, value)
+		}
+	}
+
 	return declarative.Raw()
 }
 
@@ -286,7 +680,14 @@ func (r *RunFns) init() error {
 		r.uniquePath = types.UniquePath(absPath)
 	}
 
-	r.fnResults = fnresult.NewResultList()
+	r.fnResults = r.Results
+	if r.fnResults == nil {
+		r.fnResults = fnresult.NewResultList()
+	}
+
+	if r.Progress != nil {
+		r.Progress = countProgress(r.Progress, r.functionCount())
+	}
 
 	// functionFilterProvider set the filter provider
 	if r.functionFilterProvider == nil {
@@ -306,6 +707,26 @@ func (r *RunFns) init() error {
 	return nil
 }
 
+// functionCount returns the number of functions run on the resources.
+func (r *RunFns) functionCount() int {
+	if len(r.Pipeline) > 0 {
+		return len(r.Pipeline)
+	}
+	return 1
+}
+
+// countProgress returns a ProgressFunc calling progress with the position of
+// the completed function among total functions.
+func countProgress(progress fnruntime.ProgressFunc, total int) fnruntime.ProgressFunc {
+	completed := 0
+	return func(e fnruntime.ProgressEvent) {
+		completed++
+		e.Index = completed
+		e.Total = total
+		progress(e)
+	}
+}
+
 type currentUserFunc func() (*user.User, error)
 
 // getUIDGID will return "nobody" if asCurrentUser is false. Otherwise
@@ -341,6 +762,15 @@ func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig
 			return nil, err
 		}
 	}
+	return r.newFnFilter(spec, fnConfig, r.ExecArgs, r.OriginalExec, r.hasSelection(), currentUser)
+}
+
+// newFnFilter returns the filter running the function of spec with fnConfig.
+// execArgs are the arguments of an exec function, originalExec is the exec
+// command shown in the results. If displayResourceCount is true, the number of
+// resources the function is run on is printed.
+func (r *RunFns) newFnFilter(spec runtimeutil.FunctionSpec, fnConfig *yaml.RNode, execArgs []string,
+	originalExec string, displayResourceCount bool, currentUser currentUserFunc) (kio.Filter, error) {
 	var fltr *runtimeutil.FunctionFilter
 	fnResult := &fnresult.Result{
 		// TODO(droot): This is required for making structured results subpackage aware.
@@ -354,13 +784,19 @@ func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig
 			return nil, err
 		}
 		c := &fnruntime.ContainerFn{
-			Path:            r.uniquePath,
-			Image:           spec.Container.Image,
-			ImagePullPolicy: r.ImagePullPolicy,
-			UIDGID:          uidgid,
-			StorageMounts:   r.StorageMounts,
-			Env:             spec.Container.Env,
-			FnResult:        fnResult,
+			Ctx:               r.Ctx,
+			Path:              r.uniquePath,
+			Image:             spec.Container.Image,
+			ImagePullPolicy:   r.ImagePullPolicy,
+			UIDGID:            uidgid,
+			StorageMounts:     r.ContainerMounts(),
+			Env:               spec.Container.Env,
+			FnResult:          fnResult,
+			PullRetries:       r.PullRetries,
+			LogPullRetries:    r.StderrMode == fnruntime.VerboseStderr,
+			RegistryConfigDir: r.RegistryConfigDir,
+			CacheDir:          r.CacheDir,
+			RefreshCache:      r.RefreshCache,
 			Perm: fnruntime.ContainerFnPermission{
 				AllowNetwork: r.Network,
 				// mounts are always from CLI flags so we allow
@@ -368,6 +804,9 @@ func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig
 				AllowMount: true,
 			},
 		}
+		if r.ContextDir != "" {
+			c.WorkDir = ContextDirMountPath
+		}
 		fltr = &runtimeutil.FunctionFilter{
 			Run:            c.Run,
 			FunctionConfig: fnConfig,
@@ -378,8 +817,10 @@ func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig
 
 	if spec.Exec.Path != "" {
 		e := &fnruntime.ExecFn{
+			Ctx:      r.Ctx,
 			Path:     spec.Exec.Path,
-			Args:     r.ExecArgs,
+			Args:     execArgs,
+			Env:      append(r.packagePathEnv(), r.ExecEnv...),
 			FnResult: fnResult,
 		}
 		fltr = &runtimeutil.FunctionFilter{
@@ -387,12 +828,7 @@ func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig
 			FunctionConfig: fnConfig,
 			DeferFailure:   spec.DeferFailure,
 		}
-		fnResult.ExecPath = r.OriginalExec
-	}
-
-	displayResourceCount := false
-	if !r.Selector.IsEmpty() || !r.Exclusion.IsEmpty() {
-		displayResourceCount = true
+		fnResult.ExecPath = originalExec
 	}
-	return fnruntime.NewFunctionRunner(r.Ctx, fltr, "", fnResult, r.fnResults, false, displayResourceCount)
+	return fnruntime.NewFunctionRunner(r.Ctx, fltr, "", fnResult, r.fnResults, false, displayResourceCount, r.MaxResults, r.StderrMode, r.ResultsFilter, r.Progress)
 }
@@ -10,7 +10,9 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
 	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
 	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	v1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
@@ -101,11 +103,11 @@ func TestRunFns_Execute__initDefault(t *testing.T) {
 		},
 		{
 			name:     "explicit directories in mounts",
-			instance: RunFns{StorageMounts: []runtimeutil.StorageMount{{MountType: "volume", Src: "myvol", DstPath: "/local/"}}},
+			instance: RunFns{StorageMounts: []fnruntime.StorageMount{{StorageMount: runtimeutil.StorageMount{MountType: "volume", Src: "myvol", DstPath: "/local/"}}}},
 			expected: RunFns{
 				Output:        os.Stdout,
 				Input:         os.Stdin,
-				StorageMounts: []runtimeutil.StorageMount{{MountType: "volume", Src: "myvol", DstPath: "/local/"}},
+				StorageMounts: []fnruntime.StorageMount{{StorageMount: runtimeutil.StorageMount{MountType: "volume", Src: "myvol", DstPath: "/local/"}}},
 			},
 		},
 	}
@@ -154,6 +156,99 @@ func TestCmd_Execute(t *testing.T) {
 	assert.Contains(t, string(b), "kind: StatefulSet")
 }
 
+// TestCmd_Execute_preserveUnchanged verifies that files which aren't changed
+// by the function aren't written, and files of deleted resources are removed.
+func TestCmd_Execute_preserveUnchanged(t *testing.T) {
+	testCases := map[string]struct {
+		preserveUnchanged bool
+		expectedUnchanged []string
+	}{
+		"preserve unchanged files": {
+			preserveUnchanged: true,
+			expectedUnchanged: []string{"java-configmap.resource.yaml", "java-service.resource.yaml"},
+		},
+		"rewrite all files": {
+			preserveUnchanged: false,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			dir := setupTest(t)
+			defer os.RemoveAll(dir)
+
+			files := []string{"java-configmap.resource.yaml", "java-deployment.resource.yaml", "java-service.resource.yaml"}
+			past := time.Now().Add(-time.Hour).Truncate(time.Second)
+			for _, f := range files {
+				if !assert.NoError(t, os.Chtimes(filepath.Join(dir, "java", f), past, past)) {
+					t.FailNow()
+				}
+			}
+
+			fnConfig, err := yaml.Parse(ValueReplacerYAMLData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			instance := RunFns{
+				Ctx:                    fake.CtxWithDefaultPrinter(),
+				Path:                   dir,
+				functionFilterProvider: getFilterProvider(t),
+				Function: &runtimeutil.FunctionSpec{
+					Container: runtimeutil.ContainerSpec{
+						Image: "gcr.io/example.com/image:version",
+					},
+				},
+				FnConfig:          fnConfig,
+				fnResults:         fnresult.NewResultList(),
+				PreserveUnchanged: tc.preserveUnchanged,
+			}
+			if !assert.NoError(t, instance.Execute()) {
+				t.FailNow()
+			}
+
+			var unchanged []string
+			for _, f := range files {
+				info, err := os.Stat(filepath.Join(dir, "java", f))
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				if info.ModTime().Equal(past) {
+					unchanged = append(unchanged, f)
+				}
+			}
+			assert.Equal(t, tc.expectedUnchanged, unchanged)
+		})
+	}
+}
+
+func TestCmd_Execute_preserveUnchangedDeletion(t *testing.T) {
+	dir := setupTest(t)
+	defer os.RemoveAll(dir)
+
+	instance := RunFns{
+		Ctx:  fake.CtxWithDefaultPrinter(),
+		Path: dir,
+		functionFilterProvider: func(runtimeutil.FunctionSpec, *yaml.RNode, currentUserFunc) (kio.Filter, error) {
+			return kio.FilterFunc(func([]*yaml.RNode) ([]*yaml.RNode, error) {
+				return nil, nil
+			}), nil
+		},
+		Function: &runtimeutil.FunctionSpec{
+			Container: runtimeutil.ContainerSpec{
+				Image: "gcr.io/example.com/image:version",
+			},
+		},
+		fnResults:             fnresult.NewResultList(),
+		ContinueOnEmptyResult: true,
+		PreserveUnchanged:     true,
+	}
+	if !assert.NoError(t, instance.Execute()) {
+		t.FailNow()
+	}
+	_, err := os.Stat(filepath.Join(dir, "java", "java-deployment.resource.yaml"))
+	assert.True(t, os.IsNotExist(err))
+}
+
 func TestCmd_Execute_includeMetaResources(t *testing.T) {
 	dir := setupTest(t)
 	defer os.RemoveAll(dir)
@@ -512,6 +607,41 @@ func TestRunFns_mergeContainerEnv(t *testing.T) {
 			inputEnvs: []string{"foo1=bar1", "foo"},
 			expect:    *runtimeutil.NewContainerEnvFromStringSlice([]string{"foo=bar", "foo1=bar1", "foo"}),
 		},
+		{
+			name: "injected package path",
+			instance: RunFns{
+				InjectPackagePath: true,
+				uniquePath:        "/pkg",
+				Env:               []string{"foo=bar"},
+			},
+			expect: *runtimeutil.NewContainerEnvFromStringSlice([]string{"foo=bar", "KPT_PACKAGE_PATH=/pkg"}),
+		},
+		{
+			name: "injected package path without package",
+			instance: RunFns{
+				InjectPackagePath: true,
+			},
+			expect: *runtimeutil.NewContainerEnv(),
+		},
+		{
+			name: "injected package path is overridden",
+			instance: RunFns{
+				InjectPackagePath: true,
+				uniquePath:        "/pkg",
+				Env:               []string{"KPT_PACKAGE_PATH=/other"},
+			},
+			inputEnvs: []string{"KPT_PACKAGE_PATH=/declared"},
+			expect:    *runtimeutil.NewContainerEnvFromStringSlice([]string{"KPT_PACKAGE_PATH=/other"}),
+		},
+		{
+			name: "injected package path is overridden by declarative envs",
+			instance: RunFns{
+				InjectPackagePath: true,
+				uniquePath:        "/pkg",
+			},
+			inputEnvs: []string{"KPT_PACKAGE_PATH"},
+			expect:    *runtimeutil.NewContainerEnvFromStringSlice([]string{"KPT_PACKAGE_PATH"}),
+		},
 	}
 
 	for i := range testcases {